@@ -5,6 +5,7 @@ import (
 	"github.com/lf-edge/eve/pkg/pillar/base"
 	"github.com/lf-edge/eve/pkg/pillar/pidfile"
 	"github.com/lf-edge/eve/pkg/pillar/pubsub"
+	"github.com/lf-edge/eve/pkg/pillar/types"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -34,6 +35,47 @@ type Context struct {
 	WarningTime  time.Duration
 	AgentName    string
 	NeedWatchdog bool
+	// Version is reported in this agent's HeartBeat; set it (e.g. from the
+	// agent's own "Version" build-time variable) before the first call to
+	// PublishHeartBeat.
+	Version string
+
+	bootTime     time.Time
+	lastBeat     time.Time
+	pubHeartBeat pubsub.Publication
+}
+
+// PublishHeartBeat publishes (creating the underlying publication on its
+// first call) a types.HeartBeat for this agent, so something observing
+// pubsub state remotely can tell a wedged-but-not-crashed agent apart from
+// one that is simply quiet. Callers that run their own event loop rather
+// than going through Run should call this alongside PubSub.StillRunning.
+func (ctx *Context) PublishHeartBeat() {
+	now := time.Now()
+	if ctx.pubHeartBeat == nil {
+		pub, err := ctx.PubSub.NewPublication(pubsub.PublicationOptions{
+			AgentName: ctx.AgentName,
+			TopicType: types.HeartBeat{},
+		})
+		if err != nil {
+			ctx.Log.Errorf("PublishHeartBeat: NewPublication failed: %s", err)
+			return
+		}
+		ctx.pubHeartBeat = pub
+		ctx.bootTime = now
+		ctx.lastBeat = now
+	}
+	hb := types.HeartBeat{
+		AgentName:    ctx.AgentName,
+		Version:      ctx.Version,
+		BootTime:     ctx.bootTime,
+		LastBeat:     now,
+		LoopDuration: now.Sub(ctx.lastBeat),
+	}
+	ctx.lastBeat = now
+	if err := ctx.pubHeartBeat.Publish(ctx.AgentName, hb); err != nil {
+		ctx.Log.Errorf("PublishHeartBeat: Publish failed: %s", err)
+	}
 }
 
 // processCLIFlags - Add flags common to all agents
@@ -64,5 +106,6 @@ func Run(agentSpecificContext AgentBase) {
 	log.Infof("Starting %s\n", ctx.AgentName)
 	if ctx.NeedWatchdog {
 		ctx.PubSub.StillRunning(ctx.AgentName, ctx.WarningTime, ctx.ErrorTime)
+		ctx.PublishHeartBeat()
 	}
 }
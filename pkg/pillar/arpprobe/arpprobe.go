@@ -0,0 +1,144 @@
+// Copyright (c) 2026 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package arpprobe implements a best-effort ARP probe (see RFC 5227
+// section 2.1.1) to check whether another host on the same L2 segment
+// already claims an IPv4 address before EVE configures that address for
+// itself on an uplink or a network instance's bridge -- so a site that
+// happens to reuse one of EVE's default subnets produces an explicit,
+// loggable conflict instead of two hosts silently fighting over the same
+// address.
+//
+// NOTE on scope: this sends a single ARP request and waits a short
+// timeout for replies. It does not implement the full RFC 5227
+// probe/announce/defend state machine -- repeated probes spaced out
+// before claiming an address, ANNOUNCE frames afterwards, or ongoing
+// defense against a conflict that appears later. This is a point-in-time
+// check at the moment an address is about to be assigned.
+package arpprobe
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Conflict describes another host on the wire that answered for an IPv4
+// address EVE was about to claim.
+type Conflict struct {
+	IP  net.IP
+	MAC net.HardwareAddr
+}
+
+const (
+	etherTypeARP      = 0x0806
+	etherTypeIPv4     = 0x0800
+	arpHwTypeEthernet = 1
+	arpOpRequest      = 1
+	arpOpReply        = 2
+	arpFrameLen       = 14 + 28 // Ethernet header + ARP packet
+)
+
+// Probe sends a single ARP request ("who has ip") out ifaceName with a
+// zero sender IP (an ARP Probe, so EVE isn't claiming to already own the
+// address) and waits up to timeout for a reply. It returns the first
+// replying host as a Conflict, or nil if nobody answers within timeout.
+func Probe(ifaceName string, ip net.IP, timeout time.Duration) (*Conflict, error) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return nil, fmt.Errorf("arpprobe: %s is not an IPv4 address", ip)
+	}
+	iface, err := net.InterfaceByName(ifaceName)
+	if err != nil {
+		return nil, fmt.Errorf("arpprobe: %v", err)
+	}
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(htons(etherTypeARP)))
+	if err != nil {
+		return nil, fmt.Errorf("arpprobe: socket: %v", err)
+	}
+	defer unix.Close(fd)
+
+	addr := unix.SockaddrLinklayer{
+		Protocol: htons(etherTypeARP),
+		Ifindex:  iface.Index,
+	}
+	if err := unix.Bind(fd, &addr); err != nil {
+		return nil, fmt.Errorf("arpprobe: bind to %s: %v", ifaceName, err)
+	}
+	if err := unix.Sendto(fd, buildARPProbe(iface.HardwareAddr, ip4), 0, &addr); err != nil {
+		return nil, fmt.Errorf("arpprobe: sendto %s: %v", ifaceName, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, 128)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, nil
+		}
+		tv := unix.NsecToTimeval(remaining.Nanoseconds())
+		if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+			return nil, fmt.Errorf("arpprobe: setsockopt: %v", err)
+		}
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			// Timeout (or any other receive error) means nobody answered in time.
+			return nil, nil
+		}
+		if conflict := parseARPReply(buf[:n], ip4); conflict != nil {
+			return conflict, nil
+		}
+	}
+}
+
+func htons(v uint16) uint16 {
+	return v<<8&0xff00 | v>>8
+}
+
+// buildARPProbe builds an Ethernet+ARP "who has targetIP" frame, as an
+// ARP Probe per RFC 5227: the sender protocol address is all-zeros so the
+// probe itself can never be mistaken for a claim on targetIP.
+func buildARPProbe(srcMAC net.HardwareAddr, targetIP net.IP) []byte {
+	frame := make([]byte, arpFrameLen)
+	broadcast := net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	copy(frame[0:6], broadcast)
+	copy(frame[6:12], srcMAC)
+	binary.BigEndian.PutUint16(frame[12:14], etherTypeARP)
+
+	arp := frame[14:]
+	binary.BigEndian.PutUint16(arp[0:2], arpHwTypeEthernet)
+	binary.BigEndian.PutUint16(arp[2:4], etherTypeIPv4)
+	arp[4] = 6 // hardware address length
+	arp[5] = 4 // protocol address length
+	binary.BigEndian.PutUint16(arp[6:8], arpOpRequest)
+	copy(arp[8:14], srcMAC)
+	// arp[14:18] (sender IP) is left as 0.0.0.0, per RFC 5227.
+	copy(arp[18:24], broadcast) // target hardware address, unknown in a request
+	copy(arp[24:28], targetIP)
+	return frame
+}
+
+// parseARPReply inspects a raw Ethernet frame read off the wire and, if
+// it is an ARP reply claiming targetIP, returns the replying host.
+func parseARPReply(frame []byte, targetIP net.IP) *Conflict {
+	if len(frame) < arpFrameLen {
+		return nil
+	}
+	if binary.BigEndian.Uint16(frame[12:14]) != etherTypeARP {
+		return nil
+	}
+	arp := frame[14:]
+	if binary.BigEndian.Uint16(arp[6:8]) != arpOpReply {
+		return nil
+	}
+	senderIP := net.IP(append([]byte(nil), arp[14:18]...))
+	if !senderIP.Equal(targetIP) {
+		return nil
+	}
+	senderMAC := net.HardwareAddr(append([]byte(nil), arp[8:14]...))
+	return &Conflict{IP: senderIP, MAC: senderMAC}
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2026 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package arpprobe
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildAndParseARPReply(t *testing.T) {
+	srcMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	targetIP := net.IPv4(192, 168, 1, 1).To4()
+
+	probe := buildARPProbe(srcMAC, targetIP)
+	assert.Len(t, probe, arpFrameLen)
+
+	// The probe itself must not look like a reply for targetIP: its
+	// sender IP is all-zeros, per RFC 5227.
+	assert.Nil(t, parseARPReply(probe, targetIP))
+
+	replyMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02}
+	reply := make([]byte, arpFrameLen)
+	copy(reply, probe)
+	arp := reply[14:]
+	binary.BigEndian.PutUint16(arp[6:8], arpOpReply)
+	copy(arp[8:14], replyMAC)
+	copy(arp[14:18], targetIP)
+
+	conflict := parseARPReply(reply, targetIP)
+	if assert.NotNil(t, conflict) {
+		assert.Equal(t, targetIP.String(), conflict.IP.String())
+		assert.Equal(t, replyMAC.String(), conflict.MAC.String())
+	}
+
+	assert.Nil(t, parseARPReply(reply, net.IPv4(10, 0, 0, 1).To4()))
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package assignments provides a typed, per-adapter API around the PCI
+// bind/unbind/reset operations a Hypervisor backend implements
+// (hypervisor.Hypervisor.PCIReserve/PCIRelease and, where supported,
+// hypervisor.PCIResetter), plus dry-run validation. domainmgr's
+// checkAndSetIoMember previously called hyper.PCIReserve/PCIRelease
+// directly and let a failure surface as a single generic error for
+// whatever IoBundle triggered it; Bind/Unbind/Reset here instead return a
+// types.IoBundleAssignmentStatus tied to the one PCI long address the
+// operation touched, so the caller can record it against that specific
+// adapter's IoBundle.AssignmentStatus.
+package assignments
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/hypervisor"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+func result(op string, state types.IoBundleAssignmentState, err error) types.IoBundleAssignmentStatus {
+	s := types.IoBundleAssignmentStatus{Operation: op, State: state, At: time.Now()}
+	if err != nil {
+		s.State = types.IoBundleAssignmentError
+		s.Error = err.Error()
+	}
+	return s
+}
+
+// Bind binds the PCI device at long to the hypervisor's passthrough
+// driver via hyper.PCIReserve.
+func Bind(hyper hypervisor.Hypervisor, long string) types.IoBundleAssignmentStatus {
+	if !types.PciLongExists(long) {
+		return result("bind", types.IoBundleAssignmentError, fmt.Errorf("PCI device %s not present", long))
+	}
+	err := hyper.PCIReserve(long)
+	return result("bind", types.IoBundleAssignmentBound, err)
+}
+
+// Unbind releases the PCI device at long from the hypervisor's
+// passthrough driver via hyper.PCIRelease.
+func Unbind(hyper hypervisor.Hypervisor, long string) types.IoBundleAssignmentStatus {
+	err := hyper.PCIRelease(long)
+	return result("unbind", types.IoBundleAssignmentFree, err)
+}
+
+// Reset applies a function-level reset to the PCI device at long, if the
+// hypervisor backend supports it (see hypervisor.PCIResetter). It leaves
+// the device bound to whatever driver currently holds it.
+func Reset(hyper hypervisor.Hypervisor, long string) types.IoBundleAssignmentStatus {
+	resetter, ok := hypervisor.AsPCIResetter(hyper)
+	if !ok {
+		return result("reset", types.IoBundleAssignmentError,
+			fmt.Errorf("hypervisor %s does not support PCI reset", hyper.Name()))
+	}
+	err := resetter.PCIReset(long)
+	return result("reset", types.IoBundleAssignmentBound, err)
+}
+
+// DryRun validates that Bind(hyper, long) could be attempted without
+// making any changes to the device: it only checks that the device is
+// currently present in sysfs. It does not call into the hypervisor.
+func DryRun(long string) types.IoBundleAssignmentStatus {
+	if !types.PciLongExists(long) {
+		return result("dry-run", types.IoBundleAssignmentError, fmt.Errorf("PCI device %s not present", long))
+	}
+	return result("dry-run", types.IoBundleAssignmentFree, nil)
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package assignments
+
+import (
+	"testing"
+
+	"github.com/lf-edge/eve/pkg/pillar/hypervisor"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+const noSuchPciLong = "ff:ff.f"
+
+func TestDryRunMissingDevice(t *testing.T) {
+	status := DryRun(noSuchPciLong)
+	if status.State != types.IoBundleAssignmentError || status.Error == "" {
+		t.Errorf("DryRun(%s) = %+v, want a types.IoBundleAssignmentError with a non-empty Error", noSuchPciLong, status)
+	}
+}
+
+func TestBindMissingDevice(t *testing.T) {
+	hyper, err := hypervisor.GetHypervisor("null")
+	if err != nil {
+		t.Fatalf("GetHypervisor(null): %v", err)
+	}
+	status := Bind(hyper, noSuchPciLong)
+	if status.State != types.IoBundleAssignmentError || status.Operation != "bind" {
+		t.Errorf("Bind(%s) = %+v, want a types.IoBundleAssignmentError", noSuchPciLong, status)
+	}
+}
+
+func TestUnbindNotReserved(t *testing.T) {
+	hyper, err := hypervisor.GetHypervisor("null")
+	if err != nil {
+		t.Fatalf("GetHypervisor(null): %v", err)
+	}
+	status := Unbind(hyper, noSuchPciLong)
+	if status.State != types.IoBundleAssignmentError || status.Operation != "unbind" {
+		t.Errorf("Unbind(%s) = %+v, want a types.IoBundleAssignmentError since it was never reserved", noSuchPciLong, status)
+	}
+}
+
+func TestResetUnsupportedHypervisor(t *testing.T) {
+	hyper, err := hypervisor.GetHypervisor("null")
+	if err != nil {
+		t.Fatalf("GetHypervisor(null): %v", err)
+	}
+	status := Reset(hyper, noSuchPciLong)
+	if status.State != types.IoBundleAssignmentError || status.Operation != "reset" {
+		t.Errorf("Reset(%s) = %+v, want a types.IoBundleAssignmentError since the null hypervisor has no PCIResetter", noSuchPciLong, status)
+	}
+}
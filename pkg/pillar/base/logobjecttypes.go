@@ -36,6 +36,8 @@ const (
 	AppInstanceStatusLogType LogObjectType = "app_instance_status"
 	// AppInstanceConfigLogType :
 	AppInstanceConfigLogType LogObjectType = "app_instance_config"
+	// AppInstanceHistoryLogType :
+	AppInstanceHistoryLogType LogObjectType = "app_instance_history"
 	// AppNetworkStatusLogType :
 	AppNetworkStatusLogType LogObjectType = "app_network_status"
 	// AppNetworkConfigLogType :
@@ -80,6 +82,8 @@ const (
 	DevicePortConfigLogType LogObjectType = "deviceport_config"
 	// DevicePortConfigList :
 	DevicePortConfigListLogType LogObjectType = "deviceportconfig_list"
+	// DevicePortConfigHistory :
+	DevicePortConfigHistoryLogType LogObjectType = "deviceportconfig_history"
 	// DeviceNetworkStatus :
 	DeviceNetworkStatusLogType LogObjectType = "devicenetwork_status"
 	// BlobStatusType:
@@ -144,6 +148,8 @@ const (
 	AppDiskMetricType LogObjectType = "app_disk_metric"
 	// ProcessMetricLogType:
 	ProcessMetricLogType LogObjectType = "process_metric"
+	// KernelModulePolicyLogType:
+	KernelModulePolicyLogType LogObjectType = "kernel_module_policy"
 )
 
 // RelationObjectType :
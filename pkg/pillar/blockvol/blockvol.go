@@ -0,0 +1,110 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package blockvol tracks host block devices passed through whole to app
+// instances as raw volumes (see types.VolumeConfig.PhysicalDevicePath), so
+// two app instances can't be handed the same device at once and the device
+// backing /persist can't be handed to an app by mistake. It does not open,
+// partition, or otherwise own the device node - volumemgr still hands the
+// path to the hypervisor (virtio-blk or direct) to attach, and any
+// host-side disk health monitoring (e.g. SMART) keeps polling the device
+// node directly, unaffected by the claim tracked here.
+package blockvol
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+var (
+	claimsMu sync.Mutex
+	claims   = map[string]string{} // resolved device path -> owner (VolumeStatus.Key())
+)
+
+// Claim records devicePath as exclusively assigned to owner, returning an
+// error if it is already claimed by a different owner. Claiming the same
+// devicePath again under the same owner - e.g. volumemgr re-processing its
+// VolumeStatus across a restart - is a no-op.
+func Claim(devicePath, owner string) error {
+	resolved, err := resolveDevice(devicePath)
+	if err != nil {
+		return err
+	}
+	claimsMu.Lock()
+	defer claimsMu.Unlock()
+	if existing, ok := claims[resolved]; ok && existing != owner {
+		return fmt.Errorf("blockvol: %s is already claimed by %s", devicePath, existing)
+	}
+	claims[resolved] = owner
+	return nil
+}
+
+// Release frees devicePath if it is currently claimed by owner; it is a
+// no-op otherwise.
+func Release(devicePath, owner string) {
+	resolved, err := resolveDevice(devicePath)
+	if err != nil {
+		return
+	}
+	claimsMu.Lock()
+	defer claimsMu.Unlock()
+	if claims[resolved] == owner {
+		delete(claims, resolved)
+	}
+}
+
+// IsPersistDevice reports whether devicePath is, or is a partition of,
+// the block device currently backing types.PersistDir, by comparing
+// against the mount source reported in /proc/mounts. volumemgr calls this
+// before claiming a PhysicalDevicePath volume, to refuse handing EVE's
+// own persistent storage to an app by mistake.
+func IsPersistDevice(devicePath string) (bool, error) {
+	resolved, err := resolveDevice(devicePath)
+	if err != nil {
+		return false, err
+	}
+	persistSource, err := persistMountSource()
+	if err != nil {
+		return false, err
+	}
+	if persistSource == resolved {
+		return true, nil
+	}
+	// A whole-disk passthrough (e.g. /dev/sda) also covers any of its
+	// partitions (e.g. /dev/sda9) that might back /persist.
+	return strings.HasPrefix(persistSource, resolved), nil
+}
+
+func resolveDevice(devicePath string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(devicePath)
+	if err != nil {
+		return "", fmt.Errorf("blockvol: could not resolve %s: %v", devicePath, err)
+	}
+	return resolved, nil
+}
+
+func persistMountSource() (string, error) {
+	f, err := os.Open("/proc/mounts")
+	if err != nil {
+		return "", fmt.Errorf("blockvol: could not open /proc/mounts: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if fields[1] == types.PersistDir {
+			return resolveDevice(fields[0])
+		}
+	}
+	return "", fmt.Errorf("blockvol: no mount found for %s", types.PersistDir)
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package blockvol
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClaimRelease(t *testing.T) {
+	dev := filepath.Join(t.TempDir(), "fake-device")
+	if err := os.WriteFile(dev, nil, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if err := Claim(dev, "volume-a"); err != nil {
+		t.Fatalf("Claim(volume-a): %v", err)
+	}
+	// Re-claiming under the same owner is a no-op.
+	if err := Claim(dev, "volume-a"); err != nil {
+		t.Fatalf("re-Claim(volume-a): %v", err)
+	}
+	if err := Claim(dev, "volume-b"); err == nil {
+		t.Fatalf("Claim(volume-b) succeeded while still held by volume-a")
+	}
+
+	Release(dev, "volume-b") // no-op, not the owner
+	if err := Claim(dev, "volume-b"); err == nil {
+		t.Fatalf("Claim(volume-b) succeeded after a no-op Release by a non-owner")
+	}
+
+	Release(dev, "volume-a")
+	if err := Claim(dev, "volume-b"); err != nil {
+		t.Fatalf("Claim(volume-b) after Release: %v", err)
+	}
+	Release(dev, "volume-b")
+}
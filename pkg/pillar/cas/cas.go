@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/lf-edge/edge-containers/pkg/resolver"
 	"github.com/lf-edge/eve/pkg/pillar/types"
@@ -82,31 +83,41 @@ type CAS interface {
 	ReplaceImage(reference, mediaType, blobHash string) error
 
 	//Snapshot APIs
-	//CreateSnapshotForImage: creates an snapshot with the given snapshotID for the given 'reference'
+	//CreateSnapshotForImage: creates an snapshot with the given snapshotID for the given 'reference',
+	//on the named snapshotter (e.g. "overlayfs", "zfs"), or this device's default snapshotter if
+	//snapshotter is "".
 	//Arg 'snapshotID' should be of format <algo>:<hash> (currently supporting only sha256:<hash>).
-	CreateSnapshotForImage(snapshotID, reference string) error
-	//MountSnapshot: mounts the snapshot on the given target path
+	CreateSnapshotForImage(snapshotID, reference, snapshotter string) error
+	//MountSnapshot: mounts the snapshot, found on the named snapshotter (or this device's default
+	//snapshotter if snapshotter is ""), on the given target path
 	//Arg 'snapshotID' should be of format <algo>:<hash> (currently supporting only sha256:<hash>).
-	MountSnapshot(snapshotID, targetPath string) error
+	MountSnapshot(snapshotID, targetPath, snapshotter string) error
+	//UnmountSnapshot: undoes a MountSnapshot, unmounting targetPath. It does not remove the
+	//snapshot itself; see RemoveSnapshot for that.
+	UnmountSnapshot(targetPath string) error
 	//ListSnapshots: returns a list of snapshotIDs where each entry is of format <algo>:<hash> (currently supporting only sha256:<hash>).
 	ListSnapshots() ([]string, error)
-	//ListSnapshots: removes a snapshot matching the given 'snapshotID'.
+	//RemoveSnapshot: removes a snapshot matching the given 'snapshotID', on the named snapshotter
+	//(or this device's default snapshotter if snapshotter is "").
 	//Arg 'snapshotID' should be of format <algo>:<hash> (currently supporting only sha256:<hash>).
 	//To keep this method idempotent, no error  is returned if the given 'snapshotID' is not found.
-	RemoveSnapshot(snapshotID string) error
+	RemoveSnapshot(snapshotID, snapshotter string) error
 
 	// PrepareContainerRootDir creates a reference pointing to the rootBlob and prepares a writable snapshot
-	// from the reference. Before preparing container's root directory, this API must remove any existing state
-	// that may have accumulated (like existing snapshots being available, etc.)
+	// from the reference, on the named snapshotter (e.g. "overlayfs", "zfs"), or this device's default
+	// snapshotter if snapshotter is "" - so overlayfs-backed and zfs-backed volumes can coexist on
+	// devices with mixed persist storage. Before preparing container's root directory, this API must
+	// remove any existing state that may have accumulated (like existing snapshots being available, etc.)
 	// This effectively voids any kind of caching, but on the flip side frees us
 	// from cache invalidation. Additionally this API should deposit an OCI config json file and image name
 	// next to the rootfs so that the effective structure becomes:
 	//    rootPath/rootfs, rootPath/image-config.json, rootPath/image-name
 	// The rootPath is expected to end in a basename that becomes the snapshotID
-	PrepareContainerRootDir(rootPath, reference, rootBlobSha string) error
+	PrepareContainerRootDir(rootPath, reference, rootBlobSha, snapshotter string) error
 
-	// RemoveContainerRootDir removes contents of a container's rootPath, existing snapshot and reference.
-	RemoveContainerRootDir(rootPath string) error
+	// RemoveContainerRootDir removes contents of a container's rootPath, existing snapshot and reference,
+	// on the named snapshotter (or this device's default snapshotter if snapshotter is "").
+	RemoveContainerRootDir(rootPath, snapshotter string) error
 
 	// IngestBlobsAndCreateImage is a combination of IngestBlobs and CreateImage APIs,
 	// but this API will add a lock, upload all the blobs, add reference to the blobs and release the lock.
@@ -124,8 +135,22 @@ type CAS interface {
 	// CtrNewUserServicesCtx() returns a context and a cancel function
 	CtrNewUserServicesCtx() (context.Context, context.CancelFunc)
 
+	// PruneStaleLeases deletes every lease, in both the user and system
+	// namespaces, that is older than threshold and isn't pinning content
+	// or an in-progress ingest reachable from a current image -- e.g. a
+	// lease an interrupted download left behind across a reboot.
+	// Returns the lease IDs removed (or would be removed, if dryRun is
+	// set) and the total bytes reclaimed from dropping their content and
+	// ingests.
+	PruneStaleLeases(threshold time.Duration, dryRun bool) ([]string, int64, error)
+
 	// CloseClient closes (only) the respective CAS client initialized while calling `NewCAS()`.
 	CloseClient() error
+
+	// GetMetrics returns this CAS client's running per-API-call counters
+	// and latency accumulation (see containerd.Client.GetMetrics), for
+	// publishing to diag and the controller.
+	GetMetrics() types.ContainerdMetrics
 }
 
 type casDesc struct {
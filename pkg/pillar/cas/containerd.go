@@ -17,6 +17,7 @@ import (
 	"github.com/containerd/containerd/content"
 	"github.com/containerd/containerd/images"
 	"github.com/lf-edge/edge-containers/pkg/resolver"
+	"github.com/lf-edge/eve/pkg/pillar/base"
 	"github.com/lf-edge/eve/pkg/pillar/containerd"
 	"github.com/lf-edge/eve/pkg/pillar/types"
 	"github.com/opencontainers/go-digest"
@@ -25,7 +26,7 @@ import (
 	v1types "github.com/google/go-containerregistry/pkg/v1/types"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	spec "github.com/opencontainers/image-spec/specs-go/v1"
-	log "github.com/sirupsen/logrus" // XXX add log argument
+	log "github.com/sirupsen/logrus" // used by the free functions that predate the CAS interface
 )
 
 const (
@@ -38,6 +39,8 @@ const (
 	imageNameFilename = "image-name"
 	// start of containerd gc ref label for children in content store
 	containerdGCRef = "containerd.io/gc.ref.content"
+	// minimum time between blob ingest progress log lines
+	blobProgressLogInterval = 10 * time.Second
 )
 
 type containerdCAS struct {
@@ -261,7 +264,16 @@ func (c *containerdCAS) IngestBlob(ctx context.Context, blobs ...types.BlobStatu
 		}
 
 		//Step 1.3: Ingest the blob into CAS
-		if err := c.ctrdClient.CtrWriteBlob(ctx, sha, blob.Size, r); err != nil {
+		lastLogged := time.Now()
+		progress := func(written, total int64) {
+			// throttle to avoid flooding the log on multi-GB blobs
+			if time.Since(lastLogged) < blobProgressLogInterval {
+				return
+			}
+			lastLogged = time.Now()
+			log.Infof("IngestBlob(%s): %d/%d bytes written", blob.Sha256, written, total)
+		}
+		if err := c.ctrdClient.CtrWriteBlobWithProgress(ctx, sha, blob.Size, r, progress); err != nil {
 			err = fmt.Errorf("IngestBlob(%s): could not load blob file into containerd at %s: %+s",
 				blob.Sha256, blobFile, err.Error())
 			log.Errorf(err.Error())
@@ -361,8 +373,10 @@ func (c *containerdCAS) UpdateBlobInfo(blobInfo BlobInfo) error {
 //ReadBlob: returns a reader to consume the raw data of the blob which matches the given arg 'blobHash'.
 //Returns error if no blob is found for the given 'blobHash'.
 //Arg 'blobHash' should be of format sha256:<hash>.
+//The returned reader re-verifies the blob's digest as it is consumed, surfacing an error at EOF instead of
+//silently returning content that has bit-rotted since it was written.
 func (c *containerdCAS) ReadBlob(ctrdCtx context.Context, blobHash string) (io.Reader, error) {
-	reader, err := c.ctrdClient.CtrReadBlob(ctrdCtx, blobHash)
+	reader, err := c.ctrdClient.CtrReadBlob(ctrdCtx, blobHash, true)
 	if err != nil {
 		log.Errorf("ReadBlob: Exception while reading blob: %s. %s", blobHash, err.Error())
 		return nil, err
@@ -511,9 +525,10 @@ func (c *containerdCAS) ReplaceImage(reference, mediaType, blobHash string) erro
 	return nil
 }
 
-//CreateSnapshotForImage: creates an snapshot with the given snapshotID for the given 'reference'
+//CreateSnapshotForImage: creates an snapshot with the given snapshotID for the given 'reference',
+//on the named snapshotter, or this device's default snapshotter if snapshotter is "".
 //Arg 'snapshotID' should be of format sha256:<hash>.
-func (c *containerdCAS) CreateSnapshotForImage(snapshotID, reference string) error {
+func (c *containerdCAS) CreateSnapshotForImage(snapshotID, reference, snapshotter string) error {
 	ctrdCtx, done := c.ctrdClient.CtrNewUserServicesCtx()
 	defer done()
 	clientImageObj, err := c.ctrdClient.CtrGetImage(ctrdCtx, reference)
@@ -527,24 +542,36 @@ func (c *containerdCAS) CreateSnapshotForImage(snapshotID, reference string) err
 		return err
 	}
 
-	if _, err := c.ctrdClient.CtrPrepareSnapshot(ctrdCtx, snapshotID, clientImageObj); err != nil {
+	if _, err := c.ctrdClient.CtrPrepareSnapshot(ctrdCtx, snapshotID, clientImageObj, snapshotter); err != nil {
 		return fmt.Errorf("CreateSnapshotForImage: Exception while creating snapshot: %s. %s", snapshotID, err.Error())
 	}
 	return nil
 }
 
-//MountSnapshot: mounts the snapshot on the given target path
+//MountSnapshot: mounts the snapshot, found on the named snapshotter (or this device's default
+//snapshotter if snapshotter is ""), on the given target path
 //Arg 'snapshotID' should be of format sha256:<hash>.
-func (c *containerdCAS) MountSnapshot(snapshotID, targetPath string) error {
+func (c *containerdCAS) MountSnapshot(snapshotID, targetPath, snapshotter string) error {
 	ctrdCtx, done := c.ctrdClient.CtrNewUserServicesCtx()
 	defer done()
 
-	if err := c.ctrdClient.CtrMountSnapshot(ctrdCtx, snapshotID, targetPath); err != nil {
+	if err := c.ctrdClient.CtrMountSnapshot(ctrdCtx, snapshotID, targetPath, snapshotter); err != nil {
 		return fmt.Errorf("MountSnapshot: Exception while fetching mounts of snapshot: %s. %s", snapshotID, err)
 	}
 	return nil
 }
 
+//UnmountSnapshot: undoes a MountSnapshot, unmounting targetPath.
+func (c *containerdCAS) UnmountSnapshot(targetPath string) error {
+	ctrdCtx, done := c.ctrdClient.CtrNewUserServicesCtx()
+	defer done()
+
+	if err := c.ctrdClient.CtrUnmountSnapshot(ctrdCtx, targetPath); err != nil {
+		return fmt.Errorf("UnmountSnapshot: Exception while unmounting %s: %s", targetPath, err)
+	}
+	return nil
+}
+
 //ListSnapshots: returns a list of snapshotIDs where each entry is of format sha256:<hash>.
 func (c *containerdCAS) ListSnapshots() ([]string, error) {
 	ctrdCtx, done := c.ctrdClient.CtrNewUserServicesCtx()
@@ -560,36 +587,39 @@ func (c *containerdCAS) ListSnapshots() ([]string, error) {
 	return snapshotIDList, nil
 }
 
-//ListSnapshots: removes a snapshot matching the given 'snapshotID'.
+//RemoveSnapshot: removes a snapshot matching the given 'snapshotID', on the named snapshotter
+//(or this device's default snapshotter if snapshotter is "").
 //Arg 'snapshotID' should be of format sha256:<hash>.
 //To keep this method idempotent, no error  is returned if the given 'snapshotID' is not found.
-func (c *containerdCAS) RemoveSnapshot(snapshotID string) error {
+func (c *containerdCAS) RemoveSnapshot(snapshotID, snapshotter string) error {
 	ctrdCtx, done := c.ctrdClient.CtrNewUserServicesCtx()
 	defer done()
-	if err := c.ctrdClient.CtrRemoveSnapshot(ctrdCtx, snapshotID); err != nil && !isNotFoundError(err) {
+	if err := c.ctrdClient.CtrRemoveSnapshot(ctrdCtx, snapshotID, snapshotter); err != nil && !isNotFoundError(err) {
 		return fmt.Errorf("RemoveSnapshot: Exception while removing snapshot: %s. %s", snapshotID, err.Error())
 	}
 	return nil
 }
 
-// PrepareContainerRootDir prepares a writable snapshot from the reference. Before preparing container's root directory,
+// PrepareContainerRootDir prepares a writable snapshot from the reference, on the named
+// snapshotter (e.g. "overlayfs", "zfs"), or this device's default snapshotter if snapshotter
+// is "". Before preparing container's root directory,
 // this API removes any existing state that may have accumulated (like existing snapshots being available, etc.)
 // This effectively voids any kind of caching, but on the flip side frees us
 // from cache invalidation. Additionally this API should deposit an OCI config json file and image name
 // next to the rootfs so that the effective structure becomes:
 //    rootPath/rootfs, rootPath/image-config.json
 // The rootPath is expected to end in a basename that becomes the snapshotID
-func (c *containerdCAS) PrepareContainerRootDir(rootPath, reference, rootBlobSha string) error {
+func (c *containerdCAS) PrepareContainerRootDir(rootPath, reference, rootBlobSha, snapshotter string) error {
 	//Step 1: On device restart, the existing bundle is not deleted, we need to delete the
 	// existing bundle of the container and recreate it. This is safe to run even
 	// when bundle doesn't exist
-	if c.RemoveContainerRootDir(rootPath) != nil {
+	if c.RemoveContainerRootDir(rootPath, snapshotter) != nil {
 		log.Warnf("PrepareContainerRootDir: tried to clean up any existing state, hopefully it worked")
 	}
 
 	//Step 2: create snapshot of the image so that it can be mounted as container's rootfs.
 	snapshotID := containerd.GetSnapshotID(rootPath)
-	if err := c.CreateSnapshotForImage(snapshotID, reference); err != nil {
+	if err := c.CreateSnapshotForImage(snapshotID, reference, snapshotter); err != nil {
 		err = fmt.Errorf("PrepareContainerRootDir: Could not create snapshot %s. %v", snapshotID, err)
 		log.Errorf(err.Error())
 		return err
@@ -632,8 +662,9 @@ func (c *containerdCAS) PrepareContainerRootDir(rootPath, reference, rootBlobSha
 	return nil
 }
 
-// RemoveContainerRootDir removes contents of a container's rootPath and snapshot.
-func (c *containerdCAS) RemoveContainerRootDir(rootPath string) error {
+// RemoveContainerRootDir removes contents of a container's rootPath and snapshot, on the
+// named snapshotter (or this device's default snapshotter if snapshotter is "").
+func (c *containerdCAS) RemoveContainerRootDir(rootPath, snapshotter string) error {
 	//Step 1: Un-mount container's rootfs
 	if err := syscall.Unmount(filepath.Join(rootPath, containerRootfsPath), 0); err != nil {
 		err = fmt.Errorf("RemoveContainerRootDir: exception while unmounting: %v/%v. %v",
@@ -653,7 +684,7 @@ func (c *containerdCAS) RemoveContainerRootDir(rootPath string) error {
 
 	//Step 3: Remove snapshot created for the image
 	snapshotID := containerd.GetSnapshotID(rootPath)
-	if err := c.RemoveSnapshot(snapshotID); err != nil {
+	if err := c.RemoveSnapshot(snapshotID, snapshotter); err != nil {
 		err = fmt.Errorf("RemoveContainerRootDir: unable to remove snapshot: %v. %v", snapshotID, err)
 		log.Error(err.Error())
 
@@ -733,14 +764,54 @@ func (c *containerdCAS) CloseClient() error {
 	return nil
 }
 
+// GetMetrics wraps the underlying fn
+func (c *containerdCAS) GetMetrics() types.ContainerdMetrics {
+	return c.ctrdClient.GetMetrics()
+}
+
 // CtrNewUserServicesCtx wraps the underlying fn
 func (c *containerdCAS) CtrNewUserServicesCtx() (context.Context, context.CancelFunc) {
 	return c.ctrdClient.CtrNewUserServicesCtx()
 }
 
+// PruneStaleLeases covers both the eve-user-apps and services.linuxkit
+// namespaces, since a stale lease (and the download it pinned) could have
+// been left behind in either one.
+func (c *containerdCAS) PruneStaleLeases(threshold time.Duration, dryRun bool) ([]string, int64, error) {
+	var removed []string
+	var reclaimedBytes int64
+
+	userCtx, doneUser := c.ctrdClient.CtrNewUserServicesCtx()
+	defer doneUser()
+	userRemoved, userBytes, err := c.ctrdClient.CtrPruneStaleLeases(userCtx, threshold, dryRun)
+	removed = append(removed, userRemoved...)
+	reclaimedBytes += userBytes
+	if err != nil {
+		return removed, reclaimedBytes, fmt.Errorf("PruneStaleLeases: user namespace: %s", err.Error())
+	}
+
+	sysCtx, doneSys := c.ctrdClient.CtrNewSystemServicesCtx()
+	defer doneSys()
+	sysRemoved, sysBytes, err := c.ctrdClient.CtrPruneStaleLeases(sysCtx, threshold, dryRun)
+	removed = append(removed, sysRemoved...)
+	reclaimedBytes += sysBytes
+	if err != nil {
+		return removed, reclaimedBytes, fmt.Errorf("PruneStaleLeases: system namespace: %s", err.Error())
+	}
+
+	return removed, reclaimedBytes, nil
+}
+
+// ctrdLog wraps this package's own logrus logger in a *base.LogObject so it
+// can satisfy containerd.NewContainerdClient's logging interface. The cas
+// package doesn't (yet) receive the owning agent's LogObject, so the
+// containerd client started here logs under the "cas" pseudo-agent name
+// rather than the real one.
+var ctrdLog = base.NewSourceLogObject(log.StandardLogger(), "cas", os.Getpid())
+
 //newContainerdCAS: constructor for containerd CAS
 func newContainerdCAS() CAS {
-	ctrdClient, err := containerd.NewContainerdClient()
+	ctrdClient, err := containerd.NewContainerdClient(ctrdLog)
 	if err != nil {
 		log.Fatalf("newContainerdCAS: exception while creating containerd client: %s", err.Error())
 	}
@@ -0,0 +1,120 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cas
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// PackageInfo is a single installed package entry extracted from a
+// container image's package manager database.
+type PackageInfo struct {
+	Name    string
+	Version string
+	// Origin identifies which package database the entry came from:
+	// "apk", "dpkg" or "rpm".
+	Origin string
+}
+
+const sbomFilename = "sbom.json"
+
+// apk installed db: /lib/apk/db/installed, records are "P:name\nV:version\n"
+func extractAPKPackages(rootfs string) []PackageInfo {
+	path := filepath.Join(rootfs, "lib", "apk", "db", "installed")
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var pkgs []PackageInfo
+	var name string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case len(line) > 2 && line[:2] == "P:":
+			name = line[2:]
+		case len(line) > 2 && line[:2] == "V:" && name != "":
+			pkgs = append(pkgs, PackageInfo{Name: name, Version: line[2:], Origin: "apk"})
+			name = ""
+		}
+	}
+	return pkgs
+}
+
+// dpkg status db: /var/lib/dpkg/status, stanzas separated by blank lines
+func extractDPKGPackages(rootfs string) []PackageInfo {
+	path := filepath.Join(rootfs, "var", "lib", "dpkg", "status")
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var pkgs []PackageInfo
+	var name, version string
+	nameRe := regexp.MustCompile(`^Package:\s*(\S+)`)
+	versionRe := regexp.MustCompile(`^Version:\s*(\S+)`)
+	flush := func() {
+		if name != "" {
+			pkgs = append(pkgs, PackageInfo{Name: name, Version: version, Origin: "dpkg"})
+		}
+		name, version = "", ""
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		if m := nameRe.FindStringSubmatch(line); m != nil {
+			name = m[1]
+		} else if m := versionRe.FindStringSubmatch(line); m != nil {
+			version = m[1]
+		}
+	}
+	flush()
+	return pkgs
+}
+
+// rpm uses a berkeley-db/sqlite Packages database that cannot be parsed
+// without librpm; detecting its presence is the best we can do without
+// shelling out to rpm(1) inside the (possibly foreign-arch) rootfs.
+func extractRPMPackages(rootfs string) []PackageInfo {
+	for _, candidate := range []string{"var/lib/rpm/Packages", "var/lib/rpm/rpmdb.sqlite"} {
+		if _, err := os.Stat(filepath.Join(rootfs, candidate)); err == nil {
+			return []PackageInfo{{Name: "unknown", Version: "unknown", Origin: "rpm"}}
+		}
+	}
+	return nil
+}
+
+// ExtractPackageManifest inspects an unpacked container rootfs for
+// alpine/apk, debian/dpkg and rpm package databases and returns the
+// installed package inventory (SBOM) found there.
+func ExtractPackageManifest(rootfs string) []PackageInfo {
+	var pkgs []PackageInfo
+	pkgs = append(pkgs, extractAPKPackages(rootfs)...)
+	pkgs = append(pkgs, extractDPKGPackages(rootfs)...)
+	pkgs = append(pkgs, extractRPMPackages(rootfs)...)
+	return pkgs
+}
+
+// WritePackageManifest extracts the package manifest from rootPath/rootfs
+// and writes it as JSON to rootPath/sbom.json, next to the OCI image
+// config that PrepareContainerRootDir already deposits there.
+func WritePackageManifest(rootPath string) error {
+	pkgs := ExtractPackageManifest(filepath.Join(rootPath, containerRootfsPath))
+	data, err := json.Marshal(pkgs)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(rootPath, sbomFilename), data, 0666)
+}
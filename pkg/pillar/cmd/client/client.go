@@ -257,7 +257,7 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 	}
 
 	// Load device cert
-	deviceCert, err := zedcloud.GetClientCert()
+	deviceCert, err := zedcloud.GetClientCert(log)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,60 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// coredump is installed as the kernel's core_pattern handler (see
+// configureCoreDumps in cmd/zedagent) so that crashes of EVE agents
+// and native container apps are captured instead of vanishing
+// without a trace. It is invoked synchronously by the kernel on every
+// crash as "coredump <comm> <pid> <timestamp> <exe-path-with-!-for-/>",
+// with the raw core image piped in on stdin, so it deliberately
+// avoids anything as slow as a pubsub subscription handshake.
+package coredump
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+	"github.com/lf-edge/eve/pkg/pillar/coredump"
+	"github.com/lf-edge/eve/pkg/pillar/pubsub"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Run implements the zedbox entrypoint contract. ps and loggerArg are
+// unused - this is a one-shot helper, not a long-running agent - but
+// are part of the common entrypoint signature used by zedbox.
+func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, log *base.LogObject) int {
+	args := os.Args[1:]
+	if len(args) != 4 {
+		log.Errorf("coredump: expected 4 args (comm pid timestamp exe), got %d: %v",
+			len(args), args)
+		return 1
+	}
+	comm := args[0]
+	pid, err := strconv.Atoi(args[1])
+	if err != nil {
+		log.Errorf("coredump: bad pid %q: %s", args[1], err)
+		return 1
+	}
+	timestampUnix, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		log.Errorf("coredump: bad timestamp %q: %s", args[2], err)
+		return 1
+	}
+	exePath := strings.ReplaceAll(args[3], "!", "/")
+
+	cfg, err := coredump.ReadConfig(types.CoreDumpConfigFile)
+	if err != nil {
+		log.Warnf("coredump: reading config, treating as disabled: %s", err)
+	}
+
+	if _, err := coredump.Capture(log, types.CoreDumpDirname, cfg, comm, pid,
+		time.Unix(timestampUnix, 0), exePath, os.Stdin); err != nil {
+		log.Errorf("coredump: %s", err)
+		return 1
+	}
+	return 0
+}
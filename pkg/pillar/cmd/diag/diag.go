@@ -47,25 +47,27 @@ const (
 // State passed to handlers
 type diagContext struct {
 	devicenetwork.DeviceNetworkContext
-	DevicePortConfigList    *types.DevicePortConfigList
-	forever                 bool // Keep on reporting until ^C
-	pacContents             bool // Print PAC file contents
-	ledCounter              int
-	derivedLedCounter       int // Based on ledCounter + usableAddressCount
-	subGlobalConfig         pubsub.Subscription
-	globalConfig            *types.ConfigItemValueMap
-	subLedBlinkCounter      pubsub.Subscription
-	subDeviceNetworkStatus  pubsub.Subscription
-	subDevicePortConfigList pubsub.Subscription
-	gotBC                   bool
-	gotDNS                  bool
-	gotDPCList              bool
-	serverNameAndPort       string
-	serverName              string // Without port number
-	zedcloudCtx             *zedcloud.ZedCloudContext
-	cert                    *tls.Certificate
-	usingOnboardCert        bool
-	devUUID                 uuid.UUID
+	DevicePortConfigList       *types.DevicePortConfigList
+	DevicePortConfigHistory    *types.DevicePortConfigHistory
+	forever                    bool // Keep on reporting until ^C
+	pacContents                bool // Print PAC file contents
+	ledCounter                 int
+	derivedLedCounter          int // Based on ledCounter + usableAddressCount
+	subGlobalConfig            pubsub.Subscription
+	globalConfig               *types.ConfigItemValueMap
+	subLedBlinkCounter         pubsub.Subscription
+	subDeviceNetworkStatus     pubsub.Subscription
+	subDevicePortConfigList    pubsub.Subscription
+	subDevicePortConfigHistory pubsub.Subscription
+	gotBC                      bool
+	gotDNS                     bool
+	gotDPCList                 bool
+	serverNameAndPort          string
+	serverName                 string // Without port number
+	zedcloudCtx                *zedcloud.ZedCloudContext
+	cert                       *tls.Certificate
+	usingOnboardCert           bool
+	devUUID                    uuid.UUID
 }
 
 // Set from Makefile
@@ -121,6 +123,7 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 	ctx.AgentName = agentName
 	ctx.DeviceNetworkStatus = &types.DeviceNetworkStatus{}
 	ctx.DevicePortConfigList = &types.DevicePortConfigList{}
+	ctx.DevicePortConfigHistory = &types.DevicePortConfigHistory{}
 
 	// Make sure we have a GlobalConfig file with defaults
 	utils.EnsureGCFile(log)
@@ -169,7 +172,7 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 
 	if fileExists(types.DeviceCertName) {
 		// Load device cert
-		cert, err := zedcloud.GetClientCert()
+		cert, err := zedcloud.GetClientCert(log)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -249,6 +252,24 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 	ctx.subDevicePortConfigList = subDevicePortConfigList
 	subDevicePortConfigList.Activate()
 
+	subDevicePortConfigHistory, err := ps.NewSubscription(
+		pubsub.SubscriptionOptions{
+			AgentName:     "nim",
+			MyAgentName:   agentName,
+			Persistent:    true,
+			TopicImpl:     types.DevicePortConfigHistory{},
+			Activate:      false,
+			Ctx:           &ctx,
+			CreateHandler: handleDPCHistoryModify,
+			ModifyHandler: handleDPCHistoryModify,
+		})
+	if err != nil {
+		errStr := fmt.Sprintf("ERROR: internal Subscribe failed %s\n", err)
+		panic(errStr)
+	}
+	ctx.subDevicePortConfigHistory = subDevicePortConfigHistory
+	subDevicePortConfigHistory.Activate()
+
 	subOnboardStatus, err := ps.NewSubscription(pubsub.SubscriptionOptions{
 		AgentName:     "zedclient",
 		MyAgentName:   agentName,
@@ -284,6 +305,9 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 		case change := <-subDevicePortConfigList.MsgChan():
 			ctx.gotDPCList = true
 			subDevicePortConfigList.ProcessChange(change)
+
+		case change := <-subDevicePortConfigHistory.MsgChan():
+			subDevicePortConfigHistory.ProcessChange(change)
 		}
 		if !ctx.forever && ctx.gotDNS && ctx.gotBC && ctx.gotDPCList {
 			break
@@ -291,7 +315,7 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 		if ctx.usingOnboardCert && fileExists(types.DeviceCertName) {
 			fmt.Fprintf(outfile, "WARNING: Switching from onboard to device cert\n")
 			// Load device cert
-			cert, err := zedcloud.GetClientCert()
+			cert, err := zedcloud.GetClientCert(log)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -421,6 +445,20 @@ func handleDPCModify(ctxArg interface{}, key string, statusArg interface{}) {
 	log.Infof("handleDPCModify done for %s", key)
 }
 
+func handleDPCHistoryModify(ctxArg interface{}, key string, statusArg interface{}) {
+
+	status := statusArg.(types.DevicePortConfigHistory)
+	ctx := ctxArg.(*diagContext)
+	if key != "global" {
+		log.Infof("handleDPCHistoryModify: ignoring %s", key)
+		return
+	}
+	log.Infof("handleDPCHistoryModify for %s", key)
+	*ctx.DevicePortConfigHistory = status
+	printOutput(ctx)
+	log.Infof("handleDPCHistoryModify done for %s", key)
+}
+
 // Handles UUID change from process client
 func handleOnboardStatusModify(ctxArg interface{}, key string, statusArg interface{}) {
 	status := statusArg.(types.OnboardingStatus)
@@ -517,6 +555,15 @@ func printOutput(ctx *diagContext) {
 			}
 		}
 	}
+	for _, entry := range ctx.DevicePortConfigHistory.Entries {
+		workedStr := "never reached controller"
+		if entry.WasWorking {
+			workedStr = fmt.Sprintf("reached controller, worked for %v",
+				entry.ReplacedAt.Sub(entry.TimePriority))
+		}
+		fmt.Fprintf(outfile, "INFO: Replaced DevicePortConfig key %s (ports %v) at %v: %s\n",
+			entry.Key, entry.Ports, entry.ReplacedAt, workedStr)
+	}
 	if testing {
 		fmt.Fprintf(outfile, "WARNING: The configuration below is under test hence might report failures\n")
 	}
@@ -991,9 +1038,11 @@ func myGet(zedcloudCtx *zedcloud.ZedCloudContext, reqURL string, ifname string,
 		case types.SenderStatusRefused:
 			fmt.Fprintf(outfile, "ERROR: %s: get %s Controller returned ECONNREFUSED\n",
 				ifname, reqURL)
-		case types.SenderStatusCertInvalid:
-			fmt.Fprintf(outfile, "ERROR: %s: get %s Controller certificate invalid time\n",
-				ifname, reqURL)
+		case types.SenderStatusCertInvalid, types.SenderStatusCertExpired,
+			types.SenderStatusCertHostnameMismatch, types.SenderStatusCertUnknownAuthority,
+			types.SenderStatusCertUnknownAuthorityProxy, types.SenderStatusCertUnsupportedCiphers:
+			fmt.Fprintf(outfile, "ERROR: %s: get %s Controller TLS failure: %s\n",
+				ifname, reqURL, err)
 		case types.SenderStatusCertMiss:
 			fmt.Fprintf(outfile, "ERROR: %s: get %s Controller certificate miss\n",
 				ifname, reqURL)
@@ -1051,9 +1100,11 @@ func myPost(zedcloudCtx *zedcloud.ZedCloudContext, reqURL string, ifname string,
 		case types.SenderStatusRefused:
 			fmt.Fprintf(outfile, "ERROR: %s: post %s Controller returned ECONNREFUSED\n",
 				ifname, reqURL)
-		case types.SenderStatusCertInvalid:
-			fmt.Fprintf(outfile, "ERROR: %s: post %s Controller certificate invalid time\n",
-				ifname, reqURL)
+		case types.SenderStatusCertInvalid, types.SenderStatusCertExpired,
+			types.SenderStatusCertHostnameMismatch, types.SenderStatusCertUnknownAuthority,
+			types.SenderStatusCertUnknownAuthorityProxy, types.SenderStatusCertUnsupportedCiphers:
+			fmt.Fprintf(outfile, "ERROR: %s: post %s Controller TLS failure: %s\n",
+				ifname, reqURL, err)
 		case types.SenderStatusCertMiss:
 			fmt.Fprintf(outfile, "ERROR: %s: post %s Controller certificate miss\n",
 				ifname, reqURL)
@@ -0,0 +1,104 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package domainmgr
+
+import (
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/hypervisor"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// quiesceHookDefaultTimeout bounds a quiesce hook command when
+// QuiesceHookConfig.Timeout is unset.
+const quiesceHookDefaultTimeout = 30 * time.Second
+
+// maybeCheckpointContainer checkpoints status's container task, if the
+// backend supports it (see hypervisor.Checkpointer), the app is a
+// container, and its DomainConfig has EnableCheckpoint set. It is called
+// before tearing the task down, e.g. from doInactivate, so a subsequent
+// doActivate with the same checkpoint in place (see
+// hypervisor/containerd.go's ctrdContext.Create) restores it instead of
+// starting fresh.
+//
+// If config.QuiesceHook has hook commands configured, they bracket the
+// checkpoint: PreCommand runs first and must succeed for the checkpoint
+// to be attempted, and PostCommand then runs regardless of whether the
+// checkpoint itself succeeded. Results are published as
+// types.QuiesceHookStatus for zedagent/zedmanager to report upstream.
+func maybeCheckpointContainer(ctx *domainContext, status *types.DomainStatus) {
+	if !status.IsContainer || status.DomainId == 0 {
+		return
+	}
+	config := lookupDomainConfig(ctx, status.Key())
+	if config == nil || !config.EnableCheckpoint {
+		return
+	}
+	checkpointer, ok := hypervisor.AsCheckpointer(hyper)
+	if !ok {
+		log.Debugf("maybeCheckpointContainer(%s): hypervisor %s doesn't support checkpointing",
+			config.Key(), hyper.Name())
+		return
+	}
+
+	var hookStatus types.QuiesceHookStatus
+	if config.QuiesceHook.HasHooks() {
+		hookStatus.UUIDandVersion = config.UUIDandVersion
+		hookStatus.Pre = runQuiesceHook(ctx, status, config.QuiesceHook, config.QuiesceHook.PreCommand)
+		if hookStatus.Pre.Ran && !hookStatus.Pre.Success {
+			log.Errorf("maybeCheckpointContainer(%s): pre-checkpoint quiesce hook failed, skipping checkpoint: %s",
+				status.DomainName, hookStatus.Pre.Error)
+			ctx.pubQuiesceHookStatus.Publish(hookStatus.Key(), hookStatus)
+			return
+		}
+	}
+
+	if err := checkpointer.Checkpoint(status.DomainName); err != nil {
+		// Not fatal - doInactivate proceeds to tear the task down
+		// regardless, so the app simply loses its checkpointed state
+		// and starts fresh next time.
+		log.Errorf("maybeCheckpointContainer(%s): checkpoint failed: %v",
+			status.DomainName, err)
+	}
+
+	if config.QuiesceHook.HasHooks() {
+		hookStatus.Post = runQuiesceHook(ctx, status, config.QuiesceHook, config.QuiesceHook.PostCommand)
+		ctx.pubQuiesceHookStatus.Publish(hookStatus.Key(), hookStatus)
+	}
+}
+
+// runQuiesceHook execs args, if any, inside status's container task via
+// hypervisor.QuiesceHookRunner, recording the outcome as a
+// types.QuiesceHookResult. A nil/empty args is a no-op, returning a
+// result with Ran false.
+func runQuiesceHook(ctx *domainContext, status *types.DomainStatus, hook types.QuiesceHookConfig, args []string) types.QuiesceHookResult {
+	if len(args) == 0 {
+		return types.QuiesceHookResult{}
+	}
+	runner, ok := hypervisor.AsQuiesceHookRunner(hyper)
+	if !ok {
+		return types.QuiesceHookResult{
+			Ran:   true,
+			At:    time.Now(),
+			Error: "hypervisor " + hyper.Name() + " doesn't support quiesce hook commands",
+		}
+	}
+	timeout := hook.Timeout
+	if timeout == 0 {
+		timeout = quiesceHookDefaultTimeout
+	}
+	start := time.Now()
+	stdout, stderr, err := runner.RunQuiesceCommand(status.DomainName, args, timeout)
+	result := types.QuiesceHookResult{
+		Ran:      true,
+		Success:  err == nil,
+		Output:   stdout + stderr,
+		At:       start,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -25,9 +25,11 @@ import (
 	"github.com/google/go-cmp/cmp"
 	zconfig "github.com/lf-edge/eve/api/go/config"
 	"github.com/lf-edge/eve/pkg/pillar/agentlog"
+	"github.com/lf-edge/eve/pkg/pillar/assignments"
 	"github.com/lf-edge/eve/pkg/pillar/base"
 	"github.com/lf-edge/eve/pkg/pillar/cas"
 	"github.com/lf-edge/eve/pkg/pillar/cipher"
+	"github.com/lf-edge/eve/pkg/pillar/consolewatch"
 	"github.com/lf-edge/eve/pkg/pillar/containerd"
 	"github.com/lf-edge/eve/pkg/pillar/diskmetrics"
 	"github.com/lf-edge/eve/pkg/pillar/flextimer"
@@ -70,32 +72,46 @@ type domainContext struct {
 	ps *pubsub.PubSub
 	// The isPort function is called by different goroutines
 	// hence we serialize the calls on a mutex.
-	decryptCipherContext   cipher.DecryptCipherContext
-	deviceNetworkStatus    types.DeviceNetworkStatus
-	dnsLock                sync.Mutex
-	assignableAdapters     *types.AssignableAdapters
-	DNSinitialized         bool // Received DeviceNetworkStatus
-	subDeviceNetworkStatus pubsub.Subscription
-	subPhysicalIOAdapter   pubsub.Subscription
-	subDomainConfig        pubsub.Subscription
-	pubDomainStatus        pubsub.Publication
-	subGlobalConfig        pubsub.Subscription
-	pubAssignableAdapters  pubsub.Publication
-	pubDomainMetric        pubsub.Publication
-	pubHostMemory          pubsub.Publication
-	pubProcessMetric       pubsub.Publication
-	pubCipherBlockStatus   pubsub.Publication
-	usbAccess              bool
-	createSema             *sema.Semaphore
-	GCComplete             bool
-	setInitialUsbAccess    bool
-	GCInitialized          bool
-	domainBootRetryTime    uint32 // In seconds
-	metricInterval         uint32 // In seconds
-	pids                   map[int32]bool
+	decryptCipherContext      cipher.DecryptCipherContext
+	deviceNetworkStatus       types.DeviceNetworkStatus
+	dnsLock                   sync.Mutex
+	assignableAdapters        *types.AssignableAdapters
+	DNSinitialized            bool // Received DeviceNetworkStatus
+	subDeviceNetworkStatus    pubsub.Subscription
+	subPhysicalIOAdapter      pubsub.Subscription
+	subDomainConfig           pubsub.Subscription
+	pubDomainStatus           pubsub.Publication
+	subGlobalConfig           pubsub.Subscription
+	pubAssignableAdapters     pubsub.Publication
+	pubDomainMetric           pubsub.Publication
+	pubHostMemory             pubsub.Publication
+	pubProcessMetric          pubsub.Publication
+	pubContainerRestartStatus pubsub.Publication
+	pubContainerHealthStatus  pubsub.Publication
+	pubQuiesceHookStatus      pubsub.Publication
+	pubMeasurementLog         pubsub.Publication
+	pubCipherBlockStatus      pubsub.Publication
+	usbAccess                 bool
+	createSema                *sema.Semaphore
+	GCComplete                bool
+	setInitialUsbAccess       bool
+	GCInitialized             bool
+	domainBootRetryTime       uint32 // In seconds
+	metricInterval            uint32 // In seconds
+	pids                      map[int32]bool
 	// Common CAS client which can be used by multiple routines.
 	// There is no shared data so its safe to be used by multiple goroutines
 	casClient cas.CAS
+	// kernelModulePolicy restricts which kernel modules may be
+	// modprobed, e.g. to keep i915/gasket/tpu drivers unloaded until an
+	// app actually needs the adapter that requires them.
+	kernelModulePolicy types.KernelModulePolicyList
+	// consoleWatchers tracks the running console pattern watchers
+	// keyed by DomainStatus.Key(), so we can stop them on inactivate.
+	consoleWatchers map[string]*consolewatch.Watcher
+	// resourceAlarmState tracks per-app ResourceAlarmConfig evaluation
+	// state, keyed by DomainConfig.Key(). See resourcealarm.go.
+	resourceAlarmState map[string]*resourceAlarmState
 }
 
 func (ctx *domainContext) publishAssignableAdapters() {
@@ -134,6 +150,11 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 	if err != nil {
 		log.Fatal(err)
 	}
+	if caps, err := hypervisor.GetCapabilities(); err != nil {
+		log.Warnf("Failed to detect hypervisor capabilities: %s", err)
+	} else {
+		log.Noticef("Hypervisor capabilities: %+v", caps)
+	}
 
 	if err := pidfile.CheckAndCreatePidfile(log, agentName); err != nil {
 		log.Fatal(err)
@@ -187,6 +208,8 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 		usbAccess:           true,
 		domainBootRetryTime: 600,
 		pids:                make(map[int32]bool),
+		consoleWatchers:     make(map[string]*consolewatch.Watcher),
+		resourceAlarmState:  make(map[string]*resourceAlarmState),
 	}
 	aa := types.AssignableAdapters{}
 	domainCtx.assignableAdapters = &aa
@@ -236,6 +259,46 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 	}
 	domainCtx.pubProcessMetric = pubProcessMetric
 
+	pubContainerRestartStatus, err := ps.NewPublication(
+		pubsub.PublicationOptions{
+			AgentName: agentName,
+			TopicType: types.ContainerRestartStatus{},
+		})
+	if err != nil {
+		log.Fatal(err)
+	}
+	domainCtx.pubContainerRestartStatus = pubContainerRestartStatus
+
+	pubContainerHealthStatus, err := ps.NewPublication(
+		pubsub.PublicationOptions{
+			AgentName: agentName,
+			TopicType: types.ContainerHealthStatus{},
+		})
+	if err != nil {
+		log.Fatal(err)
+	}
+	domainCtx.pubContainerHealthStatus = pubContainerHealthStatus
+
+	pubQuiesceHookStatus, err := ps.NewPublication(
+		pubsub.PublicationOptions{
+			AgentName: agentName,
+			TopicType: types.QuiesceHookStatus{},
+		})
+	if err != nil {
+		log.Fatal(err)
+	}
+	domainCtx.pubQuiesceHookStatus = pubQuiesceHookStatus
+
+	pubMeasurementLog, err := ps.NewPublication(
+		pubsub.PublicationOptions{
+			AgentName: agentName,
+			TopicType: types.MeasurementLogEntry{},
+		})
+	if err != nil {
+		log.Fatal(err)
+	}
+	domainCtx.pubMeasurementLog = pubMeasurementLog
+
 	pubHostMemory, err := ps.NewPublication(
 		pubsub.PublicationOptions{
 			AgentName: agentName,
@@ -266,6 +329,14 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 		log.Fatal(err)
 	}
 
+	containerdMetricsPub, err := ps.NewPublication(pubsub.PublicationOptions{
+		AgentName: agentName,
+		TopicType: types.ContainerdMetrics{},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Look for controller certs which will be used for decryption
 	subControllerCert, err := ps.NewSubscription(pubsub.SubscriptionOptions{
 		AgentName:   "zedagent",
@@ -581,6 +652,10 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 			if err != nil {
 				log.Errorln(err)
 			}
+			err = containerdMetricsPub.Publish("global", domainCtx.casClient.GetMetrics())
+			if err != nil {
+				log.Errorln(err)
+			}
 			ps.CheckMaxTimeTopic(agentName, "publishTimer", start,
 				warningTime, errorTime)
 			start = time.Now()
@@ -918,7 +993,7 @@ func maybeRetryBoot(ctx *domainContext, status *types.DomainStatus) {
 		return
 	}
 	status.BootFailed = false
-	doActivateTail(ctx, status, domainID)
+	doActivateTail(ctx, *config, status, domainID)
 	publishDomainStatus(ctx, status)
 	log.Infof("maybeRetryBoot(%s) DONE for %s",
 		status.Key(), status.DisplayName)
@@ -1192,6 +1267,7 @@ func doActivate(ctx *domainContext, config types.DomainConfig,
 		// We now have reserved all of the IoAdapters
 		status.IoAdapterList = config.IoAdapterList
 	}
+	status.ConsolePatterns = config.ConsolePatterns
 
 	// Pre-flight checks for containers
 	if config.IsContainer {
@@ -1226,7 +1302,7 @@ func doActivate(ctx *domainContext, config types.DomainConfig,
 			// do nothing
 		case zconfig.Format_CONTAINER:
 			snapshotID := containerd.GetSnapshotID(ds.FileLocation)
-			if err := ctx.casClient.MountSnapshot(snapshotID, getRoofFsPath(ds.FileLocation)); err != nil {
+			if err := ctx.casClient.MountSnapshot(snapshotID, getRoofFsPath(ds.FileLocation), ""); err != nil {
 				err := fmt.Errorf("doActivate: Failed mount snapshot: %s for %s. Error %s",
 					snapshotID, config.UUIDandVersion.UUID, err)
 				log.Error(err.Error())
@@ -1299,10 +1375,10 @@ func doActivate(ctx *domainContext, config types.DomainConfig,
 		time.Sleep(5 * time.Second)
 	}
 	status.BootFailed = false
-	doActivateTail(ctx, status, domainID)
+	doActivateTail(ctx, config, status, domainID)
 }
 
-func doActivateTail(ctx *domainContext, status *types.DomainStatus,
+func doActivateTail(ctx *domainContext, config types.DomainConfig, status *types.DomainStatus,
 	domainID int) {
 
 	log.Infof("created domainID %d for %s", domainID, status.DomainName)
@@ -1349,15 +1425,52 @@ func doActivateTail(ctx *domainContext, status *types.DomainStatus,
 			status.Key())
 	}
 	status.Activated = true
+	startConsoleWatcher(ctx, status)
+	startRestartWatcher(ctx, config, status)
+	startHealthWatcher(ctx, config, status)
+	measureContainerLaunch(ctx, config, status)
 	log.Infof("doActivateTail(%v) done for %s",
 		status.UUIDandVersion, status.DisplayName)
 }
 
+// startConsoleWatcher starts watching the domain's console output for
+// status.ConsolePatterns, if any were configured, replacing any watcher
+// already running for this domain (e.g. from a previous boot attempt).
+func startConsoleWatcher(ctx *domainContext, status *types.DomainStatus) {
+	stopConsoleWatcher(ctx, status)
+	if len(status.ConsolePatterns) == 0 {
+		return
+	}
+	var rules []consolewatch.PatternRule
+	for _, p := range status.ConsolePatterns {
+		rules = append(rules, consolewatch.PatternRule{Name: p.Name, Pattern: p.Pattern})
+	}
+	w := consolewatch.NewWatcher(log, status.DomainName, rules,
+		func(rule consolewatch.PatternRule, line string) {
+			log.Warnf("startConsoleWatcher(%s): console pattern %q matched: %s",
+				status.Key(), rule.Name, line)
+		})
+	w.Start()
+	ctx.consoleWatchers[status.Key()] = w
+}
+
+// stopConsoleWatcher stops and forgets any console watcher running for status.
+func stopConsoleWatcher(ctx *domainContext, status *types.DomainStatus) {
+	if w, ok := ctx.consoleWatchers[status.Key()]; ok {
+		w.Stop()
+		delete(ctx.consoleWatchers, status.Key())
+	}
+}
+
 // shutdown and wait for the domain to go away; if that fails destroy and wait
 func doInactivate(ctx *domainContext, status *types.DomainStatus, impatient bool) {
 
 	log.Infof("doInactivate(%v) for %s",
 		status.UUIDandVersion, status.DisplayName)
+	stopConsoleWatcher(ctx, status)
+	stopRestartWatcher(status)
+	stopHealthWatcher(status)
+	maybeCheckpointContainer(ctx, status)
 	domainID, _, err := hyper.Task(status).Info(status.DomainName, status.DomainId)
 	if err == nil && domainID != status.DomainId {
 		status.DomainId = domainID
@@ -1550,9 +1663,13 @@ func configToStatus(ctx *domainContext, config types.DomainConfig,
 		ds.Format = dc.Format
 		ds.MountDir = dc.MountDir
 		ds.DisplayName = dc.DisplayName
+		ds.HostDirSharePath = dc.HostDirSharePath
+		ds.HostDirShareCacheMode = dc.HostDirShareCacheMode
 		// Generate Devtype for hypervisor package
 		// XXX can hypervisor look at something different?
-		if dc.Format == zconfig.Format_CONTAINER {
+		if dc.HostDirSharePath != "" {
+			ds.Devtype = "virtiofs"
+		} else if dc.Format == zconfig.Format_CONTAINER {
 			ds.Devtype = ""
 			need9P = true
 		} else {
@@ -2340,10 +2457,10 @@ func checkAndSetIoMember(ctx *domainContext, ib *types.IoBundle, isPort bool, pu
 			if ib.PciLong != "" {
 				log.Infof("Removing %s (%s) from pciback",
 					ib.Phylabel, ib.PciLong)
-				err := hyper.PCIRelease(ib.PciLong)
-				if err != nil {
-					log.Errorf("checkAndSetIoMember(%d %s %s) PCIRelease %s failed %v",
-						ib.Type, ib.Phylabel, ib.AssignmentGroup, ib.PciLong, err)
+				ib.AssignmentStatus = assignments.Unbind(hyper, ib.PciLong)
+				if ib.AssignmentStatus.Error != "" {
+					log.Errorf("checkAndSetIoMember(%d %s %s) unbind %s failed: %s",
+						ib.Type, ib.Phylabel, ib.AssignmentGroup, ib.PciLong, ib.AssignmentStatus.Error)
 				}
 				// Seems like like no risk for race; when we return
 				// from above the driver has been attached and
@@ -2418,9 +2535,10 @@ func checkAndSetIoMember(ctx *domainContext, ib *types.IoBundle, isPort bool, pu
 		} else if ib.PciLong != "" {
 			log.Infof("Assigning %s (%s) to pciback",
 				ib.Phylabel, ib.PciLong)
-			err := hyper.PCIReserve(ib.PciLong)
-			if err != nil {
-				return err
+			ib.AssignmentStatus = assignments.Bind(hyper, ib.PciLong)
+			if ib.AssignmentStatus.Error != "" {
+				return fmt.Errorf("checkAndSetIoMember(%d %s %s) bind %s failed: %s",
+					ib.Type, ib.Phylabel, ib.AssignmentGroup, ib.PciLong, ib.AssignmentStatus.Error)
 			}
 			ib.IsPCIBack = true
 			changed = true
@@ -2503,12 +2621,12 @@ func updateUsbAccess(ctx *domainContext) {
 
 	log.Infof("updateUsbAccess(%t)", ctx.usbAccess)
 	if !ctx.usbAccess {
-		if removeUSBfromKernel() {
+		if removeUSBfromKernel(ctx) {
 			maybeAssignableAddUSB(ctx)
 		}
 	} else {
 		if maybeAssignableRemUSB(ctx) {
-			addUSBtoKernel()
+			addUSBtoKernel(ctx)
 		}
 	}
 	checkIoBundleAll(ctx)
@@ -2608,7 +2726,7 @@ var usbDrivers = []loadedDriver{
 }
 
 // Enable the above drivers; record which ones loaded
-func addUSBtoKernel() {
+func addUSBtoKernel(ctx *domainContext) {
 
 	log.Infof("addUSBtoKernel()")
 	for i := range usbDrivers {
@@ -2618,7 +2736,7 @@ func addUSBtoKernel() {
 				drv.driverName)
 			continue
 		}
-		if err := doModprobe(drv.driverName, true); err != nil {
+		if err := doModprobe(ctx, drv.driverName, true); err != nil {
 			log.Errorf("modprobe failed to add %s: %s",
 				drv.driverName, err)
 			drv.loaded = types.TS_DISABLED
@@ -2629,7 +2747,7 @@ func addUSBtoKernel() {
 }
 
 // Disable usbhid etc
-func removeUSBfromKernel() bool {
+func removeUSBfromKernel(ctx *domainContext) bool {
 
 	log.Infof("removeUSBfromKernel()")
 	ret := true
@@ -2640,7 +2758,7 @@ func removeUSBfromKernel() bool {
 				drv.driverName)
 			continue
 		}
-		if err := doModprobe(drv.driverName, false); err != nil {
+		if err := doModprobe(ctx, drv.driverName, false); err != nil {
 			log.Errorf("modprobe failed to remove %s: %s",
 				drv.driverName, err)
 			ret = false
@@ -2651,7 +2769,16 @@ func removeUSBfromKernel() bool {
 	return ret
 }
 
-func doModprobe(driver string, add bool) error {
+// doModprobe loads or unloads a kernel module, honoring the
+// controller-configured kernelModulePolicy: a module explicitly denied is
+// never modprobed, and is reported as not loaded so the controller can see
+// that policy was enforced.
+func doModprobe(ctx *domainContext, driver string, add bool) error {
+	if add && !ctx.kernelModulePolicy.Allowed(driver) {
+		err := fmt.Errorf("modprobe of %s denied by kernel module policy", driver)
+		log.Error(err)
+		return err
+	}
 	cmd := "modprobe"
 	args := []string{}
 	if !add {
@@ -2665,9 +2792,33 @@ func doModprobe(driver string, add bool) error {
 		log.Errorf("modprobe output: %s", stdoutStderr)
 		return err
 	}
+	if add {
+		ctx.kernelModulePolicy.Loaded = addUnique(ctx.kernelModulePolicy.Loaded, driver)
+	} else {
+		ctx.kernelModulePolicy.Loaded = removeString(ctx.kernelModulePolicy.Loaded, driver)
+	}
 	return nil
 }
 
+func addUnique(list []string, s string) []string {
+	for _, e := range list {
+		if e == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+func removeString(list []string, s string) []string {
+	out := list[:0]
+	for _, e := range list {
+		if e != s {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
 func handleIBDelete(ctx *domainContext, phylabel string) {
 
 	log.Infof("handleIBDelete(%s)", phylabel)
@@ -2683,10 +2834,10 @@ func handleIBDelete(ctx *domainContext, phylabel string) {
 		log.Infof("handleIBDelete: Assigning %s (%s) back",
 			ib.Phylabel, ib.PciLong)
 		if ib.PciLong != "" {
-			err := hyper.PCIRelease(ib.PciLong)
-			if err != nil {
-				log.Errorf("handleIBDelete(%d %s %s) PCIRelease %s failed %v",
-					ib.Type, ib.Phylabel, ib.AssignmentGroup, ib.PciLong, err)
+			ib.AssignmentStatus = assignments.Unbind(hyper, ib.PciLong)
+			if ib.AssignmentStatus.Error != "" {
+				log.Errorf("handleIBDelete(%d %s %s) unbind %s failed: %s",
+					ib.Type, ib.Phylabel, ib.AssignmentGroup, ib.PciLong, ib.AssignmentStatus.Error)
 			}
 			ib.IsPCIBack = false
 		}
@@ -0,0 +1,76 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package domainmgr
+
+import (
+	"github.com/lf-edge/eve/pkg/pillar/healthprobe"
+	"github.com/lf-edge/eve/pkg/pillar/hypervisor"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// ctrHealthProbeConfig converts config's HealthProbeConfig to the
+// healthprobe equivalent used by hypervisor.HealthProber.
+func ctrHealthProbeConfig(config types.HealthProbeConfig) healthprobe.Config {
+	var probeType healthprobe.Type
+	switch config.Type {
+	case types.HealthProbeTypeTCP:
+		probeType = healthprobe.TypeTCP
+	case types.HealthProbeTypeHTTP:
+		probeType = healthprobe.TypeHTTP
+	default:
+		probeType = healthprobe.TypeExec
+	}
+	return healthprobe.Config{
+		Type:             probeType,
+		Exec:             config.Exec,
+		Port:             config.Port,
+		HTTPPath:         config.HTTPPath,
+		Period:           config.Period,
+		Timeout:          config.Timeout,
+		SuccessThreshold: config.SuccessThreshold,
+		FailureThreshold: config.FailureThreshold,
+		RestartOnFailure: config.RestartOnFailure,
+	}
+}
+
+// startHealthWatcher asks the hypervisor to probe config's container for
+// health on a schedule, if both the backend supports it (see
+// hypervisor.HealthProber) and the app is a container with probing
+// configured. Health status is published as types.ContainerHealthStatus
+// for zedagent/zedmanager to report upstream.
+func startHealthWatcher(ctx *domainContext, config types.DomainConfig, status *types.DomainStatus) {
+	if !config.IsContainer || config.HealthProbe.Type == types.HealthProbeTypeNone {
+		return
+	}
+	prober, ok := hypervisor.AsHealthProber(hyper)
+	if !ok {
+		log.Debugf("startHealthWatcher(%s): hypervisor %s doesn't support health probing",
+			config.Key(), hyper.Name())
+		return
+	}
+	domainName := status.DomainName
+	uuid := config.UUIDandVersion
+	prober.WatchHealth(domainName, ctrHealthProbeConfig(config.HealthProbe),
+		func(state healthprobe.State) {
+			hs := types.ContainerHealthStatus{
+				UUIDandVersion:      uuid,
+				Healthy:             state.Status == healthprobe.StatusHealthy,
+				ConsecutiveFailures: state.ConsecutiveFailures,
+				LastCheckAt:         state.LastCheckAt,
+				LastError:           state.LastError,
+			}
+			ctx.pubContainerHealthStatus.Publish(hs.Key(), hs)
+			log.Noticef("startHealthWatcher(%s): health status now healthy=%v",
+				domainName, hs.Healthy)
+		})
+}
+
+// stopHealthWatcher stops any health probe started by startHealthWatcher
+// for status's container; it is a no-op if none is running or the
+// backend doesn't support probing.
+func stopHealthWatcher(status *types.DomainStatus) {
+	if prober, ok := hypervisor.AsHealthProber(hyper); ok {
+		prober.UnwatchHealth(status.DomainName)
+	}
+}
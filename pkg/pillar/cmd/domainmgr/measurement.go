@@ -0,0 +1,55 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package domainmgr
+
+import (
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/containerd"
+	"github.com/lf-edge/eve/pkg/pillar/evetpm"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// measureContainerLaunch extends evetpm.MeasurementPCRHdl with the digest
+// of the image config that status's container was just launched from, and
+// publishes a types.MeasurementLogEntry recording that extension, so
+// zedagent/zedmanager and ultimately the controller can see which
+// workload digests contributed to the current PCR value. It is a no-op
+// for non-container apps, or if the TPM isn't available.
+func measureContainerLaunch(ctx *domainContext, config types.DomainConfig, status *types.DomainStatus) {
+	if !config.IsContainer || len(status.DiskStatusList) == 0 {
+		return
+	}
+	if !evetpm.IsTpmEnabled() {
+		return
+	}
+	rootDisk := status.DiskStatusList[0]
+	digest, err := containerd.GetImageConfigDigest(rootDisk.FileLocation)
+	if err != nil {
+		log.Errorf("measureContainerLaunch(%s): %s", status.DomainName, err)
+		return
+	}
+	digestBytes, err := hex.DecodeString(strings.TrimPrefix(digest, "sha256:"))
+	if err != nil {
+		log.Errorf("measureContainerLaunch(%s): malformed digest %s: %s",
+			status.DomainName, digest, err)
+		return
+	}
+	if err := evetpm.ExtendMeasurementPCR(digestBytes); err != nil {
+		log.Errorf("measureContainerLaunch(%s): extending PCR failed: %s",
+			status.DomainName, err)
+		return
+	}
+	entry := types.MeasurementLogEntry{
+		UUIDandVersion: config.UUIDandVersion,
+		ImageDigest:    digest,
+		PCRIndex:       uint8(evetpm.MeasurementPCRHdl),
+		MeasuredAt:     time.Now(),
+	}
+	ctx.pubMeasurementLog.Publish(entry.Key(), entry)
+	log.Noticef("measureContainerLaunch(%s): extended PCR %d with %s",
+		status.DomainName, entry.PCRIndex, digest)
+}
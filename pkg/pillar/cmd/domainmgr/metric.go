@@ -48,6 +48,7 @@ func metricsTimerTask(ctx *domainContext, hyper hypervisor.Hypervisor) {
 
 func getAndPublishMetrics(ctx *domainContext, hyper hypervisor.Hypervisor) {
 	dmList, _ := hyper.GetDomsCPUMem()
+	guestStats, haveGuestStats := hypervisor.AsGuestNetworkStatsProvider(hyper)
 	for domainName, dm := range dmList {
 		uuid, err := domainnameToUUID(ctx, domainName)
 		if err != nil {
@@ -55,7 +56,20 @@ func getAndPublishMetrics(ctx *domainContext, hyper hypervisor.Hypervisor) {
 			continue
 		}
 		dm.UUIDandVersion.UUID = uuid
+		if haveGuestStats && domainName != dom0Name {
+			if stats, err := guestStats.GetGuestNetworkStats(domainName); err != nil {
+				log.Debugf("GetGuestNetworkStats(%s): %s", domainName, err)
+			} else {
+				dm.GuestNetworkStats = stats
+			}
+		}
 		ctx.pubDomainMetric.Publish(dm.Key(), dm)
+
+		if config := lookupDomainConfig(ctx, dm.Key()); config != nil {
+			if status := lookupDomainStatus(ctx, dm.Key()); status != nil {
+				evaluateResourceAlarms(ctx, *config, *status, dm)
+			}
+		}
 	}
 
 	hm, _ := hyper.GetHostCPUMem()
@@ -0,0 +1,149 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package domainmgr
+
+import (
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/diskmetrics"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// resourceAlarmState tracks, per app instance (keyed by DomainConfig.Key()),
+// the state needed to evaluate ResourceAlarmConfig thresholds that must be
+// sustained over time: the previous CPU sample (DomainMetric.CPUTotal is
+// cumulative seconds since boot, so a rate requires a delta), and how long
+// each configured alarm has continuously been over its threshold.
+type resourceAlarmState struct {
+	lastCPUTotal  uint64
+	lastSampleAt  time.Time
+	exceededSince map[types.ResourceAlarmMetric]time.Time
+}
+
+// evaluateResourceAlarms checks config's ResourceAlarms against the latest
+// metrics sample for this app and, for any alarm that has been continuously
+// over threshold for its configured Duration, takes the alarm's Action. This
+// runs entirely within domainmgr, independent of zedagent/controller
+// connectivity, so a runaway app is handled even when the device is
+// offline.
+func evaluateResourceAlarms(ctx *domainContext, config types.DomainConfig,
+	status types.DomainStatus, dm types.DomainMetric) {
+
+	if len(config.ResourceAlarms) == 0 {
+		return
+	}
+	key := config.Key()
+	state := ctx.resourceAlarmState[key]
+	if state == nil {
+		state = &resourceAlarmState{
+			exceededSince: make(map[types.ResourceAlarmMetric]time.Time),
+		}
+		ctx.resourceAlarmState[key] = state
+	}
+	now := time.Now()
+	cpuPercent := computeCPUPercent(state, config.VCpus, dm.CPUTotal, now)
+	diskPercent := computeDiskPercent(status)
+
+	for _, alarm := range config.ResourceAlarms {
+		var value float64
+		switch alarm.Metric {
+		case types.ResourceAlarmMetricDiskPercent:
+			value = diskPercent
+		case types.ResourceAlarmMetricCPUPercent:
+			value = cpuPercent
+		default:
+			continue
+		}
+		if value <= alarm.Threshold {
+			delete(state.exceededSince, alarm.Metric)
+			continue
+		}
+		since, exceeded := state.exceededSince[alarm.Metric]
+		if !exceeded {
+			state.exceededSince[alarm.Metric] = now
+			continue
+		}
+		if now.Sub(since) < alarm.Duration {
+			continue
+		}
+		enforceResourceAlarm(ctx, config, alarm, value)
+		// Reset the clock so the action fires at most once per Duration,
+		// rather than on every metrics tick while still over threshold.
+		state.exceededSince[alarm.Metric] = now
+	}
+}
+
+// computeCPUPercent turns dm's cumulative CPUTotal into a percentage of the
+// vCPUs assigned to the app, using the previous sample recorded in state.
+// Returns 0 for the first sample of an app, or if the domain was restarted
+// underneath us (CPUTotal going backwards).
+func computeCPUPercent(state *resourceAlarmState, vCPUs int, cpuTotal uint64, now time.Time) float64 {
+	prevTotal, prevAt := state.lastCPUTotal, state.lastSampleAt
+	state.lastCPUTotal = cpuTotal
+	state.lastSampleAt = now
+
+	if prevAt.IsZero() || cpuTotal < prevTotal {
+		return 0
+	}
+	elapsed := now.Sub(prevAt).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	if vCPUs <= 0 {
+		vCPUs = 1
+	}
+	usedSeconds := float64(cpuTotal - prevTotal)
+	return 100 * usedSeconds / (elapsed * float64(vCPUs))
+}
+
+// computeDiskPercent returns the highest thin-provisioning usage, as a
+// percentage of provisioned (virtual) size, across status's disks.
+func computeDiskPercent(status types.DomainStatus) float64 {
+	var maxPercent float64
+	for _, disk := range status.DiskStatusList {
+		info, err := diskmetrics.GetImgInfo(log, disk.FileLocation)
+		if err != nil || info.VirtualSize == 0 {
+			continue
+		}
+		if percent := 100 * float64(info.ActualSize) / float64(info.VirtualSize); percent > maxPercent {
+			maxPercent = percent
+		}
+	}
+	return maxPercent
+}
+
+// enforceResourceAlarm takes alarm's configured Action for config's app,
+// which has had metric at value continuously over alarm.Threshold for at
+// least alarm.Duration.
+func enforceResourceAlarm(ctx *domainContext, config types.DomainConfig,
+	alarm types.ResourceAlarmConfig, value float64) {
+
+	log.Warnf("ResourceAlarm: app %s metric %d at %.1f%% (threshold %.1f%%, sustained %v)",
+		config.Key(), alarm.Metric, value, alarm.Threshold, alarm.Duration)
+
+	switch alarm.Action {
+	case types.ResourceAlarmActionRestart:
+		if config.Critical {
+			log.Warnf("ResourceAlarm: not restarting critical app %s, falling back to event-only",
+				config.Key())
+			return
+		}
+		status := lookupDomainStatus(ctx, config.Key())
+		if status == nil {
+			log.Errorf("ResourceAlarm: no DomainStatus for app %s, can't restart", config.Key())
+			return
+		}
+		log.Warnf("ResourceAlarm: restarting domain for app %s", config.Key())
+		doInactivate(ctx, status, false)
+		doActivate(ctx, config, status)
+		publishDomainStatus(ctx, status)
+	case types.ResourceAlarmActionThrottle:
+		// XXX not implemented: no Hypervisor interface method exists to
+		// cap a running domain's CPU quota or disk I/O rate, so fall
+		// back to the Event action (the Warnf above) until one is added.
+		log.Warnf("ResourceAlarm: throttle action not implemented for app %s, falling back to event-only", config.Key())
+	case types.ResourceAlarmActionEvent:
+		// The Warnf above is the entire action.
+	}
+}
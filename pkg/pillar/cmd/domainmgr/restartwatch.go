@@ -0,0 +1,68 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package domainmgr
+
+import (
+	"github.com/lf-edge/eve/pkg/pillar/hypervisor"
+	"github.com/lf-edge/eve/pkg/pillar/taskmonitor"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// ctrRestartPolicy converts config's RestartPolicy to the taskmonitor
+// equivalent used by hypervisor.TaskWatcher.
+func ctrRestartPolicy(policy types.RestartPolicy) taskmonitor.Policy {
+	switch policy {
+	case types.RestartPolicyAlways:
+		return taskmonitor.PolicyAlways
+	case types.RestartPolicyOnFailure:
+		return taskmonitor.PolicyOnFailure
+	default:
+		return taskmonitor.PolicyNever
+	}
+}
+
+// startRestartWatcher asks the hypervisor to watch config's task for exit
+// and restart it per config.RestartPolicy, if both the backend supports it
+// (see hypervisor.TaskWatcher) and the app is a container with a policy
+// other than RestartPolicyNever. Restart status is published as
+// types.ContainerRestartStatus for zedagent/zedmanager to report upstream.
+func startRestartWatcher(ctx *domainContext, config types.DomainConfig, status *types.DomainStatus) {
+	if !config.IsContainer || config.RestartPolicy == types.RestartPolicyNever {
+		return
+	}
+	watcher, ok := hypervisor.AsTaskWatcher(hyper)
+	if !ok {
+		log.Debugf("startRestartWatcher(%s): hypervisor %s doesn't support restart watching",
+			config.Key(), hyper.Name())
+		return
+	}
+	domainName := status.DomainName
+	uuid := config.UUIDandVersion
+	watcher.WatchTask(domainName, ctrRestartPolicy(config.RestartPolicy),
+		func(state taskmonitor.State, restarted bool, err error) {
+			rs := types.ContainerRestartStatus{
+				UUIDandVersion: uuid,
+				RestartCount:   state.RestartCount,
+				LastExitCode:   state.LastExitCode,
+				LastExitAt:     state.LastExitAt,
+			}
+			if err != nil {
+				rs.LastRestartError = err.Error()
+			}
+			ctx.pubContainerRestartStatus.Publish(rs.Key(), rs)
+			if restarted {
+				log.Noticef("startRestartWatcher(%s): automatically restarted, restart count %d",
+					domainName, state.RestartCount)
+			}
+		})
+}
+
+// stopRestartWatcher stops any restart watch started by startRestartWatcher
+// for status's task; it is a no-op if none is running or the backend
+// doesn't support watching.
+func stopRestartWatcher(status *types.DomainStatus) {
+	if watcher, ok := hypervisor.AsTaskWatcher(hyper); ok {
+		watcher.UnwatchTask(status.DomainName)
+	}
+}
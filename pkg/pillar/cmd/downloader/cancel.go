@@ -0,0 +1,62 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package downloader
+
+import "context"
+
+// registerDownloadCancel records the CancelFunc for the download starting
+// in its own goroutine for key, so that a later Modify or Delete for the
+// same key can ask it to stop at its next safe point, and bumps key's
+// generation so the new download can recognize any later download
+// started for the same key as superseding it. Returns the generation
+// number assigned to this download; the caller must thread it through to
+// isCurrentDownload/clearDownloadCancel.
+func (ctx *downloaderContext) registerDownloadCancel(key string, cancel context.CancelFunc) uint64 {
+	ctx.downloadCancelLock.Lock()
+	defer ctx.downloadCancelLock.Unlock()
+	ctx.downloadCancelFuncs[key] = cancel
+	ctx.downloadGeneration[key]++
+	return ctx.downloadGeneration[key]
+}
+
+// clearDownloadCancel forgets about a download for key once it is no
+// longer in flight, unless a newer download has since been registered
+// for the same key (generation no longer matches) -- in that case the
+// newer download's bookkeeping must be left alone.
+func (ctx *downloaderContext) clearDownloadCancel(key string, generation uint64) {
+	ctx.downloadCancelLock.Lock()
+	defer ctx.downloadCancelLock.Unlock()
+	if ctx.downloadGeneration[key] != generation {
+		return
+	}
+	delete(ctx.downloadCancelFuncs, key)
+}
+
+// isCurrentDownload reports whether generation is still the most recent
+// download registered for key, i.e. whether a goroutine running that
+// download is still the one the rest of downloader should be listening
+// to. A download that has been superseded (a newer one for the same key
+// was started while it was still in flight) must not publish its result.
+func (ctx *downloaderContext) isCurrentDownload(key string, generation uint64) bool {
+	ctx.downloadCancelLock.Lock()
+	defer ctx.downloadCancelLock.Unlock()
+	return ctx.downloadGeneration[key] == generation
+}
+
+// cancelDownload asks the in-flight download for key, if any, to stop.
+// It is a no-op if no download is currently running for key.
+//
+// Note this only cancels our own bookkeeping loop (trying further
+// mirrors/retries and holding on to a partial file); zedUpload's
+// DronaRequest.Cancel is currently an unimplemented no-op, so a transfer
+// that is already in progress on the wire runs to completion or failure
+// before the cancellation is noticed.
+func (ctx *downloaderContext) cancelDownload(key string) {
+	ctx.downloadCancelLock.Lock()
+	cancel, ok := ctx.downloadCancelFuncs[key]
+	ctx.downloadCancelLock.Unlock()
+	if ok {
+		cancel()
+	}
+}
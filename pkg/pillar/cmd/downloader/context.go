@@ -4,6 +4,7 @@
 package downloader
 
 import (
+	"context"
 	"sync"
 
 	"github.com/lf-edge/eve/pkg/pillar/cipher"
@@ -26,6 +27,17 @@ type downloaderContext struct {
 	globalStatusLock       sync.Mutex
 	subGlobalConfig        pubsub.Subscription
 	GCInitialized          bool
+	// downloadCancelLock guards downloadCancelFuncs and
+	// downloadGeneration, which together record the download currently
+	// running in its own goroutine for each key (see
+	// handleCreate/cancel.go), so that a Modify or Delete arriving while
+	// that download is in flight can ask it to stop instead of waiting
+	// for it to finish, and so a download superseded by a newer one for
+	// the same key (see startDownload) can recognize that and not
+	// publish a stale status over the newer download's.
+	downloadCancelLock  sync.Mutex
+	downloadCancelFuncs map[string]context.CancelFunc
+	downloadGeneration  map[string]uint64
 }
 
 func (ctx *downloaderContext) registerHandlers(ps *pubsub.PubSub) error {
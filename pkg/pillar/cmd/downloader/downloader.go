@@ -8,6 +8,7 @@
 package downloader
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -97,7 +98,10 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 		time.Duration(max))
 
 	// Any state needed by handler functions
-	ctx := downloaderContext{}
+	ctx := downloaderContext{
+		downloadCancelFuncs: make(map[string]context.CancelFunc),
+		downloadGeneration:  make(map[string]uint64),
+	}
 
 	// set up any state needed by handler functions
 	err = ctx.registerHandlers(ps)
@@ -255,11 +259,18 @@ func runHandler(ctx *downloaderContext, key string, c <-chan Notify) {
 				if status == nil {
 					handleCreate(ctx, config, status, key)
 				} else {
+					if config.RefCount == 0 {
+						// Ask any download already in flight for this key to
+						// stop promptly instead of completing a download for
+						// an object nobody wants anymore.
+						ctx.cancelDownload(key)
+					}
 					handleModify(ctx, key, config, status)
 				}
 				// XXX if err start timer
 			} else {
 				// Closed
+				ctx.cancelDownload(key)
 				status := lookupDownloaderStatus(ctx, key)
 				if status != nil {
 					handleDelete(ctx, key, status)
@@ -309,7 +320,7 @@ func maybeRetryDownload(ctx *downloaderContext,
 	status.ClearError()
 	publishDownloaderStatus(ctx, status)
 
-	doDownload(ctx, *config, status)
+	startDownload(ctx, *config, status)
 }
 
 func handleCreate(ctx *downloaderContext, config types.DownloaderConfig,
@@ -344,14 +355,43 @@ func handleCreate(ctx *downloaderContext, config types.DownloaderConfig,
 	}
 	publishDownloaderStatus(ctx, status)
 
-	doDownload(ctx, config, status)
+	startDownload(ctx, config, status)
+}
+
+// startDownload runs doDownload in its own goroutine, under a context that
+// runHandler can cancel via downloaderContext.cancelDownload if the config
+// changes (RefCount back to 0) or is deleted while the download is still
+// in flight. This lets runHandler's select loop keep observing and acting
+// on those changes promptly instead of being blocked behind the download
+// for the rest of its (possibly very long) duration.
+//
+// If a download is already in flight for status.Key() (e.g. handleModify
+// calling handleCreate again while the previous download hasn't reached
+// types.DOWNLOADED or an error yet), this new download supersedes it: the
+// generation registered here is threaded through to doDownload/
+// handleSyncOp, which check it (via downloaderContext.isCurrentDownload)
+// before publishing their result, so the superseded goroutine's eventual
+// completion can no longer overwrite what this one publishes.
+//
+// The goroutine works on its own copy of status, since the caller may keep
+// updating and publishing status after this call (handleModify does, once
+// handleCreate returns).
+func startDownload(ctx *downloaderContext, config types.DownloaderConfig,
+	status *types.DownloaderStatus) {
+
+	key := status.Key()
+	dctx, cancel := context.WithCancel(context.Background())
+	generation := ctx.registerDownloadCancel(key, cancel)
+	statusCopy := *status
+	go func() {
+		defer ctx.clearDownloadCancel(key, generation)
+		doDownload(ctx, config, &statusCopy, dctx, generation)
+	}()
 }
 
-// XXX Allow to cancel by setting RefCount = 0? Such a change
-// would have to be detected outside of handler since the download is
-// single-threaded.
 // RefCount 0->1 means download.
-// RefCount -> 0 means set Expired to delete
+// RefCount -> 0 means set Expired to delete; if a download is still in
+// flight for this key, the caller has already called cancelDownload.
 func handleModify(ctx *downloaderContext, key string,
 	config types.DownloaderConfig, status *types.DownloaderStatus) {
 
@@ -400,7 +440,21 @@ func doDelete(ctx *downloaderContext, key string, filename string,
 }
 
 // perform download of the object, by reserving storage
-func doDownload(ctx *downloaderContext, config types.DownloaderConfig, status *types.DownloaderStatus) {
+//
+// dctx is canceled by downloaderContext.cancelDownload if the caller no
+// longer wants this object (RefCount dropped to 0, or it was deleted)
+// while the download is still running in its own goroutine; see
+// startDownload. handleSyncOp checks dctx between attempts and reacts by
+// cleaning up immediately, but cannot abort a transfer already in
+// progress on the wire since zedUpload.DronaRequest.Cancel is currently
+// a no-op.
+//
+// generation is the value startDownload's registerDownloadCancel
+// returned when this download was started; handleSyncOp rechecks it via
+// downloaderContext.isCurrentDownload before publishing a result, in case
+// a newer download for the same key has since superseded this one.
+func doDownload(ctx *downloaderContext, config types.DownloaderConfig,
+	status *types.DownloaderStatus, dctx context.Context, generation uint64) {
 
 	// If RefCount == 0 then we don't yet need to download.
 	if config.RefCount == 0 {
@@ -412,6 +466,11 @@ func doDownload(ctx *downloaderContext, config types.DownloaderConfig, status *t
 		log.Errorf("doDownload(%s): deferred with %s", config.Name, errStr)
 		return
 	}
+	if dctx.Err() != nil {
+		log.Noticef("doDownload(%s): canceled before starting", config.Name)
+		doDelete(ctx, status.Key(), status.Target, status)
+		return
+	}
 
 	dst, err := utils.LookupDatastoreConfig(ctx.subDatastoreConfig, config.DatastoreID)
 	if dst == nil {
@@ -425,7 +484,7 @@ func doDownload(ctx *downloaderContext, config types.DownloaderConfig, status *t
 	}
 	log.Debugf("Found datastore(%s) for %s", config.DatastoreID.String(), config.Name)
 
-	handleSyncOp(ctx, status.Key(), config, status, dst)
+	handleSyncOp(ctx, status.Key(), config, status, dst, dctx, generation)
 }
 
 func handleDelete(ctx *downloaderContext, key string,
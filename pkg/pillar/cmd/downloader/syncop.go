@@ -4,12 +4,17 @@
 package downloader
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/url"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	zconfig "github.com/lf-edge/eve/api/go/config"
@@ -20,15 +25,28 @@ import (
 )
 
 // Drona APIs for object Download
+//
+// dctx is checked between source-address attempts (see the loop below) so
+// that a download can be abandoned promptly if the caller no longer wants
+// it; it is not threaded into the individual download() calls themselves,
+// since zedUpload.DronaRequest.Cancel is currently a no-op and cannot
+// actually interrupt a transfer already in progress on the wire.
+//
+// generation is rechecked, alongside dctx, immediately before the
+// success-path handleSyncOpResponse call below: downloadWithPullThrough
+// can take long enough that a newer download for the same key started
+// (superseding this one, see startDownload) and even finished while this
+// one was still transferring, so a stale success must not be published
+// over whatever that newer download already published.
 func handleSyncOp(ctx *downloaderContext, key string,
 	config types.DownloaderConfig, status *types.DownloaderStatus,
-	dst *types.DatastoreConfig) {
+	dst *types.DatastoreConfig, dctx context.Context, generation uint64) {
 	var (
-		err                                                    error
-		errStr, locFilename, locDirname, remoteName, serverURL string
-		syncOp                                                 zedUpload.SyncOpType = zedUpload.SyncOpDownload
-		trType                                                 zedUpload.SyncTransportType
-		auth                                                   *zedUpload.AuthInput
+		err                                                                    error
+		errStr, locFilename, locDirname, remoteName, serverURL, pullThroughURL string
+		syncOp                                                                 zedUpload.SyncOpType = zedUpload.SyncOpDownload
+		trType                                                                 zedUpload.SyncTransportType
+		auth                                                                   *zedUpload.AuthInput
 	)
 
 	// the target filename, where to place the download, is provided in config.
@@ -102,6 +120,14 @@ func handleSyncOp(ctx *downloaderContext, key string,
 		serverURL, remoteName, err = ociRepositorySplit(dsCtx.DownloadURL)
 		if err != nil {
 			errStr = fmt.Sprintf("invalid OCI registry URL: %s", serverURL)
+		} else if dsCtx.Dpath != "" {
+			// Dpath is otherwise unused by the OCI registry transport
+			// (see download() below); the controller repurposes it here
+			// to designate a pull-through caching registry that mirrors
+			// this datastore, so downloads try it first - forwarding the
+			// same auth - and fall back to the origin registry on
+			// failure or digest mismatch. See downloadWithPullThrough.
+			pullThroughURL = dsCtx.Dpath
 		}
 	case zconfig.DsType_DsS3.String():
 		auth = &zedUpload.AuthInput{
@@ -175,6 +201,11 @@ func handleSyncOp(ctx *downloaderContext, key string,
 
 	// Loop through all interfaces until a success
 	for addrIndex := 0; addrIndex < addrCount; addrIndex += 1 {
+		if dctx.Err() != nil || !ctx.isCurrentDownload(key, generation) {
+			log.Noticef("handleSyncOp(%s): canceled or superseded, stopping without trying more sources", config.Name)
+			doDelete(ctx, key, locFilename, status)
+			return
+		}
 		var ipSrc net.IP
 		if !config.AllowNonFreePort {
 			ipSrc, err = types.GetLocalAddrFreeNoLinkLocal(ctx.deviceNetworkStatus,
@@ -199,9 +230,9 @@ func handleSyncOp(ctx *downloaderContext, key string,
 			status: status,
 		}
 		downloadStartTime := time.Now()
-		contentType, err := download(ctx, trType, st, syncOp, serverURL, auth,
-			dsCtx.Dpath, dsCtx.Region,
-			config.Size, ifname, ipSrc, remoteName, locFilename)
+		contentType, err := downloadWithPullThrough(ctx, trType, st, syncOp,
+			serverURL, pullThroughURL, auth, dsCtx.Dpath, dsCtx.Region,
+			config.ImageSha256, config.Size, ifname, ipSrc, remoteName, locFilename)
 		if err != nil {
 			sourceFailureError(ipSrc.String(), ifname, metricsUrl, err)
 			errStr = errStr + "\n" + err.Error()
@@ -220,6 +251,16 @@ func handleSyncOp(ctx *downloaderContext, key string,
 		status.ContentType = contentType
 		zedcloud.ZedCloudSuccess(log, ifname,
 			metricsUrl, 1024, size, downloadTime)
+
+		// Recheck immediately before publishing success: the transfer
+		// above can take long enough for a cancel or a superseding
+		// download (see startDownload) to have arrived while it was in
+		// flight, after the last check at the top of this loop.
+		if dctx.Err() != nil || !ctx.isCurrentDownload(key, generation) {
+			log.Noticef("handleSyncOp(%s): canceled or superseded after a successful transfer, discarding result", config.Name)
+			doDelete(ctx, key, locFilename, status)
+			return
+		}
 		handleSyncOpResponse(ctx, config, status,
 			locFilename, key, "")
 		return
@@ -358,3 +399,60 @@ func getDatastoreCredential(ctx *downloaderContext,
 	}
 	return decBlock, nil
 }
+
+// downloadWithPullThrough attempts pullThroughServerURL first, if it is
+// non-empty, forwarding the same auth to it as to origServerURL; it falls
+// back to origServerURL if the pull-through attempt fails outright, or if
+// expectedSha256 is set and what it downloaded doesn't match. This way a
+// controller-designated caching registry can serve most pulls while a
+// flaky or compromised mirror can never result in the wrong content being
+// accepted.
+func downloadWithPullThrough(ctx *downloaderContext, trType zedUpload.SyncTransportType,
+	st Status, syncOp zedUpload.SyncOpType, origServerURL, pullThroughServerURL string,
+	auth *zedUpload.AuthInput, dpath, region, expectedSha256 string, maxsize uint64,
+	ifname string, ipSrc net.IP, remoteName, locFilename string) (string, error) {
+
+	if pullThroughServerURL != "" {
+		contentType, err := download(ctx, trType, st, syncOp, pullThroughServerURL, auth,
+			dpath, region, maxsize, ifname, ipSrc, remoteName, locFilename)
+		switch {
+		case err != nil:
+			log.Warnf("downloadWithPullThrough: cache %s failed, falling back to origin %s: %s",
+				pullThroughServerURL, origServerURL, err)
+		case expectedSha256 != "":
+			if digestErr := verifyContentDigest(locFilename, expectedSha256); digestErr != nil {
+				log.Warnf("downloadWithPullThrough: cache %s served mismatched content, falling back to origin %s: %s",
+					pullThroughServerURL, origServerURL, digestErr)
+				_ = os.Remove(locFilename)
+			} else {
+				return contentType, nil
+			}
+		default:
+			return contentType, nil
+		}
+	}
+	return download(ctx, trType, st, syncOp, origServerURL, auth,
+		dpath, region, maxsize, ifname, ipSrc, remoteName, locFilename)
+}
+
+// verifyContentDigest returns an error unless filename's sha256 matches
+// expectedSha256 (optionally prefixed with "sha256:", as image digests
+// commonly are).
+func verifyContentDigest(filename, expectedSha256 string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	actual := hex.EncodeToString(h.Sum(nil))
+	expected := strings.ToLower(strings.TrimPrefix(strings.ToLower(expectedSha256), "sha256:"))
+	if actual != expected {
+		return fmt.Errorf("digest mismatch: expected %s, got %s", expected, actual)
+	}
+	return nil
+}
@@ -165,6 +165,17 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 	}
 	pubDevicePortConfigList.ClearRestarted()
 
+	pubDevicePortConfigHistory, err := ps.NewPublication(
+		pubsub.PublicationOptions{
+			AgentName:  agentName,
+			Persistent: true,
+			TopicType:  types.DevicePortConfigHistory{},
+		})
+	if err != nil {
+		log.Fatal(err)
+	}
+	pubDevicePortConfigHistory.ClearRestarted()
+
 	pubCipherBlockStatus, err := ps.NewPublication(
 		pubsub.PublicationOptions{
 			AgentName: agentName,
@@ -258,10 +269,13 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 	nimCtx.deviceNetworkContext.PubDevicePortConfig = pubDevicePortConfig
 	nimCtx.deviceNetworkContext.PubDummyDevicePortConfig = pubDummyDevicePortConfig
 	nimCtx.deviceNetworkContext.PubDevicePortConfigList = pubDevicePortConfigList
+	nimCtx.deviceNetworkContext.DevicePortConfigHistory = &types.DevicePortConfigHistory{}
+	nimCtx.deviceNetworkContext.PubDevicePortConfigHistory = pubDevicePortConfigHistory
 	nimCtx.deviceNetworkContext.PubCipherBlockStatus = pubCipherBlockStatus
 	nimCtx.deviceNetworkContext.PubDeviceNetworkStatus = pubDeviceNetworkStatus
 	dnc := &nimCtx.deviceNetworkContext
 	devicenetwork.IngestPortConfigList(dnc)
+	devicenetwork.IngestDPCHistory(dnc)
 
 	// We get DevicePortConfig from three sources in this priority:
 	// 1. zedagent publishing DevicePortConfig
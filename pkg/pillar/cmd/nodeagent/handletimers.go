@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/lf-edge/eve/pkg/pillar/agentlog"
+	"github.com/lf-edge/eve/pkg/pillar/integrity"
+	"github.com/lf-edge/eve/pkg/pillar/storagestall"
 	"github.com/lf-edge/eve/pkg/pillar/types"
 	"github.com/lf-edge/eve/pkg/pillar/zboot"
 )
@@ -23,6 +25,43 @@ func handleDeviceTimers(ctxPtr *nodeagentContext) {
 	handleFallbackOnCloudDisconnect(ctxPtr)
 	handleResetOnCloudDisconnect(ctxPtr)
 	handleUpgradeTestValidation(ctxPtr)
+	handleIntegrityCheck(ctxPtr)
+	handleStorageStallCheck(ctxPtr)
+}
+
+// handleIntegrityCheck collects the current dm-verity/IMA runtime
+// measurement status and logs an error event if verification has failed,
+// so it surfaces in the device's log stream even before any dedicated
+// attestation flow picks it up.
+func handleIntegrityCheck(ctxPtr *nodeagentContext) {
+	status := integrity.CollectStatus(log)
+	if status.HasErrors() {
+		log.Errorf("handleIntegrityCheck: dm-verity corruption detected: %+v", status.VerityDevices)
+	}
+}
+
+// handleStorageStallCheck probes for a hung /persist fsync or a D-state
+// agent thread and reacts per the StorageStallPolicy global setting, so
+// a storage stall leaves a recorded cause instead of just showing up as
+// an inexplicable watchdog reboot.
+func handleStorageStallCheck(ctxPtr *nodeagentContext) {
+	thresholdSecs := ctxPtr.globalConfig.GlobalValueInt(types.StorageStallThresholdSeconds)
+	threshold := time.Duration(thresholdSecs) * time.Second
+	status := storagestall.CollectStatus(log, types.PersistDir, threshold)
+	if !status.HasStall() {
+		return
+	}
+	log.Errorf("handleStorageStallCheck: storage stall detected: fsync took %v (err %v), D-state processes %+v",
+		status.FsyncDuration, status.FsyncErr, status.DProcesses)
+
+	policy := ctxPtr.globalConfig.GlobalValueString(types.StorageStallPolicy)
+	if policy != "reboot" {
+		return
+	}
+	reason := fmt.Sprintf("storage stall: fsync took %v (err %v), D-state processes %+v",
+		status.FsyncDuration, status.FsyncErr, status.DProcesses)
+	agentlog.RebootReason(reason, agentName, os.Getpid(), false)
+	scheduleNodeReboot(ctxPtr, reason)
 }
 
 // for every ticker, based on the last config
@@ -232,8 +271,9 @@ func allDomainsHalted(ctxPtr *nodeagentContext) bool {
 }
 
 // waitForAllDomainsHalted
-//  blocks till all domains are halted. Should only be invoked from
-//  a thread.
+//
+//	blocks till all domains are halted. Should only be invoked from
+//	a thread.
 func waitForAllDomainsHalted(ctxPtr *nodeagentContext) {
 
 	var totalWaitTime uint32
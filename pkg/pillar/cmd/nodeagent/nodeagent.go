@@ -88,6 +88,7 @@ type nodeagentContext struct {
 	rebootStack                 string    // From last reboot
 	rebootTime                  time.Time // From last reboot
 	restartCounter              uint32
+	safeMode                    bool // Set if device-steps.sh started us in safe mode
 
 	// Some contants.. Declared here as variables to enable unit tests
 	minRebootDelay          uint32
@@ -121,10 +122,15 @@ func newNodeagentContext(ps *pubsub.PubSub, logger *logrus.Logger, log *base.Log
 	nodeagentCtx.agentBaseContext.ErrorTime = errorTime
 	nodeagentCtx.agentBaseContext.AgentName = agentName
 	nodeagentCtx.agentBaseContext.WarningTime = warningTime
+	nodeagentCtx.agentBaseContext.Version = Version
 
 	curpart := agentlog.EveCurrentPartition()
 	nodeagentCtx.curPart = strings.TrimSpace(curpart)
 	nodeagentCtx.agentBaseContext.NeedWatchdog = true
+	// device-steps.sh sets this when it only started networking,
+	// onboarding and logging agents to recover from an app-induced
+	// crash loop; see handleNodeAgentStatusModify in zedagent.
+	nodeagentCtx.safeMode = os.Getenv("EVE_SAFE_MODE") == "1"
 	return nodeagentCtx
 }
 
@@ -235,6 +241,7 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 		case <-nodeagentCtx.stillRunning.C:
 		}
 		ps.StillRunning(agentName, warningTime, errorTime)
+		nodeagentCtx.agentBaseContext.PublishHeartBeat()
 	}
 	log.Infof("processed GlobalConfig")
 
@@ -319,6 +326,7 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 		case <-nodeagentCtx.stillRunning.C:
 		}
 		ps.StillRunning(agentName, warningTime, errorTime)
+		nodeagentCtx.agentBaseContext.PublishHeartBeat()
 	}
 }
 
@@ -533,6 +541,7 @@ func publishNodeAgentStatus(ctxPtr *nodeagentContext) {
 		RebootTime:        ctxPtr.rebootTime,
 		RebootImage:       ctxPtr.rebootImage,
 		RestartCounter:    ctxPtr.restartCounter,
+		SafeMode:          ctxPtr.safeMode,
 	}
 	pub.Publish(agentName, status)
 }
@@ -47,6 +47,8 @@ import (
 	"github.com/lf-edge/eve/pkg/pillar/pubsub"
 	"github.com/lf-edge/eve/pkg/pillar/types"
 	"github.com/lf-edge/eve/pkg/pillar/vault"
+	"github.com/lf-edge/eve/pkg/pillar/zfs"
+	"github.com/shirou/gopsutil/mem"
 	"github.com/sirupsen/logrus"
 )
 
@@ -850,6 +852,43 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 				publishVaultStatus(&ctx)
 			}
 		}
+	case "zfsTune":
+		if vault.ReadPersistType() != "zfs" {
+			log.Infof("zfsTune: persist filesystem is not zfs, nothing to do")
+			break
+		}
+		vmem, err := mem.VirtualMemory()
+		if err != nil {
+			log.Errorf("zfsTune: failed to read host memory: %s", err)
+			return 1
+		}
+		arcMax := zfs.ComputeARCMax(vmem.Total, 0)
+		if err := zfs.SetARCMax(log, arcMax); err != nil {
+			return 1
+		}
+		log.Noticef("zfsTune: set zfs_arc_max to %d bytes", arcMax)
+		stdOut, stdErr, err := execCmd(vault.ZfsPath, "/hostfs", "zpool", "status", vault.DefaultZpool)
+		if err != nil {
+			log.Errorf("zfsTune: zpool status failed: %v, %s, %s", err, stdOut, stdErr)
+			return 1
+		}
+		health := zfs.ParsePoolStatus(vault.DefaultZpool, stdOut)
+		log.Noticef("zfsTune: pool health: %+v", health)
+		if !health.Healthy() {
+			return 1
+		}
+	case "fsckPersist":
+		// Run before any agent depends on /persist, so a corrupted
+		// filesystem is repaired (or at least reported) up front.
+		persistDevice := ""
+		if len(flag.Args()) > 1 {
+			persistDevice = flag.Args()[1]
+		}
+		result := vault.RunPersistFsck(log, persistDevice)
+		log.Noticef("fsckPersist: %+v", result)
+		if result.Failed {
+			return 1
+		}
 	default:
 		log.Errorf("Unknown argument %s", flag.Args()[0])
 		return 1
@@ -9,15 +9,31 @@ import (
 	"os"
 
 	"github.com/lf-edge/edge-containers/pkg/registry"
+	zconfig "github.com/lf-edge/eve/api/go/config"
+	"github.com/lf-edge/eve/pkg/pillar/blockvol"
 	"github.com/lf-edge/eve/pkg/pillar/cas"
+	"github.com/lf-edge/eve/pkg/pillar/containerd"
 	"github.com/lf-edge/eve/pkg/pillar/diskmetrics"
 	"github.com/lf-edge/eve/pkg/pillar/types"
+	"github.com/lf-edge/eve/pkg/pillar/utils"
 )
 
 // createVolume does not update status but returns
 // new values for VolumeCreated, FileLocation, and error
 func createVolume(ctx *volumemgrContext, status types.VolumeStatus) (bool, string, error) {
 
+	if status.IsPhysicalDevice() {
+		log.Infof("createVolume(%s) as raw passthrough of %s", status.Key(), status.PhysicalDevicePath)
+		return createPhysicalVolume(ctx, status)
+	}
+	if status.IsHostDirShare() {
+		log.Infof("createVolume(%s) as virtiofs share of %s", status.Key(), status.HostDirSharePath)
+		return createHostDirShareVolume(ctx, status)
+	}
+	if status.ClonedFromKey != "" {
+		log.Infof("createVolume(%s) as clone of %s", status.Key(), status.ClonedFromKey)
+		return cloneVolume(ctx, status)
+	}
 	if status.IsContainer() {
 		log.Infof("createVolume(%s) from container %s", status.Key(), status.ReferenceName)
 		return createContainerVolume(ctx, status, status.ReferenceName)
@@ -26,6 +42,46 @@ func createVolume(ctx *volumemgrContext, status types.VolumeStatus) (bool, strin
 	return createVdiskVolume(ctx, status, status.ReferenceName)
 }
 
+// createPhysicalVolume does not update status but returns new values for
+// VolumeCreated, FileLocation, and error. Unlike the other create*Volume
+// helpers it doesn't create anything on disk: it claims exclusive use of
+// status.PhysicalDevicePath (see blockvol.Claim) and points FileLocation
+// directly at it, so the hypervisor attaches the host's own block device
+// to the app instead of a backing file under VolumeDir.
+func createPhysicalVolume(ctx *volumemgrContext, status types.VolumeStatus) (bool, string, error) {
+	devicePath := status.PhysicalDevicePath
+	if isPersist, err := blockvol.IsPersistDevice(devicePath); err != nil {
+		return false, "", fmt.Errorf("createPhysicalVolume(%s): %v", status.Key(), err)
+	} else if isPersist {
+		return false, "", fmt.Errorf("createPhysicalVolume(%s): refusing to assign %s, it backs %s",
+			status.Key(), devicePath, types.PersistDir)
+	}
+	if err := blockvol.Claim(devicePath, status.Key()); err != nil {
+		return false, "", fmt.Errorf("createPhysicalVolume(%s): %v", status.Key(), err)
+	}
+	log.Infof("createPhysicalVolume(%s) DONE, claimed %s", status.Key(), devicePath)
+	return true, devicePath, nil
+}
+
+// createHostDirShareVolume does not update status but returns new values
+// for VolumeCreated, FileLocation, and error. Like createPhysicalVolume
+// it doesn't create anything on disk: it confirms
+// status.HostDirSharePath is a directory and points FileLocation
+// directly at it; domainmgr is responsible for starting the virtiofsd
+// instance (see pkg/pillar/virtiofsd) that actually exposes it to the app.
+func createHostDirShareVolume(ctx *volumemgrContext, status types.VolumeStatus) (bool, string, error) {
+	path := status.HostDirSharePath
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, "", fmt.Errorf("createHostDirShareVolume(%s): %v", status.Key(), err)
+	}
+	if !info.IsDir() {
+		return false, "", fmt.Errorf("createHostDirShareVolume(%s): %s is not a directory", status.Key(), path)
+	}
+	log.Infof("createHostDirShareVolume(%s) DONE, sharing %s", status.Key(), path)
+	return true, path, nil
+}
+
 // createVdiskVolume does not update status but returns
 // new values for VolumeCreated, FileLocation, and error
 func createVdiskVolume(ctx *volumemgrContext, status types.VolumeStatus,
@@ -90,6 +146,61 @@ func createVdiskVolume(ctx *volumemgrContext, status types.VolumeStatus,
 	return true, filelocation, nil
 }
 
+// cloneVolume duplicates the on-disk contents of the volume identified by
+// status.ClonedFromKey into status's own location, without touching the
+// content tree or downloader, so an app instance can be cloned locally
+// for blue/green style validation.
+func cloneVolume(ctx *volumemgrContext, status types.VolumeStatus) (bool, string, error) {
+
+	created := false
+	filelocation := status.PathName()
+
+	srcStatus := lookupVolumeStatus(ctx, status.ClonedFromKey)
+	if srcStatus == nil {
+		errStr := fmt.Sprintf("cloneVolume(%s): source volume %s not found",
+			status.Key(), status.ClonedFromKey)
+		log.Error(errStr)
+		return created, filelocation, errors.New(errStr)
+	}
+	if !srcStatus.VolumeCreated {
+		errStr := fmt.Sprintf("cloneVolume(%s): source volume %s not yet created",
+			status.Key(), status.ClonedFromKey)
+		log.Error(errStr)
+		return created, filelocation, errors.New(errStr)
+	}
+
+	if status.IsContainer() {
+		if err := utils.CopyDir(srcStatus.FileLocation, filelocation); err != nil {
+			errStr := fmt.Sprintf("cloneVolume(%s): copying container rootdir from %s: %v",
+				status.Key(), srcStatus.FileLocation, err)
+			log.Error(errStr)
+			return created, filelocation, errors.New(errStr)
+		}
+	} else if status.ContentFormat == zconfig.Format_QCOW || status.ContentFormat == zconfig.Format_QCOW2 {
+		// Qcow2 supports copy-on-write backing files, so a clone can
+		// start out as a thin overlay of the source volume's own image
+		// instead of a full copy - the common case of validating a new
+		// app instance version against a copy of production data no
+		// longer pays for a full duplicate up front. checkAndFlattenBackingChains
+		// keeps the resulting chain from growing unbounded across repeated clones.
+		if err := diskmetrics.CreateOverlay(log, srcStatus.FileLocation, "qcow2", filelocation); err != nil {
+			errStr := fmt.Sprintf("cloneVolume(%s): creating qcow2 overlay of %s: %v",
+				status.Key(), srcStatus.FileLocation, err)
+			log.Error(errStr)
+			return created, filelocation, errors.New(errStr)
+		}
+	} else {
+		if err := diskmetrics.CloneImage(log, srcStatus.FileLocation, filelocation); err != nil {
+			errStr := fmt.Sprintf("cloneVolume(%s): cloning disk image from %s: %v",
+				status.Key(), srcStatus.FileLocation, err)
+			log.Error(errStr)
+			return created, filelocation, errors.New(errStr)
+		}
+	}
+	log.Infof("cloneVolume(%s) DONE from %s", status.Key(), status.ClonedFromKey)
+	return true, filelocation, nil
+}
+
 // createContainerVolume does not update status but returns
 // new values for VolumeCreated, FileLocation, and error
 func createContainerVolume(ctx *volumemgrContext, status types.VolumeStatus,
@@ -113,10 +224,26 @@ func createContainerVolume(ctx *volumemgrContext, status types.VolumeStatus,
 		log.Errorf(err.Error())
 		return created, filelocation, err
 	}
-	if err := ctx.casClient.PrepareContainerRootDir(filelocation, ref, checkAndCorrectBlobHash(rootBlobStatus.Sha256)); err != nil {
+	rootBlobSha := checkAndCorrectBlobHash(rootBlobStatus.Sha256)
+	if ctx.globalConfig.GlobalValueBool(types.LazyPullImages) {
+		if err := ctx.casClient.PrepareContainerRootDir(filelocation, ref, rootBlobSha, containerd.StargzSnapshotter); err == nil {
+			log.Infof("createContainerVolume(%s) DONE using lazy pull", status.Key())
+			return true, filelocation, nil
+		} else {
+			log.Warnf("createContainerVolume(%s): lazy pull via %s failed, falling back to regular pull: %s",
+				status.Key(), containerd.StargzSnapshotter, err)
+		}
+	}
+	snapshotter := ctx.globalConfig.GlobalValueString(types.StorageSnapshotterBackend)
+	if err := ctx.casClient.PrepareContainerRootDir(filelocation, ref, rootBlobSha, snapshotter); err != nil {
 		log.Errorf("Failed to create ctr bundle. Error %s", err)
 		return created, filelocation, err
 	}
+	// Best-effort SBOM collection; a failure here should not fail volume
+	// creation since vulnerability scanning is informational.
+	if err := cas.WritePackageManifest(filelocation); err != nil {
+		log.Warnf("createContainerVolume(%s): failed to write package manifest: %s", status.Key(), err)
+	}
 	log.Infof("createContainerVolume(%s) DONE", status.Key())
 	return true, filelocation, nil
 }
@@ -126,6 +253,16 @@ func createContainerVolume(ctx *volumemgrContext, status types.VolumeStatus,
 func destroyVolume(ctx *volumemgrContext, status types.VolumeStatus) (bool, string, error) {
 
 	log.Infof("destroyVolume(%s)", status.Key())
+	if status.IsPhysicalDevice() {
+		blockvol.Release(status.PhysicalDevicePath, status.Key())
+		log.Infof("destroyVolume(%s) released claim on %s", status.Key(), status.PhysicalDevicePath)
+		return false, "", nil
+	}
+	if status.IsHostDirShare() {
+		log.Infof("destroyVolume(%s) nothing to clean up for virtiofs share %s", status.Key(), status.HostDirSharePath)
+		return false, "", nil
+	}
+
 	if !status.VolumeCreated {
 		log.Infof("destroyVolume(%s) nothing was created", status.Key())
 		return false, status.FileLocation, nil
@@ -173,7 +310,7 @@ func destroyContainerVolume(ctx *volumemgrContext, status types.VolumeStatus) (b
 	created := status.VolumeCreated
 	filelocation := status.FileLocation
 	log.Infof("Removing container volume %s", filelocation)
-	if err := ctx.casClient.RemoveContainerRootDir(filelocation); err != nil {
+	if err := ctx.casClient.RemoveContainerRootDir(filelocation, ""); err != nil {
 		return created, filelocation, err
 	}
 	filelocation = ""
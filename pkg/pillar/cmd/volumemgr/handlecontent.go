@@ -6,11 +6,13 @@ package volumemgr
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	v1types "github.com/google/go-containerregistry/pkg/v1/types"
 	zconfig "github.com/lf-edge/eve/api/go/config"
 	"github.com/lf-edge/eve/pkg/pillar/types"
 	"github.com/lf-edge/eve/pkg/pillar/utils"
+	uuid "github.com/satori/go.uuid"
 )
 
 func handleContentTreeCreateAppImg(ctxArg interface{}, key string,
@@ -304,8 +306,38 @@ func updateContentTree(ctx *volumemgrContext, status *types.ContentTreeStatus) {
 	log.Infof("updateContentTree for %v Done", status.ContentID)
 }
 
+// volumesReferencingContentID returns the keys of any published VolumeStatus
+// still pointing at contentID, so deleteContentTree can refuse to GC a
+// content tree out from under a volume that is still extracting it.
+func volumesReferencingContentID(ctx *volumemgrContext, contentID uuid.UUID) []string {
+	var blockedBy []string
+	for _, st := range ctx.pubVolumeStatus.GetAll() {
+		vs := st.(types.VolumeStatus)
+		if vs.ContentID == contentID {
+			blockedBy = append(blockedBy, vs.Key())
+		}
+	}
+	return blockedBy
+}
+
+// deleteContentTree removes status and the blobs/image it holds a reference
+// to. If any volume still references status.ContentID, the delete is
+// refused: status is left published with a clear error describing what it
+// is blocked on, and ctx.pendingContentTreeDeletes records it so the gc
+// ticker can retry once those volumes are gone, rather than silently
+// GC'ing content out from under an in-progress volume (or leaving a
+// dangling reference behind).
 func deleteContentTree(ctx *volumemgrContext, status *types.ContentTreeStatus) {
 	log.Infof("deleteContentTree for %v", status.ContentID)
+	if blockedBy := volumesReferencingContentID(ctx, status.ContentID); len(blockedBy) != 0 {
+		errStr := fmt.Sprintf("deleteContentTree: refusing to delete content tree %s,"+
+			" still referenced by volume(s) %v", status.ContentID, blockedBy)
+		log.Warnf(errStr)
+		status.SetError(errStr, time.Now())
+		publishContentTreeStatus(ctx, status)
+		addPendingContentTreeDelete(ctx, status)
+		return
+	}
 	RemoveAllBlobsFromContentTreeStatus(ctx, status, status.Blobs...)
 	//We create a reference when we load the blobs. We should remove that reference when we delete the contentTree.
 	if err := ctx.casClient.RemoveImage(status.ReferenceID()); err != nil {
@@ -314,5 +346,36 @@ func deleteContentTree(ctx *volumemgrContext, status *types.ContentTreeStatus) {
 	}
 	unpublishContentTreeStatus(ctx, status)
 	deleteLatchContentTreeHash(ctx, status.ContentID, uint32(status.GenerationCounter))
+	removePendingContentTreeDelete(ctx, status.Key())
 	log.Infof("deleteContentTree for %v Done", status.ContentID)
 }
+
+// addPendingContentTreeDelete records that status (identified by key and
+// ObjType, since the same ContentID key space is shared by AppImgObj and
+// BaseOsObj) still needs its blocked-delete retried by retryContentTreeDeletes.
+func addPendingContentTreeDelete(ctx *volumemgrContext, status *types.ContentTreeStatus) {
+	if ctx.pendingContentTreeDeletes == nil {
+		ctx.pendingContentTreeDeletes = make(map[string]string)
+	}
+	ctx.pendingContentTreeDeletes[status.Key()] = status.ObjType
+}
+
+func removePendingContentTreeDelete(ctx *volumemgrContext, key string) {
+	delete(ctx.pendingContentTreeDeletes, key)
+}
+
+// retryContentTreeDeletes re-attempts deleteContentTree for every content
+// tree that was previously refused because a volume still referenced it.
+// Called from the gc ticker so a blocked delete completes shortly after its
+// last referencing volume goes away, instead of leaving the content tree
+// (and the disk space/blobs it holds) stuck forever.
+func retryContentTreeDeletes(ctx *volumemgrContext) {
+	for key, objType := range ctx.pendingContentTreeDeletes {
+		status := lookupContentTreeStatus(ctx, key, objType)
+		if status == nil {
+			removePendingContentTreeDelete(ctx, key)
+			continue
+		}
+		deleteContentTree(ctx, status)
+	}
+}
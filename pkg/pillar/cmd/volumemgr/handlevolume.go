@@ -34,6 +34,10 @@ func handleVolumeCreate(ctxArg interface{}, key string,
 		RefCount:                config.RefCount,
 		LastUse:                 time.Now(),
 		State:                   types.INITIAL,
+		ClonedFromKey:           config.ClonedFromKey,
+		PhysicalDevicePath:      config.PhysicalDevicePath,
+		HostDirSharePath:        config.HostDirSharePath,
+		HostDirShareCacheMode:   config.HostDirShareCacheMode,
 	}
 	updateVolumeStatusRefCount(ctx, status)
 	status.ContentFormat = volumeFormat[status.Key()]
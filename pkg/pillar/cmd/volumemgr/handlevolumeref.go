@@ -22,16 +22,18 @@ func handleVolumeRefCreate(ctxArg interface{}, key string,
 		updateVolumeStatusRefCount(ctx, vs)
 		publishVolumeStatus(ctx, vs)
 		status = &types.VolumeRefStatus{
-			VolumeID:           config.VolumeID,
-			GenerationCounter:  config.GenerationCounter,
-			RefCount:           config.RefCount,
-			MountDir:           config.MountDir,
-			State:              vs.State,
-			ActiveFileLocation: vs.FileLocation,
-			ContentFormat:      vs.ContentFormat,
-			ReadOnly:           vs.ReadOnly,
-			DisplayName:        vs.DisplayName,
-			MaxVolSize:         vs.MaxVolSize,
+			VolumeID:              config.VolumeID,
+			GenerationCounter:     config.GenerationCounter,
+			RefCount:              config.RefCount,
+			MountDir:              config.MountDir,
+			State:                 vs.State,
+			ActiveFileLocation:    vs.FileLocation,
+			ContentFormat:         vs.ContentFormat,
+			ReadOnly:              vs.ReadOnly,
+			DisplayName:           vs.DisplayName,
+			MaxVolSize:            vs.MaxVolSize,
+			HostDirSharePath:      vs.HostDirSharePath,
+			HostDirShareCacheMode: vs.HostDirShareCacheMode,
 		}
 		if vs.HasError() {
 			status.SetErrorWithSource(vs.Error, types.VolumeStatus{}, vs.ErrorTime)
@@ -149,6 +151,8 @@ func updateVolumeRefStatus(ctx *volumemgrContext, vs *types.VolumeStatus) {
 				status.ReadOnly = vs.ReadOnly
 				status.DisplayName = vs.DisplayName
 				status.MaxVolSize = vs.MaxVolSize
+				status.HostDirSharePath = vs.HostDirSharePath
+				status.HostDirShareCacheMode = vs.HostDirShareCacheMode
 				if vs.HasError() {
 					status.SetErrorWithSource(vs.Error, types.VolumeStatus{}, vs.ErrorTime)
 				} else if status.IsErrorSource(types.VolumeStatus{}) {
@@ -158,16 +162,18 @@ func updateVolumeRefStatus(ctx *volumemgrContext, vs *types.VolumeStatus) {
 				return
 			}
 			status = &types.VolumeRefStatus{
-				VolumeID:           config.VolumeID,
-				GenerationCounter:  config.GenerationCounter,
-				RefCount:           config.RefCount,
-				MountDir:           config.MountDir,
-				State:              vs.State,
-				ActiveFileLocation: vs.FileLocation,
-				ContentFormat:      vs.ContentFormat,
-				ReadOnly:           vs.ReadOnly,
-				DisplayName:        vs.DisplayName,
-				MaxVolSize:         vs.MaxVolSize,
+				VolumeID:              config.VolumeID,
+				GenerationCounter:     config.GenerationCounter,
+				RefCount:              config.RefCount,
+				MountDir:              config.MountDir,
+				State:                 vs.State,
+				ActiveFileLocation:    vs.FileLocation,
+				ContentFormat:         vs.ContentFormat,
+				ReadOnly:              vs.ReadOnly,
+				DisplayName:           vs.DisplayName,
+				MaxVolSize:            vs.MaxVolSize,
+				HostDirSharePath:      vs.HostDirSharePath,
+				HostDirShareCacheMode: vs.HostDirShareCacheMode,
 			}
 			if vs.HasError() {
 				status.SetErrorWithSource(vs.Error, types.VolumeStatus{}, vs.ErrorTime)
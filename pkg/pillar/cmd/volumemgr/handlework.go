@@ -16,10 +16,12 @@ import (
 // InitHandleWork returns an object with a MsgChan to be used in the main select loop
 // When something is received on that channel the select loop should call HandleWorkResult
 func InitHandleWork(ctx *volumemgrContext) *worker.Worker {
-	// A small channel depth; work will be processed as FIFO
-	// XXX a worker pool might make sense to avoid smaller jobs blocked
-	// behind larger jobs
-	worker := worker.NewWorker(log, volumemgrWorker, ctx, 5)
+	// Concurrency is read once, from the default ConfigItemValueMap, since
+	// ctx.globalConfig isn't populated from the controller yet this early
+	// in startup; types.VolumeCreateConcurrency bounds how many volumes
+	// are created/destroyed in parallel so boot doesn't serialize on them.
+	concurrency := int(ctx.globalConfig.GlobalValueInt(types.VolumeCreateConcurrency))
+	worker := worker.NewWorkerPool(log, volumemgrWorker, ctx, concurrency, 5)
 	return worker
 }
 
@@ -349,6 +351,17 @@ func casIngestWorker(ctxPtr interface{}, w worker.Work) worker.WorkResult {
 		}
 	}
 
+	// Refuse to create the image if device policy requires signed images
+	// and root's signature does not verify.
+	if err := verifyImageSignature(ctx, root); err != nil {
+		return worker.WorkResult{
+			Key:         w.Key,
+			Description: d,
+			Error:       err,
+			ErrorTime:   time.Now(),
+		}
+	}
+
 	// load the blobs
 	loadedBlobs, err := ctx.casClient.IngestBlobsAndCreateImage(status.ReferenceID(), *root, loadBlobs...)
 	// loadedBlobs are BlobStatus for the ones we loaded; publicize their new states.
@@ -0,0 +1,96 @@
+// Copyright (c) 2026 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package volumemgr
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/lf-edge/eve/pkg/pillar/imgverify"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// verifyImageSignature enforces types.RequireSignedImages: it looks for a
+// detached signature alongside root's already-downloaded-and-verified
+// content, at root.Path+".sig", and checks it against the trust anchors
+// provisioned under types.ImageSignTrustAnchorDirname. Returns nil (no
+// enforcement) if RequireSignedImages is false.
+//
+// NOTE on scope: a datastore that wants to serve a signature has nowhere
+// else to put it today -- ContentTreeConfig/BlobStatus have no signature
+// field, because the controller's config protocol (api/proto/config)
+// would need a new field and a regeneration of its generated Go code to
+// carry one, which this change does not attempt. So this only verifies a
+// signature file a datastore's fetch already happened to place next to
+// the content locally; actually having the downloader fetch one from the
+// datastore is a separate, larger change.
+func verifyImageSignature(ctx *volumemgrContext, root *types.BlobStatus) error {
+	if !ctx.globalConfig.GlobalValueBool(types.RequireSignedImages) {
+		return nil
+	}
+	anchors, err := imgverify.LoadTrustAnchors(types.ImageSignTrustAnchorDirname)
+	if err != nil {
+		return fmt.Errorf("verifyImageSignature(%s): %v", root.Sha256, err)
+	}
+	sigPath := root.Path + ".sig"
+	sig, err := ioutil.ReadFile(sigPath)
+	if err != nil {
+		return fmt.Errorf("verifyImageSignature(%s): RequireSignedImages is set but no signature found at %s: %v",
+			root.Sha256, sigPath, err)
+	}
+	digest, err := hashFile(root.Path)
+	if err != nil {
+		return fmt.Errorf("verifyImageSignature(%s): reading %s: %v", root.Sha256, root.Path, err)
+	}
+	keyID, err := imgverify.VerifyDigestSignature(digest, sig, anchors)
+	if err != nil {
+		return fmt.Errorf("verifyImageSignature(%s): %v", root.Sha256, err)
+	}
+	log.Noticef("verifyImageSignature(%s): verified against trust anchor %s", root.Sha256, keyID)
+	return nil
+}
+
+// hashFile returns the sha256 digest of the file at path, streaming it
+// through the hash rather than reading it into memory all at once -- root
+// can be a multi-GB VM or container image, and EVE targets edge gateways
+// with as little as 1-4GB of RAM, so buffering the whole blob here risks
+// OOMing volumemgr.
+func hashFile(path string) ([32]byte, error) {
+	var digest [32]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return digest, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return digest, err
+	}
+	copy(digest[:], h.Sum(nil))
+	return digest, nil
+}
+
+// warnIfNoTrustAnchors loudly logs when RequireSignedImages is being
+// turned on but types.ImageSignTrustAnchorDirname has no trust anchors
+// provisioned: every image creation will then fail verifyImageSignature
+// and every app install will fail, since nothing on this device can ever
+// fetch or write the detached signature verifyImageSignature expects
+// (see its doc comment) or verify one against an empty anchor set.
+func warnIfNoTrustAnchors() {
+	anchors, err := imgverify.LoadTrustAnchors(types.ImageSignTrustAnchorDirname)
+	if err != nil {
+		log.Errorf("RequireSignedImages was just enabled but trust anchors at %s "+
+			"could not be loaded: %v; every image creation will fail until this is fixed",
+			types.ImageSignTrustAnchorDirname, err)
+		return
+	}
+	if len(anchors) == 0 {
+		log.Errorf("RequireSignedImages was just enabled but %s has no trust anchors "+
+			"provisioned; every image creation will fail until at least one is added",
+			types.ImageSignTrustAnchorDirname)
+	}
+}
@@ -64,7 +64,7 @@ func gcObjects(ctx *volumemgrContext, dirName string) {
 			log.Infof("gcObjects: Found unused volume %s. Deleting it.",
 				filelocation)
 			if format == "CONTAINER" {
-				_ = ctx.casClient.RemoveContainerRootDir(filelocation)
+				_ = ctx.casClient.RemoveContainerRootDir(filelocation, "")
 			}
 			deleteFile(filelocation)
 		}
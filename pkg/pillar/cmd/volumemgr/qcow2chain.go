@@ -0,0 +1,59 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package volumemgr
+
+import (
+	zconfig "github.com/lf-edge/eve/api/go/config"
+	"github.com/lf-edge/eve/pkg/pillar/diskmetrics"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// checkAndFlattenBackingChains refreshes each qcow2 volume's backing chain
+// depth and shared/unique byte accounting (see cloneVolume's use of
+// diskmetrics.CreateOverlay), and flattens any chain that has grown past
+// diskmetrics.MaxBackingChainDepth through repeated clones. It runs on the
+// same goroutine as the rest of the volumemgr state machine (see the
+// ctx.gc.C case in Run), so it is safe to publish status directly.
+func checkAndFlattenBackingChains(ctx *volumemgrContext) {
+	for _, status := range getAllVolumeStatus(ctx) {
+		maybeFlattenBackingChain(ctx, status)
+	}
+}
+
+func maybeFlattenBackingChain(ctx *volumemgrContext, status *types.VolumeStatus) {
+	if !status.VolumeCreated || status.FileLocation == "" {
+		return
+	}
+	if status.ContentFormat != zconfig.Format_QCOW && status.ContentFormat != zconfig.Format_QCOW2 {
+		return
+	}
+	depth, err := diskmetrics.BackingChainLength(log, status.FileLocation)
+	if err != nil {
+		log.Errorf("maybeFlattenBackingChain(%s): %s", status.Key(), err)
+		return
+	}
+	if depth > diskmetrics.MaxBackingChainDepth {
+		log.Noticef("maybeFlattenBackingChain(%s): chain depth %d exceeds limit %d, flattening %s",
+			status.Key(), depth, diskmetrics.MaxBackingChainDepth, status.FileLocation)
+		if err := diskmetrics.FlattenChain(log, status.FileLocation); err != nil {
+			log.Errorf("maybeFlattenBackingChain(%s): flatten failed: %s", status.Key(), err)
+			return
+		}
+		depth = 1
+	}
+
+	unique, shared, err := diskmetrics.ChainUsage(log, status.FileLocation)
+	if err != nil {
+		log.Errorf("maybeFlattenBackingChain(%s): usage accounting failed: %s", status.Key(), err)
+		return
+	}
+	chainDepth := depth - 1
+	if status.BackingChainDepth == chainDepth && status.UniqueBytes == unique && status.SharedBytes == shared {
+		return
+	}
+	status.BackingChainDepth = chainDepth
+	status.UniqueBytes = unique
+	status.SharedBytes = shared
+	publishVolumeStatus(ctx, status)
+}
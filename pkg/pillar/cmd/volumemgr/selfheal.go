@@ -0,0 +1,77 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package volumemgr
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	zconfig "github.com/lf-edge/eve/api/go/config"
+	"github.com/lf-edge/eve/pkg/pillar/diskmetrics"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// checkAndRepairVolumes scans created vdisk volumes for on-disk corruption
+// (qcow2 check failures) and, when the content tree they were created
+// from is still present and verified, transparently recreates them from
+// it rather than leaving the app instance stuck in a permanent error
+// state. It runs on the same goroutine as the rest of the volumemgr state
+// machine (see the ctx.gc.C case in Run), so it is safe to call
+// MaybeAddWorkCreate directly.
+func checkAndRepairVolumes(ctx *volumemgrContext) {
+	for _, status := range getAllVolumeStatus(ctx) {
+		maybeRepairVolume(ctx, status)
+	}
+}
+
+// maybeRepairVolume checks a single volume and, if it is found corrupted
+// and repairable, kicks off a fresh extraction from the content tree.
+func maybeRepairVolume(ctx *volumemgrContext, status *types.VolumeStatus) {
+	if !status.VolumeCreated || status.FileLocation == "" || status.ClonedFromKey != "" {
+		return
+	}
+	// qemu-img check only meaningfully covers the qcow family; other
+	// formats (raw, ISO, container) are left to the verifier's content
+	// hash check, which already catches corruption for them.
+	if status.ContentFormat != zconfig.Format_QCOW && status.ContentFormat != zconfig.Format_QCOW2 {
+		return
+	}
+	checked, corrupted, err := diskmetrics.CheckImg(log, status.FileLocation)
+	if err != nil && !checked {
+		log.Errorf("maybeRepairVolume(%s): %s", status.Key(), err)
+		return
+	}
+	if !checked || !corrupted {
+		return
+	}
+	log.Errorf("maybeRepairVolume(%s): corruption detected in %s: %s",
+		status.Key(), status.FileLocation, err)
+
+	ctStatus := lookupContentTreeStatusAny(ctx, status.ContentID.String())
+	if ctStatus == nil || ctStatus.HasError() || ctStatus.State < types.LOADED {
+		errStr := fmt.Sprintf("volume %s backing file is corrupted and content tree %s is not available to repair from: %s",
+			status.Key(), status.ContentID.String(), err)
+		log.Error(errStr)
+		status.SetErrorWithSource(errStr, types.VolumeStatus{}, time.Now())
+		publishVolumeStatus(ctx, status)
+		return
+	}
+
+	log.Noticef("maybeRepairVolume(%s): recreating from content tree %s blobs",
+		status.Key(), ctStatus.DisplayName)
+	if removeErr := os.RemoveAll(status.FileLocation); removeErr != nil {
+		log.Errorf("maybeRepairVolume(%s): failed to remove corrupted file %s: %s",
+			status.Key(), status.FileLocation, removeErr)
+		return
+	}
+	status.VolumeCreated = false
+	status.FileLocation = ""
+	status.RepairCount++
+	status.LastRepairTime = time.Now()
+	status.ClearErrorWithSource()
+	publishVolumeStatus(ctx, status)
+
+	MaybeAddWorkCreate(ctx, status)
+}
@@ -214,6 +214,11 @@ func doUpdateContentTree(ctx *volumemgrContext, status *types.ContentTreeStatus)
 			log.Infof("doUpdateContentTree: updating CurrentSize/TotalSize/Progress %d/%d/%d",
 				currentSize, totalSize, status.Progress)
 		}
+		if status.CurrentSize > 0 && status.ProgressStartTime.IsZero() {
+			status.ProgressStartTime = time.Now()
+		}
+		status.ProgressDetail = types.NewProgress(status.State.String(), status.CurrentSize,
+			status.TotalSize, status.ProgressStartTime, status.State < types.VERIFIED)
 
 		rootBlob := lookupOrCreateBlobStatus(ctx, status.Blobs[0])
 		if rootBlob == nil {
@@ -37,6 +37,12 @@ const (
 	errorTime     = 3 * time.Minute
 	warningTime   = 40 * time.Second
 	casClientType = "containerd"
+	// staleLeaseThreshold bounds how old an unreferenced containerd
+	// lease has to be before pruneStaleLeases treats it as abandoned
+	// rather than an in-progress download. Matched to containerd's own
+	// default lease TTL, since a lease still younger than that could
+	// legitimately belong to a download still running.
+	staleLeaseThreshold = 24 * time.Hour
 )
 
 // Set from Makefile
@@ -68,10 +74,17 @@ type volumemgrContext struct {
 	pubBlobStatus           pubsub.Publication
 	pubDiskMetric           pubsub.Publication
 	pubAppDiskMetric        pubsub.Publication
+	pubContainerdMetrics    pubsub.Publication
 	subDatastoreConfig      pubsub.Subscription
 	diskMetricsTickerHandle interface{}
 	gc                      *time.Ticker
 
+	// pendingContentTreeDeletes maps a ContentTreeStatus key to its ObjType,
+	// for content trees whose delete was refused by deleteContentTree
+	// because a volume still referenced them; retried by
+	// retryContentTreeDeletes on the gc ticker.
+	pendingContentTreeDeletes map[string]string
+
 	worker *worker.Worker // For background work
 
 	verifierRestarted    bool // Wait for verifier to restart
@@ -94,6 +107,23 @@ var debugOverride bool // From command line arg
 var logger *logrus.Logger
 var log *base.LogObject
 
+// pruneStaleLeases deletes containerd leases older than staleLeaseThreshold
+// that aren't pinning anything reachable from a current image, e.g. one an
+// interrupted download left behind across a reboot, and logs the bytes
+// reclaimed. Run once at startup, before anything else touches the content
+// store, so an old lease's ingest isn't mistaken for a download still in
+// flight.
+func pruneStaleLeases(ctx *volumemgrContext) {
+	removed, reclaimedBytes, err := ctx.casClient.PruneStaleLeases(staleLeaseThreshold, false)
+	if err != nil {
+		log.Errorf("pruneStaleLeases: %s", err)
+	}
+	if len(removed) > 0 {
+		log.Noticef("pruneStaleLeases: removed %d stale lease(s), reclaimed %d bytes",
+			len(removed), reclaimedBytes)
+	}
+}
+
 // Run - the main function invoked by zedbox
 func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) int {
 	logger = loggerArg
@@ -261,6 +291,17 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 	}
 	ctx.pubAppDiskMetric = pubAppDiskMetric
 
+	pubContainerdMetrics, err := ps.NewPublication(
+		pubsub.PublicationOptions{
+			AgentName: agentName,
+			TopicType: types.ContainerdMetrics{},
+		},
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx.pubContainerdMetrics = pubContainerdMetrics
+
 	// Look for global config such as log levels
 	subZedAgentStatus, err := ps.NewSubscription(pubsub.SubscriptionOptions{
 		AgentName:     "zedagent",
@@ -454,6 +495,8 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 	//casClient which is commonly used across volumemgr will be closed when volumemgr exits.
 	defer ctx.casClient.CloseClient()
 
+	pruneStaleLeases(&ctx)
+
 	populateInitBlobStatus(&ctx)
 
 	// First we process the verifierStatus to avoid triggering a download
@@ -544,6 +587,12 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 				gcUnusedInitObjects(&ctx)
 				ctx.initGced = true
 			}
+			checkAndRepairVolumes(&ctx)
+			checkAndFlattenBackingChains(&ctx)
+			retryContentTreeDeletes(&ctx)
+			if err := ctx.pubContainerdMetrics.Publish("global", ctx.casClient.GetMetrics()); err != nil {
+				log.Errorln(err)
+			}
 			ps.CheckMaxTimeTopic(agentName, "gc", start,
 				warningTime, errorTime)
 
@@ -625,6 +674,11 @@ func maybeUpdateConfigItems(ctx *volumemgrContext, newConfigItemValueMap *types.
 	log.Infof("maybeUpdateConfigItems")
 	oldConfigItemValueMap := ctx.globalConfig
 
+	if newConfigItemValueMap.GlobalValueBool(types.RequireSignedImages) &&
+		!oldConfigItemValueMap.GlobalValueBool(types.RequireSignedImages) {
+		warnIfNoTrustAnchors()
+	}
+
 	if newConfigItemValueMap.GlobalValueInt(types.VdiskGCTime) != 0 &&
 		newConfigItemValueMap.GlobalValueInt(types.VdiskGCTime) !=
 			oldConfigItemValueMap.GlobalValueInt(types.VdiskGCTime) {
@@ -0,0 +1,106 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package zedagent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	zconfig "github.com/lf-edge/eve/api/go/config"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// configSequenceDirname holds one sanitized EdgeDevConfig proto per config
+// change accepted by inhaleDeviceConfig, named by a zero-padded sequence
+// number, recorded when debug.config.record.enable is set.
+// replayConfigSequence reads a directory like this back, in order, to
+// reproduce a controller-triggered device bug without the original
+// controller.
+const configSequenceDirname = checkpointDirname + "/configsequence"
+
+// sanitizeConfigForRecording returns a copy of config with fields that
+// exist only to carry secrets cleared, so a recorded sequence can be
+// committed to a test fixture without leaking them. Everything else --
+// app, network and device settings -- is left intact, since reproducing a
+// controller-triggered bug depends on it.
+func sanitizeConfigForRecording(config *zconfig.EdgeDevConfig) *zconfig.EdgeDevConfig {
+	sanitized := proto.Clone(config).(*zconfig.EdgeDevConfig)
+	sanitized.CipherContexts = nil
+	return sanitized
+}
+
+// maybeRecordConfigSequence appends config to configSequenceDirname as the
+// next entry in the recorded sequence, if debug.config.record.enable is
+// set. getconfigCtx.configSeqNum only counts entries written this run; the
+// sequence on disk is not truncated across a zedagent restart.
+func maybeRecordConfigSequence(getconfigCtx *getconfigContext, config *zconfig.EdgeDevConfig) {
+	if !getconfigCtx.zedagentCtx.globalConfig.GlobalValueBool(types.RecordConfigSequence) {
+		return
+	}
+	if err := os.MkdirAll(configSequenceDirname, 0755); err != nil {
+		log.Errorf("maybeRecordConfigSequence: %v", err)
+		return
+	}
+	contents, err := proto.Marshal(sanitizeConfigForRecording(config))
+	if err != nil {
+		log.Errorf("maybeRecordConfigSequence: %v", err)
+		return
+	}
+	filename := filepath.Join(configSequenceDirname,
+		fmt.Sprintf("%08d.config", getconfigCtx.configSeqNum))
+	if err := ioutil.WriteFile(filename, contents, 0644); err != nil {
+		log.Errorf("maybeRecordConfigSequence: %v", err)
+		return
+	}
+	getconfigCtx.configSeqNum++
+}
+
+// loadConfigSequence reads back a sequence recorded by
+// maybeRecordConfigSequence (or an equivalent hand-built test fixture) from
+// dir, in filename order.
+func loadConfigSequence(dir string) ([]*zconfig.EdgeDevConfig, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	configs := make([]*zconfig.EdgeDevConfig, 0, len(names))
+	for _, name := range names {
+		contents, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("loadConfigSequence: %s: %v", name, err)
+		}
+		config := &zconfig.EdgeDevConfig{}
+		if err := proto.Unmarshal(contents, config); err != nil {
+			return nil, fmt.Errorf("loadConfigSequence: %s: %v", name, err)
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// replayConfigSequence feeds each config recorded under dir through
+// inhaleDeviceConfig, in the order they were recorded, so a test build can
+// reproduce a controller-triggered device bug without the original
+// controller. Returns the number of configs replayed.
+func replayConfigSequence(getconfigCtx *getconfigContext, dir string) (int, error) {
+	configs, err := loadConfigSequence(dir)
+	if err != nil {
+		return 0, err
+	}
+	for _, config := range configs {
+		inhaleDeviceConfig(config, getconfigCtx, false)
+	}
+	return len(configs), nil
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package zedagent
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	zconfig "github.com/lf-edge/eve/api/go/config"
+)
+
+func TestSanitizeConfigForRecordingClearsCipherContexts(t *testing.T) {
+	config := &zconfig.EdgeDevConfig{
+		Name:           "mydevice",
+		CipherContexts: []*zconfig.CipherContext{{ContextId: "secret"}},
+	}
+	sanitized := sanitizeConfigForRecording(config)
+	if sanitized.CipherContexts != nil {
+		t.Errorf("expected CipherContexts to be cleared, got %v", sanitized.CipherContexts)
+	}
+	if sanitized.Name != config.Name {
+		t.Errorf("expected Name to be preserved, got %q want %q", sanitized.Name, config.Name)
+	}
+	if config.CipherContexts == nil {
+		t.Errorf("sanitizeConfigForRecording should not mutate its input")
+	}
+}
+
+func TestLoadConfigSequenceRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configsequence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	want := []*zconfig.EdgeDevConfig{
+		{Name: "first"},
+		{Name: "second"},
+		{Name: "third"},
+	}
+	for i, config := range want {
+		contents, err := proto.Marshal(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filename := dir + "/" + []string{"00", "01", "02"}[i] + ".config"
+		if err := ioutil.WriteFile(filename, contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := loadConfigSequence(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d configs, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name {
+			t.Errorf("entry %d: got Name %q, want %q", i, got[i].Name, want[i].Name)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package zedagent
+
+import (
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// uplinkCongestionLatencyMsec is the probe latency to the remote endpoint
+// (types.ProbeIntfMetrics.LatencyToRemote, populated by zedrouter's network
+// instance probing) above which a management uplink is considered
+// congested. Bulk, deferrable traffic like flow logs is held back above
+// this threshold so it doesn't compete with config polls and info/metrics
+// reports for what bandwidth a thin uplink has.
+const uplinkCongestionLatencyMsec = 1000
+
+// flowUploadStats tracks how sendFlowProtobuf's queue has behaved, so it
+// can be reported alongside the regular zedcloud metrics.
+type flowUploadStats struct {
+	deferredForCongestion uint64
+}
+
+var flowStats flowUploadStats
+
+// uplinkCongested reports whether any network instance's current uplink is
+// probing a latency to its remote endpoint above uplinkCongestionLatencyMsec.
+// It reuses the same NetworkInstanceMetrics zedrouter already publishes and
+// createNetworkInstanceMetrics already reports to zedcloud, rather than
+// adding a second RTT probe.
+func uplinkCongested(ctx *zedagentContext) bool {
+	sub := ctx.subNetworkInstanceMetrics
+	for _, met := range sub.GetAll() {
+		metrics := met.(types.NetworkInstanceMetrics)
+		for _, intf := range metrics.ProbeMetrics.IntfProbeStats {
+			if intf.LatencyToRemote > uplinkCongestionLatencyMsec {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package zedagent
+
+import (
+	"io/ioutil"
+
+	"github.com/lf-edge/eve/pkg/pillar/coredump"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+const (
+	corePatternFile    = "/proc/sys/kernel/core_pattern"
+	defaultCorePattern = "core"
+	// coredumpHelperPattern pipes crashing processes' core images to
+	// the coredump entrypoint (see cmd/coredump); %e/%p/%t/%E are
+	// standard core_pattern specifiers for comm, pid, timestamp, and
+	// executable path (with '/' replaced by '!').
+	coredumpHelperPattern = "|/opt/zededa/bin/coredump %e %p %t %E"
+)
+
+// configureCoreDumps mirrors the current GlobalConfig core-dump
+// settings out to types.CoreDumpConfigFile for the coredump
+// entrypoint to read, and points (or un-points) the kernel's
+// core_pattern at that entrypoint depending on whether either capture
+// scope is enabled.
+func configureCoreDumps(ctx *zedagentContext) {
+	cfg := coredump.Config{
+		AgentsEnabled: ctx.globalConfig.GlobalValueBool(types.CoreDumpAgentsEnable),
+		AppsEnabled:   ctx.globalConfig.GlobalValueBool(types.CoreDumpAppsEnable),
+		Compress:      ctx.globalConfig.GlobalValueBool(types.CoreDumpCompress),
+		QuotaBytes:    uint64(ctx.globalConfig.GlobalValueInt(types.CoreDumpQuotaBytes)),
+	}
+	if err := coredump.WriteConfig(types.CoreDumpConfigFile, cfg); err != nil {
+		log.Errorf("configureCoreDumps: %s", err)
+	}
+
+	pattern := defaultCorePattern
+	if cfg.AgentsEnabled || cfg.AppsEnabled {
+		pattern = coredumpHelperPattern
+	}
+	if err := ioutil.WriteFile(corePatternFile, []byte(pattern), 0644); err != nil {
+		log.Errorf("configureCoreDumps: writing %s: %s", corePatternFile, err)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package zedagent
+
+import (
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/diagbeacon"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// maybeSendDiagBeacon sends a last-resort network diagnostics beacon if
+// the controller has been unreachable for at least
+// types.NetworkDiagBeaconInterval, and no beacon has been sent for that
+// same interval. It is called on every failed getLatestConfig attempt; it
+// is a no-op as long as NetworkDiagBeaconEndpoint is unconfigured, or
+// until the unreachability threshold is crossed.
+func maybeSendDiagBeacon(getconfigCtx *getconfigContext) {
+	globalConfig := getconfigCtx.zedagentCtx.globalConfig
+	endpoint := globalConfig.GlobalValueString(types.NetworkDiagBeaconEndpoint)
+	if endpoint == "" {
+		return
+	}
+	interval := time.Duration(globalConfig.GlobalValueInt(types.NetworkDiagBeaconInterval)) * time.Second
+	if interval == 0 {
+		return
+	}
+	now := time.Now()
+	// Never successfully reached the controller since boot: use process
+	// start as a conservative stand-in for "unreachable since".
+	unreachableSince := getconfigCtx.lastConfigSuccess
+	if unreachableSince.IsZero() {
+		unreachableSince = getconfigCtx.zedagentCtx.agentStartTime
+	}
+	if now.Sub(unreachableSince) < interval {
+		return
+	}
+	if !getconfigCtx.lastBeaconSent.IsZero() && now.Sub(getconfigCtx.lastBeaconSent) < interval {
+		return
+	}
+	getconfigCtx.lastBeaconSent = now
+
+	secret := globalConfig.GlobalValueString(types.NetworkDiagBeaconSecret)
+	beacon := diagbeacon.Beacon{
+		DeviceUUID:       devUUID.String(),
+		Timestamp:        now,
+		UnreachableSince: unreachableSince,
+		LastError:        getconfigCtx.lastConfigErr,
+	}
+	go func() {
+		if err := diagbeacon.Send(log, endpoint, secret, beacon); err != nil {
+			log.Errorf("maybeSendDiagBeacon: %s", err)
+		}
+	}()
+}
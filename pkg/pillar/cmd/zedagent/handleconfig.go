@@ -46,6 +46,8 @@ type getconfigContext struct {
 	subProcessMetric         pubsub.Subscription
 	subHostMemory            pubsub.Subscription
 	subNodeAgentStatus       pubsub.Subscription
+	subHeartBeat             pubsub.Subscription
+	agentHeartBeats          map[string]types.HeartBeat // by AgentName
 	pubZedAgentStatus        pubsub.Publication
 	pubAppInstanceConfig     pubsub.Publication
 	pubAppNetworkConfig      pubsub.Publication
@@ -59,6 +61,16 @@ type getconfigContext struct {
 	subVolumeStatus          pubsub.Subscription
 	pubVolumeConfig          pubsub.Publication
 	rebootFlag               bool
+	lastConfigSuccess        time.Time // Zero until the first successful config fetch
+	lastConfigErr            string    // Most recent getLatestConfig failure, for the diag beacon
+	lastBeaconSent           time.Time // Zero until the first beacon is sent
+	configSeqNum             int       // Count of configs written to configSequenceDirname this run
+	// lastGoodLocalOverrides is the most recently successfully-parsed
+	// value of types.AppInstanceLocalOverrides; see parseLocalOverrides
+	// in localoverride.go. Kept around so a single malformed edit to
+	// that setting doesn't silently disable every local override for
+	// every app on the device until the edit is fixed.
+	lastGoodLocalOverrides localOverrides
 }
 
 // devUUID is set in handleConfigInit and never changed
@@ -182,6 +194,22 @@ func updateConfigTimer(configInterval uint32, tickerHandle interface{}) {
 	flextimer.TickNow(tickerHandle)
 }
 
+// maybeResyncAfterReconnect forces a fresh device info publish the
+// first time getLatestConfig succeeds after one or more failures, so
+// whatever device info was last attempted before the outage - which
+// may never have reached the controller - gets retransmitted instead
+// of silently waiting for the next unrelated change to trigger one.
+func maybeResyncAfterReconnect(getconfigCtx *getconfigContext) {
+	if getconfigCtx.lastConfigErr == "" {
+		return
+	}
+	ctx := getconfigCtx.zedagentCtx
+	log.Noticef("maybeResyncAfterReconnect: controller reachable again, resyncing device info (seq %d, last acked %d)",
+		ctx.deviceInfoSeq, ctx.deviceInfoAckedSeq)
+	getconfigCtx.lastConfigErr = ""
+	triggerPublishDevInfo(ctx)
+}
+
 // Start by trying the all the free management ports and then all the non-free
 // until one succeeds in communicating with the cloud.
 // We use the iteration argument to start at a different point each time.
@@ -204,6 +232,8 @@ func getLatestConfig(url string, iteration int,
 	size := int64(proto.Size(cr))
 	resp, contents, rtf, err := zedcloud.SendOnAllIntf(zedcloudCtx, url, size, buf, iteration, bailOnHTTPErr)
 	if err != nil {
+		getconfigCtx.lastConfigErr = err.Error()
+		maybeSendDiagBeacon(getconfigCtx)
 		newCount := 2
 		switch rtf {
 		case types.SenderStatusUpgrade:
@@ -278,6 +308,8 @@ func getLatestConfig(url string, iteration int,
 			getconfigCtx.configReceived = true
 		}
 		getconfigCtx.configGetStatus = types.ConfigGetSuccess
+		getconfigCtx.lastConfigSuccess = time.Now()
+		maybeResyncAfterReconnect(getconfigCtx)
 		publishZedAgentStatus(getconfigCtx)
 
 		log.Debugf("Configuration from zedcloud is unchanged")
@@ -313,6 +345,8 @@ func getLatestConfig(url string, iteration int,
 		getconfigCtx.configReceived = true
 	}
 	getconfigCtx.configGetStatus = types.ConfigGetSuccess
+	getconfigCtx.lastConfigSuccess = time.Now()
+	maybeResyncAfterReconnect(getconfigCtx)
 	publishZedAgentStatus(getconfigCtx)
 
 	if !changed {
@@ -322,6 +356,7 @@ func getLatestConfig(url string, iteration int,
 		return false
 	}
 	writeReceivedProtoMessage(contents)
+	maybeRecordConfigSequence(getconfigCtx, config)
 
 	return inhaleDeviceConfig(config, getconfigCtx, false)
 }
@@ -474,6 +474,30 @@ func publishMetrics(ctx *zedagentContext, iteration int) {
 		ReportDeviceMetric.MetricItems = append(ReportDeviceMetric.MetricItems, item)
 	}
 
+	// Report how the flow log upload queue (see sendFlowProtobuf) is
+	// behaving, so a thin or congested uplink shows up in the metrics
+	// rather than only in the logs.
+	depthItem := new(metrics.MetricItem)
+	depthItem.Key = "flowlog-queue-depth"
+	depthItem.Type = metrics.MetricItemType(types.MetricItemGauge)
+	setMetricAnyValue(depthItem, uint32(flowQ.Len()))
+	ReportDeviceMetric.MetricItems = append(ReportDeviceMetric.MetricItems, depthItem)
+
+	deferredItem := new(metrics.MetricItem)
+	deferredItem.Key = "flowlog-deferred-congestion-count"
+	deferredItem.Type = metrics.MetricItemType(types.MetricItemCounter)
+	setMetricAnyValue(deferredItem, flowStats.deferredForCongestion)
+	ReportDeviceMetric.MetricItems = append(ReportDeviceMetric.MetricItems, deferredItem)
+
+	// Report each instrumented containerd API call's counters and latency
+	// (see containerd.Client.GetMetrics), published by volumemgr and
+	// domainmgr, so a degrading containerd daemon shows up here before
+	// it starts failing app or volume operations outright.
+	ReportDeviceMetric.MetricItems = append(ReportDeviceMetric.MetricItems,
+		containerdCallMetricItems("volumemgr", containerdMetricsVM)...)
+	ReportDeviceMetric.MetricItems = append(ReportDeviceMetric.MetricItems,
+		containerdCallMetricItems("domainmgr", containerdMetricsDM)...)
+
 	// Get device info using nil UUID
 	dm := lookupDomainMetric(ctx, nilUUID.String())
 	if dm != nil {
@@ -587,6 +611,28 @@ func publishMetrics(ctx *zedagentContext, iteration int) {
 				networkDetails)
 		}
 
+		// If the guest agent channel reported in-guest interface counters,
+		// report them side by side with the host-side VIF counters above so
+		// operators can tell whether loss happens inside the guest or in
+		// the host datapath.
+		if dm != nil {
+			for _, guestMetric := range dm.GuestNetworkStats {
+				networkDetails := new(metrics.NetworkMetric)
+				networkDetails.IName = guestMetric.IfName + "-guest"
+				networkDetails.LocalName = guestMetric.IfName
+				networkDetails.TxPkts = guestMetric.TxPkts
+				networkDetails.RxPkts = guestMetric.RxPkts
+				networkDetails.TxBytes = guestMetric.TxBytes
+				networkDetails.RxBytes = guestMetric.RxBytes
+				networkDetails.TxDrops = guestMetric.TxDrops
+				networkDetails.RxDrops = guestMetric.RxDrops
+				networkDetails.TxErrors = guestMetric.TxErrors
+				networkDetails.RxErrors = guestMetric.RxErrors
+				ReportAppMetric.Network = append(ReportAppMetric.Network,
+					networkDetails)
+			}
+		}
+
 		for _, vrs := range aiStatus.VolumeRefStatusList {
 			appDiskDetails := new(metrics.AppDiskMetric)
 			if vrs.ActiveFileLocation == "" {
@@ -712,6 +758,45 @@ func getSecurityInfo(ctx *zedagentContext) *info.SecurityInfo {
 	return si
 }
 
+// containerdCallMetricItems flattens a types.ContainerdMetrics snapshot
+// (one containerd client's running per-API-call counters) into MetricItems
+// prefixed with agent, the name of the agent that published it, so
+// "volumemgr" and "domainmgr" each publishing their own containerd client's
+// metrics don't collide on the same call name.
+func containerdCallMetricItems(agent string, callMetrics types.ContainerdMetrics) []*metrics.MetricItem {
+	var items []*metrics.MetricItem
+	for name, call := range callMetrics {
+		prefix := fmt.Sprintf("containerd-%s-%s", agent, name)
+
+		countItem := new(metrics.MetricItem)
+		countItem.Key = prefix + "-call-count"
+		countItem.Type = metrics.MetricItemType(types.MetricItemCounter)
+		setMetricAnyValue(countItem, call.CallCount)
+		items = append(items, countItem)
+
+		errItem := new(metrics.MetricItem)
+		errItem.Key = prefix + "-error-count"
+		errItem.Type = metrics.MetricItemType(types.MetricItemCounter)
+		setMetricAnyValue(errItem, call.ErrorCount)
+		items = append(items, errItem)
+
+		latencyItem := new(metrics.MetricItem)
+		latencyItem.Key = prefix + "-last-latency-msec"
+		latencyItem.Type = metrics.MetricItemType(types.MetricItemGauge)
+		setMetricAnyValue(latencyItem, uint32(call.LastLatencyNsec/uint64(time.Millisecond)))
+		items = append(items, latencyItem)
+
+		for code, count := range call.ErrorsByCode {
+			codeItem := new(metrics.MetricItem)
+			codeItem.Key = fmt.Sprintf("%s-error-count-%s", prefix, code)
+			codeItem.Type = metrics.MetricItemType(types.MetricItemCounter)
+			setMetricAnyValue(codeItem, count)
+			items = append(items, codeItem)
+		}
+	}
+	return items
+}
+
 func setMetricAnyValue(item *metrics.MetricItem, val interface{}) {
 	switch t := val.(type) {
 	case uint32:
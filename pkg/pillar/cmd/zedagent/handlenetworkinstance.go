@@ -434,13 +434,14 @@ func handleAppFlowMonitorModify(ctxArg interface{}, key string,
 	statusArg interface{}) {
 
 	log.Infof("handleAppFlowMonitorModify(%s)", key)
+	ctx := ctxArg.(*zedagentContext)
 	flows := statusArg.(types.IPFlow)
 
 	// encoding the flows with protobuf format
 	pflows := protoEncodeAppFlowMonitorProto(flows)
 
 	// send protobuf to zedcloud
-	sendFlowProtobuf(pflows)
+	sendFlowProtobuf(ctx, pflows)
 }
 
 func handleAppFlowMonitorDelete(ctxArg interface{}, key string,
@@ -491,6 +492,10 @@ func protoEncodeAppFlowMonitorProto(ipflow types.IPFlow) *flowlog.FlowMessage {
 
 	pflows := new(flowlog.FlowMessage)
 	pflows.DevId = ipflow.DevID.String()
+	// NOTE: ipflow.SampleFactor (the FlowlogSamplingFactor in effect for
+	// this batch) is not yet carried over the wire: flowlog.FlowMessage
+	// has no field for it, and adding one requires extending
+	// api/proto/flowlog/flowlog.proto and regenerating flowlog.pb.go.
 
 	// ScopeInfo fill in
 	pScope := new(flowlog.ScopeInfo)
@@ -547,10 +552,20 @@ func protoEncodeAppFlowMonitorProto(ipflow types.IPFlow) *flowlog.FlowMessage {
 	return pflows
 }
 
-func sendFlowProtobuf(protoflows *flowlog.FlowMessage) {
+func sendFlowProtobuf(ctx *zedagentContext, protoflows *flowlog.FlowMessage) {
 
 	flowQ.PushBack(protoflows)
 
+	if uplinkCongested(ctx) {
+		// Flow logs are bulk, deferrable traffic; leave them queued
+		// rather than competing with config polls and info/metrics
+		// reports for bandwidth on a congested uplink.
+		flowStats.deferredForCongestion++
+		log.Debugf("sendFlowProtobuf: uplink congested, deferring, flowQ size %d",
+			flowQ.Len())
+		return
+	}
+
 	for flowQ.Len() > 0 {
 		ent := flowQ.Front()
 		pflowsPtr := ent.Value.(*flowlog.FlowMessage)
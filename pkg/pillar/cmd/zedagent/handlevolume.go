@@ -80,6 +80,13 @@ func parseVolumeConfig(ctx *getconfigContext,
 		volumeConfig.DisplayName = cfgVolume.GetDisplayName()
 		volumeConfig.ReadOnly = cfgVolume.GetReadonly()
 		volumeConfig.RefCount = 1
+
+		// Apply any stopgap per-volume override from
+		// types.AppInstanceLocalOverrides for fields the controller's
+		// proto has no field for yet. See cmd/zedagent/localoverride.go.
+		vKey := volumeKey(cfgVolume.GetUuid(), cfgVolume.GetGenerationCount())
+		volumeConfig.ClonedFromKey = volumeLocalOverrideFor(ctx, vKey).ClonedFromKey
+
 		publishVolumeConfig(ctx, *volumeConfig)
 	}
 	log.Debugf("parsing volume config done\n")
@@ -0,0 +1,130 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package zedagent
+
+import (
+	"encoding/json"
+
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// appInstanceLocalOverride holds per-app fields that the controller's
+// current AppInstanceConfig proto has no field for yet. It is applied on
+// top of the config parsed from the controller's proto by
+// parseAppInstanceConfig, keyed by app UUID, as a stopgap until each
+// field gets a real proto field of its own.
+//
+// That real field now has a documented shape: see
+// AppInstanceConfig.localOverride and the LocalOverrideConfig message in
+// api/proto/config/appconfig.proto. This struct's JSON tags intentionally
+// mirror LocalOverrideConfig's fields one-for-one, so that once the
+// vendored protobuf bindings are regenerated from that message,
+// switching parseAppInstanceConfig over to cfgApp.GetLocalOverride() is a
+// mechanical field-by-field swap rather than a redesign.
+type appInstanceLocalOverride struct {
+	// CVMType selects confidential VM support; see
+	// types.VmConfig.CVMType.
+	CVMType types.CVMType `json:"cvmType,omitempty"`
+
+	// ApparmorProfile and SelinuxLabel confine this app's container; see
+	// types.AppInstanceConfig.ApparmorProfile/SelinuxLabel.
+	ApparmorProfile string `json:"apparmorProfile,omitempty"`
+	SelinuxLabel    string `json:"selinuxLabel,omitempty"`
+
+	// Critical and BootOrder sequence activation across apps at boot;
+	// see types.AppInstanceConfig.Critical/BootOrder.
+	Critical  bool   `json:"critical,omitempty"`
+	BootOrder uint32 `json:"bootOrder,omitempty"`
+
+	// RestartPolicy controls domainmgr's automatic container restart
+	// behavior; see types.AppInstanceConfig.RestartPolicy.
+	RestartPolicy types.RestartPolicy `json:"restartPolicy,omitempty"`
+
+	// ResourceAlarms are per-app hard resource usage thresholds; see
+	// types.AppInstanceConfig.ResourceAlarms.
+	ResourceAlarms []types.ResourceAlarmConfig `json:"resourceAlarms,omitempty"`
+
+	// ContainerHealthProbe configures domainmgr's scheduled container
+	// health probe; see types.AppInstanceConfig.ContainerHealthProbe.
+	ContainerHealthProbe types.HealthProbeConfig `json:"containerHealthProbe,omitempty"`
+
+	// BlueGreenUpgrade and HealthProbePort control the one-shot health
+	// probe of a purged app's new instance; see
+	// types.AppInstanceConfig.BlueGreenUpgrade/HealthProbePort.
+	BlueGreenUpgrade bool   `json:"blueGreenUpgrade,omitempty"`
+	HealthProbePort  uint16 `json:"healthProbePort,omitempty"`
+
+	// QuiesceHook configures pre/post commands run around a checkpoint;
+	// see types.AppInstanceConfig.QuiesceHook.
+	QuiesceHook types.QuiesceHookConfig `json:"quiesceHook,omitempty"`
+
+	// EnableCheckpoint asks domainmgr to CRIU-checkpoint this app's
+	// container; see types.AppInstanceConfig.EnableCheckpoint.
+	EnableCheckpoint bool `json:"enableCheckpoint,omitempty"`
+
+	// OCIRuntime overrides the containerd shim runtime used for this
+	// app's container; see types.VmConfig.OCIRuntime.
+	OCIRuntime string `json:"ociRuntime,omitempty"`
+
+	// EnableGVisor runs this container's syscalls through gVisor; see
+	// types.VmConfig.EnableGVisor.
+	EnableGVisor bool `json:"enableGVisor,omitempty"`
+}
+
+// volumeLocalOverride holds per-volume fields that the controller's
+// current Volume proto has no field for yet. It is applied on top of the
+// config parsed from the controller's proto by parseVolumeConfig, keyed
+// by volumeKey(VolumeID, GenerationCounter).
+type volumeLocalOverride struct {
+	// ClonedFromKey, if set, has volumemgr create this volume as a
+	// local clone of the volume with that key instead of fetching its
+	// content; see types.VolumeConfig.ClonedFromKey.
+	ClonedFromKey string `json:"clonedFromKey,omitempty"`
+}
+
+// localOverrides is the JSON shape of the types.AppInstanceLocalOverrides
+// global setting: a stopgap way to reach AppInstanceConfig/VolumeConfig
+// fields the controller's proto can't set yet, without waiting on a proto
+// change. Keyed by app UUID (as a string) and, for volumes, by
+// volumeKey(VolumeID, GenerationCounter).
+type localOverrides struct {
+	Apps    map[string]appInstanceLocalOverride `json:"apps,omitempty"`
+	Volumes map[string]volumeLocalOverride      `json:"volumes,omitempty"`
+}
+
+// parseLocalOverrides decodes the types.AppInstanceLocalOverrides global
+// setting. An empty value yields a zero-value localOverrides (i.e., no
+// overrides), since this setting is optional and most devices will never
+// set it. A value that fails to parse keeps serving
+// getconfigCtx.lastGoodLocalOverrides instead of falling back to a zero
+// value -- a single malformed edit to this one GlobalConfig string would
+// otherwise silently disable every local override for every app on the
+// device until the edit is noticed and fixed, which is a much bigger
+// blast radius than the typo that caused it.
+func parseLocalOverrides(getconfigCtx *getconfigContext) localOverrides {
+	raw := getconfigCtx.zedagentCtx.globalConfig.GlobalValueString(types.AppInstanceLocalOverrides)
+	if raw == "" {
+		getconfigCtx.lastGoodLocalOverrides = localOverrides{}
+		return localOverrides{}
+	}
+	var overrides localOverrides
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		log.Errorf("parseLocalOverrides: keeping last known good overrides: %v", err)
+		return getconfigCtx.lastGoodLocalOverrides
+	}
+	getconfigCtx.lastGoodLocalOverrides = overrides
+	return overrides
+}
+
+// appLocalOverride returns the appInstanceLocalOverride for uuidStr, or
+// its zero value if none is configured.
+func appLocalOverride(getconfigCtx *getconfigContext, uuidStr string) appInstanceLocalOverride {
+	return parseLocalOverrides(getconfigCtx).Apps[uuidStr]
+}
+
+// volumeLocalOverrideFor returns the volumeLocalOverride for key (see
+// volumeKey), or its zero value if none is configured.
+func volumeLocalOverrideFor(getconfigCtx *getconfigContext, key string) volumeLocalOverride {
+	return parseLocalOverrides(getconfigCtx).Volumes[key]
+}
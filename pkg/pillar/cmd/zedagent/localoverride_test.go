@@ -0,0 +1,61 @@
+// Copyright (c) 2026 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package zedagent
+
+import (
+	"testing"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	if log == nil {
+		log = base.NewSourceLogObject(logrus.StandardLogger(), "zedagent_test", 1234)
+	}
+}
+
+func newTestGetconfigContext() *getconfigContext {
+	return &getconfigContext{
+		zedagentCtx: &zedagentContext{globalConfig: *types.DefaultConfigItemValueMap()},
+	}
+}
+
+func TestParseLocalOverridesMalformedKeepsLastGood(t *testing.T) {
+	ctx := newTestGetconfigContext()
+	globalConfig := &ctx.zedagentCtx.globalConfig
+
+	globalConfig.SetGlobalValueString(types.AppInstanceLocalOverrides,
+		`{"apps":{"dead-beef":{"critical":true,"bootOrder":3}}}`)
+	got := parseLocalOverrides(ctx)
+	if !got.Apps["dead-beef"].Critical || got.Apps["dead-beef"].BootOrder != 3 {
+		t.Fatalf("got %+v, want Critical=true BootOrder=3", got.Apps["dead-beef"])
+	}
+
+	// A subsequent malformed edit must not wipe out the overrides that
+	// were already in effect for every app on the device.
+	globalConfig.SetGlobalValueString(types.AppInstanceLocalOverrides, `{not valid json`)
+	got = parseLocalOverrides(ctx)
+	if !got.Apps["dead-beef"].Critical || got.Apps["dead-beef"].BootOrder != 3 {
+		t.Fatalf("malformed edit should preserve last known good overrides, got %+v", got.Apps["dead-beef"])
+	}
+}
+
+func TestParseLocalOverridesEmptyIsZeroValue(t *testing.T) {
+	ctx := newTestGetconfigContext()
+	globalConfig := &ctx.zedagentCtx.globalConfig
+
+	globalConfig.SetGlobalValueString(types.AppInstanceLocalOverrides,
+		`{"apps":{"dead-beef":{"critical":true}}}`)
+	if !parseLocalOverrides(ctx).Apps["dead-beef"].Critical {
+		t.Fatal("expected override to take effect before being cleared")
+	}
+
+	globalConfig.SetGlobalValueString(types.AppInstanceLocalOverrides, "")
+	got := parseLocalOverrides(ctx)
+	if len(got.Apps) != 0 {
+		t.Fatalf("expected clearing the setting to clear overrides, got %+v", got.Apps)
+	}
+}
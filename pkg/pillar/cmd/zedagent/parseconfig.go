@@ -485,6 +485,25 @@ func parseAppInstanceConfig(config *zconfig.EdgeDevConfig,
 		appInstance.CipherBlockStatus = parseCipherBlock(getconfigCtx, appInstance.Key(),
 			cfgApp.GetCipherData())
 
+		// Apply any stopgap per-app overrides from
+		// types.AppInstanceLocalOverrides for fields the controller's
+		// proto has no field for yet. See cmd/zedagent/localoverride.go.
+		override := appLocalOverride(getconfigCtx, cfgApp.Uuidandversion.Uuid)
+		appInstance.FixedResources.CVMType = override.CVMType
+		appInstance.ApparmorProfile = override.ApparmorProfile
+		appInstance.SelinuxLabel = override.SelinuxLabel
+		appInstance.Critical = override.Critical
+		appInstance.BootOrder = override.BootOrder
+		appInstance.RestartPolicy = override.RestartPolicy
+		appInstance.ResourceAlarms = override.ResourceAlarms
+		appInstance.ContainerHealthProbe = override.ContainerHealthProbe
+		appInstance.BlueGreenUpgrade = override.BlueGreenUpgrade
+		appInstance.HealthProbePort = override.HealthProbePort
+		appInstance.QuiesceHook = override.QuiesceHook
+		appInstance.EnableCheckpoint = override.EnableCheckpoint
+		appInstance.FixedResources.OCIRuntime = override.OCIRuntime
+		appInstance.FixedResources.EnableGVisor = override.EnableGVisor
+
 		// write to zedmanager config directory
 		publishAppInstanceConfig(getconfigCtx, appInstance)
 	}
@@ -1503,8 +1522,13 @@ func parseConfigItems(config *zconfig.EdgeDevConfig, ctx *getconfigContext) {
 	// attached keyboard.
 	newGlobalConfig.SetGlobalValueBool(types.UsbAccess, false)
 	newGlobalStatus := types.NewGlobalStatus()
+	newAnnotations := make(map[string]string)
 
 	for _, item := range items {
+		if annotation, ok := parseAnnotationKey(item.Key); ok {
+			newAnnotations[annotation] = item.Value
+			continue
+		}
 		itemValue, err := ctx.zedagentCtx.specMap.ParseItem(newGlobalConfig,
 			gcPtr, item.Key, item.Value)
 		newGlobalStatus.ConfigItems[item.Key] = types.ConfigItemStatus{
@@ -1514,6 +1538,7 @@ func parseConfigItems(config *zconfig.EdgeDevConfig, ctx *getconfigContext) {
 		log.Debugf("Processed ConfigItem: key: %s, Value: %s, itemValue: %+v",
 			item.Key, item.Value, itemValue)
 	}
+	publishDeviceAnnotations(ctx.zedagentCtx, newAnnotations)
 	log.Debugf("Done with Parsing ConfigItems. globalStatus: %+v",
 		*newGlobalStatus)
 	ctx.zedagentCtx.globalStatus = *newGlobalStatus
@@ -1563,6 +1588,42 @@ func parseConfigItems(config *zconfig.EdgeDevConfig, ctx *getconfigContext) {
 	}
 }
 
+// annotationKeyPrefix is a reserved ConfigItem key prefix the controller can
+// use to set arbitrary device annotations (site/rack/owner tags and the
+// like), since the config API has no dedicated field for them. A ConfigItem
+// with key "annotation.site" and value "rack12-bayA" publishes an
+// annotation named "site".
+const annotationKeyPrefix = "annotation."
+
+// parseAnnotationKey strips annotationKeyPrefix from key, reporting whether
+// it was present.
+func parseAnnotationKey(key string) (string, bool) {
+	if !strings.HasPrefix(key, annotationKeyPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(key, annotationKeyPrefix), true
+}
+
+// prevAnnotations lets publishDeviceAnnotations skip republishing when the
+// controller resends an unchanged config, the same way itemsPrevConfigHash
+// does for the rest of parseConfigItems.
+var prevAnnotations map[string]string
+
+// publishDeviceAnnotations publishes the device's current annotations, see
+// annotationKeyPrefix, so other agents and zedmanager's cloud-init template
+// substitution can consume them.
+func publishDeviceAnnotations(ctx *zedagentContext, annotations map[string]string) {
+	if cmp.Equal(prevAnnotations, annotations) {
+		return
+	}
+	prevAnnotations = annotations
+	err := ctx.pubDeviceAnnotations.Publish("global",
+		types.DeviceAnnotations{Annotations: annotations})
+	if err != nil {
+		log.Errorf("publishDeviceAnnotations failed: %s", err)
+	}
+}
+
 func publishAppInstanceConfig(getconfigCtx *getconfigContext,
 	config types.AppInstanceConfig) {
 
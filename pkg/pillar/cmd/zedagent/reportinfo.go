@@ -60,6 +60,9 @@ func PublishDeviceInfoToZedCloud(ctx *zedagentContext) {
 	iteration := ctx.iteration
 	subBaseOsStatus := ctx.subBaseOsStatus
 
+	ctx.deviceInfoSeq++
+	mySeq := ctx.deviceInfoSeq
+
 	var ReportInfo = &info.ZInfoMsg{}
 
 	deviceType := new(info.ZInfoTypes)
@@ -351,6 +354,9 @@ func PublishDeviceInfoToZedCloud(ctx *zedagentContext) {
 
 	ReportDeviceInfo.LastRebootReason = ctx.rebootReason
 	ReportDeviceInfo.LastRebootStack = ctx.rebootStack
+	// XXX ctx.safeMode (see handleNodeAgentStatusModify) has no home yet
+	// in ZInfoDevice; reporting it to the controller needs a new field
+	// added to the info API, which is out of scope here.
 	if !ctx.rebootTime.IsZero() {
 		rebootTime, _ := ptypes.TimestampProto(ctx.rebootTime)
 		ReportDeviceInfo.LastRebootTime = rebootTime
@@ -404,7 +410,8 @@ func PublishDeviceInfoToZedCloud(ctx *zedagentContext) {
 	size := int64(proto.Size(ReportInfo))
 	err = SendProtobuf(statusUrl, buf, size, iteration)
 	if err != nil {
-		log.Errorf("PublishDeviceInfoToZedCloud failed: %s", err)
+		log.Errorf("PublishDeviceInfoToZedCloud failed for seq %d (last acked %d): %s",
+			mySeq, ctx.deviceInfoAckedSeq, err)
 		// Try sending later
 		// The buf might have been consumed
 		buf := bytes.NewBuffer(data)
@@ -415,6 +422,10 @@ func PublishDeviceInfoToZedCloud(ctx *zedagentContext) {
 			statusUrl, true)
 	} else {
 		writeSentDeviceInfoProtoMessage(data)
+		if mySeq > ctx.deviceInfoAckedSeq {
+			ctx.deviceInfoAckedSeq = mySeq
+		}
+		log.Debugf("PublishDeviceInfoToZedCloud acked seq %d", mySeq)
 	}
 }
 
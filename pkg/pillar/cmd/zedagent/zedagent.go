@@ -39,6 +39,7 @@ import (
 	"github.com/lf-edge/eve/pkg/pillar/pubsub"
 	"github.com/lf-edge/eve/pkg/pillar/types"
 	"github.com/lf-edge/eve/pkg/pillar/utils"
+	"github.com/lf-edge/eve/pkg/pillar/webhook"
 	"github.com/lf-edge/eve/pkg/pillar/zedcloud"
 
 	"github.com/sirupsen/logrus"
@@ -69,6 +70,8 @@ var networkMetrics types.NetworkMetrics
 var cipherMetricsDL types.CipherMetricsMap
 var cipherMetricsDM types.CipherMetricsMap
 var cipherMetricsNim types.CipherMetricsMap
+var containerdMetricsVM types.ContainerdMetrics
+var containerdMetricsDM types.ContainerdMetrics
 
 // Context for handleDNSModify
 type DNSContext struct {
@@ -96,6 +99,7 @@ type zedagentContext struct {
 	subAppVifIPTrig           pubsub.Subscription
 	pubGlobalConfig           pubsub.Publication
 	subGlobalConfig           pubsub.Subscription
+	pubDeviceAnnotations      pubsub.Publication
 	subEdgeNodeCert           pubsub.Subscription
 	subVaultStatus            pubsub.Subscription
 	subAttestQuote            pubsub.Subscription
@@ -116,6 +120,10 @@ type zedagentContext struct {
 	rebootTime                time.Time // Previous reboot from nodeagent
 	// restartCounter - counts number of reboots of the device by Eve
 	restartCounter uint32
+	// safeMode records whether nodeagent reported that this boot only
+	// started networking, onboarding and logging agents, see
+	// handleNodeAgentStatusModify and scripts/device-steps.sh.
+	safeMode bool
 	// rebootConfigCounter - reboot counter sent by the cloud in its config.
 	//  This is the value of counter that triggered reboot. This is sent in
 	//  device info msg. Can be used to verify device is caught up on all
@@ -129,6 +137,24 @@ type zedagentContext struct {
 	specMap                 types.ConfigItemSpecMap
 	globalStatus            types.GlobalStatus
 	appContainerStatsTime   time.Time // last time the App Container stats uploaded
+	// webhookNotifiedAppError tracks which app UUIDs we already sent an
+	// "app down" webhook notification for, so we don't resend on every
+	// AppInstanceStatus modify while the app remains in the same error.
+	webhookNotifiedAppError map[string]bool
+	// agentStartTime is used as a conservative "unreachable since" for
+	// the network diagnostics beacon when the controller has never been
+	// reached at all since this zedagent started.
+	agentStartTime time.Time
+	// deviceInfoSeq is a local, monotonically increasing sequence
+	// number assigned to each device info publish attempt, so gaps or
+	// reordering in what the controller actually received can be
+	// diagnosed from the logs on both ends.
+	deviceInfoSeq uint64
+	// deviceInfoAckedSeq is the highest deviceInfoSeq that zedcloud
+	// has confirmed delivering (HTTP success); see
+	// maybeResyncAfterReconnect, which forces a resend of anything
+	// still unacknowledged once the controller is reachable again.
+	deviceInfoAckedSeq uint64
 }
 
 var debug = false
@@ -193,8 +219,10 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 
 	triggerDeviceInfo := make(chan struct{}, 1)
 	zedagentCtx := zedagentContext{
-		ps:                ps,
-		TriggerDeviceInfo: triggerDeviceInfo,
+		ps:                      ps,
+		TriggerDeviceInfo:       triggerDeviceInfo,
+		webhookNotifiedAppError: make(map[string]bool),
+		agentStartTime:          time.Now(),
 	}
 	zedagentCtx.specMap = types.NewConfigItemSpecMap()
 	zedagentCtx.globalConfig = *types.DefaultConfigItemValueMap()
@@ -223,6 +251,15 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 		log.Fatal(err)
 	}
 
+	zedagentCtx.pubDeviceAnnotations, err = ps.NewPublication(pubsub.PublicationOptions{
+		AgentName:  agentName,
+		TopicType:  types.DeviceAnnotations{},
+		Persistent: true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Run a periodic timer so we always update StillRunning
 	stillRunning := time.NewTicker(25 * time.Second)
 	ps.StillRunning(agentName, warningTime, errorTime)
@@ -754,6 +791,29 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 	getconfigCtx.subNodeAgentStatus = subNodeAgentStatus
 	subNodeAgentStatus.Activate()
 
+	// Watch nodeagent's heartbeat so a nodeagent whose loop stalled for
+	// longer than staleHeartBeatTime shows up in our own logs once its next
+	// heartbeat arrives. Only nodeagent's HeartBeat topic is subscribed to
+	// here; see agentbase.Context.PublishHeartBeat.
+	getconfigCtx.agentHeartBeats = make(map[string]types.HeartBeat)
+	subHeartBeat, err := ps.NewSubscription(pubsub.SubscriptionOptions{
+		AgentName:     "nodeagent",
+		MyAgentName:   agentName,
+		TopicImpl:     types.HeartBeat{},
+		Activate:      false,
+		Ctx:           &getconfigCtx,
+		CreateHandler: handleHeartBeatModify,
+		ModifyHandler: handleHeartBeatModify,
+		DeleteHandler: handleHeartBeatDelete,
+		WarningTime:   warningTime,
+		ErrorTime:     errorTime,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	getconfigCtx.subHeartBeat = subHeartBeat
+	subHeartBeat.Activate()
+
 	DNSctx := DNSContext{}
 	subDeviceNetworkStatus, err := ps.NewSubscription(pubsub.SubscriptionOptions{
 		AgentName:     "nim",
@@ -1038,6 +1098,27 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 		log.Fatal(err)
 	}
 
+	subContainerdMetricsVM, err := ps.NewSubscription(pubsub.SubscriptionOptions{
+		AgentName:   "volumemgr",
+		MyAgentName: agentName,
+		TopicImpl:   types.ContainerdMetrics{},
+		Activate:    true,
+		Ctx:         &zedagentCtx,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	subContainerdMetricsDM, err := ps.NewSubscription(pubsub.SubscriptionOptions{
+		AgentName:   "domainmgr",
+		MyAgentName: agentName,
+		TopicImpl:   types.ContainerdMetrics{},
+		Activate:    true,
+		Ctx:         &zedagentCtx,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Use a go routine to make sure we have wait/timeout without
 	// blocking the main select loop
 	log.Infof("Creating %s at %s", "deviceInfoTask", agentlog.GetMyStack())
@@ -1104,6 +1185,9 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 		case change := <-getconfigCtx.subNodeAgentStatus.MsgChan():
 			subNodeAgentStatus.ProcessChange(change)
 
+		case change := <-getconfigCtx.subHeartBeat.MsgChan():
+			getconfigCtx.subHeartBeat.ProcessChange(change)
+
 		case change := <-subDeviceNetworkStatus.MsgChan():
 			subDeviceNetworkStatus.ProcessChange(change)
 			if DNSctx.triggerGetConfig {
@@ -1196,6 +1280,26 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 				cipherMetricsNim = m.(types.CipherMetricsMap)
 			}
 
+		case change := <-subContainerdMetricsVM.MsgChan():
+			subContainerdMetricsVM.ProcessChange(change)
+			m, err := subContainerdMetricsVM.Get("global")
+			if err != nil {
+				log.Errorf("subContainerdMetricsVM.Get failed: %s",
+					err)
+			} else {
+				containerdMetricsVM = m.(types.ContainerdMetrics)
+			}
+
+		case change := <-subContainerdMetricsDM.MsgChan():
+			subContainerdMetricsDM.ProcessChange(change)
+			m, err := subContainerdMetricsDM.Get("global")
+			if err != nil {
+				log.Errorf("subContainerdMetricsDM.Get failed: %s",
+					err)
+			} else {
+				containerdMetricsDM = m.(types.ContainerdMetrics)
+			}
+
 		case change := <-subNetworkInstanceStatus.MsgChan():
 			subNetworkInstanceStatus.ProcessChange(change)
 
@@ -1297,7 +1401,8 @@ func initializeDirs() {
 }
 
 // handleAppInstanceStatusCreate - Handle AIS create. Publish ZInfoApp
-//  and ZInfoDevice to the cloud.
+//
+//	and ZInfoDevice to the cloud.
 func handleAppInstanceStatusCreate(ctxArg interface{}, key string,
 	statusArg interface{}) {
 	status := statusArg.(types.AppInstanceStatus)
@@ -1322,10 +1427,47 @@ func handleAppInstanceStatusModify(ctxArg interface{}, key string,
 	uuidStr := status.Key()
 	PublishAppInfoToZedCloud(ctx, uuidStr, &status, ctx.assignableAdapters,
 		ctx.iteration)
+	notifyWebhookOnAppError(ctx, status)
 	ctx.iteration++
 	log.Infof("handleAppInstanceStatusModify(%s) DONE", key)
 }
 
+// notifyWebhookOnAppError sends a local webhook notification the first time
+// an app instance transitions into an error state, and clears the dedup
+// entry once it recovers so a later failure is reported again.
+func notifyWebhookOnAppError(ctx *zedagentContext, status types.AppInstanceStatus) {
+	uuidStr := status.Key()
+	if !status.HasError() {
+		delete(ctx.webhookNotifiedAppError, uuidStr)
+		return
+	}
+	if ctx.webhookNotifiedAppError[uuidStr] {
+		return
+	}
+	ctx.webhookNotifiedAppError[uuidStr] = true
+
+	url := ctx.globalConfig.GlobalValueString(types.WebhookNotificationURL)
+	if url == "" {
+		return
+	}
+	secret := ctx.globalConfig.GlobalValueString(types.WebhookNotificationSecret)
+	event := webhook.Event{
+		Type:       "app-down",
+		DeviceUUID: devUUID.String(),
+		Timestamp:  time.Now(),
+		Detail: struct {
+			AppUUID     string `json:"appUUID"`
+			DisplayName string `json:"displayName"`
+			Error       string `json:"error"`
+		}{status.UUIDandVersion.UUID.String(), status.DisplayName, status.Error},
+	}
+	go func() {
+		if err := webhook.Send(log, url, secret, event); err != nil {
+			log.Errorf("notifyWebhookOnAppError(%s): %s", uuidStr, err)
+		}
+	}()
+}
+
 func handleAppInstanceStatusDelete(ctxArg interface{}, key string,
 	statusArg interface{}) {
 
@@ -1481,6 +1623,7 @@ func handleGlobalConfigModify(ctxArg interface{}, key string,
 		ctx.globalConfig = *gcp
 		ctx.GCInitialized = true
 	}
+	configureCoreDumps(ctx)
 	log.Infof("handleGlobalConfigModify done for %s", key)
 }
 
@@ -1496,6 +1639,7 @@ func handleGlobalConfigDelete(ctxArg interface{}, key string,
 	debug, _ = agentlog.HandleGlobalConfig(log, ctx.subGlobalConfig, agentName,
 		debugOverride, logger)
 	ctx.globalConfig = *types.DefaultConfigItemValueMap()
+	configureCoreDumps(ctx)
 	log.Infof("handleGlobalConfigDelete done for %s", key)
 }
 
@@ -1568,6 +1712,7 @@ func handleNodeAgentStatusModify(ctxArg interface{}, key string,
 	ctx.rebootStack = status.RebootStack
 	ctx.rebootReason = status.RebootReason
 	ctx.restartCounter = status.RestartCounter
+	ctx.safeMode = status.SafeMode
 	// if config reboot command was initiated and
 	// was deferred, and the device is not in inprogress
 	// state, initiate the reboot process
@@ -1592,3 +1737,31 @@ func handleNodeAgentStatusDelete(ctxArg interface{}, key string,
 	// Nothing to do
 	triggerPublishDevInfo(ctx)
 }
+
+// staleHeartBeatTime is the gap between two consecutive HeartBeats of an
+// agent (HeartBeat.LoopDuration) above which handleHeartBeatModify calls
+// the later one out in our own logs, which ship to the controller through
+// the normal log pipeline. The check runs only when a HeartBeat arrives:
+// an agent that stops publishing altogether is not reported, and
+// HeartBeats are not included in device info.
+const staleHeartBeatTime = 5 * time.Minute
+
+func handleHeartBeatModify(ctxArg interface{}, key string,
+	statusArg interface{}) {
+
+	getconfigCtx := ctxArg.(*getconfigContext)
+	hb := statusArg.(types.HeartBeat)
+	if hb.LoopDuration > staleHeartBeatTime {
+		log.Warnf("handleHeartBeatModify: %s took %v between heartbeats",
+			hb.AgentName, hb.LoopDuration)
+	}
+	getconfigCtx.agentHeartBeats[hb.AgentName] = hb
+}
+
+func handleHeartBeatDelete(ctxArg interface{}, key string,
+	statusArg interface{}) {
+
+	getconfigCtx := ctxArg.(*getconfigContext)
+	log.Infof("handleHeartBeatDelete: for %s", key)
+	delete(getconfigCtx.agentHeartBeats, key)
+}
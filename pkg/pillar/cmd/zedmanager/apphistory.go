@@ -0,0 +1,73 @@
+// Copyright (c) 2022 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package zedmanager
+
+import (
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// recordAppInstanceCreate creates (or, across a reboot, re-publishes) the
+// AppInstanceHistory record for an app instance that is being created.
+// CreateTime is only set the first time; a pre-existing record (e.g. one
+// that outlived a prior instance of this same UUID) keeps its original
+// CreateTime.
+func recordAppInstanceCreate(ctx *zedmanagerContext, config types.AppInstanceConfig) {
+	key := config.UUIDandVersion.UUID.String()
+	hist := lookupAppInstanceHistory(ctx, key)
+	if hist == nil {
+		hist = &types.AppInstanceHistory{
+			UUID:       config.UUIDandVersion.UUID,
+			CreateTime: time.Now(),
+		}
+	}
+	hist.DisplayName = config.DisplayName
+	hist.Version = config.UUIDandVersion.Version
+	publishAppInstanceHistory(ctx, hist)
+}
+
+// recordAppInstancePurge updates the PurgeTime of the AppInstanceHistory
+// record for an app instance that is being purged.
+func recordAppInstancePurge(ctx *zedmanagerContext, status *types.AppInstanceStatus) {
+	key := status.Key()
+	hist := lookupAppInstanceHistory(ctx, key)
+	if hist == nil {
+		log.Errorf("recordAppInstancePurge(%s) no AppInstanceHistory", key)
+		return
+	}
+	hist.PurgeTime = time.Now()
+	publishAppInstanceHistory(ctx, hist)
+}
+
+// recordAppInstanceDelete sets the DeleteTime of the AppInstanceHistory
+// record for a deleted app instance. Unlike AppInstanceStatus, the
+// record itself is kept around (and remains Persistent across reboots)
+// so that UUIDs of long-deleted apps can still be resolved to a name.
+func recordAppInstanceDelete(ctx *zedmanagerContext, status *types.AppInstanceStatus) {
+	key := status.Key()
+	hist := lookupAppInstanceHistory(ctx, key)
+	if hist == nil {
+		log.Errorf("recordAppInstanceDelete(%s) no AppInstanceHistory", key)
+		return
+	}
+	hist.DeleteTime = time.Now()
+	publishAppInstanceHistory(ctx, hist)
+}
+
+func lookupAppInstanceHistory(ctx *zedmanagerContext, key string) *types.AppInstanceHistory {
+	i, err := ctx.pubAppInstanceHistory.Get(key)
+	if err != nil {
+		return nil
+	}
+	hist := i.(types.AppInstanceHistory)
+	return &hist
+}
+
+func publishAppInstanceHistory(ctx *zedmanagerContext, hist *types.AppInstanceHistory) {
+	key := hist.Key()
+	if err := ctx.pubAppInstanceHistory.Publish(key, *hist); err != nil {
+		log.Errorf("publishAppInstanceHistory(%s) failed: %s", key, err)
+	}
+}
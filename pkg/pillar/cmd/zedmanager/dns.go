@@ -0,0 +1,38 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package zedmanager
+
+import (
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// Handles both create and modify events
+func handleDNSModify(ctxArg interface{}, key string, statusArg interface{}) {
+
+	ctx := ctxArg.(*zedmanagerContext)
+	status := statusArg.(types.DeviceNetworkStatus)
+	if key != "global" {
+		log.Infof("handleDNSModify: ignoring %s", key)
+		return
+	}
+	log.Infof("handleDNSModify for %s", key)
+	if ctx.deviceNetworkStatus.MostlyEqual(status) {
+		log.Infof("handleDNSModify unchanged")
+		return
+	}
+	ctx.deviceNetworkStatus = status
+	log.Infof("handleDNSModify done for %s", key)
+}
+
+func handleDNSDelete(ctxArg interface{}, key string, statusArg interface{}) {
+
+	ctx := ctxArg.(*zedmanagerContext)
+	log.Infof("handleDNSDelete for %s", key)
+	if key != "global" {
+		log.Infof("handleDNSDelete: ignoring %s", key)
+		return
+	}
+	ctx.deviceNetworkStatus = types.DeviceNetworkStatus{}
+	log.Infof("handleDNSDelete done for %s", key)
+}
@@ -41,8 +41,16 @@ func MaybeAddDomainConfig(ctx *zedmanagerContext,
 		IsContainer:       aiStatus.IsContainer,
 		VmConfig:          aiConfig.FixedResources,
 		IoAdapterList:     aiConfig.IoAdapterList,
-		CloudInitUserData: aiConfig.CloudInitUserData,
+		CloudInitUserData: resolveCloudInitTemplate(ctx, aiConfig),
+		ResourceAlarms:    aiConfig.ResourceAlarms,
+		RestartPolicy:     aiConfig.RestartPolicy,
+		HealthProbe:       aiConfig.ContainerHealthProbe,
+		EnableCheckpoint:  aiConfig.EnableCheckpoint,
+		QuiesceHook:       aiConfig.QuiesceHook,
 		CipherBlockStatus: aiConfig.CipherBlockStatus,
+		Critical:          aiConfig.Critical,
+		ApparmorProfile:   aiConfig.ApparmorProfile,
+		SelinuxLabel:      aiConfig.SelinuxLabel,
 	}
 
 	dc.DiskConfigList = make([]types.DiskConfig, 0, len(aiStatus.VolumeRefStatusList))
@@ -65,6 +73,8 @@ func MaybeAddDomainConfig(ctx *zedmanagerContext,
 		disk.Format = vrs.ContentFormat
 		disk.MountDir = vrs.MountDir
 		disk.DisplayName = vrs.DisplayName
+		disk.HostDirSharePath = vrs.HostDirSharePath
+		disk.HostDirShareCacheMode = vrs.HostDirShareCacheMode
 		dc.DiskConfigList = append(dc.DiskConfigList, disk)
 	}
 	if ns != nil {
@@ -0,0 +1,124 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package zedmanager
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"text/template"
+
+	"github.com/lf-edge/eve/pkg/pillar/hardware"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// templateVars holds the device-local values that a site template may
+// reference, so one controller app definition (with e.g. "{{.SiteTag}}" in
+// its cloud-init user-data) can be instantiated unmodified across hundreds
+// of sites.
+type templateVars struct {
+	Serial      string
+	Hostname    string
+	SiteTag     string
+	UplinkIP    string
+	Annotations map[string]string
+}
+
+// siteTagFile is a device-local file, analogous to /config/server, that an
+// installer or onboarding flow can drop to identify which site/location
+// this device is deployed at.
+const siteTagFile = "/config/site-tag"
+
+// resolveTemplateVars substitutes templateVars into data using Go's
+// text/template syntax, e.g. "{{.Serial}}" or "{{.UplinkIP}}". data that
+// contains no "{{" is returned unchanged without attempting to parse it, so
+// plain (non-templated) user-data keeps working exactly as before. Cloud-init
+// user-data protected by CipherBlockStatus is decrypted in domainmgr, not
+// here, so it is never seen by this function and templating does not apply
+// to it.
+func resolveTemplateVars(ctx *zedmanagerContext, data string) string {
+	if !bytes.Contains([]byte(data), []byte("{{")) {
+		return data
+	}
+	t, err := template.New("appconfig").Option("missingkey=error").Parse(data)
+	if err != nil {
+		log.Warnf("resolveTemplateVars: parse failed, leaving data unmodified: %s", err)
+		return data
+	}
+	hostname, _ := os.Hostname()
+	vars := templateVars{
+		Serial:      hardware.GetProductSerial(log),
+		Hostname:    hostname,
+		SiteTag:     readSiteTag(),
+		Annotations: ctx.deviceAnnotations,
+	}
+	if uplinkIP, err := types.GetLocalAddrAnyNoLinkLocal(ctx.deviceNetworkStatus, 0, ""); err == nil {
+		vars.UplinkIP = uplinkIP.String()
+	}
+	var out bytes.Buffer
+	if err := t.Execute(&out, vars); err != nil {
+		log.Warnf("resolveTemplateVars: execute failed, leaving data unmodified: %s", err)
+		return data
+	}
+	return out.String()
+}
+
+// resolveCloudInitTemplate returns aiConfig's CloudInitUserData with any
+// per-site template variables substituted in, per resolveTemplateVars.
+// Cipher-protected cloud-init (aiConfig.IsCipher) is left untouched: it is
+// only decrypted later in domainmgr, so zedmanager never sees its plaintext
+// to template.
+func resolveCloudInitTemplate(ctx *zedmanagerContext,
+	aiConfig types.AppInstanceConfig) *string {
+
+	if aiConfig.CloudInitUserData == nil || aiConfig.IsCipher {
+		return aiConfig.CloudInitUserData
+	}
+	raw, err := base64.StdEncoding.DecodeString(*aiConfig.CloudInitUserData)
+	if err != nil {
+		log.Warnf("resolveCloudInitTemplate: base64 decode failed, leaving data unmodified: %s", err)
+		return aiConfig.CloudInitUserData
+	}
+	resolved := resolveTemplateVars(ctx, string(raw))
+	encoded := base64.StdEncoding.EncodeToString([]byte(resolved))
+	return &encoded
+}
+
+// handleDeviceAnnotationsModify picks up the device annotations zedagent
+// parsed out of ConfigItems (see parseAnnotationKey and
+// publishDeviceAnnotations in zedagent's parseconfig.go), for use by
+// resolveTemplateVars.
+func handleDeviceAnnotationsModify(ctxArg interface{}, key string,
+	statusArg interface{}) {
+
+	ctx := ctxArg.(*zedmanagerContext)
+	status := statusArg.(types.DeviceAnnotations)
+	if key != "global" {
+		log.Infof("handleDeviceAnnotationsModify: ignoring %s", key)
+		return
+	}
+	log.Infof("handleDeviceAnnotationsModify for %s", key)
+	ctx.deviceAnnotations = status.Annotations
+}
+
+func handleDeviceAnnotationsDelete(ctxArg interface{}, key string,
+	statusArg interface{}) {
+
+	ctx := ctxArg.(*zedmanagerContext)
+	log.Infof("handleDeviceAnnotationsDelete for %s", key)
+	if key != "global" {
+		log.Infof("handleDeviceAnnotationsDelete: ignoring %s", key)
+		return
+	}
+	ctx.deviceAnnotations = nil
+}
+
+func readSiteTag() string {
+	b, err := ioutil.ReadFile(siteTagFile)
+	if err != nil {
+		return ""
+	}
+	return string(bytes.TrimSpace(b))
+}
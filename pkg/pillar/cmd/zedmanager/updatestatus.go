@@ -7,11 +7,29 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/lf-edge/eve/pkg/pillar/healthprobe"
 	"github.com/lf-edge/eve/pkg/pillar/types"
 	"github.com/lf-edge/eve/pkg/pillar/uuidtonum"
 	"github.com/satori/go.uuid"
 )
 
+// blueGreenProbePasses reports whether it is safe to switch the purge
+// over to the newly activated instance. When BlueGreenUpgrade is not
+// requested this is always true, preserving the previous behavior of
+// switching over as soon as the domain is activated.
+func blueGreenProbePasses(config types.AppInstanceConfig, status *types.AppInstanceStatus) bool {
+	if !config.BlueGreenUpgrade || config.HealthProbePort == 0 {
+		return true
+	}
+	ok := healthprobe.TCPProbe(log, config.CollectStatsIPAddr, config.HealthProbePort)
+	status.BlueGreenProbeFailed = !ok
+	if !ok {
+		log.Warnf("blueGreenProbePasses(%s): health probe on port %d failed; holding switchover",
+			status.Key(), config.HealthProbePort)
+	}
+	return ok
+}
+
 // Update this AppInstanceStatus generate config updates to
 // the microservices
 func updateAIStatusUUID(ctx *zedmanagerContext, uuidStr string) {
@@ -148,12 +166,66 @@ func doUpdate(ctx *zedmanagerContext,
 		return changed
 	}
 	log.Infof("Have config.Activate for %s", uuidStr)
+	if waitingForBootOrder(ctx, config) {
+		if !status.WaitingForBootOrder {
+			status.WaitingForBootOrder = true
+			changed = true
+		}
+		log.Infof("Waiting for lower BootOrder app instances to reach RUNNING for %s", uuidStr)
+		return changed
+	}
+	if status.WaitingForBootOrder {
+		status.WaitingForBootOrder = false
+		changed = true
+	}
+	wasRunning := status.State == types.RUNNING
 	c = doActivate(ctx, uuidStr, config, status)
 	changed = changed || c
+	if !wasRunning && status.State == types.RUNNING {
+		unblockBootOrderWaiters(ctx)
+	}
 	log.Infof("doUpdate done for %s", uuidStr)
 	return changed
 }
 
+// waitingForBootOrder reports whether config's BootOrder should hold off
+// activation: BootOrder 0 means no ordering constraint, and otherwise every
+// other app instance with a lower non-zero BootOrder must already have
+// reached types.RUNNING.
+func waitingForBootOrder(ctx *zedmanagerContext, config types.AppInstanceConfig) bool {
+	if config.BootOrder == 0 {
+		return false
+	}
+	for _, st := range ctx.subAppInstanceConfig.GetAll() {
+		other := st.(types.AppInstanceConfig)
+		if other.Key() == config.Key() || other.BootOrder == 0 ||
+			other.BootOrder >= config.BootOrder {
+			continue
+		}
+		status := lookupAppInstanceStatus(ctx, other.Key())
+		if status == nil || status.State != types.RUNNING {
+			return true
+		}
+	}
+	return false
+}
+
+// unblockBootOrderWaiters re-runs doUpdate for every app instance currently
+// held off by waitingForBootOrder, in case the app instance that just
+// reached types.RUNNING was the last one they were waiting on.
+func unblockBootOrderWaiters(ctx *zedmanagerContext) {
+	for _, st := range ctx.subAppInstanceConfig.GetAll() {
+		config := st.(types.AppInstanceConfig)
+		status := lookupAppInstanceStatus(ctx, config.Key())
+		if status == nil || !status.WaitingForBootOrder {
+			continue
+		}
+		if doUpdate(ctx, config, status) {
+			publishAppInstanceStatus(ctx, status)
+		}
+	}
+}
+
 func doInstall(ctx *zedmanagerContext,
 	config types.AppInstanceConfig,
 	status *types.AppInstanceStatus) (bool, bool) {
@@ -549,11 +621,12 @@ func doActivate(ctx *zedmanagerContext, uuidStr string,
 		}
 	}
 	if status.PurgeInprogress == types.BringUp {
-		if ds.Activated {
+		if ds.Activated && blueGreenProbePasses(config, status) {
 			log.Infof("PurgeInprogress(%s) activated",
 				status.Key())
 			status.PurgeInprogress = types.NotInprogress
 			status.State = types.RUNNING
+			status.BlueGreenProbeFailed = false
 			_ = purgeCmdDone(ctx, config, status)
 			changed = true
 		} else {
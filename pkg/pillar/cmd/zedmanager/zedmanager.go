@@ -35,18 +35,23 @@ var Version = "No version specified"
 
 // State used by handlers
 type zedmanagerContext struct {
-	subAppInstanceConfig pubsub.Subscription
-	pubAppInstanceStatus pubsub.Publication
-	pubVolumeRefConfig   pubsub.Publication
-	subVolumeRefStatus   pubsub.Subscription
-	pubAppNetworkConfig  pubsub.Publication
-	subAppNetworkStatus  pubsub.Subscription
-	pubDomainConfig      pubsub.Publication
-	subDomainStatus      pubsub.Subscription
-	subGlobalConfig      pubsub.Subscription
-	globalConfig         *types.ConfigItemValueMap
-	pubUuidToNum         pubsub.Publication
-	GCInitialized        bool
+	subAppInstanceConfig   pubsub.Subscription
+	pubAppInstanceStatus   pubsub.Publication
+	pubVolumeRefConfig     pubsub.Publication
+	subVolumeRefStatus     pubsub.Subscription
+	pubAppNetworkConfig    pubsub.Publication
+	subAppNetworkStatus    pubsub.Subscription
+	pubDomainConfig        pubsub.Publication
+	subDomainStatus        pubsub.Subscription
+	subGlobalConfig        pubsub.Subscription
+	subDeviceNetworkStatus pubsub.Subscription
+	deviceNetworkStatus    types.DeviceNetworkStatus
+	subDeviceAnnotations   pubsub.Subscription
+	deviceAnnotations      map[string]string
+	globalConfig           *types.ConfigItemValueMap
+	pubUuidToNum           pubsub.Publication
+	pubAppInstanceHistory  pubsub.Publication
+	GCInitialized          bool
 }
 
 var debug = false
@@ -136,6 +141,20 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 	ctx.pubUuidToNum = pubUuidToNum
 	pubUuidToNum.ClearRestarted()
 
+	// AppInstanceHistory is Persistent and, unlike pubAppInstanceStatus,
+	// is never unpublished when an app instance is deleted; see
+	// handleDelete.
+	pubAppInstanceHistory, err := ps.NewPublication(pubsub.PublicationOptions{
+		AgentName:  agentName,
+		Persistent: true,
+		TopicType:  types.AppInstanceHistory{},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx.pubAppInstanceHistory = pubAppInstanceHistory
+	pubAppInstanceHistory.ClearRestarted()
+
 	// Look for global config such as log levels
 	subGlobalConfig, err := ps.NewSubscription(pubsub.SubscriptionOptions{
 		AgentName:     "",
@@ -234,6 +253,46 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 	ctx.subDomainStatus = subDomainStatus
 	subDomainStatus.Activate()
 
+	// Get DeviceNetworkStatus from nim, used to resolve the UplinkIP
+	// per-site template variable; see template.go
+	subDeviceNetworkStatus, err := ps.NewSubscription(pubsub.SubscriptionOptions{
+		AgentName:     "nim",
+		MyAgentName:   agentName,
+		TopicImpl:     types.DeviceNetworkStatus{},
+		Activate:      false,
+		Ctx:           &ctx,
+		CreateHandler: handleDNSModify,
+		ModifyHandler: handleDNSModify,
+		DeleteHandler: handleDNSDelete,
+		WarningTime:   warningTime,
+		ErrorTime:     errorTime,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx.subDeviceNetworkStatus = subDeviceNetworkStatus
+	subDeviceNetworkStatus.Activate()
+
+	// Get DeviceAnnotations from zedagent, used for the "{{.Annotations.*}}"
+	// per-site template variables; see template.go
+	subDeviceAnnotations, err := ps.NewSubscription(pubsub.SubscriptionOptions{
+		AgentName:     "zedagent",
+		MyAgentName:   agentName,
+		TopicImpl:     types.DeviceAnnotations{},
+		Activate:      false,
+		Ctx:           &ctx,
+		CreateHandler: handleDeviceAnnotationsModify,
+		ModifyHandler: handleDeviceAnnotationsModify,
+		DeleteHandler: handleDeviceAnnotationsDelete,
+		WarningTime:   warningTime,
+		ErrorTime:     errorTime,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx.subDeviceAnnotations = subDeviceAnnotations
+	subDeviceAnnotations.Activate()
+
 	// Pick up debug aka log level before we start real work
 	for !ctx.GCInitialized {
 		log.Infof("waiting for GCInitialized")
@@ -259,6 +318,12 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 		case change := <-subDomainStatus.MsgChan():
 			subDomainStatus.ProcessChange(change)
 
+		case change := <-subDeviceNetworkStatus.MsgChan():
+			subDeviceNetworkStatus.ProcessChange(change)
+
+		case change := <-subDeviceAnnotations.MsgChan():
+			subDeviceAnnotations.ProcessChange(change)
+
 		case change := <-subAppInstanceConfig.MsgChan():
 			subAppInstanceConfig.ProcessChange(change)
 
@@ -380,9 +445,13 @@ func handleCreate(ctxArg interface{}, key string,
 		IoAdapterList:       config.IoAdapterList,
 		RestartCmd:          config.RestartCmd,
 		PurgeCmd:            config.PurgeCmd,
+		Critical:            config.Critical,
+		BootOrder:           config.BootOrder,
 		State:               types.INITIAL,
 	}
 
+	recordAppInstanceCreate(ctx, config)
+
 	// Do we have a PurgeCmd counter from before the reboot?
 	c, err := uuidtonum.UuidToNumGet(log, ctx.pubUuidToNum,
 		config.UUIDandVersion.UUID, "purgeCmdCounter")
@@ -528,6 +597,7 @@ func handleModify(ctxArg interface{}, key string,
 		status.PurgeInprogress = types.RecreateVolumes
 		status.State = types.PURGING
 		// We persist the PurgeCmd Counter when PurgeInprogress is done
+		recordAppInstancePurge(ctx, status)
 	} else if needPurge {
 		errStr := fmt.Sprintf("Need purge due to %s but not a purgeCmd",
 			purgeReason)
@@ -548,6 +618,8 @@ func handleModify(ctxArg interface{}, key string,
 	status.FixedResources = config.FixedResources
 	status.UnderlayNetworkList = config.UnderlayNetworkList
 	status.IoAdapterList = config.IoAdapterList
+	status.Critical = config.Critical
+	status.BootOrder = config.BootOrder
 	publishAppInstanceStatus(ctx, status)
 	log.Infof("handleModify done for %s", config.DisplayName)
 }
@@ -561,6 +633,10 @@ func handleDelete(ctx *zedmanagerContext, key string,
 	removeAIStatus(ctx, status)
 	// Remove the recorded PurgeCmd Counter
 	uuidtonum.UuidToNumDelete(log, ctx.pubUuidToNum, status.UUIDandVersion.UUID)
+	// Unlike UuidToNum, the AppInstanceHistory record is kept (with its
+	// DeleteTime now set) so the UUID can still be resolved to a name
+	// later.
+	recordAppInstanceDelete(ctx, status)
 	log.Infof("handleDelete done for %s", status.DisplayName)
 }
 
@@ -244,6 +244,9 @@ func createACLConfiglet(aclArgs types.AppNetworkACLArgs,
 		return rules, err
 	}
 	rules = append(rules, dropRules...)
+	// Anti-spoofing and rogue-DHCP-server filters go ahead of the user
+	// ACLs so that they can't be relaxed by a user-configured accept.
+	rules = append(antiSpoofRules(aclArgs), rules...)
 	return applyACLRules(aclArgs, rules)
 }
 
@@ -595,6 +598,65 @@ func aclDropRules(aclArgs types.AppNetworkACLArgs) (types.IPTablesRuleList, erro
 	return rulesList, nil
 }
 
+// antiSpoofRules builds the ingress filters that apply regardless of the
+// user-configured ACLs: the app may only source traffic using the MAC and
+// IP address assigned to it, and on switch network instances it may not
+// answer DHCP requests on behalf of the (non-existent) DHCP server. Drops
+// here land in the same -i/--physdev-in bucket as the default drop rule
+// below, so they show up in the existing per-app TxAclDrops/RxAclDrops
+// counters without any new counter plumbing.
+func antiSpoofRules(aclArgs types.AppNetworkACLArgs) types.IPTablesRuleList {
+	var rulesList types.IPTablesRuleList
+	if aclArgs.IsMgmt {
+		return rulesList
+	}
+
+	if len(aclArgs.AppMacAddr) != 0 {
+		var macRule types.IPTablesRule
+		macRule.IPVer = aclArgs.IPVer
+		macRule.Rule = []string{"-i", aclArgs.BridgeName, "-m", "mac",
+			"!", "--mac-source", aclArgs.AppMacAddr.String()}
+		macRule.Action = []string{"-j", "DROP"}
+		rulesList = append(rulesList, macRule)
+	}
+
+	dhcpClientPort, dhcpServerPort := "bootpc", "bootps"
+	if aclArgs.IPVer == 6 {
+		dhcpClientPort, dhcpServerPort = "dhcpv6-client", "dhcpv6-server"
+	}
+
+	if aclArgs.AppIP != "" {
+		// Let the initial DHCP negotiation through before locking the vif
+		// down to its assigned address; it is sent from the unassigned/
+		// unspecified address.
+		var dhcpNegotiate types.IPTablesRule
+		dhcpNegotiate.IPVer = aclArgs.IPVer
+		dhcpNegotiate.Rule = []string{"-i", aclArgs.BridgeName, "-p", "udp",
+			"--sport", dhcpClientPort, "--dport", dhcpServerPort}
+		dhcpNegotiate.Action = []string{"-j", "ACCEPT"}
+		rulesList = append(rulesList, dhcpNegotiate)
+
+		var ipRule types.IPTablesRule
+		ipRule.IPVer = aclArgs.IPVer
+		ipRule.Rule = []string{"-i", aclArgs.BridgeName, "!", "-s", aclArgs.AppIP}
+		ipRule.Action = []string{"-j", "DROP"}
+		rulesList = append(rulesList, ipRule)
+	}
+
+	if aclArgs.NIType == types.NetworkInstanceTypeSwitch {
+		// An app on a switch network instance has no business acting as a
+		// DHCP server for its neighbors.
+		var noRogueDhcp types.IPTablesRule
+		noRogueDhcp.IPVer = aclArgs.IPVer
+		noRogueDhcp.Rule = []string{"-i", aclArgs.BridgeName, "-p", "udp",
+			"--sport", dhcpServerPort}
+		noRogueDhcp.Action = []string{"-j", "DROP"}
+		rulesList = append(rulesList, noRogueDhcp)
+	}
+
+	return rulesList
+}
+
 func aceToRules(aclArgs types.AppNetworkACLArgs, ace types.ACE) (types.IPTablesRuleList,
 	error) {
 	var rulesList types.IPTablesRuleList
@@ -44,9 +44,11 @@ neg-ttl=10
 dhcp-ttl=600
 `
 
-// dnsmasqLeaseDir is used to for the leases
-// of bridgeNames
-var dnsmasqLeaseDir = runDirname + "/dnsmasq.leases/"
+// dnsmasqLeaseDir is used to for the leases of bridgeNames. It lives under
+// types.DnsmasqLeaseDirname on /persist, rather than zedrouter's /var/run
+// scratch dir, so app instances get back the same IP address across an EVE
+// reboot instead of racing the controller's static IP assignment.
+var dnsmasqLeaseDir = types.DnsmasqLeaseDirname + "/"
 
 // dnsmasqLeasePath provides a unique file
 // We traverse the dnsmasqLeaseDir directory to get the list of bridgeNames
@@ -481,6 +483,10 @@ func checkAndPublishDhcpLeases(ctx *zedrouterContext) {
 			ulStatus := &status.UnderlayNetworkList[i]
 			l := findLease(ctx, status.Key(), ulStatus.Mac, true)
 			assigned := (l != nil)
+			if assigned && ulStatus.LeaseExpires != l.LeaseTime {
+				ulStatus.LeaseExpires = l.LeaseTime
+				changed = true
+			}
 			if ulStatus.Assigned != assigned {
 				log.Infof("Changing(%s) %s mac %s to %t",
 					status.Key(), status.DisplayName,
@@ -488,6 +494,7 @@ func checkAndPublishDhcpLeases(ctx *zedrouterContext) {
 				ulStatus.Assigned = assigned
 				if !assigned {
 					ulStatus.IPAddrMisMatch = true
+					ulStatus.LeaseExpires = time.Time{}
 					changed = true
 					continue
 				}
@@ -657,6 +664,7 @@ func readLeases(bridgeName string) ([]dnsmasqLease, error) {
 	if err != nil {
 		return leases, err
 	}
+	defer fileDesc.Close()
 	reader := bufio.NewReader(fileDesc)
 	for {
 		line, err := reader.ReadString('\n')
@@ -682,6 +690,18 @@ func readLeases(bridgeName string) ([]dnsmasqLease, error) {
 			log.Errorf("Bad unix time %s: %s", tokens[0], err)
 			i = 0
 		}
+		// Now that the lease database survives across reboots (see
+		// types.DnsmasqLeaseDirname), an unclean shutdown can leave a
+		// partially-written record behind; skip it rather than feeding a
+		// bogus MAC/IP into ctx.dhcpLeases.
+		if _, err := net.ParseMAC(tokens[1]); err != nil {
+			log.Errorf("Bad MAC address %s in leases file: %s", tokens[1], err)
+			continue
+		}
+		if net.ParseIP(tokens[2]) == nil {
+			log.Errorf("Bad IP address %s in leases file", tokens[2])
+			continue
+		}
 		lease := dnsmasqLease{
 			BridgeName: bridgeName,
 			LastSeen:   info.ModTime(),
@@ -7,6 +7,8 @@ package zedrouter
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"strconv"
@@ -104,6 +106,19 @@ var loopcount int // XXX debug
 var dnssys [maxBridgeNumber]dnsSys // per bridge DNS records for the collection period
 var devUUID, nilUUID uuid.UUID
 var broadcastMAC = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+var flowSampleCounter uint32 // counts every observed flow, sampled against ctx.flowlogSamplingFactor
+
+// sampleFlow reports whether the current flow should be kept, given
+// ctx.flowlogSamplingFactor (e.g. a factor of 5 keeps 1 in 5 flows).
+func sampleFlow(ctx *zedrouterContext) bool {
+	factor := ctx.flowlogSamplingFactor
+	if factor == 0 {
+		factor = 1
+	}
+	keep := flowSampleCounter%factor == 0
+	flowSampleCounter++
+	return keep
+}
 
 // FlowStatsCollect : Timer fired to collect iptable flow stats
 func FlowStatsCollect(ctx *zedrouterContext) {
@@ -266,6 +281,9 @@ func FlowStatsCollect(ctx *zedrouterContext) {
 					RxPkts:    int64(tuple.RecvPkts),
 				}
 
+				if !sampleFlow(ctx) {
+					continue
+				}
 				flowdata.Flows = append(flowdata.Flows, flowrec)
 				flowIdx++
 				if flowIdx > maxFlowPack {
@@ -301,8 +319,12 @@ func FlowStatsCollect(ctx *zedrouterContext) {
 					log.Debugf("!!FlowStats: DNS time %v, domain %s, appIP %v, count %d, Answers %v",
 						dnsRec.TimeStamp, dnsRec.DomainName, dnsRec.AppIP, dnsRec.ANCount, dnsRec.Answers)
 
+					hostName := dnsRec.DomainName
+					if niConfig := lookupNetworkInstanceConfig(ctx, instData.bnNet[bnx].netUUID.String()); niConfig != nil {
+						hostName = redactDNSQueryName(hostName, niConfig.DNSQueryLogPrivacy)
+					}
 					dnsrec := types.DNSReq{
-						HostName:    dnsRec.DomainName,
+						HostName:    hostName,
 						Addrs:       dnsRec.Answers,
 						RequestTime: dnsRec.TimeStamp.UnixNano(),
 					}
@@ -585,6 +607,25 @@ func checkAppAndACL(ctx *zedrouterContext, instData *networkAttrs) {
 	}
 }
 
+// redactDNSQueryName applies mode's privacy redaction to a DNS query name
+// before it is packed into IPFlow.DNSReqs for export off the device.
+func redactDNSQueryName(name string, mode types.DNSQueryLogPrivacyMode) string {
+	switch mode {
+	case types.DNSQueryLogPrivacyHash:
+		sum := sha256.Sum256([]byte(name))
+		return hex.EncodeToString(sum[:8])
+	case types.DNSQueryLogPrivacyTruncate:
+		name = strings.TrimSuffix(name, ".")
+		labels := strings.Split(name, ".")
+		if len(labels) <= 2 {
+			return name
+		}
+		return strings.Join(labels[len(labels)-2:], ".")
+	default:
+		return name
+	}
+}
+
 func flowPublish(ctx *zedrouterContext, flowdata *types.IPFlow, seq, idx *int) {
 	var flowKey string
 	scope := flowdata.Scope
@@ -592,6 +633,13 @@ func flowPublish(ctx *zedrouterContext, flowdata *types.IPFlow, seq, idx *int) {
 		scope.Sequence = strconv.Itoa(*seq)
 	}
 	flowKey = scope.UUID.String() + scope.NetUUID.String() + scope.Sequence
+	// Echo the sampling rate in effect so downstream analytics can scale
+	// these counts back up to an estimate of the true total.
+	factor := ctx.flowlogSamplingFactor
+	if factor == 0 {
+		factor = 1
+	}
+	flowdata.SampleFactor = int32(factor)
 	ctx.pubAppFlowMonitor.Publish(flowKey, *flowdata)
 	log.Infof("FlowStats: publish to zedagent: total records %d, sequence %d\n", *idx, *seq)
 	*seq++
@@ -14,14 +14,21 @@ import (
 	uuid "github.com/satori/go.uuid"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/eriknordmark/netlink"
 	"github.com/lf-edge/eve/pkg/pillar/agentlog"
+	"github.com/lf-edge/eve/pkg/pillar/arpprobe"
 	"github.com/lf-edge/eve/pkg/pillar/devicenetwork"
 	"github.com/lf-edge/eve/pkg/pillar/iptables"
 	"github.com/lf-edge/eve/pkg/pillar/types"
 )
 
+// arpProbeTimeout bounds how long setBridgeIPAddr waits for an ARP reply
+// before concluding nobody else on the segment claims the address it is
+// about to assign.
+const arpProbeTimeout = 300 * time.Millisecond
+
 func allowSharedPort(status *types.NetworkInstanceStatus) bool {
 	return status.Type != types.NetworkInstanceTypeSwitch
 }
@@ -1073,6 +1080,29 @@ func setBridgeIPAddr(
 		return nil
 	}
 
+	// Before claiming this address on the bridge, check that no other
+	// host on the segment already answers for it -- catches a site that
+	// happens to reuse one of our default network instance subnets,
+	// which otherwise fails silently (both sides think they own the
+	// address and connectivity degrades without any obvious error).
+	if ip := net.ParseIP(status.BridgeIPAddr); ip != nil && ip.To4() != nil {
+		if conflict, err := arpprobe.Probe(status.BridgeName, ip, arpProbeTimeout); err != nil {
+			log.Warnf("setBridgeIPAddr: ARP probe for %s on %s failed: %v",
+				ip, status.BridgeName, err)
+		} else if conflict != nil {
+			errStr := fmt.Sprintf("address conflict: %s is already in use by %s on %s",
+				conflict.IP, conflict.MAC, status.BridgeName)
+			log.Errorf("setBridgeIPAddr: %s", errStr)
+			status.SetErrorNow(errStr)
+			publishNetworkInstanceStatus(ctx, status)
+			// Do not claim an address that another host on the segment
+			// is already answering for; leave the bridge without an
+			// IP rather than creating the exact outage this probe is
+			// meant to prevent.
+			return errors.New(errStr)
+		}
+	}
+
 	prefixLen := getPrefixLenForBridgeIP(status)
 	if err = doConfigureIpAddrOnInterface(ipAddr, prefixLen, link); err != nil {
 		log.Errorf("Failed to configure IPAddr on Interface\n")
@@ -1180,6 +1210,7 @@ func doNetworkInstanceActivate(ctx *zedrouterContext,
 	aclArgs := types.AppNetworkACLArgs{IsMgmt: false, BridgeName: status.BridgeName,
 		BridgeIP: status.BridgeIPAddr, NIType: status.Type, UpLinks: status.IfNameList}
 	handleNetworkInstanceACLConfiglet("-A", aclArgs)
+	maybeSetupNITrafficMirror(ctx, status)
 	return err
 }
 
@@ -1236,6 +1267,7 @@ func doNetworkInstanceInactivate(
 	log.Infof("doNetworkInstanceInactivate NetworkInstance key %s type %d\n",
 		status.UUID, status.Type)
 
+	maybeTeardownNITrafficMirror(status)
 	bridgeInactivateforNetworkInstance(ctx, status)
 	switch status.Type {
 	case types.NetworkInstanceTypeLocal:
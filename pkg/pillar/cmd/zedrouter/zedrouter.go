@@ -84,6 +84,7 @@ type zedrouterContext struct {
 	appCollectStatsRunning    bool
 	appStatsMutex             sync.Mutex // to protect the changing appNetworkStatus & appCollectStatsRunning
 	appStatsInterval          uint32
+	flowlogSamplingFactor     uint32         // only 1 in this many flow records is uploaded
 	aclog                     *logrus.Logger // App Container logger
 }
 
@@ -196,6 +197,7 @@ func Run(ps *pubsub.PubSub, loggerArg *logrus.Logger, logArg *base.LogObject) in
 
 	gcp := *types.DefaultConfigItemValueMap()
 	zedrouterCtx.appStatsInterval = gcp.GlobalValueInt(types.AppContainerStatsInterval)
+	zedrouterCtx.flowlogSamplingFactor = gcp.GlobalValueInt(types.FlowlogSamplingFactor)
 
 	// Look for global config such as log levels
 	subGlobalConfig, err := ps.NewSubscription(pubsub.SubscriptionOptions{
@@ -939,7 +941,7 @@ func appNetworkDoActivateUnderlayNetwork(
 	aclArgs := types.AppNetworkACLArgs{IsMgmt: false, BridgeName: bridgeName,
 		VifName: vifName, BridgeIP: bridgeIPAddr, AppIP: appIPAddr,
 		UpLinks: netInstStatus.IfNameList, NIType: netInstStatus.Type,
-		AppNum: int32(status.AppNum)}
+		AppNum: int32(status.AppNum), AppMacAddr: ulStatus.AppMacAddr}
 
 	// Set up ACLs
 	ruleList, err := createACLConfiglet(aclArgs, ulStatus.ACLs)
@@ -972,6 +974,8 @@ func appNetworkDoActivateUnderlayNetwork(
 	networkInstanceInfo.AddVif(log, vifName, appMac,
 		config.UUIDandVersion.UUID)
 	networkInstanceInfo.BridgeIPSets = newIpsets
+
+	maybeSetupUnderlayMirror(ctx, ulConfig, ulStatus)
 	log.Infof("set BridgeIPSets to %v for %s", newIpsets,
 		networkInstanceInfo.BridgeName)
 
@@ -1316,7 +1320,7 @@ func doAppNetworkModifyUnderlayNetwork(
 	aclArgs := types.AppNetworkACLArgs{IsMgmt: false, BridgeName: bridgeName,
 		VifName: ulStatus.Vif, BridgeIP: ulStatus.BridgeIPAddr, AppIP: appIPAddr,
 		UpLinks: netstatus.IfNameList, NIType: netstatus.Type,
-		AppNum: int32(status.AppNum)}
+		AppNum: int32(status.AppNum), AppMacAddr: ulStatus.AppMacAddr}
 
 	// We ignore any errors in netstatus
 
@@ -1478,6 +1482,8 @@ func appNetworkDoInactivateUnderlayNetwork(
 		VifName: ulStatus.Vif, BridgeIP: ulStatus.BridgeIPAddr, AppIP: appIPAddr,
 		UpLinks: netstatus.IfNameList}
 
+	maybeTeardownUnderlayMirror(ulStatus)
+
 	// XXX Could ulStatus.Vif not be set? Means we didn't add
 	if ulStatus.Vif != "" {
 		ruleList, err := deleteACLConfiglet(aclArgs, ulStatus.ACLRules)
@@ -1561,6 +1567,7 @@ func handleGlobalConfigModify(ctxArg interface{}, key string,
 	if gcp != nil {
 		ctx.GCInitialized = true
 		ctx.appStatsInterval = gcp.GlobalValueInt(types.AppContainerStatsInterval)
+		ctx.flowlogSamplingFactor = gcp.GlobalValueInt(types.FlowlogSamplingFactor)
 	}
 	log.Infof("handleGlobalConfigModify done for %s\n", key)
 }
@@ -1578,6 +1585,7 @@ func handleGlobalConfigDelete(ctxArg interface{}, key string,
 		debugOverride, logger)
 	gcp := *types.DefaultConfigItemValueMap()
 	ctx.appStatsInterval = gcp.GlobalValueInt(types.AppContainerStatsInterval)
+	ctx.flowlogSamplingFactor = gcp.GlobalValueInt(types.FlowlogSamplingFactor)
 	log.Infof("handleGlobalConfigDelete done for %s\n", key)
 }
 
@@ -0,0 +1,151 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package consolewatch provides a lightweight pattern-match engine on top
+// of the memlogd console log stream, so that basic in-guest failure
+// conditions (e.g. a kernel panic string) can be detected without running
+// an agent inside the app.
+package consolewatch
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+)
+
+const logReadSocket = "/var/run/memlogdq.sock"
+
+// logDumpCommand requests memlogd to replay its buffered log lines over
+// the connection before closing it; see containerd/logging.go's Dump for
+// the same protocol used to print an app's console to stdout.
+const logDumpCommand byte = 0
+
+// pollInterval is how often we re-request the buffered log and scan the
+// lines that are new since the last poll, since memlogd only supports a
+// dump-then-close request, not an open-ended subscription.
+const pollInterval = 2 * time.Second
+
+// PatternRule matches a single regexp against an app's console output and
+// names the rule so matches can be attributed back to it.
+type PatternRule struct {
+	Name    string
+	Pattern string
+}
+
+// MatchFunc is invoked with the rule that matched and the console line
+// that triggered it.
+type MatchFunc func(rule PatternRule, line string)
+
+// Watcher streams one app's console log from memlogd and evaluates a set
+// of compiled PatternRules against every line.
+type Watcher struct {
+	log      *base.LogObject
+	appName  string
+	rules    []PatternRule
+	compiled []*regexp.Regexp
+	onMatch  MatchFunc
+	done     chan struct{}
+}
+
+// NewWatcher compiles rules and returns a Watcher for appName. Invalid
+// regexes are logged and skipped rather than failing the whole set, since
+// one bad rule should not disable detection of the others.
+func NewWatcher(log *base.LogObject, appName string, rules []PatternRule, onMatch MatchFunc) *Watcher {
+	w := &Watcher{
+		log:     log,
+		appName: appName,
+		onMatch: onMatch,
+		done:    make(chan struct{}),
+	}
+	for _, r := range rules {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			log.Errorf("consolewatch: skipping rule %s with invalid pattern %q: %s", r.Name, r.Pattern, err)
+			continue
+		}
+		w.rules = append(w.rules, r)
+		w.compiled = append(w.compiled, re)
+	}
+	return w
+}
+
+// Start begins tailing the console log in a goroutine. Call Stop to end it.
+func (w *Watcher) Start() {
+	go w.run()
+}
+
+// Stop ends the tail goroutine started by Start.
+func (w *Watcher) Stop() {
+	close(w.done)
+}
+
+func (w *Watcher) run() {
+	if len(w.compiled) == 0 {
+		return
+	}
+	seen := 0
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			seen = w.scanOnce(seen)
+		}
+	}
+}
+
+// scanOnce dumps the current buffered log, skips the first alreadySeen
+// matching lines (already evaluated on a previous poll), evaluates the
+// rest, and returns the updated count of matching lines seen so far.
+func (w *Watcher) scanOnce(alreadySeen int) int {
+	addr := net.UnixAddr{Name: logReadSocket, Net: "unix"}
+	conn, err := net.DialUnix("unix", nil, &addr)
+	if err != nil {
+		w.log.Warnf("consolewatch(%s): failed to connect to memlogd: %s", w.appName, err)
+		return alreadySeen
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte{logDumpCommand}); err != nil {
+		w.log.Warnf("consolewatch(%s): failed to request log dump: %s", w.appName, err)
+		return alreadySeen
+	}
+	reader := bufio.NewReader(conn)
+	count := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			w.log.Warnf("consolewatch(%s): error reading log dump: %s", w.appName, err)
+			break
+		}
+		// a line is of the form <timestamp>,<source>;<body>
+		prefixBody := strings.SplitN(line, ";", 2)
+		if len(prefixBody) != 2 {
+			continue
+		}
+		csv := strings.Split(prefixBody[0], ",")
+		if len(csv) < 2 || csv[1] != w.appName {
+			continue
+		}
+		count++
+		if count <= alreadySeen {
+			continue
+		}
+		body := prefixBody[1]
+		for i, re := range w.compiled {
+			if re.MatchString(body) {
+				w.onMatch(w.rules[i], body)
+			}
+		}
+	}
+	return count
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package consolewatch
+
+import (
+	"testing"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNewWatcherSkipsInvalidPattern(t *testing.T) {
+	logger := logrus.StandardLogger()
+	log := base.NewSourceLogObject(logger, "test", 0)
+	rules := []PatternRule{
+		{Name: "panic", Pattern: "Kernel panic"},
+		{Name: "bad", Pattern: "("},
+	}
+	w := NewWatcher(log, "myapp", rules, func(rule PatternRule, line string) {})
+	if len(w.compiled) != 1 {
+		t.Errorf("expected 1 compiled rule, got %d", len(w.compiled))
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/images/archive"
+	"github.com/containerd/containerd/platforms"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// checkpointImageName is the transient containerd image reference a
+// checkpoint is created under before being exported; it is deleted from
+// containerd's own (ephemeral, not persisted across a reboot) image store
+// once exported, since checkpointPath on /persist is the copy of record.
+func checkpointImageName(domainName string) string {
+	return "eve-checkpoint/" + domainName
+}
+
+// CheckpointPath returns the on-disk location domainName's checkpoint is
+// (or would be) stored at, under types.ContainerCheckpointDirname.
+func CheckpointPath(domainName string) string {
+	return filepath.Join(types.ContainerCheckpointDirname, domainName+".tar")
+}
+
+// CtrCheckpointTask checkpoints domainName's running task - process
+// state, and, via CRIU, in-memory state - to checkpointPath, an OCI-
+// layout tarball analogous to the one CtrExportImage writes for an
+// image. It requires the containerd-shim's runtime to have CRIU
+// installed; without it, the underlying runc checkpoint call fails and
+// this returns that error unchanged.
+func (client *Client) CtrCheckpointTask(ctx context.Context, domainName string, checkpointPath string) error {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return fmt.Errorf("CtrCheckpointTask: exception while verifying ctrd client: %s", err.Error())
+	}
+	ctr, err := client.CtrLoadContainer(ctx, domainName)
+	if err != nil {
+		return fmt.Errorf("CtrCheckpointTask: could not load container: %v", err)
+	}
+
+	ref := checkpointImageName(domainName)
+	img, err := ctr.Checkpoint(ctx, ref, containerd.WithCheckpointTask)
+	if err != nil {
+		return fmt.Errorf("CtrCheckpointTask: checkpoint failed (is CRIU installed?): %v", err)
+	}
+	defer func() {
+		if delErr := client.ctrdClient.ImageService().Delete(ctx, img.Name()); delErr != nil {
+			client.log.Warnf("CtrCheckpointTask(%s): couldn't delete transient checkpoint image %s: %v",
+				domainName, img.Name(), delErr)
+		}
+	}()
+
+	if err := os.MkdirAll(filepath.Dir(checkpointPath), 0700); err != nil {
+		return fmt.Errorf("CtrCheckpointTask: could not create checkpoint dir for %s: %v", checkpointPath, err)
+	}
+	f, err := os.Create(checkpointPath)
+	if err != nil {
+		return fmt.Errorf("CtrCheckpointTask: could not create %s: %v", checkpointPath, err)
+	}
+	defer f.Close()
+
+	if err := client.ctrdClient.Export(ctx, f,
+		archive.WithImage(client.ctrdClient.ImageService(), img.Name()),
+		archive.WithPlatform(platforms.Default())); err != nil {
+		return fmt.Errorf("CtrCheckpointTask: could not export checkpoint to %s: %v",
+			checkpointPath, err)
+	}
+	return nil
+}
+
+// CtrRestoreTask creates domainName's task from checkpointPath, as written
+// by a prior CtrCheckpointTask, loading the checkpoint's process and
+// CRIU-restored memory state instead of starting fresh. Like
+// CtrCreateTask, it only creates the task - the caller still calls
+// CtrStartTask (e.g. via hyper.Task().Start()) to resume it, so restoring
+// a checkpoint slots into the hypervisor's normal create-then-start
+// sequence unchanged. The container itself (domainName) must already
+// exist, e.g. via the hypervisor's normal create path, but must not yet
+// have a task.
+func (client *Client) CtrRestoreTask(ctx context.Context, domainName string, checkpointPath string) (int, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return 0, fmt.Errorf("CtrRestoreTask: exception while verifying ctrd client: %s", err.Error())
+	}
+	ctr, err := client.CtrLoadContainer(ctx, domainName)
+	if err != nil {
+		return 0, fmt.Errorf("CtrRestoreTask: could not load container: %v", err)
+	}
+
+	f, err := os.Open(checkpointPath)
+	if err != nil {
+		return 0, fmt.Errorf("CtrRestoreTask: could not open %s: %v", checkpointPath, err)
+	}
+	defer f.Close()
+
+	imported, err := client.ctrdClient.Import(ctx, f)
+	if err != nil {
+		return 0, fmt.Errorf("CtrRestoreTask: could not import checkpoint %s: %v", checkpointPath, err)
+	}
+	if len(imported) == 0 {
+		return 0, fmt.Errorf("CtrRestoreTask: checkpoint %s contained no image", checkpointPath)
+	}
+	checkpointImg, err := client.ctrdClient.GetImage(ctx, imported[0].Name)
+	if err != nil {
+		return 0, fmt.Errorf("CtrRestoreTask: could not load imported checkpoint image: %v", err)
+	}
+	defer func() {
+		if delErr := client.ctrdClient.ImageService().Delete(ctx, checkpointImg.Name()); delErr != nil {
+			client.log.Warnf("CtrRestoreTask(%s): couldn't delete transient checkpoint image %s: %v",
+				domainName, checkpointImg.Name(), delErr)
+		}
+	}()
+
+	logger := GetLog(client.log)
+	io := func(id string) (cio.IO, error) {
+		stdoutFile := logger.Path("guest_vm-" + domainName)
+		stderrFile := logger.Path("guest_vm_err-" + domainName)
+		return &logio{
+			cio.Config{
+				Stdin:    "/dev/null",
+				Stdout:   stdoutFile,
+				Stderr:   stderrFile,
+				Terminal: false,
+			},
+		}, nil
+	}
+	task, err := ctr.NewTask(ctx, io, containerd.WithTaskCheckpoint(checkpointImg))
+	if err != nil {
+		return 0, fmt.Errorf("CtrRestoreTask: could not create task from checkpoint (is CRIU installed?): %v", err)
+	}
+	return int(task.Pid()), nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/platforms"
+	"github.com/containerd/containerd/rootfs"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// CtrCommitSnapshot diffs the writable snapshot snapshotID against its
+// parent and commits the result as a new image newRef layered on top of
+// parentImage, the image the snapshot was prepared from (e.g. via
+// CtrPrepareSnapshot). This is the moral equivalent of "docker commit":
+// it lets a field engineer capture a running app container's filesystem
+// changes - for debugging, or to seed a golden image - without having to
+// reconstruct them from scratch.
+func (client *Client) CtrCommitSnapshot(ctx context.Context, snapshotID string, parentImage containerd.Image, newRef string) (images.Image, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return images.Image{}, fmt.Errorf("CtrCommitSnapshot: exception while verifying ctrd client: %s", err.Error())
+	}
+
+	platform := platforms.Default()
+	parentManifest, err := images.Manifest(ctx, client.contentStore, parentImage.Target(), platform)
+	if err != nil {
+		return images.Image{}, fmt.Errorf("CtrCommitSnapshot: could not read parent manifest: %v", err)
+	}
+	parentConfigDesc, err := images.Config(ctx, client.contentStore, parentImage.Target(), platform)
+	if err != nil {
+		return images.Image{}, fmt.Errorf("CtrCommitSnapshot: could not read parent config: %v", err)
+	}
+	var parentConfig ocispec.Image
+	if err := readJSON(ctx, client.contentStore, parentConfigDesc, &parentConfig); err != nil {
+		return images.Image{}, fmt.Errorf("CtrCommitSnapshot: could not decode parent config: %v", err)
+	}
+
+	snapshotter := client.ctrdClient.SnapshotService(defaultSnapshotter)
+	layerDesc, err := rootfs.CreateDiff(ctx, snapshotID, snapshotter, client.ctrdClient.DiffService())
+	if err != nil {
+		return images.Image{}, fmt.Errorf("CtrCommitSnapshot: could not diff snapshot %s: %v", snapshotID, err)
+	}
+
+	newConfig := parentConfig
+	newConfig.RootFS.DiffIDs = append(append([]digest.Digest{}, parentConfig.RootFS.DiffIDs...), layerDesc.Digest)
+	newConfig.History = append(append([]ocispec.History{}, parentConfig.History...), ocispec.History{
+		Created:   &[]time.Time{time.Now()}[0],
+		CreatedBy: fmt.Sprintf("eve commit of snapshot %s", snapshotID),
+		Comment:   "CtrCommitSnapshot",
+	})
+	newConfigDesc, err := writeJSON(ctx, client.contentStore, newConfig, ocispec.MediaTypeImageConfig)
+	if err != nil {
+		return images.Image{}, fmt.Errorf("CtrCommitSnapshot: could not write new config: %v", err)
+	}
+
+	newManifest := ocispec.Manifest{
+		Versioned: parentManifest.Versioned,
+		Config:    newConfigDesc,
+		Layers:    append(append([]ocispec.Descriptor{}, parentManifest.Layers...), layerDesc),
+	}
+	newManifestDesc, err := writeJSON(ctx, client.contentStore, newManifest, ocispec.MediaTypeImageManifest)
+	if err != nil {
+		return images.Image{}, fmt.Errorf("CtrCommitSnapshot: could not write new manifest: %v", err)
+	}
+
+	newImage := images.Image{
+		Name:      newRef,
+		Target:    newManifestDesc,
+		CreatedAt: time.Now(),
+	}
+	return client.ctrdClient.ImageService().Create(ctx, newImage)
+}
+
+// readJSON reads and decodes the content-addressed blob named by desc.
+func readJSON(ctx context.Context, store content.Store, desc ocispec.Descriptor, out interface{}) error {
+	raw, err := content.ReadBlob(ctx, store, desc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// writeJSON encodes v as JSON, writes it into the content store under its
+// own digest, and returns its descriptor.
+func writeJSON(ctx context.Context, store content.Store, v interface{}, mediaType string) (ocispec.Descriptor, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	desc := ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(raw),
+		Size:      int64(len(raw)),
+	}
+	ref := "commit-" + desc.Digest.String()
+	if err := content.WriteBlob(ctx, store, ref, bytes.NewReader(raw), desc); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	return desc, nil
+}
@@ -4,16 +4,23 @@
 package containerd
 
 import (
+	"archive/tar"
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
+	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"golang.org/x/sys/unix"
@@ -23,22 +30,28 @@ import (
 	"github.com/containerd/containerd/api/services/tasks/v1"
 	"github.com/containerd/containerd/cio"
 	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/images/archive"
 	"github.com/containerd/containerd/leases"
 	"github.com/containerd/containerd/mount"
 	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/platforms"
 	"github.com/containerd/containerd/snapshots"
 	"github.com/containerd/typeurl"
 	"github.com/eriknordmark/netlink"
+	"github.com/vishvananda/netns"
 	"github.com/lf-edge/edge-containers/pkg/resolver"
 	"github.com/lf-edge/eve/pkg/pillar/types"
 	"github.com/opencontainers/go-digest"
 	"github.com/opencontainers/image-spec/identity"
 
+	"github.com/lf-edge/eve/pkg/pillar/base"
+
 	v1stat "github.com/containerd/cgroups/stats/v1"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	spec "github.com/opencontainers/image-spec/specs-go/v1"
-	log "github.com/sirupsen/logrus" // XXX add log argument
+	"github.com/sirupsen/logrus" // OK for logrus.Info in init(), before any Client exists
 )
 
 const (
@@ -50,8 +63,31 @@ const (
 	ctrdSystemServicesNamespace = "services.linuxkit"
 	// ctrdServicesNamespace containerd namespace for running user containers
 	ctrdServicesNamespace = "eve-user-apps"
-	//containerdRunTime - default runtime of containerd
-	containerdRunTime = "io.containerd.runtime.v1.linux"
+	//containerdRunTime - default runtime of containerd, the runc v2 shim;
+	//io.containerd.runtime.v1.linux is deprecated upstream and newer
+	//containerd releases are dropping it, so new containers get the v2
+	//shim unless runtimeByNamespace pins their namespace elsewhere.
+	containerdRunTime = "io.containerd.runc.v2"
+	//containerdRunTimeV1Linux is the legacy v1 shim, kept only as a
+	//pinning target in runtimeByNamespace in case a v2 regression is
+	//found for a given namespace.
+	containerdRunTimeV1Linux = "io.containerd.runtime.v1.linux"
+	//KataRuntime is the shim that launches a container inside its own
+	//lightweight VM via kata-containers, for untrusted workloads that
+	//need VM-grade isolation from the host while system containers
+	//stay on the runc-backed containerdRunTime.
+	KataRuntime = "io.containerd.kata.v2"
+	//GvisorRuntime is the shim that runs a container's syscalls through
+	//the runsc sandboxed user-space kernel instead of handing them
+	//straight to the host, for eve-user-apps that don't need Kata's
+	//full VM boundary but still shouldn't be trusted with a bare runc
+	//container.
+	GvisorRuntime = "io.containerd.runsc.v1"
+	//gvisorUntrustedWorkloadAnnotation is the spec annotation the runsc
+	//shim looks for to confirm a container was deliberately routed to
+	//it, matching the key CRI-compatible runtimes use for the same
+	//purpose.
+	gvisorUntrustedWorkloadAnnotation = "io.kubernetes.cri.untrusted-workload"
 	// container config file name
 	imageConfigFilename = "image-config.json"
 	// default socket to connect tasks to memlogd
@@ -70,16 +106,115 @@ const (
 var (
 	// default snapshotter used by containerd
 	defaultSnapshotter = "overlayfs"
+
+	// runtimeByNamespace lets a specific containerd namespace be pinned
+	// to a shim runtime other than containerdRunTime, e.g. back to
+	// containerdRunTimeV1Linux if the v2 shim regresses for that
+	// namespace's workloads. Empty by default: every namespace uses
+	// containerdRunTime.
+	runtimeByNamespace = map[string]string{}
 )
 
+// runtimeForNamespace returns the containerd shim runtime that new
+// containers created in namespace should be started with.
+func runtimeForNamespace(namespace string) string {
+	if rt, found := runtimeByNamespace[namespace]; found && rt != "" {
+		return rt
+	}
+	return containerdRunTime
+}
+
+// shimBinaryName derives the v2 shim binary containerd would exec for
+// runtime, following containerd's own "io.containerd.<name>.<version>"
+// -> "containerd-shim-<name>-<version>" convention, e.g.
+// "io.containerd.kata.v2" -> "containerd-shim-kata-v2".
+func shimBinaryName(runtime string) (string, error) {
+	parts := strings.Split(runtime, ".")
+	if len(parts) < 2 {
+		return "", fmt.Errorf("shimBinaryName: %q is not a io.containerd.<name>.<version> runtime", runtime)
+	}
+	name, version := parts[len(parts)-2], parts[len(parts)-1]
+	return fmt.Sprintf("containerd-shim-%s-%s", name, version), nil
+}
+
+// validateRuntimeInstalled checks that the shim binary backing runtime
+// is present on PATH, so a typo'd or not-yet-installed runtime (e.g.
+// KataRuntime on a device without kata-containers packaged) is caught
+// at container-creation time instead of surfacing as an opaque
+// containerd "task failed to start" error.
+func validateRuntimeInstalled(runtime string) error {
+	shim, err := shimBinaryName(runtime)
+	if err != nil {
+		return err
+	}
+	if _, err := exec.LookPath(shim); err != nil {
+		return fmt.Errorf("validateRuntimeInstalled: runtime %s requires shim %s: %v", runtime, shim, err)
+	}
+	return nil
+}
+
+const (
+	// DevmapperSnapshotter is the name of the snapshotter that backs
+	// container volumes with a devicemapper thin-pool, an alternative
+	// to overlayfs/zfs for devices where overlayfs on persist performs
+	// poorly or lacks features (e.g. no CoW support on the underlying
+	// filesystem).
+	DevmapperSnapshotter = "devmapper"
+	// ErofsSnapshotter is the name of the snapshotter that backs
+	// read-only container layers with EROFS images, an alternative to
+	// overlayfs/zfs favoring lower per-layer overhead at the cost of
+	// read-only layers.
+	ErofsSnapshotter = "erofs"
+)
+
+// StargzSnapshotter is the name of the remote snapshotter that serves
+// layers lazily, on demand, straight off an eStargz-formatted image -
+// letting an eligible container start before the rest of its layers
+// have finished downloading. Pass it as the snapshotter argument to
+// CtrPrepareSnapshot (or cas.CAS's PrepareContainerRootDir) to request
+// a lazy pull; the caller is responsible for falling back to "" (this
+// device's default snapshotter) if the image isn't eStargz-formatted
+// or the stargz-snapshotter daemon isn't running.
+const StargzSnapshotter = "stargz"
+
 // Client is the handle we return to the caller
 type Client struct {
+	log          *base.LogObject
 	ctrdClient   *containerd.Client
 	contentStore content.Store
+	// connMu guards ctrdClient and contentStore, which reconnect swaps and
+	// CloseClient clears; without it a reconnect racing a concurrent
+	// CloseClient (or two concurrent CloseClient calls racing each other)
+	// can observe or double-close a connection that is already gone.
+	// verifyCtr/reconnect/CloseClient all take it; every other call on
+	// Client is expected to have already gone through verifyCtr for this
+	// request and relies on the containerd.Client it got back remaining
+	// valid for the lifetime of that single RPC, not across calls -
+	// namespaces are multiplexed over this one connection via context
+	// (see newServiceCtx), not one connection per namespace.
+	connMu sync.Mutex
+	// restartMu guards restartWatches; see WatchTask/UnwatchTask.
+	restartMu      sync.Mutex
+	restartWatches map[string]*taskWatch
+	// healthMu guards healthWatches; see WatchHealth/UnwatchHealth.
+	healthMu      sync.Mutex
+	healthWatches map[string]*healthWatch
+	// metrics is this client's running per-API-call counters and latency
+	// accumulation; see recordCall and GetMetrics in metrics.go.
+	metrics clientMetrics
 }
 
+const (
+	//reconnectAttempts is the number of times verifyCtr will try to
+	//reconnect to a restarted containerd daemon before giving up
+	reconnectAttempts = 4
+	//reconnectBaseDelay is the initial delay of the exponential backoff
+	//between reconnect attempts; doubled after every failed attempt
+	reconnectBaseDelay = 250 * time.Millisecond
+)
+
 func init() {
-	log.Info("Containerd Init")
+	logrus.Info("Containerd Init")
 	// see if we need to fine-tune default snapshotter based on what flavor of storage persist partition is
 	persistType, err := ioutil.ReadFile(eveStorageTypeFile)
 	if err == nil && strings.TrimSpace(string(persistType)) == "zfs" {
@@ -87,9 +222,26 @@ func init() {
 	}
 }
 
-// NewContainerdClient returns a *Client
+// snapshotterOrDefault returns snapshotter, unless it is empty, in which
+// case it falls back to defaultSnapshotter (picked once at init time from
+// the device's persist storage type). This lets CtrPrepareSnapshot,
+// CtrMountSnapshot and CtrRemoveSnapshot be called either with an
+// explicit snapshotter - so overlayfs-backed and zfs-backed volumes can
+// coexist on the same device - or, for existing callers, with "" to keep
+// using whatever this device defaults to.
+func snapshotterOrDefault(snapshotter string) string {
+	if snapshotter == "" {
+		return defaultSnapshotter
+	}
+	return snapshotter
+}
+
+// NewContainerdClient returns a *Client. log carries the calling agent's
+// identity, severity filtering, and object keys through to every method on
+// the returned Client, so its log entries are consumable by the rest of the
+// log pipeline just like the agent's own.
 // Callable from multiple go-routines.
-func NewContainerdClient() (*Client, error) {
+func NewContainerdClient(log *base.LogObject) (*Client, error) {
 	log.Infof("NewContainerdClient")
 	var (
 		err          error
@@ -97,6 +249,11 @@ func NewContainerdClient() (*Client, error) {
 		contentStore content.Store
 	)
 
+	if err := os.MkdirAll(fifoDir, 0777); err != nil {
+		log.Errorf("NewContainerdClient: could not create fifo dir %s: %s", fifoDir, err)
+	}
+	cleanStaleFifosOnce.Do(func() { cleanStaleFifos(log) })
+
 	ctrdClient, err = containerd.New(ctrdSocket, containerd.WithDefaultRuntime(containerdRunTime))
 	if err != nil {
 		log.Errorf("NewContainerdClient: could not create containerd client. %v", err.Error())
@@ -105,6 +262,7 @@ func NewContainerdClient() (*Client, error) {
 
 	contentStore = ctrdClient.ContentStore()
 	c := Client{
+		log:          log,
 		ctrdClient:   ctrdClient,
 		contentStore: contentStore,
 	}
@@ -115,23 +273,49 @@ func NewContainerdClient() (*Client, error) {
 	return &c, nil
 }
 
-//CloseClient closes containerd client
+// CloseClient closes the containerd client. It is safe to call
+// concurrently, including from two goroutines racing to close the same
+// Client, or racing a reconnect triggered by an in-flight RPC: connMu
+// serializes all of them, and a Client that is already closed (or whose
+// connection was never established) is treated as a no-op rather than an
+// error, so callers don't need to coordinate who "owns" the close.
 func (client *Client) CloseClient() error {
-	if err := client.verifyCtr(nil, false); err != nil {
-		return fmt.Errorf("CloseClient: exception while verifying ctrd client: %s", err.Error())
+	client.connMu.Lock()
+	defer client.connMu.Unlock()
+	if client.ctrdClient == nil {
+		return nil
 	}
 	if err := client.ctrdClient.Close(); err != nil {
 		err = fmt.Errorf("CloseClient: exception while closing containerd client. %v", err.Error())
-		log.Errorf(err.Error())
+		client.log.Errorf(err.Error())
 		return err
 	}
 	client.ctrdClient = nil
 	return nil
 }
 
-//CtrWriteBlob reads the blob as raw data from `reader` and writes it into containerd.
+// CtrWriteBlob reads the blob as raw data from `reader` and writes it into containerd.
 // Accepts a custom context. If ctx is nil, then default context will be used.
 func (client *Client) CtrWriteBlob(ctx context.Context, blobHash string, expectedSize uint64, reader io.Reader) error {
+	return client.ctrWriteBlob(ctx, blobHash, expectedSize, reader, nil)
+}
+
+// WriteBlobProgressFunc is invoked periodically while CtrWriteBlobWithProgress
+// is streaming a blob into containerd, with the bytes written so far and the
+// expected total (0 if unknown).
+type WriteBlobProgressFunc func(written, total int64)
+
+// CtrWriteBlobWithProgress behaves like CtrWriteBlob, but calls onProgress
+// every time a chunk is read from reader, so callers writing multi-GB VM
+// images can surface download/ingest progress instead of blocking silently.
+func (client *Client) CtrWriteBlobWithProgress(ctx context.Context, blobHash string, expectedSize uint64,
+	reader io.Reader, onProgress WriteBlobProgressFunc) error {
+	return client.ctrWriteBlob(ctx, blobHash, expectedSize, reader, onProgress)
+}
+
+func (client *Client) ctrWriteBlob(ctx context.Context, blobHash string, expectedSize uint64,
+	reader io.Reader, onProgress WriteBlobProgressFunc) (err error) {
+	defer func(start time.Time) { client.recordCall("WriteBlob", start, err) }(time.Now())
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return fmt.Errorf("CtrWriteBlob: exception while verifying ctrd client: %s", err.Error())
 	}
@@ -147,6 +331,9 @@ func (client *Client) CtrWriteBlob(ctx context.Context, blobHash string, expecte
 	if err := expectedDigest.Validate(); err != nil {
 		return fmt.Errorf("CtrWriteBlob: exception while validating hash format of %s. %v", blobHash, err)
 	}
+	if onProgress != nil {
+		reader = &progressReader{reader: reader, total: int64(expectedSize), onProgress: onProgress}
+	}
 	if err := content.WriteBlob(ctx, client.contentStore, blobHash, reader,
 		spec.Descriptor{Digest: expectedDigest, Size: int64(expectedSize)}); err != nil {
 		return fmt.Errorf("CtrWriteBlob: Exception while writing blob: %s. %s", blobHash, err.Error())
@@ -154,7 +341,25 @@ func (client *Client) CtrWriteBlob(ctx context.Context, blobHash string, expecte
 	return nil
 }
 
-//CtrUpdateBlobInfo updates blobs info
+// progressReader wraps an io.Reader and reports cumulative bytes read via
+// onProgress after every Read call.
+type progressReader struct {
+	reader     io.Reader
+	written    int64
+	total      int64
+	onProgress WriteBlobProgressFunc
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.reader.Read(buf)
+	if n > 0 {
+		p.written += int64(n)
+		p.onProgress(p.written, p.total)
+	}
+	return n, err
+}
+
+// CtrUpdateBlobInfo updates blobs info
 func (client *Client) CtrUpdateBlobInfo(ctx context.Context, updatedContentInfo content.Info, updatedFields []string) error {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return fmt.Errorf("CtrUpdateBlobInfo: exception while verifying ctrd client: %s", err.Error())
@@ -166,8 +371,11 @@ func (client *Client) CtrUpdateBlobInfo(ctx context.Context, updatedContentInfo
 	return nil
 }
 
-//CtrReadBlob return a reader for the blob with given blobHash. Error is returned if no blob is found for the blobHash
-func (client *Client) CtrReadBlob(ctx context.Context, blobHash string) (io.Reader, error) {
+// CtrReadBlob return a reader for the blob with given blobHash. Error is returned if no blob is found for the blobHash.
+// If verifyDigest is set, the returned reader re-hashes the content as it is read and, at EOF, returns an error
+// instead of io.EOF if the content no longer matches blobHash -- catching bit-rot on flash media before a
+// corrupted rootfs or config blob is used, rather than trusting the content store's on-disk bytes blindly.
+func (client *Client) CtrReadBlob(ctx context.Context, blobHash string, verifyDigest bool) (io.Reader, error) {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return nil, fmt.Errorf("CtrReadBlob: exception while verifying ctrd client: %s", err.Error())
 	}
@@ -180,10 +388,34 @@ func (client *Client) CtrReadBlob(ctx context.Context, blobHash string) (io.Read
 	if err != nil {
 		return nil, fmt.Errorf("CtrReadBlob: Exception while reading blob: %s. %s", blobHash, err.Error())
 	}
-	return content.NewReader(readerAt), nil
+	reader := content.NewReader(readerAt)
+	if !verifyDigest {
+		return reader, nil
+	}
+	return &digestVerifyReader{inner: reader, digest: shaDigest, verifier: shaDigest.Verifier()}, nil
+}
+
+// digestVerifyReader wraps a reader with a running hash of what has been read so far, so that on EOF it can
+// catch content that has silently changed since it was written -- e.g. bit-rot on flash media -- instead of
+// letting the corrupted bytes pass as if nothing were wrong.
+type digestVerifyReader struct {
+	inner    io.Reader
+	digest   digest.Digest
+	verifier digest.Verifier
+}
+
+func (r *digestVerifyReader) Read(p []byte) (int, error) {
+	n, err := r.inner.Read(p)
+	if n > 0 {
+		r.verifier.Write(p[:n])
+	}
+	if err == io.EOF && !r.verifier.Verified() {
+		return n, fmt.Errorf("digestVerifyReader: content no longer matches digest %s", r.digest)
+	}
+	return n, err
 }
 
-//CtrGetBlobInfo returns a bolb's info as content.Info
+// CtrGetBlobInfo returns a bolb's info as content.Info
 func (client *Client) CtrGetBlobInfo(ctx context.Context, blobHash string) (content.Info, error) {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return content.Info{}, fmt.Errorf("CtrReadBlob: exception while verifying ctrd client: %s", err.Error())
@@ -191,7 +423,21 @@ func (client *Client) CtrGetBlobInfo(ctx context.Context, blobHash string) (cont
 	return client.contentStore.Info(ctx, digest.Digest(blobHash))
 }
 
-//CtrListBlobInfo returns a list of blob infos as []content.Info
+// CtrGetIngestStatus looks up the content store's ingest status for an
+// in-progress or interrupted CtrWriteBlob of blobHash, returning the byte
+// offset already committed. content.WriteBlob resumes a write from this
+// offset automatically (via the content store's ingest refs) as long as
+// the same blobHash is used again, so callers that reboot or get killed
+// mid-download can ask how far they got before re-requesting the rest of
+// the blob over the network rather than re-downloading it from scratch.
+func (client *Client) CtrGetIngestStatus(ctx context.Context, blobHash string) (content.Status, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return content.Status{}, fmt.Errorf("CtrGetIngestStatus: exception while verifying ctrd client: %s", err.Error())
+	}
+	return client.contentStore.Status(ctx, blobHash)
+}
+
+// CtrListBlobInfo returns a list of blob infos as []content.Info
 func (client *Client) CtrListBlobInfo(ctx context.Context) ([]content.Info, error) {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return nil, fmt.Errorf("CtrListBlobInfo: exception while verifying ctrd client: %s", err.Error())
@@ -207,7 +453,47 @@ func (client *Client) CtrListBlobInfo(ctx context.Context) ([]content.Info, erro
 	return infos, nil
 }
 
-//CtrDeleteBlob deletes blob with the given blobHash
+// CtrContentStats returns the total number of blobs and bytes occupied by
+// the content store, for reporting actual containerd disk consumption to
+// the controller instead of approximating it from image manifest sizes.
+func (client *Client) CtrContentStats(ctx context.Context) (blobCount int, totalBytes int64, err error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return 0, 0, fmt.Errorf("CtrContentStats: exception while verifying ctrd client: %s", err.Error())
+	}
+	walkFn := func(info content.Info) error {
+		blobCount++
+		totalBytes += info.Size
+		return nil
+	}
+	if err := client.contentStore.Walk(ctx, walkFn); err != nil {
+		return 0, 0, fmt.Errorf("CtrContentStats: error walking content store: %s", err.Error())
+	}
+	return blobCount, totalBytes, nil
+}
+
+// CtrSnapshotterStats returns the on-disk usage of every snapshot known to
+// the default snapshotter, keyed by snapshot ID.
+func (client *Client) CtrSnapshotterStats(ctx context.Context) (map[string]snapshots.Usage, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return nil, fmt.Errorf("CtrSnapshotterStats: exception while verifying ctrd client: %s", err.Error())
+	}
+	snapshotter := client.ctrdClient.SnapshotService(defaultSnapshotter)
+	stats := make(map[string]snapshots.Usage)
+	err := snapshotter.Walk(ctx, func(ctx context.Context, info snapshots.Info) error {
+		usage, err := snapshotter.Usage(ctx, info.Name)
+		if err != nil {
+			return fmt.Errorf("could not get usage of snapshot %s: %s", info.Name, err.Error())
+		}
+		stats[info.Name] = usage
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("CtrSnapshotterStats: error walking snapshotter: %s", err.Error())
+	}
+	return stats, nil
+}
+
+// CtrDeleteBlob deletes blob with the given blobHash
 func (client *Client) CtrDeleteBlob(ctx context.Context, blobHash string) error {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return fmt.Errorf("CtrDeleteBlob: exception while verifying ctrd client: %s", err.Error())
@@ -215,7 +501,176 @@ func (client *Client) CtrDeleteBlob(ctx context.Context, blobHash string) error
 	return client.contentStore.Delete(ctx, digest.Digest(blobHash))
 }
 
-//CtrCreateImage create an image in containerd's image store
+// CtrPruneContent walks the content store and deletes any blob that is
+// not reachable from an image in the image store and not held by an active
+// lease, returning the digests it removed (or would remove, if dryRun is
+// set) and the total bytes they occupied. This is for reclaiming space on
+// the small /persist partitions volumemgr has to work with; containerd
+// itself only garbage collects content when a lease expires, which doesn't
+// help with blobs that were ingested but never associated with an image or
+// a lease, e.g. after a crash mid-pull.
+func (client *Client) CtrPruneContent(ctx context.Context, dryRun bool) ([]string, int64, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return nil, 0, fmt.Errorf("CtrPruneContent: exception while verifying ctrd client: %s", err.Error())
+	}
+
+	referenced := make(map[digest.Digest]bool)
+
+	imgList, err := client.ctrdClient.ImageService().List(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("CtrPruneContent: could not list images: %s", err.Error())
+	}
+	handler := images.ChildrenHandler(client.contentStore)
+	for _, img := range imgList {
+		referenced[img.Target.Digest] = true
+		if err := images.Walk(ctx, images.HandlerFunc(
+			func(ctx context.Context, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+				referenced[desc.Digest] = true
+				return handler.Handle(ctx, desc)
+			}), img.Target); err != nil {
+			return nil, 0, fmt.Errorf("CtrPruneContent: could not walk image %s: %s", img.Name, err.Error())
+		}
+	}
+
+	leaseList, err := client.ctrdClient.LeasesService().List(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("CtrPruneContent: could not list leases: %s", err.Error())
+	}
+	for _, lease := range leaseList {
+		resources, err := client.ctrdClient.LeasesService().ListResources(ctx, lease)
+		if err != nil {
+			return nil, 0, fmt.Errorf("CtrPruneContent: could not list resources of lease %s: %s", lease.ID, err.Error())
+		}
+		for _, resource := range resources {
+			if resource.Type == "content" || resource.Type == "ingests" {
+				referenced[digest.Digest(resource.ID)] = true
+			}
+		}
+	}
+
+	var reclaimed []string
+	var reclaimedBytes int64
+	walkFn := func(info content.Info) error {
+		if referenced[info.Digest] {
+			return nil
+		}
+		reclaimed = append(reclaimed, info.Digest.String())
+		reclaimedBytes += info.Size
+		if !dryRun {
+			if err := client.contentStore.Delete(ctx, info.Digest); err != nil {
+				return fmt.Errorf("could not delete unreferenced blob %s: %s", info.Digest, err.Error())
+			}
+		}
+		return nil
+	}
+	if err := client.contentStore.Walk(ctx, walkFn); err != nil {
+		return reclaimed, reclaimedBytes, fmt.Errorf("CtrPruneContent: error walking content store: %s", err.Error())
+	}
+	return reclaimed, reclaimedBytes, nil
+}
+
+// CtrPruneStaleLeases walks ctx's namespace's leases and deletes any lease
+// older than threshold that isn't pinning content or an in-progress ingest
+// reachable from a current image, returning the lease IDs removed (or
+// would be removed, if dryRun is set) and the bytes their content/ingests
+// occupied. A lease is normally released by whatever created it (e.g.
+// CtrNewUserServicesCtxWithLease's done()), but a crash or reboot mid-pull
+// leaves the lease, and the gigabytes of partial ingest it pins, around
+// forever -- this is the boot-time cleanup for that. Call once per
+// namespace (e.g. with both CtrNewUserServicesCtx and
+// CtrNewSystemServicesCtx contexts) to cover everything containerd holds.
+func (client *Client) CtrPruneStaleLeases(ctx context.Context, threshold time.Duration, dryRun bool) ([]string, int64, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return nil, 0, fmt.Errorf("CtrPruneStaleLeases: exception while verifying ctrd client: %s", err.Error())
+	}
+
+	referenced := make(map[digest.Digest]bool)
+
+	imgList, err := client.ctrdClient.ImageService().List(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("CtrPruneStaleLeases: could not list images: %s", err.Error())
+	}
+	handler := images.ChildrenHandler(client.contentStore)
+	for _, img := range imgList {
+		referenced[img.Target.Digest] = true
+		if err := images.Walk(ctx, images.HandlerFunc(
+			func(ctx context.Context, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+				referenced[desc.Digest] = true
+				return handler.Handle(ctx, desc)
+			}), img.Target); err != nil {
+			return nil, 0, fmt.Errorf("CtrPruneStaleLeases: could not walk image %s: %s", img.Name, err.Error())
+		}
+	}
+
+	leaseList, err := client.ctrdClient.LeasesService().List(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("CtrPruneStaleLeases: could not list leases: %s", err.Error())
+	}
+
+	cutoff := time.Now().Add(-threshold)
+	var removed []string
+	var reclaimedBytes int64
+	for _, lease := range leaseList {
+		if lease.CreatedAt.After(cutoff) {
+			continue
+		}
+		resources, err := client.ctrdClient.LeasesService().ListResources(ctx, lease)
+		if err != nil {
+			return removed, reclaimedBytes, fmt.Errorf("CtrPruneStaleLeases: could not list resources of lease %s: %s",
+				lease.ID, err.Error())
+		}
+
+		stale := true
+		var leaseBytes int64
+		for _, resource := range resources {
+			switch resource.Type {
+			case "content":
+				if referenced[digest.Digest(resource.ID)] {
+					stale = false
+					break
+				}
+				if info, err := client.contentStore.Info(ctx, digest.Digest(resource.ID)); err == nil {
+					leaseBytes += info.Size
+				}
+			case "ingests":
+				// An ingest never appears in referenced -- it isn't a
+				// finished blob yet -- so its presence alone doesn't
+				// make the lease stale, but it does tell us how many
+				// bytes we'd reclaim by aborting it.
+				if status, err := client.contentStore.Status(ctx, resource.ID); err == nil {
+					leaseBytes += status.Offset
+				}
+			}
+			if !stale {
+				break
+			}
+		}
+		if !stale {
+			continue
+		}
+
+		removed = append(removed, lease.ID)
+		reclaimedBytes += leaseBytes
+		if dryRun {
+			continue
+		}
+		for _, resource := range resources {
+			if resource.Type == "ingests" {
+				if err := client.contentStore.Abort(ctx, resource.ID); err != nil {
+					client.log.Warnf("CtrPruneStaleLeases: could not abort ingest %s of lease %s: %s",
+						resource.ID, lease.ID, err.Error())
+				}
+			}
+		}
+		if err := client.ctrdClient.LeasesService().Delete(ctx, lease); err != nil {
+			return removed, reclaimedBytes, fmt.Errorf("CtrPruneStaleLeases: could not delete lease %s: %s",
+				lease.ID, err.Error())
+		}
+	}
+	return removed, reclaimedBytes, nil
+}
+
+// CtrCreateImage create an image in containerd's image store
 func (client *Client) CtrCreateImage(ctx context.Context, image images.Image) (images.Image, error) {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return images.Image{}, fmt.Errorf("CtrCreateImage: exception while verifying ctrd client: %s", err.Error())
@@ -223,41 +678,254 @@ func (client *Client) CtrCreateImage(ctx context.Context, image images.Image) (i
 	return client.ctrdClient.ImageService().Create(ctx, image)
 }
 
-//CtrLoadImage reads image as raw data from `reader` and loads it into containerd
+// CtrExportImage writes reference out to writer as an OCI-layout tarball,
+// the inverse of CtrLoadImage, so operators can pull an app image back off
+// a device for forensic analysis or to seed other air-gapped nodes.
+func (client *Client) CtrExportImage(ctx context.Context, reference string, writer io.Writer) error {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return fmt.Errorf("CtrExportImage: exception while verifying ctrd client: %s", err.Error())
+	}
+	image, err := client.ctrdClient.GetImage(ctx, reference)
+	if err != nil {
+		return fmt.Errorf("CtrExportImage: could not get image %s: %v", reference, err)
+	}
+	if err := client.ctrdClient.Export(ctx, writer,
+		archive.WithImage(client.ctrdClient.ImageService(), image.Name()),
+		archive.WithPlatform(platforms.Default())); err != nil {
+		return fmt.Errorf("CtrExportImage: could not export image %s: %v", reference, err)
+	}
+	return nil
+}
+
+// CtrLoadImage reads image as raw data from `reader` and loads it into containerd
 func (client *Client) CtrLoadImage(ctx context.Context, reader *os.File) ([]images.Image, error) {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return nil, fmt.Errorf("CtrLoadImage: exception while verifying ctrd client: %s", err.Error())
 	}
 	imgs, err := client.ctrdClient.Import(ctx, reader)
 	if err != nil {
-		log.Errorf("CtrLoadImage: could not load image %s into containerd: %+s", reader.Name(), err.Error())
+		client.log.Errorf("CtrLoadImage: could not load image %s into containerd: %+s", reader.Name(), err.Error())
 		return nil, err
 	}
 	return imgs, nil
 }
 
-//CtrGetImage returns image object for the reference. Returns error if no image is found for the reference.
+// CtrLoadImagePath imports an image from path, auto-detecting the format
+// so field engineers can sideload an image from a USB stick without
+// knowing in advance how it was produced: a gzip-compressed tarball, an
+// uncompressed tarball (containerd's Import already handles both
+// docker-archive's manifest.json and OCI image-layout's index.json inside
+// the tar), or an OCI image-layout directory (which is tarred up on the
+// fly, since Import only accepts a tar stream).
+func (client *Client) CtrLoadImagePath(ctx context.Context, path string) ([]images.Image, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return nil, fmt.Errorf("CtrLoadImagePath: exception while verifying ctrd client: %s", err.Error())
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("CtrLoadImagePath: could not stat %s: %v", path, err)
+	}
+
+	var reader io.Reader
+	if info.IsDir() {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(tarDirectory(path, pw))
+		}()
+		reader = pr
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("CtrLoadImagePath: could not open %s: %v", path, err)
+		}
+		defer f.Close()
+		buffered := bufio.NewReader(f)
+		magic, err := buffered.Peek(2)
+		if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+			gz, err := gzip.NewReader(buffered)
+			if err != nil {
+				return nil, fmt.Errorf("CtrLoadImagePath: %s looked gzipped but failed to open: %v", path, err)
+			}
+			defer gz.Close()
+			reader = gz
+		} else {
+			reader = buffered
+		}
+	}
+
+	imgs, err := client.ctrdClient.Import(ctx, reader)
+	if err != nil {
+		return nil, fmt.Errorf("CtrLoadImagePath: could not load image %s into containerd: %v", path, err)
+	}
+	return imgs, nil
+}
+
+// tarDirectory walks root and writes it as a tar stream to w, so an OCI
+// image-layout directory (index.json, oci-layout, blobs/) can be fed to
+// containerd's Import, which only accepts tar streams.
+func tarDirectory(root string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+	return filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = relPath
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// CtrGetImage returns image object for the reference. Returns error if no image is found for the reference.
 func (client *Client) CtrGetImage(ctx context.Context, reference string) (containerd.Image, error) {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return nil, fmt.Errorf("CtrGetImage: exception while verifying ctrd client: %s", err.Error())
 	}
 	image, err := client.ctrdClient.GetImage(ctx, reference)
 	if err != nil {
-		log.Errorf("CtrGetImage: could not get image %s from containerd: %+s", reference, err.Error())
+		client.log.Errorf("CtrGetImage: could not get image %s from containerd: %+s", reference, err.Error())
 		return nil, err
 	}
 	return image, nil
 }
 
-//CtrListImages returns a list of images object from ontainerd's image store
-func (client *Client) CtrListImages(ctx context.Context) ([]images.Image, error) {
+// CtrGetImageWithPlatform is like CtrGetImage, but when reference resolves
+// to a manifest list it explicitly selects platform (e.g. "linux/arm64",
+// "linux/arm/v7" for a Raspberry Pi variant) instead of silently falling
+// back to the default host platform. It fails with a descriptive error if
+// the manifest list has no entry matching platform.
+func (client *Client) CtrGetImageWithPlatform(ctx context.Context, reference string, platform string) (containerd.Image, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return nil, fmt.Errorf("CtrGetImageWithPlatform: exception while verifying ctrd client: %s", err.Error())
+	}
+	p, err := platforms.Parse(platform)
+	if err != nil {
+		return nil, fmt.Errorf("CtrGetImageWithPlatform: invalid platform %q: %v", platform, err)
+	}
+	img, err := client.ctrdClient.ImageService().Get(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("CtrGetImageWithPlatform: could not get image %s: %v", reference, err)
+	}
+	image := containerd.NewImageWithPlatform(client.ctrdClient, img, platforms.Only(p))
+	if _, err := image.Config(ctx); err != nil {
+		return nil, fmt.Errorf("CtrGetImageWithPlatform: manifest list for %s has no entry matching platform %s: %v",
+			reference, platform, err)
+	}
+	return image, nil
+}
+
+// PullProgress reports how many bytes of an in-progress image pull have
+// landed in the content store, for callers (e.g. volumemgr) that want to
+// surface per-layer download progress instead of waiting silently.
+type PullProgress struct {
+	Ref    string
+	Offset int64
+	Total  int64
+	Done   bool
+	Err    error
+}
+
+// CtrPullImage pulls reference directly from an OCI registry using
+// containerd's own Pull path and the resolver obtained from Resolver(),
+// rather than shelling the data through an intermediate blob writer.
+// platform, if non-empty, restricts the pull to a single platform out of
+// a multi-arch manifest list (e.g. "linux/arm64"). If progress is non-nil,
+// CtrPullImage polls the content store's active ingests and sends updates
+// on it until the pull completes; the channel is closed before returning.
+func (client *Client) CtrPullImage(ctx context.Context, reference string, platform string,
+	progress chan<- PullProgress) (containerd.Image, error) {
+
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return nil, fmt.Errorf("CtrPullImage: exception while verifying ctrd client: %s", err.Error())
+	}
+	resolver, err := client.Resolver(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("CtrPullImage: could not get resolver: %v", err)
+	}
+	opts := []containerd.RemoteOpt{containerd.WithResolver(resolver)}
+	if platform != "" {
+		opts = append(opts, containerd.WithPlatform(platform))
+	}
+
+	if progress != nil {
+		stopProgress := make(chan struct{})
+		progressDone := make(chan struct{})
+		go func() {
+			client.reportPullProgress(reference, progress, stopProgress)
+			close(progressDone)
+		}()
+		defer func() {
+			close(stopProgress)
+			<-progressDone
+		}()
+	}
+
+	image, err := client.ctrdClient.Pull(ctx, reference, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("CtrPullImage: pull of %s failed: %v", reference, err)
+	}
+	return image, nil
+}
+
+// reportPullProgress polls the content store's active ingest statuses for
+// reference every second and publishes them on progress, until stop fires.
+func (client *Client) reportPullProgress(reference string, progress chan<- PullProgress, stop <-chan struct{}) {
+	defer close(progress)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			statuses, err := client.contentStore.ListStatuses(context.Background())
+			if err != nil {
+				progress <- PullProgress{Ref: reference, Err: err}
+				continue
+			}
+			for _, s := range statuses {
+				progress <- PullProgress{Ref: s.Ref, Offset: s.Offset, Total: s.Total}
+			}
+		}
+	}
+}
+
+// CtrListImages returns a list of images objects from containerd's image
+// store. filters, if any, are containerd filter expressions (e.g.
+// "name~=myimage", "labels.\"foo\"==bar") ANDed together within each
+// expression and ORed across expressions, same as `ctr images list`;
+// passing them down to containerd avoids pulling the full image list on
+// devices with hundreds of images just to filter it client-side.
+func (client *Client) CtrListImages(ctx context.Context, filters ...string) ([]images.Image, error) {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return nil, fmt.Errorf("CtrListImages: exception while verifying ctrd client: %s", err.Error())
 	}
-	return client.ctrdClient.ImageService().List(ctx)
+	return client.ctrdClient.ImageService().List(ctx, filters...)
 }
 
-//CtrUpdateImage updates the files provided in fieldpaths of the image in containerd'd image store
+// CtrUpdateImage updates the files provided in fieldpaths of the image in containerd'd image store
 func (client *Client) CtrUpdateImage(ctx context.Context, image images.Image, fieldpaths ...string) (images.Image, error) {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return images.Image{}, fmt.Errorf("CtrUpdateImage: exception while verifying ctrd client: %s", err.Error())
@@ -265,7 +933,48 @@ func (client *Client) CtrUpdateImage(ctx context.Context, image images.Image, fi
 	return client.ctrdClient.ImageService().Update(ctx, image, fieldpaths...)
 }
 
-//CtrDeleteImage deletes an image with the given reference
+// CtrGetImageLabels returns the labels currently stored on the image with
+// the given reference.
+func (client *Client) CtrGetImageLabels(ctx context.Context, reference string) (map[string]string, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return nil, fmt.Errorf("CtrGetImageLabels: exception while verifying ctrd client: %s", err.Error())
+	}
+	image, err := client.ctrdClient.ImageService().Get(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("CtrGetImageLabels: could not get image %s: %v", reference, err)
+	}
+	return image.Labels, nil
+}
+
+// CtrSetImageLabels sets labels on the image with the given reference,
+// merging them into (and overwriting any overlapping keys in) its
+// existing label set, and returns the final label set. EVE uses this to
+// stamp images with its own bookkeeping metadata - app UUID, content-tree
+// UUID, purge counter - directly in containerd's metadata store instead
+// of maintaining separate bookkeeping files that can drift out of sync
+// after a crash.
+func (client *Client) CtrSetImageLabels(ctx context.Context, reference string, labels map[string]string) (map[string]string, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return nil, fmt.Errorf("CtrSetImageLabels: exception while verifying ctrd client: %s", err.Error())
+	}
+	image, err := client.ctrdClient.ImageService().Get(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("CtrSetImageLabels: could not get image %s: %v", reference, err)
+	}
+	if image.Labels == nil {
+		image.Labels = map[string]string{}
+	}
+	for k, v := range labels {
+		image.Labels[k] = v
+	}
+	updated, err := client.CtrUpdateImage(ctx, image, "labels")
+	if err != nil {
+		return nil, fmt.Errorf("CtrSetImageLabels: could not update image %s: %v", reference, err)
+	}
+	return updated.Labels, nil
+}
+
+// CtrDeleteImage deletes an image with the given reference
 func (client *Client) CtrDeleteImage(ctx context.Context, reference string) error {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return fmt.Errorf("CtrDeleteImage: exception while verifying ctrd client: %s", err.Error())
@@ -273,41 +982,73 @@ func (client *Client) CtrDeleteImage(ctx context.Context, reference string) erro
 	return client.ctrdClient.ImageService().Delete(ctx, reference)
 }
 
-//CtrPrepareSnapshot creates snapshot for the given image
-func (client *Client) CtrPrepareSnapshot(ctx context.Context, snapshotID string, image containerd.Image) ([]mount.Mount, error) {
+// CtrPrepareSnapshot creates a snapshot for the given image, using the
+// named snapshotter (e.g. "overlayfs", "zfs"), or this device's default
+// snapshotter if snapshotter is "". Callers that manage volumes on mixed
+// persist storage can pass the snapshotter that matches where the
+// volume's backing store actually lives.
+func (client *Client) CtrPrepareSnapshot(ctx context.Context, snapshotID string, image containerd.Image, snapshotter string) (mounts []mount.Mount, err error) {
+	defer func(start time.Time) { client.recordCall("PrepareSnapshot", start, err) }(time.Now())
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return nil, fmt.Errorf("CtrPrepareSnapshot: exception while verifying ctrd client: %s", err.Error())
 	}
-	// use rootfs unpacked image to create a writable snapshot with default snapshotter
+	// use rootfs unpacked image to create a writable snapshot with the selected snapshotter
 	diffIDs, err := image.RootFS(ctx)
 	if err != nil {
 		err = fmt.Errorf("CtrPrepareSnapshot: Could not load rootfs of image: %v. %v", image.Name(), err)
 		return nil, err
 	}
 
-	snapshotter := client.ctrdClient.SnapshotService(defaultSnapshotter)
+	svc := client.ctrdClient.SnapshotService(snapshotterOrDefault(snapshotter))
 	parent := identity.ChainID(diffIDs).String()
 	labels := map[string]string{"containerd.io/gc.root": time.Now().UTC().Format(time.RFC3339)}
-	return snapshotter.Prepare(ctx, snapshotID, parent, snapshots.WithLabels(labels))
+	// svc.Prepare is a single idempotent RPC for a given snapshotID, so it
+	// is safe to retry on a transient error (e.g. the snapshotter being
+	// momentarily unavailable) rather than failing the caller immediately.
+	err = withCallRetry(ctx, func() error {
+		var rerr error
+		mounts, rerr = svc.Prepare(ctx, snapshotID, parent, snapshots.WithLabels(labels))
+		return rerr
+	})
+	return mounts, err
 }
 
-//CtrMountSnapshot mounts the snapshot with snapshotID on the given targetPath.
-func (client *Client) CtrMountSnapshot(ctx context.Context, snapshotID, targetPath string) error {
+// CtrMountSnapshot mounts the snapshot with snapshotID, found on the
+// named snapshotter (or this device's default snapshotter if snapshotter
+// is ""), on the given targetPath.
+func (client *Client) CtrMountSnapshot(ctx context.Context, snapshotID, targetPath, snapshotter string) error {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return fmt.Errorf("CtrMountSnapshot: exception while verifying ctrd client: %s", err.Error())
 	}
-	snapshotter := client.ctrdClient.SnapshotService(defaultSnapshotter)
-	mounts, err := snapshotter.Mounts(ctx, snapshotID)
+	svc := client.ctrdClient.SnapshotService(snapshotterOrDefault(snapshotter))
+	mounts, err := svc.Mounts(ctx, snapshotID)
 	if err != nil {
 		return fmt.Errorf("CtrMountSnapshot: Exception while fetching mounts of snapshot: %s. %s", snapshotID, err)
 	}
 	if err := os.MkdirAll(targetPath, 0766); err != nil {
 		return fmt.Errorf("CtrMountSnapshot: Exception while creating targetPath dir. %v", err)
 	}
-	return mounts[0].Mount(targetPath)
+	// Some snapshotters (zfs, devmapper) return more than one mount, or a
+	// single mount with options mount.All knows how to handle but a bare
+	// Mount() does not - overlayfs's single "overlay" mount is safe with
+	// either, but mounts[0].Mount(targetPath) silently dropped the rest
+	// for backends that aren't overlayfs.
+	if err := mount.All(mounts, targetPath); err != nil {
+		return fmt.Errorf("CtrMountSnapshot: Exception while mounting snapshot %s at %s: %v", snapshotID, targetPath, err)
+	}
+	return nil
 }
 
-//CtrListSnapshotInfo returns a list of all snapshot's info present in containerd's snapshot store.
+// CtrUnmountSnapshot undoes a CtrMountSnapshot, unmounting targetPath. It
+// does not remove the snapshot itself; see CtrRemoveSnapshot for that.
+func (client *Client) CtrUnmountSnapshot(ctx context.Context, targetPath string) error {
+	if err := mount.UnmountAll(targetPath, 0); err != nil {
+		return fmt.Errorf("CtrUnmountSnapshot: Exception while unmounting %s: %v", targetPath, err)
+	}
+	return nil
+}
+
+// CtrListSnapshotInfo returns a list of all snapshot's info present in containerd's snapshot store.
 func (client *Client) CtrListSnapshotInfo(ctx context.Context) ([]snapshots.Info, error) {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return nil, fmt.Errorf("CtrListSnapshotInfo: exception while verifying ctrd client: %s", err.Error())
@@ -323,20 +1064,22 @@ func (client *Client) CtrListSnapshotInfo(ctx context.Context) ([]snapshots.Info
 	return snapshotInfoList, nil
 }
 
-//CtrRemoveSnapshot removed snapshot by ID from containerd
-func (client *Client) CtrRemoveSnapshot(ctx context.Context, snapshotID string) error {
+// CtrRemoveSnapshot removes the snapshot identified by snapshotID from
+// the named snapshotter, or this device's default snapshotter if
+// snapshotter is "".
+func (client *Client) CtrRemoveSnapshot(ctx context.Context, snapshotID, snapshotter string) error {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return fmt.Errorf("CtrRemoveSnapshot: exception while verifying ctrd client: %s", err.Error())
 	}
-	snapshotter := client.ctrdClient.SnapshotService(defaultSnapshotter)
-	if err := snapshotter.Remove(ctx, snapshotID); err != nil {
-		log.Errorf("CtrRemoveSnapshot: unable to remove snapshot: %v. %v", snapshotID, err)
+	svc := client.ctrdClient.SnapshotService(snapshotterOrDefault(snapshotter))
+	if err := svc.Remove(ctx, snapshotID); err != nil {
+		client.log.Errorf("CtrRemoveSnapshot: unable to remove snapshot: %v. %v", snapshotID, err)
 		return err
 	}
 	return nil
 }
 
-//CtrLoadContainer returns conatiner with the given `containerID`. Error is returned if there no container is found.
+// CtrLoadContainer returns conatiner with the given `containerID`. Error is returned if there no container is found.
 func (client *Client) CtrLoadContainer(ctx context.Context, containerID string) (containerd.Container, error) {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return nil, fmt.Errorf("CtrLoadContainer: exception while verifying ctrd client: %s", err.Error())
@@ -348,13 +1091,44 @@ func (client *Client) CtrLoadContainer(ctx context.Context, containerID string)
 	return container, err
 }
 
-//CtrListContainerIds returns a list of all known container IDs
-func (client *Client) CtrListContainerIds(ctx context.Context) ([]string, error) {
+// CtrGetContainerLabels returns the labels currently stored on
+// domainName's container.
+func (client *Client) CtrGetContainerLabels(ctx context.Context, domainName string) (map[string]string, error) {
+	container, err := client.CtrLoadContainer(ctx, domainName)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := container.Labels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("CtrGetContainerLabels(%s): %v", domainName, err)
+	}
+	return labels, nil
+}
+
+// CtrSetContainerLabels sets labels on domainName's container, merging
+// them into its existing label set, and returns the final label set. See
+// CtrSetImageLabels - the same rationale (EVE bookkeeping metadata
+// surviving a crash without a parallel file) applies to containers.
+func (client *Client) CtrSetContainerLabels(ctx context.Context, domainName string, labels map[string]string) (map[string]string, error) {
+	container, err := client.CtrLoadContainer(ctx, domainName)
+	if err != nil {
+		return nil, err
+	}
+	final, err := container.SetLabels(ctx, labels)
+	if err != nil {
+		return nil, fmt.Errorf("CtrSetContainerLabels(%s): %v", domainName, err)
+	}
+	return final, nil
+}
+
+// CtrListContainerIds returns a list of all known container IDs matching
+// filters, if any; see CtrListContainer.
+func (client *Client) CtrListContainerIds(ctx context.Context, filters ...string) ([]string, error) {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return nil, fmt.Errorf("CtrListContainerIds: exception while verifying ctrd client: %s", err.Error())
 	}
 	res := []string{}
-	ctrs, err := client.CtrListContainer(ctx)
+	ctrs, err := client.CtrListContainer(ctx, filters...)
 	if err != nil {
 		return nil, err
 	}
@@ -364,16 +1138,43 @@ func (client *Client) CtrListContainerIds(ctx context.Context) ([]string, error)
 	return res, nil
 }
 
-//CtrListContainer returns a list of containerd.Container ibjects
-func (client *Client) CtrListContainer(ctx context.Context) ([]containerd.Container, error) {
+// CtrListContainer returns a list of containerd.Container objects.
+// filters, if any, are containerd filter expressions (e.g. "id~=myapp",
+// "labels.\"foo\"==bar"), passed straight through to containerd so
+// callers like volumemgr don't have to pull the full container list on
+// devices with hundreds of containers just to filter it client-side.
+func (client *Client) CtrListContainer(ctx context.Context, filters ...string) ([]containerd.Container, error) {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return nil, fmt.Errorf("CtrListContainer: exception while verifying ctrd client: %s", err.Error())
 	}
-	return client.ctrdClient.Containers(ctx)
+	return client.ctrdClient.Containers(ctx, filters...)
+}
+
+// ContainerMetrics is the subset of the cgroup memory/CPU accounting
+// counters that GetDomsCPUMem needs, independent of whether the host
+// reports them through the cgroup v1 (hybrid) or v2 (unified) hierarchy.
+// CtrGetContainerMetrics converts whichever one a given host gives us into
+// this before handing it to the metrics publisher.
+type ContainerMetrics struct {
+	UsedMemory              uint64
+	HierarchicalMemoryLimit uint64
+	CPUTotal                uint64 // Nanoseconds
+}
+
+// unifiedCgroupHierarchyFile is present iff the host is using the cgroup v2
+// unified hierarchy rather than the v1 (hybrid/legacy) one; this is the
+// detection method containerd itself uses.
+const unifiedCgroupHierarchyFile = "/sys/fs/cgroup/cgroup.controllers"
+
+// isUnifiedCgroupHierarchy reports whether the host is using the cgroup v2
+// unified hierarchy.
+func isUnifiedCgroupHierarchy() bool {
+	_, err := os.Stat(unifiedCgroupHierarchyFile)
+	return err == nil
 }
 
 // CtrGetContainerMetrics returns all runtime metrics associated with a container ID
-func (client *Client) CtrGetContainerMetrics(ctx context.Context, containerID string) (*v1stat.Metrics, error) {
+func (client *Client) CtrGetContainerMetrics(ctx context.Context, containerID string) (*ContainerMetrics, error) {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return nil, fmt.Errorf("CtrGetContainerMetrics: exception while verifying ctrd client: %s", err.Error())
 	}
@@ -399,8 +1200,21 @@ func (client *Client) CtrGetContainerMetrics(ctx context.Context, containerID st
 
 	switch v := data.(type) {
 	case *v1stat.Metrics:
-		return v, nil
+		return &ContainerMetrics{
+			UsedMemory:              v.Memory.Usage.Usage,
+			HierarchicalMemoryLimit: v.Memory.HierarchicalMemoryLimit,
+			CPUTotal:                v.CPU.Usage.Total,
+		}, nil
 	default:
+		if isUnifiedCgroupHierarchy() {
+			// The host reports cgroup v2 (unified hierarchy) metrics,
+			// which use a different typeurl message than v1stat.Metrics
+			// above (e.g. "io.containerd.cgroups.v2.Metrics"). Decoding
+			// that needs the cgroups v2 stats types, which are not
+			// vendored here yet; once they are, add a case alongside
+			// *v1stat.Metrics the same way.
+			return nil, fmt.Errorf("CtrGetContainerMetrics: host uses the cgroup v2 unified hierarchy, which this build cannot parse task metrics for (got %T)", data)
+		}
 		return nil, fmt.Errorf("can't parse task metric %v", data)
 	}
 }
@@ -443,7 +1257,7 @@ func (client *Client) CtrCreateTask(ctx context.Context, domainName string) (int
 		return 0, err
 	}
 
-	logger := GetLog()
+	logger := GetLog(client.log)
 
 	io := func(id string) (cio.IO, error) {
 		stdoutFile := logger.Path("guest_vm-" + domainName)
@@ -498,19 +1312,97 @@ func (client *Client) CtrStartTask(ctx context.Context, domainName string) error
 		return err
 	}
 
-	if err := prepareProcess(int(task.Pid()), nil); err != nil {
+	if err := prepareProcess(client.log, int(task.Pid()), nil, domainName, nil); err != nil {
 		return err
 	}
 
 	return task.Start(ctx)
 }
 
+// CtrWaitTask returns a channel that delivers the exit status of the
+// container's task exactly once, when it exits, so callers can block on
+// (or select against) task completion instead of polling
+// CtrContainerInfo for a terminal status.
+func (client *Client) CtrWaitTask(ctx context.Context, domainName string) (<-chan containerd.ExitStatus, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return nil, fmt.Errorf("CtrWaitTask: exception while verifying ctrd client: %s", err.Error())
+	}
+	ctr, err := client.CtrLoadContainer(ctx, domainName)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := ctr.Task(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("CtrWaitTask: couldn't load task for container %s: %v", domainName, err)
+	}
+
+	return task.Wait(ctx)
+}
+
+// CtrPauseTask suspends the container's task, freezing its processes in
+// place without stopping them, so e.g. its CPU usage drops to zero during a
+// maintenance window while its memory state (and thus the app instance's
+// running state) is preserved for a later CtrResumeTask.
+func (client *Client) CtrPauseTask(ctx context.Context, domainName string) error {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return fmt.Errorf("CtrPauseTask: exception while verifying ctrd client: %s", err.Error())
+	}
+	ctr, err := client.CtrLoadContainer(ctx, domainName)
+	if err != nil {
+		return err
+	}
+
+	task, err := ctr.Task(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("CtrPauseTask: couldn't load task for container %s: %v", domainName, err)
+	}
+
+	return task.Pause(ctx)
+}
+
+// CtrResumeTask resumes a container's task previously suspended with
+// CtrPauseTask.
+func (client *Client) CtrResumeTask(ctx context.Context, domainName string) error {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return fmt.Errorf("CtrResumeTask: exception while verifying ctrd client: %s", err.Error())
+	}
+	ctr, err := client.CtrLoadContainer(ctx, domainName)
+	if err != nil {
+		return err
+	}
+
+	task, err := ctr.Task(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("CtrResumeTask: couldn't load task for container %s: %v", domainName, err)
+	}
+
+	return task.Resume(ctx)
+}
+
+// defaultExecTimeout is the exec timeout used by CtrExec/CtrSystemExec, and
+// by CtrExecWithOpts/CtrSystemExecWithOpts when opts.Timeout is zero.
+const defaultExecTimeout = 30 * time.Second
+
+// CtrExecOpts customizes CtrExecWithOpts/CtrSystemExecWithOpts beyond the
+// plain CtrExec/CtrSystemExec behavior.
+type CtrExecOpts struct {
+	// Timeout bounds how long to wait for the process to exit; zero
+	// means defaultExecTimeout, matching CtrExec/CtrSystemExec.
+	Timeout time.Duration
+	// Stdout and Stderr, if non-nil, additionally receive the process
+	// output as it is produced, instead of only after it exits. The
+	// full output is still buffered and returned as before.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
 // CtrExec starts the executable in a running user container
 func (client *Client) CtrExec(ctx context.Context, domainName string, args []string) (string, string, error) {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return "", "", fmt.Errorf("CtrExec: exception while verifying ctrd client: %s", err.Error())
 	}
-	return client.ctrExec(ctx, domainName, args)
+	return client.ctrExec(ctx, domainName, args, CtrExecOpts{})
 }
 
 // CtrSystemExec starts the executable in a running system (EVE's) container
@@ -518,30 +1410,133 @@ func (client *Client) CtrSystemExec(ctx context.Context, domainName string, args
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return "", "", fmt.Errorf("CtrSystemExec: exception while verifying ctrd client: %s", err.Error())
 	}
-	return client.ctrExec(ctx, domainName, args)
+	return client.ctrExec(ctx, domainName, args, CtrExecOpts{})
+}
+
+// CtrExecWithOpts is CtrExec with a caller-chosen timeout and/or streaming
+// output writers; see CtrExecOpts.
+func (client *Client) CtrExecWithOpts(ctx context.Context, domainName string, args []string, opts CtrExecOpts) (string, string, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return "", "", fmt.Errorf("CtrExecWithOpts: exception while verifying ctrd client: %s", err.Error())
+	}
+	return client.ctrExec(ctx, domainName, args, opts)
+}
+
+// CtrSystemExecWithOpts is CtrSystemExec with a caller-chosen timeout
+// and/or streaming output writers; see CtrExecOpts.
+func (client *Client) CtrSystemExecWithOpts(ctx context.Context, domainName string, args []string, opts CtrExecOpts) (string, string, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return "", "", fmt.Errorf("CtrSystemExecWithOpts: exception while verifying ctrd client: %s", err.Error())
+	}
+	return client.ctrExec(ctx, domainName, args, opts)
+}
+
+// CtrExecTTY starts an interactive process with a TTY and stdin attached
+// in a running container, for remote-shell style use cases (e.g.
+// edge-view) that stream stdin and window-resize requests for the
+// lifetime of the session instead of collecting buffered output like
+// CtrExec/CtrSystemExec. Unlike those, it does not wait for the process
+// to exit, apply a timeout, or delete the process when done: the caller
+// owns the returned Process and is responsible for calling Resize as
+// window size changes arrive, and eventually Wait/Delete on it. The
+// Process's ID() can later be passed to CtrAttach to reattach.
+func (client *Client) CtrExecTTY(ctx context.Context, domainName string, args []string,
+	stdin io.Reader, stdout, stderr io.Writer) (containerd.Process, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return nil, fmt.Errorf("CtrExecTTY: exception while verifying ctrd client: %s", err.Error())
+	}
+	ctr, err := client.ctrdClient.LoadContainer(ctx, domainName)
+	if err != nil {
+		return nil, fmt.Errorf("CtrExecTTY: Exception while loading container: %v", err)
+	}
+	spec, err := ctr.Spec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	task, err := ctr.Task(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	pspec := spec.Process
+	pspec.Terminal = true
+	pspec.Args = args
+
+	cioOpts := []cio.Opt{cio.WithStreams(stdin, stdout, stderr), cio.WithTerminal, cio.WithFIFODir(fifoDir)}
+	// exec-id for task.Exec can NOT be longer than 71 runes, on top of that it has to match:
+	//   ^[A-Za-z0-9]+(?:[._-](?:[A-Za-z0-9]+))*$:
+	process, err := task.Exec(ctx, fmt.Sprintf("%.50s%.20d", domainName, rand.Int()), pspec, cio.NewCreator(cioOpts...))
+	if err != nil {
+		return nil, err
+	}
+	if err := process.Start(ctx); err != nil {
+		process.Delete(ctx)
+		return nil, err
+	}
+	return process, nil
+}
+
+// CtrAttach reattaches to the IO streams of a process previously started
+// with CtrExecTTY in domainName, identified by execID (the Process.ID()
+// returned by CtrExecTTY), e.g. after a remote-shell client reconnects.
+func (client *Client) CtrAttach(ctx context.Context, domainName, execID string,
+	stdin io.Reader, stdout, stderr io.Writer) (containerd.Process, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return nil, fmt.Errorf("CtrAttach: exception while verifying ctrd client: %s", err.Error())
+	}
+	ctr, err := client.ctrdClient.LoadContainer(ctx, domainName)
+	if err != nil {
+		return nil, fmt.Errorf("CtrAttach: Exception while loading container: %v", err)
+	}
+	task, err := ctr.Task(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("CtrAttach: couldn't load task for container %s: %v", domainName, err)
+	}
+
+	cioOpts := []cio.Opt{cio.WithStreams(stdin, stdout, stderr), cio.WithTerminal, cio.WithFIFODir(fifoDir)}
+	return task.LoadProcess(ctx, execID, cio.NewAttach(cioOpts...))
 }
 
 // CtrStopContainer stops (kills) the main task in the container
-func (client *Client) CtrStopContainer(ctx context.Context, containerID string, force bool) error {
+// CtrStopContainer sends the container's stop signal - the image's
+// StopSignal (see containerd.StopSignalLabel, set by CreateContainer from
+// the OCI image config) if it has one, else SIGTERM - and waits up to
+// gracePeriod for its task to exit on its own before escalating to
+// SIGKILL. It returns whether the task exited gracefully within
+// gracePeriod, so callers such as domainmgr can report that up through
+// DomainStatus to zedmanager. force skips the signal and grace period
+// entirely and kills the task immediately, e.g. when cleaning up a stale
+// task we don't care about; force always reports a non-graceful stop.
+func (client *Client) CtrStopContainer(ctx context.Context, containerID string, force bool, gracePeriod time.Duration) (bool, error) {
 	if err := client.verifyCtr(ctx, true); err != nil {
-		return fmt.Errorf("CtrStopContainer: exception while verifying ctrd client: %s", err.Error())
+		return false, fmt.Errorf("CtrStopContainer: exception while verifying ctrd client: %s", err.Error())
 	}
 	ctr, err := client.CtrLoadContainer(ctx, containerID)
 	if err != nil {
-		return fmt.Errorf("can't find cotainer %s (%v)", containerID, err)
+		return false, fmt.Errorf("can't find cotainer %s (%v)", containerID, err)
+	}
+
+	task, err := ctr.Task(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	if force {
+		_, err = task.Delete(ctx, containerd.WithProcessKill)
+		return false, err
 	}
 
 	signal, err := containerd.ParseSignal(defaultSignal)
 	if err != nil {
-		return err
+		return false, err
 	}
 	if signal, err = containerd.GetStopSignal(ctx, ctr, signal); err != nil {
-		return err
+		return false, err
 	}
 
-	task, err := ctr.Task(ctx, nil)
+	exitCh, err := task.Wait(ctx)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// it is unclear whether we have to wait after this or proceed
@@ -549,16 +1544,28 @@ func (client *Client) CtrStopContainer(ctx context.Context, containerID string,
 	// to the err returned is worth anything at this point
 	_ = task.Kill(ctx, signal, containerd.WithKillAll)
 
-	if force {
-		_, err = task.Delete(ctx, containerd.WithProcessKill)
-	} else {
-		_, err = task.Delete(ctx)
+	graceful := true
+	select {
+	case <-exitCh:
+	case <-time.After(gracePeriod):
+		graceful = false
+		_ = task.Kill(ctx, syscall.SIGKILL, containerd.WithKillAll)
+		<-exitCh
 	}
 
-	return err
+	_, err = task.Delete(ctx)
+	return graceful, err
 }
 
-// CtrDeleteContainer is a simple wrapper around container.Delete()
+// CtrDeleteContainer stops containerID's task if still running, then
+// removes the container along with the rootfs snapshot it allocated (see
+// containerd.WithSnapshotCleanup) and any lease created with containerID
+// as its ID - the convention callers use (see CtrNewUserServicesCtxWithLease
+// and friends) when a lease should live and die with a specific container
+// rather than with one call. Previously only the container itself was
+// removed, leaving its snapshot and lease, if any, to eventual GC - on a
+// busy device cycling through many short-lived containers this let
+// /persist fill up well before GC caught up.
 func (client *Client) CtrDeleteContainer(ctx context.Context, containerID string) error {
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return fmt.Errorf("CtrDeleteContainer: exception while verifying ctrd client: %s", err.Error())
@@ -569,9 +1576,36 @@ func (client *Client) CtrDeleteContainer(ctx context.Context, containerID string
 	}
 
 	// do this just in case
-	_ = client.CtrStopContainer(ctx, containerID, true)
+	_, _ = client.CtrStopContainer(ctx, containerID, true, 0)
+
+	info, err := ctr.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("CtrDeleteContainer: exception while fetching container info: %s", err.Error())
+	}
 
-	return ctr.Delete(ctx)
+	var reclaimed int64
+	if info.SnapshotKey != "" && info.Snapshotter != "" {
+		svc := client.ctrdClient.SnapshotService(info.Snapshotter)
+		if usage, err := svc.Usage(ctx, info.SnapshotKey); err != nil {
+			client.log.Warnf("CtrDeleteContainer: could not compute snapshot usage for %s: %s", containerID, err.Error())
+		} else {
+			reclaimed = usage.Size
+		}
+	}
+
+	if err := ctr.Delete(ctx, containerd.WithSnapshotCleanup); err != nil {
+		return fmt.Errorf("CtrDeleteContainer: exception while deleting container: %s", err.Error())
+	}
+
+	ls := client.ctrdClient.LeasesService()
+	if err := ls.Delete(ctx, leases.Lease{ID: containerID}); err != nil && !errdefs.IsNotFound(err) {
+		client.log.Warnf("CtrDeleteContainer: could not delete lease %s: %s", containerID, err.Error())
+	}
+
+	unbindTaskNamespaces(client.log, containerID)
+
+	client.log.Noticef("CtrDeleteContainer(%s): reclaimed %d bytes of snapshot storage", containerID, reclaimed)
+	return nil
 }
 
 // Resolver return a resolver.ResolverCloser that can read from containerd
@@ -594,7 +1628,7 @@ func (client *Client) LKTaskPrepare(name, linuxkit string, domSettings *types.Do
 	config := "/containers/services/" + linuxkit + "/config.json"
 	rootfs := "/containers/services/" + linuxkit + "/rootfs"
 
-	log.Infof("Starting LKTaskLaunch for %s", linuxkit)
+	client.log.Infof("Starting LKTaskLaunch for %s", linuxkit)
 	f, err := os.Open("/hostfs" + config)
 	if err != nil {
 		return fmt.Errorf("LKTaskLaunch: can't open spec file %s %v", config, err)
@@ -641,22 +1675,106 @@ func (client *Client) CtrNewSystemServicesCtx() (context.Context, context.Cancel
 	return newServiceCtx(ctrdSystemServicesNamespace)
 }
 
+// defaultLeaseTTL is used by CtrNewUserServicesCtxWithLease and
+// CtrNewSystemServicesCtxWithLease for callers that don't care to pick
+// their own duration.
+const defaultLeaseTTL = 24 * time.Hour
+
 // CtrNewUserServicesCtxWithLease returns a new user service containerd context with a 24 hrs lease
 // and a done func to delete the lease and cancel the context after use.
 func (client *Client) CtrNewUserServicesCtxWithLease() (context.Context, context.CancelFunc, error) {
-	return newServiceCtxWithLease(client.ctrdClient, ctrdServicesNamespace)
+	return newServiceCtxWithLease(client.log, client.ctrdClient, ctrdServicesNamespace, defaultLeaseTTL)
 }
 
 // CtrNewSystemServicesCtxWithLease returns a new system service containerd context with a 24 hrs lease
 // and a done func to delete the lease and cancel the context after use.
 func (client *Client) CtrNewSystemServicesCtxWithLease() (context.Context, context.CancelFunc, error) {
-	return newServiceCtxWithLease(client.ctrdClient, ctrdSystemServicesNamespace)
+	return newServiceCtxWithLease(client.log, client.ctrdClient, ctrdSystemServicesNamespace, defaultLeaseTTL)
+}
+
+// CtrNewUserServicesCtxWithLeaseTTL is CtrNewUserServicesCtxWithLease with a
+// caller-chosen lease duration, for downloads slow enough that 24 hrs isn't
+// enough, or cleanups that want a much shorter-lived lease.
+func (client *Client) CtrNewUserServicesCtxWithLeaseTTL(ttl time.Duration) (context.Context, context.CancelFunc, error) {
+	return newServiceCtxWithLease(client.log, client.ctrdClient, ctrdServicesNamespace, ttl)
+}
+
+// CtrNewSystemServicesCtxWithLeaseTTL is CtrNewSystemServicesCtxWithLease
+// with a caller-chosen lease duration.
+func (client *Client) CtrNewSystemServicesCtxWithLeaseTTL(ttl time.Duration) (context.Context, context.CancelFunc, error) {
+	return newServiceCtxWithLease(client.log, client.ctrdClient, ctrdSystemServicesNamespace, ttl)
+}
+
+// CtrAttachToLease returns a copy of ctx that refers to the existing lease
+// leaseID instead of creating a new one, so resources written through it
+// (e.g. a blob ingested by CtrWriteBlob) are kept alive by a long-lived
+// lease owned by the caller (such as volumemgr's lease for a pending
+// download) rather than by a lease scoped to this one call.
+func (client *Client) CtrAttachToLease(ctx context.Context, leaseID string) (context.Context, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return ctx, fmt.Errorf("CtrAttachToLease: exception while verifying ctrd client: %s", err.Error())
+	}
+	return leases.WithLease(ctx, leaseID), nil
+}
+
+// CtrListLeases returns every lease currently held in the namespace
+// embedded in ctx.
+func (client *Client) CtrListLeases(ctx context.Context) ([]leases.Lease, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return nil, fmt.Errorf("CtrListLeases: exception while verifying ctrd client: %s", err.Error())
+	}
+	return client.ctrdClient.LeasesService().List(ctx)
+}
+
+// CtrRenewLease extends the life of leaseID by ttl from now. The lease
+// manager has no in-place update, so this re-creates the lease under a new
+// ID with the resources and labels carried over, deletes the old lease, and
+// returns the new ID; callers that track a lease ID (e.g. across a long
+// download) need to start using the returned ID.
+func (client *Client) CtrRenewLease(ctx context.Context, leaseID string, ttl time.Duration) (string, error) {
+	if err := client.verifyCtr(ctx, true); err != nil {
+		return "", fmt.Errorf("CtrRenewLease: exception while verifying ctrd client: %s", err.Error())
+	}
+	ls := client.ctrdClient.LeasesService()
+	existing, err := ls.List(ctx)
+	if err != nil {
+		return "", fmt.Errorf("CtrRenewLease: could not list leases: %s", err.Error())
+	}
+	var old *leases.Lease
+	for i := range existing {
+		if existing[i].ID == leaseID {
+			old = &existing[i]
+			break
+		}
+	}
+	if old == nil {
+		return "", fmt.Errorf("CtrRenewLease: lease %s not found", leaseID)
+	}
+	resources, err := ls.ListResources(ctx, *old)
+	if err != nil {
+		return "", fmt.Errorf("CtrRenewLease: could not list resources of lease %s: %s", leaseID, err.Error())
+	}
+	renewed, err := ls.Create(ctx, leases.WithRandomID(), leases.WithExpiration(ttl), leases.WithLabels(old.Labels))
+	if err != nil {
+		return "", fmt.Errorf("CtrRenewLease: could not create renewed lease: %s", err.Error())
+	}
+	for _, resource := range resources {
+		if err := ls.AddResource(ctx, renewed, resource); err != nil {
+			return "", fmt.Errorf("CtrRenewLease: could not carry resource %s over to renewed lease: %s",
+				resource.ID, err.Error())
+		}
+	}
+	if err := ls.Delete(ctx, *old); err != nil {
+		client.log.Warnf("CtrRenewLease: could not delete superseded lease %s: %s", leaseID, err.Error())
+	}
+	return renewed.ID, nil
 }
 
 // Util methods
 
 // ctrExec starts the executable in a running container and attaches its logging to memlogd
-func (client *Client) ctrExec(ctx context.Context, domainName string, args []string) (string, string, error) {
+func (client *Client) ctrExec(ctx context.Context, domainName string, args []string, opts CtrExecOpts) (outStr string, errStr string, err error) {
+	defer func(start time.Time) { client.recordCall("Exec", start, err) }(time.Now())
 	if err := client.verifyCtr(ctx, true); err != nil {
 		return "", "", fmt.Errorf("ctrExec: exception while verifying ctrd client: %s", err.Error())
 	}
@@ -678,12 +1796,22 @@ func (client *Client) ctrExec(ctx context.Context, domainName string, args []str
 	pspec.Terminal = true
 	pspec.Args = args
 
-	// plumb the process for I/O
+	// plumb the process for I/O; stdOut/stdErr always capture the full
+	// output so the return value is unchanged, and additionally tee to
+	// opts.Stdout/opts.Stderr, if given, as the output is produced
 	var (
 		stdOut bytes.Buffer
 		stdErr bytes.Buffer
+		outW   io.Writer = &stdOut
+		errW   io.Writer = &stdErr
 	)
-	cioOpts := []cio.Opt{cio.WithStreams(new(bytes.Buffer), &stdOut, &stdErr), cio.WithFIFODir(fifoDir)}
+	if opts.Stdout != nil {
+		outW = io.MultiWriter(&stdOut, opts.Stdout)
+	}
+	if opts.Stderr != nil {
+		errW = io.MultiWriter(&stdErr, opts.Stderr)
+	}
+	cioOpts := []cio.Opt{cio.WithStreams(new(bytes.Buffer), outW, errW), cio.WithFIFODir(fifoDir)}
 	// exec-id for task.Exec can NOT be longer than 71 runes, on top of that it has to match:
 	//   ^[A-Za-z0-9]+(?:[._-](?:[A-Za-z0-9]+))*$:
 	process, err := task.Exec(ctx, fmt.Sprintf("%.50s%.20d", domainName, rand.Int()), pspec, cio.NewCreator(cioOpts...))
@@ -703,8 +1831,12 @@ func (client *Client) ctrExec(ctx context.Context, domainName string, args []str
 		return "", "", err
 	}
 
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = defaultExecTimeout
+	}
 	// block until the process exits or the timer fires
-	timer := time.NewTimer(30 * time.Second)
+	timer := time.NewTimer(timeout)
 	select {
 	case status := <-statusC:
 		if code, _, e := status.Result(); e == nil && code != 0 {
@@ -714,10 +1846,17 @@ func (client *Client) ctrExec(ctx context.Context, domainName string, args []str
 		}
 	case <-timer.C:
 		err = fmt.Errorf("execution timed out")
+		// Kill the whole exec'd process group rather than leaving it
+		// running in the background after we stop waiting on it; a
+		// long-running diagnostic command (e.g. a shell pipeline) can
+		// otherwise outlive this call indefinitely.
+		if killErr := process.Kill(ctx, syscall.SIGKILL, containerd.WithKillAll); killErr != nil {
+			client.log.Warnf("ctrExec: killing timed-out process for %s failed: %v", domainName, killErr)
+		}
 	}
 
 	st, ee := process.Status(ctx)
-	log.Debugf("ctrExec process exited with: %v %v %d %d %d %d", st, ee, stdOut.Cap(), stdOut.Len(), stdErr.Cap(), stdErr.Len())
+	client.log.Debugf("ctrExec process exited with: %v %v %d %d %d %d", st, ee, stdOut.Cap(), stdOut.Len(), stdErr.Cap(), stdErr.Len())
 	return stdOut.String(), stdErr.String(), err
 }
 
@@ -729,19 +1868,19 @@ func createMountPointExecEnvFiles(containerPath string, mountpoints map[string]s
 
 	mpFile, err := os.Create(mpFileName)
 	if err != nil {
-		log.Errorf("createMountPointExecEnvFiles: os.Create for %v, failed: %v", mpFileName, err.Error())
+		logrus.Errorf("createMountPointExecEnvFiles: os.Create for %v, failed: %v", mpFileName, err.Error())
 	}
 	defer mpFile.Close()
 
 	cmdFile, err := os.Create(cmdFileName)
 	if err != nil {
-		log.Errorf("createMountPointExecEnvFiles: os.Create for %v, failed: %v", cmdFileName, err.Error())
+		logrus.Errorf("createMountPointExecEnvFiles: os.Create for %v, failed: %v", cmdFileName, err.Error())
 	}
 	defer cmdFile.Close()
 
 	envFile, err := os.Create(envFileName)
 	if err != nil {
-		log.Errorf("createMountPointExecEnvFiles: os.Create for %v, failed: %v", envFileName, err.Error())
+		logrus.Errorf("createMountPointExecEnvFiles: os.Create for %v, failed: %v", envFileName, err.Error())
 	}
 	defer envFile.Close()
 
@@ -752,7 +1891,7 @@ func createMountPointExecEnvFiles(containerPath string, mountpoints map[string]s
 	switch {
 	case noOfDisks > len(mountpoints):
 		//If no. of disks is (strictly) greater than no. of mount-points provided, we will ignore excessive disks.
-		log.Warnf("createMountPointExecEnvFiles: Number of volumes provided: %v is more than number of mount-points: %v. "+
+		logrus.Warnf("createMountPointExecEnvFiles: Number of volumes provided: %v is more than number of mount-points: %v. "+
 			"Excessive volumes will be ignored", noOfDisks, len(mountpoints))
 	case noOfDisks < len(mountpoints):
 		//If no. of mount-points is (strictly) greater than no. of disks provided, we need to throw an error as there
@@ -765,13 +1904,13 @@ func createMountPointExecEnvFiles(containerPath string, mountpoints map[string]s
 		if !strings.HasPrefix(path, "/") {
 			//Target path is expected to be absolute.
 			err := fmt.Errorf("createMountPointExecEnvFiles: targetPath should be absolute")
-			log.Errorf(err.Error())
+			logrus.Errorf(err.Error())
 			return err
 		}
-		log.Infof("createMountPointExecEnvFiles: Processing mount point %s\n", path)
+		logrus.Infof("createMountPointExecEnvFiles: Processing mount point %s\n", path)
 		if _, err := mpFile.WriteString(fmt.Sprintf("%s\n", path)); err != nil {
 			err := fmt.Errorf("createMountPointExecEnvFiles: writing to %s failed %v", mpFileName, err)
-			log.Errorf(err.Error())
+			logrus.Errorf(err.Error())
 			return err
 		}
 	}
@@ -783,7 +1922,7 @@ func createMountPointExecEnvFiles(containerPath string, mountpoints map[string]s
 	}
 	if _, err := cmdFile.WriteString(strings.Join(execpathQuoted, " ")); err != nil {
 		err := fmt.Errorf("createMountPointExecEnvFiles: writing to %s failed %v", cmdFileName, err)
-		log.Errorf(err.Error())
+		logrus.Errorf(err.Error())
 		return err
 	}
 
@@ -796,7 +1935,7 @@ func createMountPointExecEnvFiles(containerPath string, mountpoints map[string]s
 	}
 	if _, err := envFile.WriteString(envContent); err != nil {
 		err := fmt.Errorf("createMountPointExecEnvFiles: writing to %s failed %v", envFileName, err)
-		log.Errorf(err.Error())
+		logrus.Errorf(err.Error())
 		return err
 	}
 
@@ -834,50 +1973,182 @@ func getContainerConfigs(imageInfo ocispec.Image, userEnvVars map[string]string)
 	return mountpoints, execpath, workdir, env, nil
 }
 
+// defaultNsBindSet is the set of kernel namespaces prepareProcess binds for
+// a container when its DomainConfig doesn't ask for a different set.
+var defaultNsBindSet = []string{"cgroup", "ipc", "mnt", "net", "pid", "user", "uts"}
+
+// nsBindDir is the base directory under which prepareProcess bind-mounts a
+// task's namespace files, keyed by domainName, so unbindTaskNamespaces can
+// find and tear them back down again at task delete time.
+const nsBindDir = "/run/eve-ctr-ns"
+
+// taskNsBindDir returns the per-task directory prepareProcess bind-mounts
+// domainName's namespace files under.
+func taskNsBindDir(domainName string) string {
+	return filepath.Join(nsBindDir, domainName)
+}
+
 // prepareProcess sets up anything that needs to be done after the container process is created,
-// but before it runs (for example networking)
-func prepareProcess(pid int, VifList []types.VifInfo) error {
+// but before it runs (for example networking).
+//
+// domainName identifies the task for bookkeeping: its namespace files are
+// bind-mounted under taskNsBindDir(domainName), which unbindTaskNamespaces
+// tears back down when the task is deleted, so long-running devices don't
+// accumulate leaked namespace bind mounts. nsBindSet overrides which
+// namespaces are bound (nil uses defaultNsBindSet); a container that needs
+// to stay in the host's namespace for one of them (e.g. "user") can drop
+// it from its own set.
+func prepareProcess(log *base.LogObject, pid int, VifList []types.VifInfo,
+	domainName string, nsBindSet []string) error {
 	log.Infof("prepareProcess(%d, %v)", pid, VifList)
 	for _, iface := range VifList {
-		if iface.Vif == "" {
-			return fmt.Errorf("Interface requires a name")
+		if err := prepareVif(pid, iface); err != nil {
+			return err
+		}
+	}
+
+	if len(nsBindSet) == 0 {
+		nsBindSet = defaultNsBindSet
+	}
+	bindDir := taskNsBindDir(domainName)
+	for _, ns := range nsBindSet {
+		if err := bindNS(ns, filepath.Join(bindDir, ns), pid); err != nil {
+			return err
 		}
+	}
 
-		var link netlink.Link
-		var err error
+	return nil
+}
+
+// prepareVif gets iface's Vif into pid's network namespace -- creating it as
+// a macvlan sub-interface of MacvlanParent first if that is set, otherwise
+// moving the existing host interface named Vif -- then applies whatever
+// rename/MTU/address configuration iface asks for once it is there.
+func prepareVif(pid int, iface types.VifInfo) error {
+	if iface.Vif == "" {
+		return fmt.Errorf("Interface requires a name")
+	}
 
+	var link netlink.Link
+	var err error
+
+	if iface.MacvlanParent != "" {
+		link, err = newMacvlan(iface)
+		if err != nil {
+			return fmt.Errorf("prepareProcess: Cannot create macvlan %s on %s: %v",
+				iface.Vif, iface.MacvlanParent, err)
+		}
+	} else {
 		link, err = netlink.LinkByName(iface.Vif)
 		if err != nil {
 			return fmt.Errorf("prepareProcess: Cannot find interface %s: %v", iface.Vif, err)
 		}
+	}
+
+	if err := netlink.LinkSetNsPid(link, pid); err != nil {
+		return fmt.Errorf("prepareProcess: Cannot move interface %s into namespace: %v", iface.Vif, err)
+	}
+
+	if iface.NsVifName == "" && iface.Mtu == 0 && len(iface.IPAddrs) == 0 {
+		return nil
+	}
+
+	nsName := iface.Vif
+	ns, err := netns.GetFromPid(pid)
+	if err != nil {
+		return fmt.Errorf("prepareProcess: Cannot look up namespace of pid %d: %v", pid, err)
+	}
+	defer ns.Close()
+	handle, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		return fmt.Errorf("prepareProcess: Cannot open netlink handle in namespace of pid %d: %v", pid, err)
+	}
+	defer handle.Delete()
 
-		if err := netlink.LinkSetNsPid(link, int(pid)); err != nil {
-			return fmt.Errorf("prepareProcess: Cannot move interface %s into namespace: %v", iface.Vif, err)
+	if iface.NsVifName != "" {
+		if err := handle.LinkSetName(link, iface.NsVifName); err != nil {
+			return fmt.Errorf("prepareProcess: Cannot rename interface %s to %s: %v",
+				iface.Vif, iface.NsVifName, err)
+		}
+		nsName = iface.NsVifName
+		if link, err = handle.LinkByName(nsName); err != nil {
+			return fmt.Errorf("prepareProcess: Cannot find renamed interface %s: %v", nsName, err)
 		}
 	}
 
-	binds := []struct {
-		ns   string
-		path string
-	}{
-		{"cgroup", ""},
-		{"ipc", ""},
-		{"mnt", ""},
-		{"net", ""},
-		{"pid", ""},
-		{"user", ""},
-		{"uts", ""},
+	if iface.Mtu != 0 {
+		if err := handle.LinkSetMTU(link, int(iface.Mtu)); err != nil {
+			return fmt.Errorf("prepareProcess: Cannot set MTU on %s: %v", nsName, err)
+		}
 	}
 
-	for _, b := range binds {
-		if err := bindNS(b.ns, b.path, pid); err != nil {
-			return err
+	for _, cidr := range iface.IPAddrs {
+		addr, err := netlink.ParseAddr(cidr)
+		if err != nil {
+			return fmt.Errorf("prepareProcess: Cannot parse address %s for %s: %v", cidr, nsName, err)
+		}
+		if err := handle.AddrAdd(link, addr); err != nil {
+			return fmt.Errorf("prepareProcess: Cannot add address %s to %s: %v", cidr, nsName, err)
 		}
 	}
 
 	return nil
 }
 
+// newMacvlan creates iface.Vif as a new macvlan sub-interface of
+// iface.MacvlanParent in the host namespace, ready to be moved into a
+// task's namespace by the caller. ipvlan sub-interfaces are not supported:
+// the vendored netlink library used here has no ipvlan link type.
+func newMacvlan(iface types.VifInfo) (netlink.Link, error) {
+	parent, err := netlink.LinkByName(iface.MacvlanParent)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find parent interface %s: %v", iface.MacvlanParent, err)
+	}
+	mv := &netlink.Macvlan{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:        iface.Vif,
+			ParentIndex: parent.Attrs().Index,
+		},
+		Mode: netlink.MACVLAN_MODE_BRIDGE,
+	}
+	if iface.Mac != "" {
+		hwAddr, err := net.ParseMAC(iface.Mac)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse MAC %s: %v", iface.Mac, err)
+		}
+		mv.LinkAttrs.HardwareAddr = hwAddr
+	}
+	if err := netlink.LinkAdd(mv); err != nil {
+		return nil, fmt.Errorf("cannot create macvlan device: %v", err)
+	}
+	return netlink.LinkByName(iface.Vif)
+}
+
+// unbindTaskNamespaces tears down the namespace bind mounts prepareProcess
+// set up for domainName, if any, so deleting a task doesn't leak them.
+// It is best-effort: errors are logged, not returned, since a container
+// that never started (no bind directory) or one whose mounts are already
+// gone is not a failure for the caller deleting it.
+func unbindTaskNamespaces(log *base.LogObject, domainName string) {
+	bindDir := taskNsBindDir(domainName)
+	entries, err := ioutil.ReadDir(bindDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("unbindTaskNamespaces(%s): cannot read %s: %v", domainName, bindDir, err)
+		}
+		return
+	}
+	for _, entry := range entries {
+		path := filepath.Join(bindDir, entry.Name())
+		if err := unbindNS(path); err != nil {
+			log.Warnf("unbindTaskNamespaces(%s): %v", domainName, err)
+		}
+	}
+	if err := os.RemoveAll(bindDir); err != nil {
+		log.Warnf("unbindTaskNamespaces(%s): cannot remove %s: %v", domainName, bindDir, err)
+	}
+}
+
 func getSavedImageInfo(containerPath string) (ocispec.Image, error) {
 	var image ocispec.Image
 
@@ -891,14 +2162,61 @@ func getSavedImageInfo(containerPath string) (ocispec.Image, error) {
 	return image, nil
 }
 
-//verifyCtr verifies is containerd client and context(if verifyCtx is true) .
+// reconnect tears down the existing containerd connection (if any) and
+// dials a fresh one, retrying with exponential backoff. It is called by
+// verifyCtr when the current connection has gone into connectivity.Shutdown,
+// which happens when the containerd daemon is restarted out from under us.
+func (client *Client) reconnect() error {
+	client.connMu.Lock()
+	defer client.connMu.Unlock()
+
+	// another caller may have already reconnected us while we waited on the lock
+	if client.ctrdClient != nil && client.ctrdClient.Conn().GetState() != connectivity.Shutdown {
+		return nil
+	}
+
+	if client.ctrdClient != nil {
+		client.ctrdClient.Close()
+	}
+
+	delay := reconnectBaseDelay
+	var err error
+	for attempt := 0; attempt < reconnectAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		var ctrdClient *containerd.Client
+		ctrdClient, err = containerd.New(ctrdSocket, containerd.WithDefaultRuntime(containerdRunTime))
+		if err == nil {
+			client.ctrdClient = ctrdClient
+			client.contentStore = ctrdClient.ContentStore()
+			client.log.Infof("reconnect: reconnected to containerd after %d attempt(s)", attempt+1)
+			return nil
+		}
+		client.log.Warnf("reconnect: attempt %d to reconnect to containerd failed: %s", attempt+1, err)
+	}
+	return fmt.Errorf("reconnect: giving up after %d attempts: %v", reconnectAttempts, err)
+}
+
+// verifyCtr verifies is containerd client and context(if verifyCtx is true) .
+// If the containerd daemon has restarted out from under us, it transparently
+// reconnects with backoff before reporting an error, so idempotent callers
+// do not need to know a reconnect happened.
 func (client *Client) verifyCtr(ctx context.Context, verifyCtx bool) error {
-	if client.ctrdClient == nil {
+	client.connMu.Lock()
+	ctrdClient := client.ctrdClient
+	needsReconnect := ctrdClient != nil && ctrdClient.Conn().GetState() == connectivity.Shutdown
+	client.connMu.Unlock()
+
+	if ctrdClient == nil {
 		return fmt.Errorf("verifyCtr: Containerd client is nil")
 	}
 
-	if client.ctrdClient.Conn().GetState() == connectivity.Shutdown {
-		return fmt.Errorf("verifyCtr: Containerd client is closed")
+	if needsReconnect {
+		if err := client.reconnect(); err != nil {
+			return fmt.Errorf("verifyCtr: Containerd client is closed: %v", err)
+		}
 	}
 
 	if verifyCtx {
@@ -936,11 +2254,23 @@ func bindNS(ns string, path string, pid int) error {
 	return nil
 }
 
+// unbindNS undoes bindNS: it unmounts the namespace bind at path and
+// removes the now-plain file it was bound onto.
+func unbindNS(path string) error {
+	if err := unix.Unmount(path, 0); err != nil {
+		return fmt.Errorf("unbindNS: Failed to unmount %s: %v", path, err)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unbindNS: Failed to remove bind mount point %s: %v", path, err)
+	}
+	return nil
+}
+
 func newServiceCtx(namespace string) (context.Context, context.CancelFunc) {
 	return context.WithCancel(namespaces.WithNamespace(context.Background(), namespace))
 }
 
-func newServiceCtxWithLease(ctrdClient *containerd.Client, namespace string) (context.Context, context.CancelFunc, error) {
+func newServiceCtxWithLease(log *base.LogObject, ctrdClient *containerd.Client, namespace string, ttl time.Duration) (context.Context, context.CancelFunc, error) {
 	if ctrdClient == nil {
 		return nil, nil, fmt.Errorf("newServiceCtxWithLease(%s): exception while verifying ctrd client: "+
 			namespace, "Container client is nil")
@@ -949,7 +2279,7 @@ func newServiceCtxWithLease(ctrdClient *containerd.Client, namespace string) (co
 	//We need to cancel the context separately other that calling the done() returned from `ctrdClient.WithLease(ctx)`
 	//because done() only deletes the lease associated with the context.
 	ctx, cancel := newServiceCtx(namespace)
-	ctx, done, err := ctrdClient.WithLease(ctx)
+	ctx, done, err := ctrdClient.WithLease(ctx, leases.WithRandomID(), leases.WithExpiration(ttl))
 	if err != nil {
 		cancel()
 		return nil, nil, fmt.Errorf("CtrCreateCtxWithLease: exception while creating lease: %s", err.Error())
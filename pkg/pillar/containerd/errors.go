@@ -0,0 +1,58 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package containerd
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// callRetryAttempts and callRetryBaseDelay bound the retry/backoff applied
+// by withCallRetry to individual containerd API calls classified as
+// transient by isTransientError.
+const (
+	callRetryAttempts  = 3
+	callRetryBaseDelay = 100 * time.Millisecond
+)
+
+// isTransientError reports whether err is a gRPC status worth retrying --
+// the containerd daemon or one of its backing stores being momentarily
+// unavailable or overloaded -- as opposed to a permanent failure like
+// NotFound or InvalidArgument, which retrying cannot fix.
+func isTransientError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// withCallRetry calls fn, retrying with bounded exponential backoff while
+// fn's error is classified as transient by isTransientError, so a momentary
+// containerd/snapshotter hiccup doesn't immediately surface as a call
+// failure to volumemgr/domainmgr. ctx cancellation aborts the retry loop.
+// fn must be safe to call more than once; callers with a non-idempotent or
+// streaming operation (e.g. consuming a reader) should not use this.
+func withCallRetry(ctx context.Context, fn func() error) error {
+	delay := callRetryBaseDelay
+	var err error
+	for attempt := 0; attempt < callRetryAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return err
+			}
+			delay *= 2
+		}
+		if err = fn(); err == nil || !isTransientError(err) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/healthprobe"
+)
+
+// healthWatch is the state for one in-flight WatchHealth goroutine.
+type healthWatch struct {
+	cancel context.CancelFunc
+	state  healthprobe.State
+}
+
+// WatchHealth runs probe on domainName's container on a schedule, per
+// probe.Period, until UnwatchHealth is called. onTransition, if non-nil,
+// is called from the watch goroutine every time the health status
+// changes, so callers can publish it; it must not block. If
+// probe.RestartOnFailure is set and the container becomes
+// healthprobe.StatusUnhealthy, its task is force-stopped so that any
+// restart-policy watch started via WatchTask picks up the exit and
+// restarts it - WatchHealth itself never restarts a task directly.
+// Calling WatchHealth again for a domainName already being watched first
+// stops the existing watch.
+func (client *Client) WatchHealth(domainName string, probe healthprobe.Config,
+	onTransition func(state healthprobe.State)) {
+
+	client.UnwatchHealth(domainName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &healthWatch{cancel: cancel}
+
+	client.healthMu.Lock()
+	if client.healthWatches == nil {
+		client.healthWatches = make(map[string]*healthWatch)
+	}
+	client.healthWatches[domainName] = w
+	client.healthMu.Unlock()
+
+	go client.watchHealthLoop(ctx, domainName, probe, w, onTransition)
+}
+
+// UnwatchHealth stops any health watch running for domainName; it is a
+// no-op if none is running.
+func (client *Client) UnwatchHealth(domainName string) {
+	client.healthMu.Lock()
+	w, found := client.healthWatches[domainName]
+	if found {
+		delete(client.healthWatches, domainName)
+	}
+	client.healthMu.Unlock()
+	if found {
+		w.cancel()
+	}
+}
+
+func (client *Client) watchHealthLoop(ctx context.Context, domainName string,
+	probe healthprobe.Config, w *healthWatch, onTransition func(state healthprobe.State)) {
+
+	period := probe.Period
+	if period <= 0 {
+		period = 10 * time.Second
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		probeCtx, cancel := client.CtrNewUserServicesCtx()
+		ok, err := client.runHealthProbe(probeCtx, domainName, probe)
+		cancel()
+
+		if transitioned := w.state.RecordResult(probe, ok, err, time.Now()); transitioned {
+			client.log.Infof("watchHealthLoop(%s): health status now %v", domainName, w.state.Status)
+			if onTransition != nil {
+				onTransition(w.state)
+			}
+			if w.state.Status == healthprobe.StatusUnhealthy && probe.RestartOnFailure {
+				client.log.Warnf("watchHealthLoop(%s): unhealthy, stopping task to trigger restart",
+					domainName)
+				if _, stopErr := client.CtrStopContainer(ctx, domainName, true, 0); stopErr != nil {
+					client.log.Errorf("watchHealthLoop(%s): stop for restart failed: %v",
+						domainName, stopErr)
+				}
+			}
+		}
+	}
+}
+
+// runHealthProbe runs one probe attempt per probe.Type, executed inside
+// domainName's own container (and so its own network namespace, for
+// TypeTCP/TypeHTTP) since domainmgr doesn't otherwise track a container's
+// address. A non-nil error, or ok==false, means the probe failed.
+func (client *Client) runHealthProbe(ctx context.Context, domainName string,
+	probe healthprobe.Config) (bool, error) {
+
+	var args []string
+	switch probe.Type {
+	case healthprobe.TypeExec:
+		if len(probe.Exec) == 0 {
+			return false, fmt.Errorf("runHealthProbe: TypeExec with no command configured")
+		}
+		args = probe.Exec
+	case healthprobe.TypeTCP:
+		// /dev/tcp is a shell builtin, not universally available on
+		// minimal (e.g. busybox ash) images; same caveat applies to
+		// exec-based TCP probes in other container runtimes.
+		script := fmt.Sprintf("cat < /dev/tcp/127.0.0.1/%d", probe.Port)
+		args = []string{"/bin/sh", "-c", script}
+	case healthprobe.TypeHTTP:
+		path := probe.HTTPPath
+		if path == "" {
+			path = "/"
+		}
+		url := fmt.Sprintf("http://127.0.0.1:%d%s", probe.Port, path)
+		script := fmt.Sprintf("wget -q -O /dev/null '%s' || curl -fsS -o /dev/null '%s'", url, url)
+		args = []string{"/bin/sh", "-c", script}
+	default:
+		return false, fmt.Errorf("runHealthProbe: unknown probe type %v", probe.Type)
+	}
+
+	opts := CtrExecOpts{Timeout: probe.Timeout}
+	_, _, err := client.ctrExec(ctx, domainName, args, opts)
+	return err == nil, err
+}
@@ -13,19 +13,56 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/containerd/containerd/cio"
+	"github.com/lf-edge/eve/pkg/pillar/base"
 	logutils "github.com/lf-edge/eve/pkg/pillar/utils/logging"
-
-	log "github.com/sirupsen/logrus" // XXX add log argument
 )
 
 const (
-	fifoDir        string = "/var/run/tasks/fifos"
-	logDumpCommand byte   = iota
+	logDumpCommand byte = iota
 )
 
+// fifoDir is the directory exec and log-streaming FIFOs (and, via
+// cio.WithFIFODir, containerd's own per-exec tempdirs - see
+// NewFIFOSetInDir in the vendored cio package) are created under. It is a
+// var, not a const, so SetFifoDir can point it at an alternate tmpfs
+// mount; callers must do so, if at all, before NewContainerdClient.
+var fifoDir = "/var/run/tasks/fifos"
+
+// SetFifoDir overrides the directory used for exec and log-streaming
+// FIFOs. Must be called before NewContainerdClient, which creates the
+// directory and cleans up anything left behind under it by a previous
+// process.
+func SetFifoDir(dir string) {
+	fifoDir = dir
+}
+
+// cleanStaleFifosOnce keeps a second NewContainerdClient in the same
+// process from removing FIFOs that an earlier Client is still using.
+var cleanStaleFifosOnce sync.Once
+
+// cleanStaleFifos removes any entries left under fifoDir by a previous
+// pillar process that crashed or was restarted mid-exec before it had a
+// chance to close (and thus remove) its own FIFOs and per-exec tempdirs.
+// It is only safe to call once, before this process has created any
+// FIFOs of its own, which is why NewContainerdClient is the only caller,
+// and calls it through cleanStaleFifosOnce.
+func cleanStaleFifos(log *base.LogObject) {
+	entries, err := ioutil.ReadDir(fifoDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		stale := filepath.Join(fifoDir, entry.Name())
+		if err := os.RemoveAll(stale); err != nil {
+			log.Warnf("cleanStaleFifos: failed to remove stale %s: %s", stale, err)
+		}
+	}
+}
+
 type logio struct {
 	config cio.Config
 }
@@ -51,12 +88,21 @@ type Log interface {
 	Dump(string)                         // Copies logs to the console
 }
 
-// GetLog returns the log destination we should use.
-func GetLog() Log {
+// GetLog returns the log destination we should use. log is used to report
+// problems encountered while plumbing a task's own stdout/stderr into
+// memlogd; it is unrelated to the log stream being set up.
+//
+// Note this only plumbs a task's stdout/stderr into memlogd. The v2
+// shim process itself (io.containerd.runc.v2) logs to the containerd
+// daemon's own debug log, which is configured in containerd's
+// config.toml outside this tree; there is no per-container hook here
+// to redirect that into memlogd.
+func GetLog(log *base.LogObject) Log {
 	if _, err := os.Stat(logWriteSocket); !os.IsNotExist(err) {
 		_ = os.MkdirAll(fifoDir, 0777)
 		return &remoteLog{
 			fifoDir: fifoDir,
+			log:     log,
 		}
 	}
 	return &nullLog{}
@@ -89,28 +135,45 @@ func (f *nullLog) Dump(n string) {
 
 type remoteLog struct {
 	fifoDir string
+	log     *base.LogObject
 }
 
-// Path returns the name of a FIFO connected to the logging daemon.
+// Path returns the name of a FIFO connected to the logging daemon. The
+// FIFO's contents are not handed to memlogd directly - they are pumped
+// through rateLimitedCopy first (over a local pipe whose read end is
+// handed to memlogd instead), so a chatty guest_vm stream can't starve
+// memlogd or fill /persist. See logStreamByteRateLimit/logStreamSizeCap.
 func (r *remoteLog) Path(n string) string {
 	path := filepath.Join(r.fifoDir, n+".log")
 	if err := syscall.Mkfifo(path, 0600); err != nil {
 		return "/dev/null"
 	}
-	log.Infof("Creating %s at %s", "func", logutils.GetMyStack())
+	r.log.Infof("Creating %s at %s", "func", logutils.GetMyStack())
 	go func() {
 		// In a goroutine because Open of the FIFO will block until
 		// containerd opens it when the task is started.
 		fd, err := syscall.Open(path, syscall.O_RDONLY, 0)
 		if err != nil {
 			// Should never happen: we just created the fifo
-			log.Printf("failed to open fifo %s: %s", path, err)
+			r.log.Errorf("failed to open fifo %s: %s", path, err)
+			return
 		}
 		defer syscall.Close(fd)
-		if err := sendToLogger(n, fd); err != nil {
+
+		pipeR, pipeW, err := os.Pipe()
+		if err != nil {
+			r.log.Errorf("failed to create rate-limiting pipe for %s: %s", n, err)
+			return
+		}
+		defer pipeW.Close()
+		if err := sendToLogger(r.log, n, int(pipeR.Fd())); err != nil {
 			// Should never happen: logging is enabled
-			log.Printf("failed to send fifo %s to logger: %s", path, err)
+			r.log.Errorf("failed to send fifo %s to logger: %s", path, err)
+			pipeR.Close()
+			return
 		}
+		pipeR.Close()
+		rateLimitedCopy(r.log, n, pipeW, fdReader(fd))
 	}()
 	return path
 }
@@ -119,11 +182,11 @@ func (r *remoteLog) Path(n string) string {
 func (r *remoteLog) Open(n string) (io.WriteCloser, error) {
 	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
 	if err != nil {
-		log.Fatal("Unable to create socketpair: ", err)
+		r.log.Fatal("Unable to create socketpair: ", err)
 	}
 	logFile := os.NewFile(uintptr(fds[0]), "")
 
-	if err := sendToLogger(n, fds[1]); err != nil {
+	if err := sendToLogger(r.log, n, fds[1]); err != nil {
 		return nil, err
 	}
 	return logFile, nil
@@ -137,13 +200,13 @@ func (r *remoteLog) Dump(n string) {
 	}
 	conn, err := net.DialUnix("unix", nil, &addr)
 	if err != nil {
-		log.Printf("Failed to connect to logger: %s", err)
+		r.log.Errorf("Failed to connect to logger: %s", err)
 		return
 	}
 	defer conn.Close()
 	nWritten, err := conn.Write([]byte{logDumpCommand})
 	if err != nil || nWritten < 1 {
-		log.Printf("Failed to request logs from logger: %s", err)
+		r.log.Errorf("Failed to request logs from logger: %s", err)
 		return
 	}
 	reader := bufio.NewReader(conn)
@@ -153,7 +216,7 @@ func (r *remoteLog) Dump(n string) {
 			return
 		}
 		if err != nil {
-			log.Printf("Failed to read log message: %s", err)
+			r.log.Errorf("Failed to read log message: %s", err)
 			return
 		}
 		// a line is of the form
@@ -161,7 +224,7 @@ func (r *remoteLog) Dump(n string) {
 		prefixBody := strings.SplitN(line, ";", 2)
 		csv := strings.Split(prefixBody[0], ",")
 		if len(csv) < 2 {
-			log.Printf("Failed to parse log message: %s", line)
+			r.log.Errorf("Failed to parse log message: %s", line)
 			continue
 		}
 		if csv[1] == n {
@@ -170,7 +233,7 @@ func (r *remoteLog) Dump(n string) {
 	}
 }
 
-func sendToLogger(name string, fd int) error {
+func sendToLogger(log *base.LogObject, name string, fd int) error {
 	var ctlSocket int
 	var err error
 	if ctlSocket, err = syscall.Socket(syscall.AF_UNIX, syscall.SOCK_DGRAM, 0); err != nil {
@@ -0,0 +1,98 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package containerd
+
+import (
+	"fmt"
+	"io"
+	"syscall"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+)
+
+// logStreamByteRateLimit caps the sustained throughput, in bytes per
+// second, of a single guest_vm stdout/stderr stream forwarded into
+// memlogd by remoteLog.Path, so a chatty guest can't starve memlogd - and,
+// downstream, every other task's logs - on its own.
+//
+// logStreamSizeCap bounds the cumulative bytes a single stream may
+// forward before pillar stops forwarding it and writes a rotation marker
+// in its place, rather than letting it fill /persist. The stream resumes
+// forwarding (with a fresh cap) the next time the task is created, since
+// remoteLog.Path is called again then.
+//
+// Both are vars, rather than consts, so tests can shrink them instead of
+// pushing real cap-sized volumes through rateLimitedCopy.
+var (
+	logStreamByteRateLimit int64 = 64 * 1024
+	logStreamSizeCap       int64 = 64 * 1024 * 1024
+)
+
+// fdReader adapts a raw fd into an io.Reader without the finalizer
+// concerns of wrapping it in an *os.File, since the caller already owns
+// closing the fd itself.
+type fdReader int
+
+func (f fdReader) Read(p []byte) (int, error) {
+	n, err := syscall.Read(int(f), p)
+	if n == 0 && err == nil {
+		return 0, io.EOF
+	}
+	return n, err
+}
+
+// rateLimitedCopy copies from src to dst, capping sustained throughput at
+// logStreamByteRateLimit and cumulative volume at logStreamSizeCap; once
+// the cap is hit it writes a rotation marker to dst and silently drains
+// (without forwarding) the rest of src, so the writer on the other end of
+// src never blocks on a full pipe. It returns once src returns an error,
+// typically io.EOF when the task's end of the stream is closed; errors
+// are logged, not returned, matching the other fire-and-forget goroutines
+// in this package.
+func rateLimitedCopy(log *base.LogObject, name string, dst io.Writer, src io.Reader) {
+	var total, windowBytes int64
+	var windowStart time.Time
+	capped := false
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if !capped {
+				now := time.Now()
+				if windowStart.IsZero() || now.Sub(windowStart) >= time.Second {
+					windowStart = now
+					windowBytes = 0
+				}
+				windowBytes += int64(n)
+				if windowBytes > logStreamByteRateLimit {
+					time.Sleep(time.Second - now.Sub(windowStart))
+				}
+
+				total += int64(n)
+				if total > logStreamSizeCap {
+					capped = true
+					marker := fmt.Sprintf(
+						"\n[pillar: log stream %q exceeded %d bytes, dropping further output until the task restarts]\n",
+						name, logStreamSizeCap)
+					if _, werr := dst.Write([]byte(marker)); werr != nil {
+						log.Errorf("rateLimitedCopy(%s): writing rotation marker: %s", name, werr)
+						return
+					}
+					log.Warnf("rateLimitedCopy(%s): exceeded %d byte cap, dropping further output",
+						name, logStreamSizeCap)
+				} else if _, werr := dst.Write(buf[:n]); werr != nil {
+					log.Errorf("rateLimitedCopy(%s): %s", name, werr)
+					return
+				}
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Warnf("rateLimitedCopy(%s): read: %s", name, err)
+			}
+			return
+		}
+	}
+}
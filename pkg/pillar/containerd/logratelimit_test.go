@@ -0,0 +1,48 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package containerd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRateLimitedCopyPassesThroughUnderCap(t *testing.T) {
+	log := base.NewSourceLogObject(logrus.StandardLogger(), "test", 1234)
+	src := strings.NewReader("hello world")
+	var dst bytes.Buffer
+
+	rateLimitedCopy(log, "test-stream", &dst, src)
+
+	if dst.String() != "hello world" {
+		t.Errorf("rateLimitedCopy() = %q, want %q", dst.String(), "hello world")
+	}
+}
+
+func TestRateLimitedCopyCapsSize(t *testing.T) {
+	origRate, origCap := logStreamByteRateLimit, logStreamSizeCap
+	logStreamByteRateLimit = 1024 * 1024
+	logStreamSizeCap = 1024
+	defer func() {
+		logStreamByteRateLimit, logStreamSizeCap = origRate, origCap
+	}()
+
+	log := base.NewSourceLogObject(logrus.StandardLogger(), "test", 1234)
+	src := bytes.NewReader(bytes.Repeat([]byte("x"), int(logStreamSizeCap)+1))
+	var dst bytes.Buffer
+
+	rateLimitedCopy(log, "test-stream", &dst, src)
+
+	if dst.Len() > int(logStreamSizeCap)+512 {
+		// Allow some slack for the rotation marker appended past the cap.
+		t.Errorf("rateLimitedCopy() forwarded %d bytes, want close to the %d byte cap", dst.Len(), logStreamSizeCap)
+	}
+	if !strings.Contains(dst.String(), "exceeded") {
+		t.Errorf("rateLimitedCopy() output missing rotation marker: %q", dst.String())
+	}
+}
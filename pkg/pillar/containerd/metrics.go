@@ -0,0 +1,67 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package containerd
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/types"
+	"google.golang.org/grpc/status"
+)
+
+// clientMetrics holds the running types.ContainerdMetrics for a Client
+// (its metrics field), guarded by mu. Updated by recordCall, which the instrumented
+// Ctr* calls (CtrWriteBlob, CtrPrepareSnapshot, Ctr*Exec) defer on entry -
+// see GetMetrics for how a caller reads it out.
+type clientMetrics struct {
+	mu sync.Mutex
+	m  types.ContainerdMetrics
+}
+
+// recordCall records one completed call named name, that started at start
+// and returned err (nil on success), into client's running metrics. err is
+// classified by its gRPC status code, if it has one, so a caller can tell
+// "containerd is unavailable" apart from "that blob does not exist".
+func (client *Client) recordCall(name string, start time.Time, err error) {
+	latency := time.Since(start)
+
+	client.metrics.mu.Lock()
+	defer client.metrics.mu.Unlock()
+	if client.metrics.m == nil {
+		client.metrics.m = make(types.ContainerdMetrics)
+	}
+	call := client.metrics.m[name]
+	call.CallCount++
+	call.TotalLatencyNsec += uint64(latency.Nanoseconds())
+	call.LastLatencyNsec = uint64(latency.Nanoseconds())
+	if err != nil {
+		call.ErrorCount++
+		call.LastError = err.Error()
+		call.LastErrorTime = time.Now()
+		if call.ErrorsByCode == nil {
+			call.ErrorsByCode = make(map[string]uint64)
+		}
+		call.ErrorsByCode[status.Code(err).String()]++
+	}
+	client.metrics.m[name] = call
+}
+
+// GetMetrics returns a point-in-time copy of client's per-API-call
+// counters and latency accumulation (see recordCall), for publishing to
+// diag/the controller.
+func (client *Client) GetMetrics() types.ContainerdMetrics {
+	client.metrics.mu.Lock()
+	defer client.metrics.mu.Unlock()
+	snapshot := make(types.ContainerdMetrics, len(client.metrics.m))
+	for name, call := range client.metrics.m {
+		errsByCode := make(map[string]uint64, len(call.ErrorsByCode))
+		for code, count := range call.ErrorsByCode {
+			errsByCode[code] = count
+		}
+		call.ErrorsByCode = errsByCode
+		snapshot[name] = call
+	}
+	return snapshot
+}
@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"github.com/containerd/containerd"
 	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/namespaces"
 	"github.com/containerd/containerd/oci"
 	zconfig "github.com/lf-edge/eve/api/go/config"
 	"github.com/lf-edge/eve/pkg/pillar/types"
@@ -26,6 +27,12 @@ import (
 
 const eveScript = "/bin/eve"
 
+// defaultContainerApparmorProfile confines an eve-user-apps container that
+// doesn't set DomainConfig.ApparmorProfile itself. It is expected to be
+// loaded as part of the base image's AppArmor profile set; this name just
+// has to match the profile loaded there.
+const defaultContainerApparmorProfile = "eve-default-container"
+
 var vethScript = []string{"eve", "exec", "pillar", "/opt/zededa/bin/veth.sh"}
 
 // ociSpec is kept private (with all the actions done by getters and setters
@@ -39,6 +46,7 @@ type ociSpec struct {
 	volumes      map[string]struct{}
 	labels       map[string]string
 	stopSignal   string
+	runtime      string
 }
 
 // OCISpec provides methods to manipulate OCI runtime specifications and create containers based on them
@@ -54,6 +62,7 @@ type OCISpec interface {
 	UpdateMounts([]types.DiskStatus)
 	UpdateMountsNested([]types.DiskStatus)
 	UpdateEnvVar(map[string]string)
+	SetRuntime(string)
 }
 
 // NewOciSpec returns a default oci spec from the containerd point of view
@@ -102,15 +111,42 @@ func (s *ociSpec) Load(file *os.File) error {
 	return nil
 }
 
+// SetRuntime pins this container to a specific containerd shim runtime
+// (e.g. KataRuntime), overriding the namespace's default picked by
+// runtimeForNamespace. Passing "" reverts to that default.
+func (s *ociSpec) SetRuntime(runtime string) {
+	s.runtime = runtime
+}
+
 // CreateContainer starts an OCI container based on the spec
 func (s *ociSpec) CreateContainer(removeExisting bool) error {
 	ctrdCtx, done := s.client.CtrNewUserServicesCtx()
 	defer done()
-	_, err := s.client.ctrdClient.NewContainer(ctrdCtx, s.name, containerd.WithSpec(&s.Spec))
+	runtime := s.runtime
+	if runtime == "" {
+		ns, _ := namespaces.Namespace(ctrdCtx)
+		runtime = runtimeForNamespace(ns)
+	}
+	if err := validateRuntimeInstalled(runtime); err != nil {
+		return fmt.Errorf("CreateContainer(%s): %v", s.name, err)
+	}
+	opts := []containerd.NewContainerOpts{
+		containerd.WithSpec(&s.Spec),
+		containerd.WithRuntime(runtime, nil),
+	}
+	if s.stopSignal != "" {
+		// Recorded by CtrStopContainer via containerd.GetStopSignal, so a
+		// graceful stop signals what the image actually expects instead
+		// of always sending SIGTERM.
+		opts = append(opts, containerd.WithContainerLabels(map[string]string{
+			containerd.StopSignalLabel: s.stopSignal,
+		}))
+	}
+	_, err := s.client.ctrdClient.NewContainer(ctrdCtx, s.name, opts...)
 	// if container exists, is stopped and we are asked to remove existing - try that
 	if err != nil && removeExisting {
 		_ = s.client.CtrDeleteContainer(ctrdCtx, s.name)
-		_, err = s.client.ctrdClient.NewContainer(ctrdCtx, s.name, containerd.WithSpec(&s.Spec))
+		_, err = s.client.ctrdClient.NewContainer(ctrdCtx, s.name, opts...)
 	}
 	return err
 }
@@ -169,6 +205,25 @@ func (s *ociSpec) UpdateFromDomain(dom types.DomainConfig) {
 		s.Linux.Resources.CPU.Period = &p
 		s.Linux.Resources.CPU.Quota = &q
 	}
+	if dom.EnableGVisor {
+		// EnableGVisor takes precedence over a plain OCIRuntime string
+		// since picking gVisor also requires the untrusted-workload
+		// annotation below, not just a different shim.
+		s.runtime = GvisorRuntime
+		if s.Spec.Annotations == nil {
+			s.Spec.Annotations = make(map[string]string)
+		}
+		s.Spec.Annotations[gvisorUntrustedWorkloadAnnotation] = "true"
+	} else {
+		s.runtime = dom.OCIRuntime
+	}
+	if dom.IsContainer {
+		s.Process.ApparmorProfile = dom.ApparmorProfile
+		if s.Process.ApparmorProfile == "" {
+			s.Process.ApparmorProfile = defaultContainerApparmorProfile
+		}
+		s.Process.SelinuxLabel = dom.SelinuxLabel
+	}
 }
 
 // UpdateFromVolume updates values in the OCI spec based on the location
@@ -0,0 +1,99 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package containerd
+
+import (
+	"context"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/taskmonitor"
+)
+
+// taskWatch is the state for one in-flight WatchTask goroutine.
+type taskWatch struct {
+	cancel context.CancelFunc
+	state  taskmonitor.State
+}
+
+// WatchTask watches domainName's task for exit and, per policy, restarts
+// it via CtrStartTask with the exponential backoff implemented by package
+// taskmonitor, until UnwatchTask is called or a restart attempt itself
+// fails. onRestart, if non-nil, is called from the watch goroutine after
+// every exit (whether or not it led to a restart) so callers can publish
+// restart status; it must not block. Calling WatchTask again for a
+// domainName already being watched first stops the existing watch.
+func (client *Client) WatchTask(domainName string, policy taskmonitor.Policy,
+	onRestart func(state taskmonitor.State, restarted bool, err error)) {
+
+	client.UnwatchTask(domainName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &taskWatch{cancel: cancel}
+
+	client.restartMu.Lock()
+	if client.restartWatches == nil {
+		client.restartWatches = make(map[string]*taskWatch)
+	}
+	client.restartWatches[domainName] = w
+	client.restartMu.Unlock()
+
+	go client.watchTaskLoop(ctx, domainName, policy, w, onRestart)
+}
+
+// UnwatchTask stops any restart watch running for domainName; it is a
+// no-op if none is running.
+func (client *Client) UnwatchTask(domainName string) {
+	client.restartMu.Lock()
+	w, found := client.restartWatches[domainName]
+	if found {
+		delete(client.restartWatches, domainName)
+	}
+	client.restartMu.Unlock()
+	if found {
+		w.cancel()
+	}
+}
+
+func (client *Client) watchTaskLoop(ctx context.Context, domainName string,
+	policy taskmonitor.Policy, w *taskWatch,
+	onRestart func(state taskmonitor.State, restarted bool, err error)) {
+
+	for {
+		exitCh, err := client.CtrWaitTask(ctx, domainName)
+		if err != nil {
+			client.log.Errorf("watchTaskLoop(%s): couldn't wait for task: %v", domainName, err)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case status := <-exitCh:
+			w.state.RecordExit(int(status.ExitCode()), time.Now())
+			if !w.state.ShouldRestart(policy) {
+				if onRestart != nil {
+					onRestart(w.state, false, nil)
+				}
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(w.state.Backoff()):
+			}
+
+			ctrdCtx, done := client.CtrNewUserServicesCtx()
+			startErr := client.CtrStartTask(ctrdCtx, domainName)
+			done()
+			if onRestart != nil {
+				onRestart(w.state, startErr == nil, startErr)
+			}
+			if startErr != nil {
+				client.log.Errorf("watchTaskLoop(%s): restart failed: %v", domainName, startErr)
+				return
+			}
+		}
+	}
+}
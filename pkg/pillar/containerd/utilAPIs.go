@@ -4,6 +4,7 @@
 package containerd
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -11,7 +12,7 @@ import (
 
 	"github.com/containerd/containerd"
 	uuid "github.com/satori/go.uuid"
-	log "github.com/sirupsen/logrus" // XXX add log argument
+	log "github.com/sirupsen/logrus" // used by the free functions below, which predate any Client
 )
 
 const (
@@ -79,9 +80,23 @@ func GetSnapshotID(rootpath string) string {
 	return snapshotID
 }
 
+// GetImageConfigDigest returns the sha256 digest, in the usual
+// "sha256:<hex>" form, of the OCI image config saved alongside a
+// container's bundle at containerPath by PrepareMount. This identifies
+// the app image actually launched from containerPath, for measured-boot
+// style attestation (see evetpm.ExtendMeasurementPCR).
+func GetImageConfigDigest(containerPath string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(containerPath, imageConfigFilename))
+	if err != nil {
+		return "", fmt.Errorf("GetImageConfigDigest(%s): %v", containerPath, err)
+	}
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("sha256:%x", sum), nil
+}
+
 //UnpackClientImage unpacks given client image into containerd.
 func (client *Client) UnpackClientImage(clientImage containerd.Image) error {
-	log.Infof("UnpackClientImage: for image :%s", clientImage.Name())
+	client.log.Infof("UnpackClientImage: for image :%s", clientImage.Name())
 	ctrdCtx, done := client.CtrNewUserServicesCtx()
 	defer done()
 	unpacked, err := clientImage.IsUnpacked(ctrdCtx, defaultSnapshotter)
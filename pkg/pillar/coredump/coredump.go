@@ -0,0 +1,226 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package coredump implements capture and retention of core dumps for
+// crashing EVE agent processes and native container app processes, so
+// a later support bundle can include them instead of the crash simply
+// vanishing without a trace. The actual capture is driven by the
+// kernel's core_pattern mechanism piping the core image, on stdin, to
+// the "coredump" entrypoint (see cmd/coredump); this package holds the
+// logic that entrypoint and its configuring agent share.
+package coredump
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+)
+
+// eveAgentPathPrefixes are where EVE's own agent binaries live; any
+// crashing process whose executable is not under one of these is
+// assumed to be a native container app process.
+var eveAgentPathPrefixes = []string{"/opt/zededa/bin/", "/usr/bin/"}
+
+// Config mirrors the subset of GlobalConfig that controls core dump
+// capture. It is written by zedagent to types.CoreDumpConfigFile
+// whenever GlobalConfig changes, and read by the coredump entrypoint
+// on every invocation, so the entrypoint - invoked synchronously by
+// the kernel on the crash path - never needs a pubsub subscription.
+type Config struct {
+	// AgentsEnabled captures cores of crashing EVE agent processes.
+	AgentsEnabled bool `json:"agentsEnabled"`
+	// AppsEnabled captures cores of crashing native container app
+	// processes.
+	AppsEnabled bool `json:"appsEnabled"`
+	// Compress gzip-compresses captured core files.
+	Compress bool `json:"compress"`
+	// QuotaBytes caps the total size of the retained capture
+	// directory; oldest cores are deleted first to make room.
+	QuotaBytes uint64 `json:"quotaBytes"`
+}
+
+// Metadata is the sidecar JSON written alongside each captured core,
+// recording enough to symbolize it later: which binary crashed, and
+// when. A real symbolizer additionally needs that binary's debug
+// info, which for EVE agents and app containers is held in their
+// respective eve/app images rather than alongside the core itself.
+type Metadata struct {
+	Comm      string    `json:"comm"`
+	Pid       int       `json:"pid"`
+	Timestamp time.Time `json:"timestamp"`
+	ExePath   string    `json:"exePath"`
+	IsApp     bool      `json:"isApp"`
+}
+
+// WriteConfig atomically writes cfg to path, for the coredump
+// entrypoint to pick up on the next crash.
+func WriteConfig(path string, cfg Config) error {
+	body, err := json.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("coredump.WriteConfig: %s", err)
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, body, 0644); err != nil {
+		return fmt.Errorf("coredump.WriteConfig: %s", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("coredump.WriteConfig: %s", err)
+	}
+	return nil
+}
+
+// ReadConfig reads back what WriteConfig wrote. If path does not
+// exist yet (e.g. zedagent hasn't started), it returns a zero Config,
+// which has capture disabled for both scopes - the safe default.
+func ReadConfig(path string) (Config, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Config{}, nil
+		}
+		return Config{}, fmt.Errorf("coredump.ReadConfig: %s", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		return Config{}, fmt.Errorf("coredump.ReadConfig: %s", err)
+	}
+	return cfg, nil
+}
+
+// IsEveAgentPath reports whether exePath looks like one of EVE's own
+// agent binaries, as opposed to a process running inside an app
+// container.
+func IsEveAgentPath(exePath string) bool {
+	for _, prefix := range eveAgentPathPrefixes {
+		if strings.HasPrefix(exePath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Capture applies cfg's per-scope enablement to a crash identified by
+// comm/pid/timestamp/exePath, and if enabled, reads the raw core
+// image from body and stores it (optionally gzip-compressed) under
+// dir, along with a Metadata sidecar, then enforces cfg.QuotaBytes by
+// deleting the oldest retained cores. If capture is disabled for this
+// crash's scope, body is drained and discarded so the kernel's pipe
+// doesn't block, and "" is returned with no error.
+func Capture(log *base.LogObject, dir string, cfg Config, comm string, pid int,
+	timestamp time.Time, exePath string, body io.Reader) (string, error) {
+
+	isApp := !IsEveAgentPath(exePath)
+	enabled := cfg.AgentsEnabled
+	if isApp {
+		enabled = cfg.AppsEnabled
+	}
+	if !enabled {
+		io.Copy(ioutil.Discard, body)
+		return "", nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		io.Copy(ioutil.Discard, body)
+		return "", fmt.Errorf("coredump.Capture: %s", err)
+	}
+
+	baseName := fmt.Sprintf("%s.%d.%d", comm, pid, timestamp.Unix())
+	corePath := filepath.Join(dir, baseName+".core")
+	if cfg.Compress {
+		corePath += ".gz"
+	}
+	if err := writeCore(corePath, cfg.Compress, body); err != nil {
+		return "", fmt.Errorf("coredump.Capture: %s", err)
+	}
+
+	meta := Metadata{
+		Comm:      comm,
+		Pid:       pid,
+		Timestamp: timestamp,
+		ExePath:   exePath,
+		IsApp:     isApp,
+	}
+	metaBody, err := json.Marshal(meta)
+	if err != nil {
+		log.Errorf("coredump.Capture: marshaling metadata for %s: %s", corePath, err)
+	} else if err := ioutil.WriteFile(corePath+".json", metaBody, 0644); err != nil {
+		log.Errorf("coredump.Capture: writing metadata for %s: %s", corePath, err)
+	}
+
+	if err := enforceQuota(log, dir, cfg.QuotaBytes); err != nil {
+		log.Errorf("coredump.Capture: enforcing quota on %s: %s", dir, err)
+	}
+	log.Noticef("coredump.Capture: captured %s (isApp %t) to %s", comm, isApp, corePath)
+	return corePath, nil
+}
+
+func writeCore(path string, compress bool, body io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if !compress {
+		_, err = io.Copy(f, body)
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	if _, err := io.Copy(gz, body); err != nil {
+		gz.Close()
+		return err
+	}
+	return gz.Close()
+}
+
+// enforceQuota deletes the oldest core (and its metadata sidecar,
+// if any) in dir, repeatedly, until the total size of *.core and
+// *.core.gz files is at or under quotaBytes.
+func enforceQuota(log *base.LogObject, dir string, quotaBytes uint64) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	type coreFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var cores []coreFile
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.Contains(entry.Name(), ".core") {
+			continue
+		}
+		cores = append(cores, coreFile{
+			path:    filepath.Join(dir, entry.Name()),
+			size:    entry.Size(),
+			modTime: entry.ModTime(),
+		})
+		total += entry.Size()
+	}
+	sort.Slice(cores, func(i, j int) bool {
+		return cores[i].modTime.Before(cores[j].modTime)
+	})
+	for _, c := range cores {
+		if uint64(total) <= quotaBytes {
+			break
+		}
+		if err := os.Remove(c.path); err != nil {
+			log.Warnf("enforceQuota: removing %s: %s", c.path, err)
+			continue
+		}
+		os.Remove(c.path + ".json")
+		total -= c.size
+		log.Infof("enforceQuota: evicted %s to stay under %d byte quota", c.path, quotaBytes)
+	}
+	return nil
+}
@@ -7,12 +7,19 @@ package devicenetwork
 
 import (
 	"github.com/eriknordmark/netlink"
+	"github.com/lf-edge/eve/pkg/pillar/arpprobe"
 	"github.com/lf-edge/eve/pkg/pillar/base"
 	"github.com/lf-edge/eve/pkg/pillar/types"
 	"net"
 	"reflect"
+	"time"
 )
 
+// arpProbeTimeout bounds how long AddrChange waits for an ARP reply
+// before concluding nobody else on the segment answers for a newly
+// assigned uplink address.
+const arpProbeTimeout = 200 * time.Millisecond
+
 // Returns a channel for address updates
 // Caller then does this in select loop:
 //	case change := <-addrChanges:
@@ -63,6 +70,7 @@ func AddrChange(ctx DeviceNetworkContext, change netlink.AddrUpdate) (bool, int)
 		if isPort {
 			if change.NewAddr {
 				AddSourceRule(log, change.LinkIndex, change.LinkAddress, false)
+				probeUplinkAddrConflict(log, ifname, change.LinkAddress.IP)
 			} else {
 				DelSourceRule(log, change.LinkIndex, change.LinkAddress, false)
 			}
@@ -153,6 +161,30 @@ func checkIfMgmtPortsHaveIPandDNS(log *base.LogObject, status types.DeviceNetwor
 	return false
 }
 
+// probeUplinkAddrConflict checks whether another host on ifname's L2
+// segment already answers for ip before EVE starts using it as an uplink
+// address, loudly logging a conflict the same way setBridgeIPAddr does
+// for network instance bridges -- the address still came from DHCP/static
+// config and EVE can't refuse to use it, but an operator debugging
+// intermittent uplink connectivity should not have to guess that it is
+// actually a duplicate-address fight with another host on the wire.
+func probeUplinkAddrConflict(log *base.LogObject, ifname string, ip net.IP) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return
+	}
+	conflict, err := arpprobe.Probe(ifname, ip4, arpProbeTimeout)
+	if err != nil {
+		log.Warnf("probeUplinkAddrConflict: ARP probe for %s on %s failed: %v",
+			ip4, ifname, err)
+		return
+	}
+	if conflict != nil {
+		log.Errorf("probeUplinkAddrConflict: address conflict: %s is already in use by %s on %s",
+			conflict.IP, conflict.MAC, ifname)
+	}
+}
+
 func HandleAddressChange(ctx *DeviceNetworkContext) {
 
 	log := ctx.Log
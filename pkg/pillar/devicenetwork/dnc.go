@@ -32,25 +32,27 @@ type DPCPending struct {
 }
 
 type DeviceNetworkContext struct {
-	DecryptCipherContext     cipher.DecryptCipherContext
-	AgentName                string
-	UsableAddressCount       int
-	DevicePortConfig         *types.DevicePortConfig // Currently in use
-	DevicePortConfigList     *types.DevicePortConfigList
-	AssignableAdapters       *types.AssignableAdapters
-	DevicePortConfigTime     time.Time
-	DeviceNetworkStatus      *types.DeviceNetworkStatus
-	SubDevicePortConfigA     pubsub.Subscription
-	SubDevicePortConfigO     pubsub.Subscription
-	SubDevicePortConfigS     pubsub.Subscription
-	SubAssignableAdapters    pubsub.Subscription
-	PubDevicePortConfig      pubsub.Publication
-	PubDummyDevicePortConfig pubsub.Publication // For logging
-	PubDevicePortConfigList  pubsub.Publication
-	PubCipherBlockStatus     pubsub.Publication
-	PubDeviceNetworkStatus   pubsub.Publication
-	Changed                  bool
-	SubGlobalConfig          pubsub.Subscription
+	DecryptCipherContext       cipher.DecryptCipherContext
+	AgentName                  string
+	UsableAddressCount         int
+	DevicePortConfig           *types.DevicePortConfig // Currently in use
+	DevicePortConfigList       *types.DevicePortConfigList
+	DevicePortConfigHistory    *types.DevicePortConfigHistory
+	AssignableAdapters         *types.AssignableAdapters
+	DevicePortConfigTime       time.Time
+	DeviceNetworkStatus        *types.DeviceNetworkStatus
+	SubDevicePortConfigA       pubsub.Subscription
+	SubDevicePortConfigO       pubsub.Subscription
+	SubDevicePortConfigS       pubsub.Subscription
+	SubAssignableAdapters      pubsub.Subscription
+	PubDevicePortConfig        pubsub.Publication
+	PubDummyDevicePortConfig   pubsub.Publication // For logging
+	PubDevicePortConfigList    pubsub.Publication
+	PubDevicePortConfigHistory pubsub.Publication
+	PubCipherBlockStatus       pubsub.Publication
+	PubDeviceNetworkStatus     pubsub.Publication
+	Changed                    bool
+	SubGlobalConfig            pubsub.Subscription
 
 	Pending                DPCPending
 	NetworkTestTimer       *time.Timer
@@ -188,6 +190,7 @@ func compressDPCL(ctx *DeviceNetworkContext) types.DevicePortConfigList {
 				break
 			}
 			log.Infof("compressDPCL: Ignoring - i = %d, dpc: %+v", i, dpc)
+			recordDPCHistory(ctx, dpc)
 		}
 	}
 
@@ -197,8 +200,62 @@ func compressDPCL(ctx *DeviceNetworkContext) types.DevicePortConfigList {
 	}
 }
 
+// IngestDPCHistory restores ctx.DevicePortConfigHistory from whatever was
+// last persisted, so a reboot doesn't lose the "worked until X" record
+// diagnostics relies on.
+func IngestDPCHistory(ctx *DeviceNetworkContext) {
+	log := ctx.Log
+	item, err := ctx.PubDevicePortConfigHistory.Get("global")
+	if err != nil {
+		log.Infof("IngestDPCHistory: no persisted history yet")
+		return
+	}
+	history := item.(types.DevicePortConfigHistory)
+	ctx.DevicePortConfigHistory = &history
+	log.Infof("IngestDPCHistory: restored %d entries", len(history.Entries))
+}
+
+// recordDPCHistory appends a summary of dpc, which compressDPCL is about
+// to drop from the candidate list, to ctx.DevicePortConfigHistory and
+// publishes the (bounded) result, so diagnostics can still see how long
+// a since-replaced config worked for.
+func recordDPCHistory(ctx *DeviceNetworkContext, dpc types.DevicePortConfig) {
+	if ctx.DevicePortConfigHistory == nil || ctx.PubDevicePortConfigHistory == nil {
+		return
+	}
+	ports := make([]string, 0, len(dpc.Ports))
+	for _, p := range dpc.Ports {
+		ports = append(ports, p.IfName)
+	}
+	entry := types.DPCHistoryEntry{
+		Key:           dpc.Key,
+		TimePriority:  dpc.TimePriority,
+		Ports:         ports,
+		WasWorking:    dpc.WasDPCWorking(),
+		LastSucceeded: dpc.LastSucceeded,
+		LastFailed:    dpc.LastFailed,
+		LastError:     dpc.LastError,
+		ReplacedAt:    time.Now(),
+	}
+	entries := append([]types.DPCHistoryEntry{entry}, ctx.DevicePortConfigHistory.Entries...)
+	if len(entries) > types.MaxDPCHistoryEntries {
+		entries = entries[:types.MaxDPCHistoryEntries]
+	}
+	ctx.DevicePortConfigHistory.Entries = entries
+	ctx.PubDevicePortConfigHistory.Publish(ctx.DevicePortConfigHistory.PubKey(),
+		*ctx.DevicePortConfigHistory)
+}
+
 var nilUUID = uuid.UUID{} // Really a const
 
+// VerifyDeviceNetworkStatusFunc is the function VerifyPending calls to test
+// outbound connectivity for a candidate DevicePortConfig. It defaults to
+// VerifyDeviceNetworkStatus, which makes a real HTTPS call to the
+// controller, but tests can override it with a scripted fake so the DPC
+// priority/retry/rollback state machine below can be driven through link
+// flaps and DHCP failures without real network hardware; see dnc_test.go.
+var VerifyDeviceNetworkStatusFunc = VerifyDeviceNetworkStatus
+
 func VerifyPending(ctx *DeviceNetworkContext, pending *DPCPending,
 	aa *types.AssignableAdapters, timeout uint32) types.PendDPCStatus {
 
@@ -261,7 +318,7 @@ func VerifyPending(ctx *DeviceNetworkContext, pending *DPCPending,
 	// Hard-coded at 1 for now; at least one interface needs to work
 	const successCount uint = 1
 	ctx.Iteration++
-	rtf, intfStatusMap, err := VerifyDeviceNetworkStatus(log, pending.PendDNS,
+	rtf, intfStatusMap, err := VerifyDeviceNetworkStatusFunc(log, pending.PendDNS,
 		successCount, ctx.Iteration, timeout)
 	// Use TestResults to update the DevicePortConfigList and DeviceNetworkStatus
 	// Note that the TestResults will at least have an updated timestamp
@@ -567,7 +624,6 @@ func HandleDPCModify(ctxArg interface{}, key string, configArg interface{}) {
 	log.Infof("HandleDPCModify done for %s\n", key)
 }
 
-//
 func HandleDPCDelete(ctxArg interface{}, key string, configArg interface{}) {
 
 	ctx := ctxArg.(*DeviceNetworkContext)
@@ -721,7 +777,8 @@ func lookupPortConfig(ctx *DeviceNetworkContext,
 }
 
 // doUpdatePortConfigListAndPublish
-//		Returns if the current config has actually changed.
+//
+//	Returns if the current config has actually changed.
 func (ctx *DeviceNetworkContext) doUpdatePortConfigListAndPublish(
 	portConfig *types.DevicePortConfig, delete bool) bool {
 	// Look up based on timestamp, then content
@@ -889,6 +946,7 @@ func removePortConfig(ctx *DeviceNetworkContext, portConfig types.DevicePortConf
 }
 
 // DoDNSUpdate
+//
 //	Update the device network status and publish it.
 func DoDNSUpdate(ctx *DeviceNetworkContext) {
 	log := ctx.Log
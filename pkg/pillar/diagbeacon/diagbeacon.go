@@ -0,0 +1,78 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package diagbeacon implements a last-resort diagnostics beacon: when a
+// device has not been able to reach its controller for a configurable
+// period, zedagent periodically emits a minimal status datagram, HMAC-signed
+// when a secret is configured, to a configurable rendezvous endpoint so fleet
+// operators can at least see that the device is alive and why it thinks
+// it's failing. It is deliberately independent of the controller
+// connection it is reporting the loss of: a single UDP datagram, not an
+// HTTPS POST that might itself be blocked by whatever is blocking the
+// controller. Whether the device has local link is not checked before
+// sending.
+package diagbeacon
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+)
+
+const sendTimeout = 5 * time.Second
+
+// Beacon is the payload sent to the configured rendezvous endpoint.
+type Beacon struct {
+	DeviceUUID       string    `json:"deviceUUID"`
+	Timestamp        time.Time `json:"timestamp"`
+	UnreachableSince time.Time `json:"unreachableSince"`
+	LastError        string    `json:"lastError"`
+}
+
+// Send signs beacon with secret (if non-empty) and sends it as a single
+// JSON UDP datagram to endpoint, a "host:port" address. An empty endpoint
+// is treated as "beacon disabled" and returns nil without doing anything.
+func Send(log *base.LogObject, endpoint string, secret string, beacon Beacon) error {
+	if endpoint == "" {
+		return nil
+	}
+	body, err := json.Marshal(beacon)
+	if err != nil {
+		return fmt.Errorf("diagbeacon.Send: failed to marshal beacon: %s", err)
+	}
+	datagram := struct {
+		Beacon    json.RawMessage `json:"beacon"`
+		Signature string          `json:"signature,omitempty"`
+	}{Beacon: body}
+	if secret != "" {
+		datagram.Signature = sign(body, secret)
+	}
+	packet, err := json.Marshal(datagram)
+	if err != nil {
+		return fmt.Errorf("diagbeacon.Send: failed to marshal datagram: %s", err)
+	}
+
+	conn, err := net.DialTimeout("udp", endpoint, sendTimeout)
+	if err != nil {
+		return fmt.Errorf("diagbeacon.Send: failed to reach %s: %s", endpoint, err)
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(sendTimeout))
+	if _, err := conn.Write(packet); err != nil {
+		return fmt.Errorf("diagbeacon.Send: failed to send to %s: %s", endpoint, err)
+	}
+	log.Noticef("diagbeacon.Send: sent beacon to %s", endpoint)
+	return nil
+}
+
+func sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
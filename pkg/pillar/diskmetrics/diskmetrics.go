@@ -8,18 +8,20 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/lf-edge/eve/pkg/pillar/base"
 )
 
 // Matches the json output of qemu-img info
 type ImgInfo struct {
-	VirtualSize uint64 `json:"virtual-size"`
-	Filename    string `json:"filename"`
-	ClusterSize uint64 `json:"cluster-size"`
-	Format      string `json:"format"`
-	ActualSize  uint64 `json:"actual-size"`
-	DirtyFlag   bool   `json:"dirty-flag"`
+	VirtualSize     uint64 `json:"virtual-size"`
+	Filename        string `json:"filename"`
+	ClusterSize     uint64 `json:"cluster-size"`
+	Format          string `json:"format"`
+	ActualSize      uint64 `json:"actual-size"`
+	DirtyFlag       bool   `json:"dirty-flag"`
+	BackingFilename string `json:"backing-filename"`
 }
 
 func GetImgInfo(log *base.LogObject, diskfile string) (*ImgInfo, error) {
@@ -50,6 +52,44 @@ func GetDiskVirtualSize(log *base.LogObject, diskfile string) (uint64, error) {
 	return imgInfo.VirtualSize, nil
 }
 
+// CloneImage makes an independent, full copy of a disk image using
+// qemu-img convert, preserving the source image's format. It is used to
+// duplicate an app instance's volumes locally without re-downloading the
+// source image.
+func CloneImage(log *base.LogObject, src, dst string) error {
+	imgInfo, err := GetImgInfo(log, src)
+	if err != nil {
+		return err
+	}
+	output, err := base.Exec(log, "/usr/bin/qemu-img", "convert", "-O", imgInfo.Format,
+		src, dst).CombinedOutput()
+	if err != nil {
+		errStr := fmt.Sprintf("qemu-img convert failed: %s, %s\n",
+			err, output)
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// CheckImg runs "qemu-img check" against diskfile to look for on-disk
+// corruption (the qcow2 case of the checks described for volume
+// self-heal). checked reports whether the format supports checking at
+// all (e.g. raw images do not); corrupted is only meaningful when checked
+// is true.
+func CheckImg(log *base.LogObject, diskfile string) (checked bool, corrupted bool, err error) {
+	if _, err := os.Stat(diskfile); err != nil {
+		return false, false, err
+	}
+	output, cmdErr := base.Exec(log, "/usr/bin/qemu-img", "check", "-U", diskfile).CombinedOutput()
+	if cmdErr == nil {
+		return true, false, nil
+	}
+	if strings.Contains(string(output), "does not support checks") {
+		return false, false, nil
+	}
+	return true, true, fmt.Errorf("qemu-img check failed: %s, %s", cmdErr, output)
+}
+
 func ResizeImg(log *base.LogObject, diskfile string, newsize uint64) error {
 
 	if _, err := os.Stat(diskfile); err != nil {
@@ -64,3 +104,110 @@ func ResizeImg(log *base.LogObject, diskfile string, newsize uint64) error {
 	}
 	return nil
 }
+
+// MaxBackingChainDepth caps how many qcow2 images deep a backing chain
+// built by CreateOverlay (e.g. through successive clones) is allowed to
+// grow before FlattenChain should be applied. Every extra link costs a
+// lookup on every read and makes removing any one ancestor fatal to all
+// its descendants, so chains are kept shallow rather than left to grow
+// unbounded.
+const MaxBackingChainDepth = 4
+
+// CreateOverlay creates a new qcow2 file at dst backed by the existing
+// image at backing (of format backingFormat), so dst starts out reading
+// identically to backing without copying any of its data - only writes to
+// dst consume new space. It is the thin-clone building block
+// BackingChainLength, CommitOverlay and FlattenChain manage.
+func CreateOverlay(log *base.LogObject, backing, backingFormat, dst string) error {
+	output, err := base.Exec(log, "/usr/bin/qemu-img", "create", "-f", "qcow2",
+		"-F", backingFormat, "-b", backing, dst).CombinedOutput()
+	if err != nil {
+		errStr := fmt.Sprintf("qemu-img create (overlay) failed: %s, %s\n",
+			err, output)
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// BackingChainLength returns how many images deep diskfile's backing chain
+// is, counting diskfile itself: 1 if it has no backing file, 2 if it has
+// one backing file which itself has none, and so on.
+func BackingChainLength(log *base.LogObject, diskfile string) (int, error) {
+	const maxSaneDepth = 64
+	depth := 1
+	current := diskfile
+	for {
+		info, err := GetImgInfo(log, current)
+		if err != nil {
+			return 0, err
+		}
+		if info.BackingFilename == "" {
+			return depth, nil
+		}
+		depth++
+		if depth > maxSaneDepth {
+			return depth, fmt.Errorf("backing chain of %s is more than %d images deep, probably a loop",
+				diskfile, maxSaneDepth)
+		}
+		current = info.BackingFilename
+	}
+}
+
+// CommitOverlay merges overlay's own writes down into its immediate
+// backing file via qemu-img commit. It shortens overlay's chain by one
+// link; run it repeatedly (or use FlattenChain) to collapse a chain all
+// the way down.
+func CommitOverlay(log *base.LogObject, overlay string) error {
+	output, err := base.Exec(log, "/usr/bin/qemu-img", "commit", overlay).CombinedOutput()
+	if err != nil {
+		errStr := fmt.Sprintf("qemu-img commit failed: %s, %s\n",
+			err, output)
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// FlattenChain rewrites diskfile in place as a standalone qcow2 image with
+// no backing file, pulling in every link of its current backing chain. Use
+// it when BackingChainLength exceeds MaxBackingChainDepth, or before
+// removing a backing image out from under its descendants.
+func FlattenChain(log *base.LogObject, diskfile string) error {
+	flattened := diskfile + ".flatten-tmp"
+	output, err := base.Exec(log, "/usr/bin/qemu-img", "convert", "-O", "qcow2",
+		diskfile, flattened).CombinedOutput()
+	if err != nil {
+		os.Remove(flattened)
+		errStr := fmt.Sprintf("qemu-img convert (flatten) failed: %s, %s\n",
+			err, output)
+		return errors.New(errStr)
+	}
+	if err := os.Rename(flattened, diskfile); err != nil {
+		os.Remove(flattened)
+		return fmt.Errorf("FlattenChain: could not replace %s with flattened image: %v", diskfile, err)
+	}
+	return nil
+}
+
+// ChainUsage reports how many bytes of diskfile's on-disk footprint are
+// unique to it (its own allocated clusters) versus shared with whatever
+// else reads through the same backing chain (the allocated clusters of
+// every ancestor beneath it). It is meant for answering "how much would
+// deleting just this volume actually reclaim", which the virtual size
+// alone cannot tell you once volumes share a backing chain.
+func ChainUsage(log *base.LogObject, diskfile string) (unique uint64, shared uint64, err error) {
+	info, err := GetImgInfo(log, diskfile)
+	if err != nil {
+		return 0, 0, err
+	}
+	unique = info.ActualSize
+	current := info.BackingFilename
+	for current != "" {
+		ancestor, err := GetImgInfo(log, current)
+		if err != nil {
+			return unique, shared, err
+		}
+		shared += ancestor.ActualSize
+		current = ancestor.BackingFilename
+	}
+	return unique, shared, nil
+}
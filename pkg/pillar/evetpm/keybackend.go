@@ -0,0 +1,88 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package evetpm
+
+import (
+	"crypto"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// KeyBackendType identifies which device identity key backend is in
+// effect on this device, so callers like zedcloud don't have to
+// special-case TPM vs non-TPM devices themselves.
+type KeyBackendType uint8
+
+const (
+	// KeyBackendSoftkey is a plain file-based ECDSA key under
+	// types.DeviceKeyName; dev-only, since the key is unprotected.
+	KeyBackendSoftkey KeyBackendType = iota
+	// KeyBackendTPM is a TPM 2.0 resident key; see TpmPrivateKey.
+	KeyBackendTPM
+	// KeyBackendPkcs11 is a key held by a PKCS#11 token (a discrete HSM,
+	// smartcard, or similar), identified by Pkcs11ModuleFile.
+	KeyBackendPkcs11
+)
+
+// Pkcs11ModuleFile, if present, names the PKCS#11 middleware shared
+// library (e.g. a vendor-supplied .so) to use for device identity key
+// operations, selecting KeyBackendPkcs11 in place of the TPM/softkey
+// backends. Expected to be dropped in place per hardware model, the same
+// way hardware.GetHardwareModelOverride's file is.
+var Pkcs11ModuleFile = types.IdentityDirname + "/pkcs11module"
+
+// SelectKeyBackend returns which device identity key backend this device
+// is configured to use. Order of precedence: an explicit PKCS#11 module
+// override, then a TPM if one has been provisioned, else the plain
+// file-based softkey.
+func SelectKeyBackend() KeyBackendType {
+	if FileExists(Pkcs11ModuleFile) {
+		return KeyBackendPkcs11
+	}
+	if IsTpmEnabled() {
+		return KeyBackendTPM
+	}
+	return KeyBackendSoftkey
+}
+
+// GetDeviceSigner returns a crypto.Signer backed by whichever key backend
+// SelectKeyBackend picks for this device, so callers like
+// zedcloud.GetClientCert don't need their own TPM/softkey/PKCS#11
+// branching.
+//
+// This build has no PKCS#11 support (see the KeyBackendPkcs11 case
+// below), so KeyBackendPkcs11 falls back to TPM/softkey rather than
+// failing: dropping Pkcs11ModuleFile in place should never by itself
+// make the device unable to build a TLS client cert. log is used to warn
+// loudly when that fallback happens, since an operator who provisioned
+// Pkcs11ModuleFile specifically to require a hardware-backed key gets no
+// other indication the device silently downgraded to it.
+func GetDeviceSigner(log *base.LogObject) (crypto.Signer, error) {
+	switch SelectKeyBackend() {
+	case KeyBackendTPM:
+		tpmPrivKey := TpmPrivateKey{}
+		tpmPrivKey.PublicKey = tpmPrivKey.Public()
+		return tpmPrivKey, nil
+	case KeyBackendPkcs11:
+		// Talking to a PKCS#11 token requires linking a PKCS#11
+		// middleware library via cgo (e.g. github.com/miekg/pkcs11),
+		// which isn't part of this build's vendored dependencies.
+		// Fail open to whichever backend SelectKeyBackend would have
+		// picked without Pkcs11ModuleFile, instead of refusing to
+		// produce a signer at all.
+		if IsTpmEnabled() {
+			log.Warnf("GetDeviceSigner: Pkcs11ModuleFile %s is present but this build has no PKCS#11 support; falling back to the TPM-resident key instead of the requested hardware-backed one",
+				Pkcs11ModuleFile)
+			tpmPrivKey := TpmPrivateKey{}
+			tpmPrivKey.PublicKey = tpmPrivKey.Public()
+			return tpmPrivKey, nil
+		}
+		log.Warnf("GetDeviceSigner: Pkcs11ModuleFile %s is present but this build has no PKCS#11 support; falling back to the plain softkey instead of the requested hardware-backed one",
+			Pkcs11ModuleFile)
+		return GetDevicePrivateKey()
+	default:
+		return GetDevicePrivateKey()
+	}
+}
@@ -67,6 +67,20 @@ const (
 	//TpmSealedDiskPubHdl is the handle for constructing disk encryption key
 	TpmSealedDiskPubHdl tpmutil.Handle = 0x1900000
 
+	//MeasurementPCRHdl is PCR 14, one of the PCRs the TCG PC Client PCR
+	//layout sets aside for OS/application use that can only be cleared by
+	//a full platform reset (TPM2_Startup(CLEAR)). That rules out PCR 16
+	//("Debug") and PCR 23 ("Application Support"), which the same spec
+	//makes resettable by TPM2_PCR_Reset from locality 0 at any time --
+	//meaning any process able to talk to the TPM could erase a
+	//measurement extended into either of them before it is ever quoted,
+	//defeating the point of measuring it in the first place. We extend
+	//PCR 14 with the digest of every app image actually launched, so a
+	//quote of it lets the controller attest the running workload set,
+	//not just EVE itself, for as long as the device has been up since
+	//its last reset.
+	MeasurementPCRHdl tpmutil.Handle = 14
+
 	//EmptyPassword is an empty string
 	EmptyPassword  = ""
 	vaultKeyLength = 32 //Bytes
@@ -200,6 +214,21 @@ func TpmSign(digest []byte) (*big.Int, *big.Int, error) {
 	return sig.ECC.R, sig.ECC.S, nil
 }
 
+//ExtendMeasurementPCR extends MeasurementPCRHdl with digest, recording
+//that something - typically an app image, identified by the content
+//digest of its OCI config - was launched on this device. A later quote
+//of MeasurementPCRHdl then reflects every workload measured in since
+//boot, in addition to the boot software measured into the earlier PCRs.
+func ExtendMeasurementPCR(digest []byte) error {
+	rw, err := tpm2.OpenTPM(TpmDevicePath)
+	if err != nil {
+		return err
+	}
+	defer rw.Close()
+
+	return tpm2.PCRExtend(rw, MeasurementPCRHdl, tpm2.AlgSHA256, digest, EmptyPassword)
+}
+
 //FileExists returns true if a file with name filename is found
 func FileExists(filename string) bool {
 	_, err := os.Stat(filename)
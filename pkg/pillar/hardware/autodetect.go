@@ -0,0 +1,119 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Auto-detection of a device's model description (network adapters,
+// watchdog, TPM presence) from DMI and PCI probing, used to
+// bootstrap a PhysicalIOAdapterList on first boot instead of requiring a
+// static per-model JSON file to be bundled into the image.
+
+package hardware
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+)
+
+const (
+	pciDevicesDir = "/sys/bus/pci/devices"
+	usbDevicesDir = "/sys/bus/usb/devices"
+	watchdogDir   = "/dev"
+	tpmDevice     = "/dev/tpm0"
+)
+
+// DetectedAdapter describes a single network or PCI adapter found during
+// probing, in a form which can be turned into a types.PhysicalIOAdapter.
+type DetectedAdapter struct {
+	Pcilong string
+	Ifname  string
+	Class   string
+}
+
+// DetectedModel is the result of probing the running hardware. It is
+// deliberately a subset of what a hand-authored model JSON file
+// contains; GenerateModelDescription fills in what can be determined
+// locally and leaves the rest for controller confirmation.
+type DetectedModel struct {
+	Manufacturer string
+	ProductName  string
+	Compatible   string
+	Adapters     []DetectedAdapter
+	HasWatchdog  bool
+	HasTPM       bool
+}
+
+// GenerateModelDescription probes DMI and PCI on the running device, plus
+// /dev for a watchdog and the TPM device node, and returns a best-effort
+// DetectedModel. Only network-class PCI devices are reported as adapters;
+// USB devices are not probed. It never fails; individual
+// probes which are unavailable (e.g. no dmidecode on ARM) simply leave
+// the corresponding fields empty.
+func GenerateModelDescription(log *base.LogObject) DetectedModel {
+	manufacturer, product, _, _, _ := GetDeviceManufacturerInfo(log)
+	dm := DetectedModel{
+		Manufacturer: strings.TrimSpace(manufacturer),
+		ProductName:  strings.TrimSpace(product),
+		Compatible:   GetCompatible(log),
+		Adapters:     probePCIAdapters(log),
+		HasWatchdog:  probeWatchdog(log),
+		HasTPM:       probeTPM(log),
+	}
+	return dm
+}
+
+// probePCIAdapters walks /sys/bus/pci/devices looking for network class
+// devices (class 0x02xxxx) and returns their PCI address and, when
+// present, the bound netdev interface name.
+func probePCIAdapters(log *base.LogObject) []DetectedAdapter {
+	var adapters []DetectedAdapter
+	entries, err := ioutil.ReadDir(pciDevicesDir)
+	if err != nil {
+		log.Tracef("probePCIAdapters: %s", err)
+		return adapters
+	}
+	for _, entry := range entries {
+		pcilong := entry.Name()
+		classFile := filepath.Join(pciDevicesDir, pcilong, "class")
+		class, err := ioutil.ReadFile(classFile)
+		if err != nil {
+			continue
+		}
+		classStr := strings.TrimSpace(string(class))
+		if !strings.HasPrefix(classStr, "0x02") {
+			continue
+		}
+		ifname := ""
+		netDir := filepath.Join(pciDevicesDir, pcilong, "net")
+		if ifaces, err := ioutil.ReadDir(netDir); err == nil && len(ifaces) > 0 {
+			ifname = ifaces[0].Name()
+		}
+		adapters = append(adapters, DetectedAdapter{
+			Pcilong: pcilong,
+			Ifname:  ifname,
+			Class:   classStr,
+		})
+	}
+	return adapters
+}
+
+func probeWatchdog(log *base.LogObject) bool {
+	entries, err := ioutil.ReadDir(watchdogDir)
+	if err != nil {
+		log.Tracef("probeWatchdog: %s", err)
+		return false
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "watchdog") {
+			return true
+		}
+	}
+	return false
+}
+
+func probeTPM(log *base.LogObject) bool {
+	_, err := os.Stat(tpmDevice)
+	return err == nil
+}
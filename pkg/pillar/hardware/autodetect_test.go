@@ -0,0 +1,22 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package hardware
+
+import (
+	"testing"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+	"github.com/sirupsen/logrus"
+)
+
+func TestGenerateModelDescription(t *testing.T) {
+	log := base.NewSourceLogObject(logrus.StandardLogger(), "test", 1234)
+
+	// GenerateModelDescription must never fail even when none of the
+	// probed paths exist on the machine running the test.
+	dm := GenerateModelDescription(log)
+	if dm.Adapters == nil && len(dm.Adapters) != 0 {
+		t.Errorf("unexpected adapters: %v", dm.Adapters)
+	}
+}
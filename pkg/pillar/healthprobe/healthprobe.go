@@ -0,0 +1,136 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package healthprobe implements health checks for running containers.
+// TCPProbe is a minimal one-shot reachability check used to gate
+// blue/green app instance upgrades. Type/Config/State implement the
+// scheduled exec/TCP/HTTP probe framework used by domainmgr to publish a
+// container's ongoing health and, optionally, trigger a restart - the
+// actual probe I/O (exec'ing into the container, dialing its namespace)
+// lives in the containerd package, which owns the task; this package only
+// holds the policy of what counts as healthy, so it can be tested without
+// a live container.
+package healthprobe
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+)
+
+const probeTimeout = 3 * time.Second
+
+// TCPProbe reports whether a TCP connection to ip:port can be
+// established within probeTimeout. It is used as the default health
+// check for a newly activated app instance during a blue/green upgrade.
+func TCPProbe(log *base.LogObject, ip net.IP, port uint16) bool {
+	addr := fmt.Sprintf("%s:%d", ip.String(), port)
+	conn, err := net.DialTimeout("tcp", addr, probeTimeout)
+	if err != nil {
+		log.Warnf("TCPProbe(%s) failed: %s", addr, err)
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Type identifies how a container health probe determines success,
+// mirroring the exec/tcpSocket/httpGet probe types common to container
+// orchestrators.
+type Type uint8
+
+const (
+	// TypeExec runs a command inside the container; a zero exit code is
+	// a success.
+	TypeExec Type = iota
+	// TypeTCP succeeds if a TCP connection to Port, dialed from inside
+	// the container's own network namespace, can be established.
+	TypeTCP
+	// TypeHTTP succeeds if an HTTP GET of HTTPPath on Port, from inside
+	// the container's own network namespace, returns a 2xx status.
+	TypeHTTP
+)
+
+// Status is the current health of a probed container.
+type Status uint8
+
+const (
+	// StatusUnknown applies before SuccessThreshold/FailureThreshold
+	// consecutive results have been observed.
+	StatusUnknown Status = iota
+	// StatusHealthy applies once SuccessThreshold consecutive probes
+	// have succeeded.
+	StatusHealthy
+	// StatusUnhealthy applies once FailureThreshold consecutive probes
+	// have failed.
+	StatusUnhealthy
+)
+
+// Config describes one container's scheduled health probe.
+type Config struct {
+	Type Type
+	// Exec is the command run inside the container for TypeExec.
+	Exec []string
+	// Port is the TCP port probed for TypeTCP and TypeHTTP.
+	Port int
+	// HTTPPath is the path requested for TypeHTTP, e.g. "/healthz".
+	HTTPPath string
+	// Period is the time between probes.
+	Period time.Duration
+	// Timeout bounds a single probe attempt.
+	Timeout time.Duration
+	// SuccessThreshold is the number of consecutive successes needed to
+	// (re)enter StatusHealthy.
+	SuccessThreshold int
+	// FailureThreshold is the number of consecutive failures needed to
+	// enter StatusUnhealthy.
+	FailureThreshold int
+	// RestartOnFailure asks the watcher to restart the container's task
+	// once it becomes StatusUnhealthy.
+	RestartOnFailure bool
+}
+
+// State tracks the consecutive-result counters for one container's probe.
+type State struct {
+	Status              Status
+	ConsecutiveFailures int
+	LastCheckAt         time.Time
+	LastError           string
+
+	consecutiveSuccesses int
+}
+
+// RecordResult folds in the outcome of a probe run at now, updating
+// Status per cfg's thresholds, and reports whether Status changed.
+func (s *State) RecordResult(cfg Config, ok bool, probeErr error, now time.Time) bool {
+	s.LastCheckAt = now
+	before := s.Status
+	successThreshold := cfg.SuccessThreshold
+	if successThreshold < 1 {
+		successThreshold = 1
+	}
+	failureThreshold := cfg.FailureThreshold
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	if ok {
+		s.LastError = ""
+		s.ConsecutiveFailures = 0
+		s.consecutiveSuccesses++
+		if s.consecutiveSuccesses >= successThreshold {
+			s.Status = StatusHealthy
+		}
+	} else {
+		if probeErr != nil {
+			s.LastError = probeErr.Error()
+		}
+		s.consecutiveSuccesses = 0
+		s.ConsecutiveFailures++
+		if s.ConsecutiveFailures >= failureThreshold {
+			s.Status = StatusUnhealthy
+		}
+	}
+	return s.Status != before
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package healthprobe
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRecordResultThresholds(t *testing.T) {
+	cfg := Config{SuccessThreshold: 2, FailureThreshold: 3}
+	var s State
+	now := time.Now()
+
+	// Below threshold, status stays Unknown.
+	if transitioned := s.RecordResult(cfg, true, nil, now); transitioned {
+		t.Errorf("RecordResult() transitioned on first success, want no transition")
+	}
+	if s.Status != StatusUnknown {
+		t.Errorf("Status = %v after 1 success, want StatusUnknown", s.Status)
+	}
+
+	// Second consecutive success reaches SuccessThreshold.
+	if transitioned := s.RecordResult(cfg, true, nil, now); !transitioned {
+		t.Errorf("RecordResult() didn't transition on reaching SuccessThreshold")
+	}
+	if s.Status != StatusHealthy {
+		t.Errorf("Status = %v after 2 successes, want StatusHealthy", s.Status)
+	}
+
+	// A single failure doesn't yet flip to Unhealthy, but resets the streak.
+	failErr := errors.New("connection refused")
+	if transitioned := s.RecordResult(cfg, false, failErr, now); transitioned {
+		t.Errorf("RecordResult() transitioned on first failure, want no transition")
+	}
+	if s.Status != StatusHealthy {
+		t.Errorf("Status = %v after 1 failure, want still StatusHealthy", s.Status)
+	}
+	if s.LastError != failErr.Error() {
+		t.Errorf("LastError = %q, want %q", s.LastError, failErr.Error())
+	}
+
+	s.RecordResult(cfg, false, failErr, now)
+	if transitioned := s.RecordResult(cfg, false, failErr, now); !transitioned {
+		t.Errorf("RecordResult() didn't transition on reaching FailureThreshold")
+	}
+	if s.Status != StatusUnhealthy {
+		t.Errorf("Status = %v after 3 failures, want StatusUnhealthy", s.Status)
+	}
+	if s.ConsecutiveFailures != 3 {
+		t.Errorf("ConsecutiveFailures = %d, want 3", s.ConsecutiveFailures)
+	}
+
+	// Recovering clears LastError and ConsecutiveFailures.
+	s.RecordResult(cfg, true, nil, now)
+	if s.ConsecutiveFailures != 0 || s.LastError != "" {
+		t.Errorf("success didn't clear failure state: ConsecutiveFailures=%d LastError=%q",
+			s.ConsecutiveFailures, s.LastError)
+	}
+}
+
+func TestRecordResultZeroThresholdsDefaultToOne(t *testing.T) {
+	var cfg Config // SuccessThreshold/FailureThreshold both zero
+	var s State
+	now := time.Now()
+
+	if transitioned := s.RecordResult(cfg, false, nil, now); !transitioned {
+		t.Errorf("RecordResult() didn't transition to Unhealthy on first failure with zero threshold")
+	}
+	if s.Status != StatusUnhealthy {
+		t.Errorf("Status = %v, want StatusUnhealthy", s.Status)
+	}
+
+	if transitioned := s.RecordResult(cfg, true, nil, now); !transitioned {
+		t.Errorf("RecordResult() didn't transition to Healthy on first success with zero threshold")
+	}
+	if s.Status != StatusHealthy {
+		t.Errorf("Status = %v, want StatusHealthy", s.Status)
+	}
+}
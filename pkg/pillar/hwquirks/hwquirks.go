@@ -0,0 +1,107 @@
+// Copyright (c) 2026 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package hwquirks maintains known workarounds for problematic NICs and
+// GPUs as structured data, keyed by PCI (and, in principle, USB) vendor
+// and device IDs, so per-vendor hacks don't need to be scattered across
+// hypervisor and networking code. Each quirk is reported through the
+// caller's log when applied, so a support session can see which
+// workaround kicked in and why instead of having to read source.
+package hwquirks
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Quirk describes a known workaround for a specific vendor/device.
+type Quirk struct {
+	// VendorID and DeviceID are lowercase 4-hex-digit IDs, e.g. "8086"
+	// and "10d3", as reported by sysfs's vendor/device files. An
+	// empty DeviceID matches any device from VendorID.
+	VendorID string
+	DeviceID string
+	// Description is logged whenever this quirk is applied.
+	Description string
+	// DisableOffloads lists ethtool offload features (e.g. "tso",
+	// "gro") that should be turned off for this device.
+	DisableOffloads []string
+	// DriverOptions are extra module parameters this device's driver
+	// should be (re)loaded with, e.g. {"msi": "0"}.
+	DriverOptions map[string]string
+	// ResetBeforeAssign triggers a sysfs function-level reset
+	// (<device>/reset) before the device is handed off for VFIO
+	// passthrough, working around firmware that otherwise leaves the
+	// device in a state the guest's driver can't initialize from.
+	ResetBeforeAssign bool
+}
+
+// knownQuirks is the structured-data table this framework exists to
+// replace ad hoc vendor checks with. Add entries here, not special
+// cases in hypervisor/networking code.
+var knownQuirks = []Quirk{
+	{
+		// Some firmware revisions of the 82574L corrupt passthrough
+		// traffic under TSO/GSO.
+		VendorID:        "8086",
+		DeviceID:        "10d3",
+		Description:     "Intel 82574L: disabling TSO/GSO, known to corrupt packets under passthrough",
+		DisableOffloads: []string{"tso", "gso"},
+	},
+	{
+		// NVIDIA GPUs commonly need a function-level reset before
+		// VFIO passthrough since many boards don't leave a clean
+		// state behind when dom0's driver detaches.
+		VendorID:          "10de",
+		Description:       "NVIDIA GPU: resetting before VFIO passthrough to clear dom0 driver state",
+		ResetBeforeAssign: true,
+	},
+}
+
+// Lookup returns the quirk, if any, registered for vendorID/deviceID.
+func Lookup(vendorID, deviceID string) (Quirk, bool) {
+	for _, q := range knownQuirks {
+		if q.VendorID == vendorID && (q.DeviceID == "" || q.DeviceID == deviceID) {
+			return q, true
+		}
+	}
+	return Quirk{}, false
+}
+
+func readHexID(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(b)), "0x"), nil
+}
+
+// ReadPCIIDs reads the vendor and device IDs for the PCI device at
+// pciLong (its Domain:Bus:Device.Function address) out of the sysfs
+// tree rooted at sysfsPciDevices.
+func ReadPCIIDs(sysfsPciDevices, pciLong string) (vendorID, deviceID string, err error) {
+	vendorID, err = readHexID(filepath.Join(sysfsPciDevices, pciLong, "vendor"))
+	if err != nil {
+		return "", "", err
+	}
+	deviceID, err = readHexID(filepath.Join(sysfsPciDevices, pciLong, "device"))
+	if err != nil {
+		return "", "", err
+	}
+	return vendorID, deviceID, nil
+}
+
+// LookupForPCI reads pciLong's vendor/device ID out of sysfs and looks
+// up any quirk registered for it. Callers are expected to log
+// quirk.Description through their own logger when found is true, so
+// the workaround shows up in whatever log stream that caller already
+// reports its actions to.
+func LookupForPCI(sysfsPciDevices, pciLong string) (quirk Quirk, found bool, err error) {
+	vendorID, deviceID, err := ReadPCIIDs(sysfsPciDevices, pciLong)
+	if err != nil {
+		return Quirk{}, false, err
+	}
+	quirk, found = Lookup(vendorID, deviceID)
+	return quirk, found, nil
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package hypervisor
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/shirou/gopsutil/cpu"
+)
+
+// Capabilities describes the host virtualization features available,
+// so the controller can validate app placement constraints (e.g. a SEV
+// app cannot be placed on a device without AMD-V) before assignment.
+type Capabilities struct {
+	VMX      bool // Intel VT-x
+	SVM      bool // AMD-V
+	EPT      bool // Intel extended page tables
+	NPT      bool // AMD nested page tables (a.k.a. RVI)
+	SEV      bool // AMD SEV
+	TDX      bool // Intel TDX
+	IOMMU    bool
+	SRIOV    bool
+	MaxVCPUs uint32
+}
+
+const (
+	cpuinfoFile = "/proc/cpuinfo"
+	iommuDir    = "/sys/class/iommu"
+	sriovGlob   = "/sys/bus/pci/devices/*/sriov_totalvfs"
+)
+
+// GetCapabilities probes /proc/cpuinfo and sysfs for the host's
+// virtualization-related features. It never fails; unavailable probes
+// simply report as false/zero.
+func GetCapabilities() (Capabilities, error) {
+	var caps Capabilities
+
+	contents, err := ioutil.ReadFile(cpuinfoFile)
+	if err == nil {
+		flags := string(contents)
+		caps.VMX = strings.Contains(flags, " vmx ") || strings.Contains(flags, "\tvmx ")
+		caps.SVM = strings.Contains(flags, " svm ") || strings.Contains(flags, "\tsvm ")
+		caps.EPT = strings.Contains(flags, " ept ")
+		caps.NPT = strings.Contains(flags, " npt ")
+		caps.SEV = strings.Contains(flags, " sev ")
+		caps.TDX = strings.Contains(flags, " tdx ")
+	}
+
+	if entries, err := ioutil.ReadDir(iommuDir); err == nil && len(entries) > 0 {
+		caps.IOMMU = true
+	}
+
+	if matches, err := filepath.Glob(sriovGlob); err == nil && len(matches) > 0 {
+		caps.SRIOV = true
+	}
+
+	counts, err := cpu.Counts(true)
+	if err == nil {
+		caps.MaxVCPUs = uint32(counts)
+	}
+	return caps, nil
+}
@@ -0,0 +1,16 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package hypervisor
+
+import "testing"
+
+func TestGetCapabilities(t *testing.T) {
+	caps, err := GetCapabilities()
+	if err != nil {
+		t.Errorf("GetCapabilities failed: %s", err)
+	}
+	if caps.MaxVCPUs == 0 {
+		t.Errorf("expected at least 1 vCPU to be detected")
+	}
+}
@@ -6,7 +6,10 @@ package hypervisor
 import (
 	"fmt"
 	zconfig "github.com/lf-edge/eve/api/go/config"
+	"github.com/lf-edge/eve/pkg/pillar/base"
 	"github.com/lf-edge/eve/pkg/pillar/containerd"
+	"github.com/lf-edge/eve/pkg/pillar/healthprobe"
+	"github.com/lf-edge/eve/pkg/pillar/taskmonitor"
 	"github.com/lf-edge/eve/pkg/pillar/types"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"os"
@@ -15,15 +18,26 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// stopGracePeriod is how long Stop waits for a task to exit on its own,
+// after sending its stop signal (the image's StopSignal if it has one,
+// else SIGTERM), before escalating to SIGKILL.
+const stopGracePeriod = 10 * time.Second
+
+// ctrdLog wraps this package's own logrus logger in a *base.LogObject so it
+// can satisfy containerd.NewContainerdClient's logging interface. The
+// hypervisor package doesn't (yet) receive the owning agent's LogObject,
+// so the containerd client started here logs under the "hypervisor"
+// pseudo-agent name rather than the real one.
+var ctrdLog = base.NewSourceLogObject(log.StandardLogger(), "hypervisor", os.Getpid())
+
 type ctrdContext struct {
-	// XXX add log?
 	domCounter int
 	PCI        map[string]bool
 	ctrdClient *containerd.Client
 }
 
 func initContainerd() (*ctrdContext, error) {
-	ctrdClient, err := containerd.NewContainerdClient()
+	ctrdClient, err := containerd.NewContainerdClient(ctrdLog)
 	if err != nil {
 		return nil, err
 	}
@@ -88,11 +102,41 @@ func (ctx ctrdContext) Create(domainName string, cfgFilename string, config *typ
 	// nothing to kill)
 	ctrdCtx, done := ctx.ctrdClient.CtrNewUserServicesCtx()
 	defer done()
-	_ = ctx.ctrdClient.CtrStopContainer(ctrdCtx, domainName, true)
+	_, _ = ctx.ctrdClient.CtrStopContainer(ctrdCtx, domainName, true, 0)
+
+	if config != nil && config.EnableCheckpoint {
+		checkpointPath := containerd.CheckpointPath(domainName)
+		if _, err := os.Stat(checkpointPath); err == nil {
+			domainID, restoreErr := ctx.ctrdClient.CtrRestoreTask(ctrdCtx, domainName, checkpointPath)
+			if restoreErr == nil {
+				return domainID, nil
+			}
+			log.Warnf("Create(%s): restoring checkpoint %s failed, falling back to a fresh start: %v",
+				domainName, checkpointPath, restoreErr)
+		}
+	}
 
 	return ctx.ctrdClient.CtrCreateTask(ctrdCtx, domainName)
 }
 
+// Checkpoint checkpoints domainName's running task to the path returned
+// by containerd.CheckpointPath, for a later restore by Create when the
+// app's EnableCheckpoint is set; see containerd.CtrCheckpointTask.
+func (ctx ctrdContext) Checkpoint(domainName string) error {
+	ctrdCtx, done := ctx.ctrdClient.CtrNewUserServicesCtx()
+	defer done()
+	return ctx.ctrdClient.CtrCheckpointTask(ctrdCtx, domainName, containerd.CheckpointPath(domainName))
+}
+
+// RunQuiesceCommand execs args inside domainName's container task and
+// returns its combined stdout/stderr, for maybeCheckpointContainer's
+// pre/post quiesce hooks; see containerd.Client.CtrExecWithOpts.
+func (ctx ctrdContext) RunQuiesceCommand(domainName string, args []string, timeout time.Duration) (string, string, error) {
+	ctrdCtx, done := ctx.ctrdClient.CtrNewUserServicesCtx()
+	defer done()
+	return ctx.ctrdClient.CtrExecWithOpts(ctrdCtx, domainName, args, containerd.CtrExecOpts{Timeout: timeout})
+}
+
 func (ctx ctrdContext) Start(domainName string, domainID int) error {
 	ctrdCtx, done := ctx.ctrdClient.CtrNewUserServicesCtx()
 	defer done()
@@ -113,10 +157,22 @@ func (ctx ctrdContext) Start(domainName string, domainID int) error {
 	return fmt.Errorf("task %s couldn't reach a steady state in time", domainName)
 }
 
+// Stop asks containerd to stop the container's task, waiting up to
+// stopGracePeriod for a graceful exit before escalating to SIGKILL.
+//
+// XXX the types.Task interface this satisfies only returns an error, so
+// whether the stop was graceful isn't surfaced past this point; reporting
+// it up through DomainStatus to zedmanager, as opposed to just logging it
+// here, would require widening that interface for every hypervisor
+// backend (xen, kvm, null), which is out of scope here.
 func (ctx ctrdContext) Stop(domainName string, domainID int, force bool) error {
 	ctrdCtx, done := ctx.ctrdClient.CtrNewUserServicesCtx()
 	defer done()
-	return ctx.ctrdClient.CtrStopContainer(ctrdCtx, domainName, force)
+	graceful, err := ctx.ctrdClient.CtrStopContainer(ctrdCtx, domainName, force, stopGracePeriod)
+	if err == nil {
+		log.Infof("containerd Stop: domain %s stopped, graceful=%v", domainName, graceful)
+	}
+	return err
 }
 
 func (ctx ctrdContext) Delete(domainName string, domainID int) error {
@@ -175,6 +231,33 @@ func (ctx ctrdContext) PCIRelease(long string) error {
 	}
 }
 
+// WatchTask starts watching domainName's task for exit, restarting it per
+// policy; see containerd.Client.WatchTask. kvmContext and xenContext also
+// get this for free by embedding ctrdContext, since their domains run as
+// containerd tasks too.
+func (ctx ctrdContext) WatchTask(domainName string, policy taskmonitor.Policy,
+	onRestart func(state taskmonitor.State, restarted bool, err error)) {
+	ctx.ctrdClient.WatchTask(domainName, policy, onRestart)
+}
+
+// UnwatchTask stops any restart watch started with WatchTask for domainName.
+func (ctx ctrdContext) UnwatchTask(domainName string) {
+	ctx.ctrdClient.UnwatchTask(domainName)
+}
+
+// WatchHealth starts probing domainName's container on a schedule; see
+// containerd.Client.WatchHealth. kvmContext and xenContext also get this
+// for free by embedding ctrdContext.
+func (ctx ctrdContext) WatchHealth(domainName string, probe healthprobe.Config,
+	onTransition func(state healthprobe.State)) {
+	ctx.ctrdClient.WatchHealth(domainName, probe, onTransition)
+}
+
+// UnwatchHealth stops any health probe started with WatchHealth for domainName.
+func (ctx ctrdContext) UnwatchHealth(domainName string) {
+	ctx.ctrdClient.UnwatchHealth(domainName)
+}
+
 func (ctx ctrdContext) GetHostCPUMem() (types.HostMemory, error) {
 	return selfDomCPUMem()
 }
@@ -195,8 +278,8 @@ func (ctx ctrdContext) GetDomsCPUMem() (map[string]types.DomainMetric, error) {
 		var cpuTotal uint64
 
 		if metric, err := ctx.ctrdClient.CtrGetContainerMetrics(ctrdCtx, id); err == nil {
-			usedMem = uint32(roundFromBytesToMbytes(metric.Memory.Usage.Usage))
-			totalMem = uint32(roundFromBytesToMbytes(metric.Memory.HierarchicalMemoryLimit))
+			usedMem = uint32(roundFromBytesToMbytes(metric.UsedMemory))
+			totalMem = uint32(roundFromBytesToMbytes(metric.HierarchicalMemoryLimit))
 			availMem = 0
 			if totalMem > usedMem {
 				availMem = totalMem - usedMem
@@ -206,7 +289,7 @@ func (ctx ctrdContext) GetDomsCPUMem() (map[string]types.DomainMetric, error) {
 			} else {
 				usedMemPerc = 0
 			}
-			cpuTotal = metric.CPU.Usage.Total / 1000000000
+			cpuTotal = metric.CPUTotal / 1000000000
 		} else {
 			log.Errorf("GetDomsCPUMem failed with error %v", err)
 		}
@@ -5,11 +5,14 @@ package hypervisor
 
 import (
 	"fmt"
+	"github.com/lf-edge/eve/pkg/pillar/healthprobe"
+	"github.com/lf-edge/eve/pkg/pillar/taskmonitor"
 	"github.com/lf-edge/eve/pkg/pillar/types"
 	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/mem"
 	log "github.com/sirupsen/logrus"
 	"os"
+	"time"
 )
 
 // Hypervisor provides methods for manipulating domains on the host
@@ -24,6 +27,110 @@ type Hypervisor interface {
 	GetDomsCPUMem() (map[string]types.DomainMetric, error)
 }
 
+// TaskWatcher is implemented by Hypervisor backends whose domains run as
+// containerd tasks and so can be watched for exit and automatically
+// restarted per a restart policy; ctrdContext implements it, and
+// kvmContext/xenContext get it for free by embedding ctrdContext. Callers
+// should type-assert for it (see AsTaskWatcher) rather than assuming every
+// Hypervisor supports it - nullContext, notably, does not.
+type TaskWatcher interface {
+	WatchTask(domainName string, policy taskmonitor.Policy,
+		onRestart func(state taskmonitor.State, restarted bool, err error))
+	UnwatchTask(domainName string)
+}
+
+// AsTaskWatcher returns hyper as a TaskWatcher if its backend supports
+// restart watching, and whether it does.
+func AsTaskWatcher(hyper Hypervisor) (TaskWatcher, bool) {
+	tw, ok := hyper.(TaskWatcher)
+	return tw, ok
+}
+
+// HealthProber is implemented by Hypervisor backends whose domains run as
+// containerd tasks and so can be health-probed on a schedule via exec,
+// like TaskWatcher; see AsHealthProber.
+type HealthProber interface {
+	WatchHealth(domainName string, probe healthprobe.Config,
+		onTransition func(state healthprobe.State))
+	UnwatchHealth(domainName string)
+}
+
+// AsHealthProber returns hyper as a HealthProber if its backend supports
+// health probing, and whether it does.
+func AsHealthProber(hyper Hypervisor) (HealthProber, bool) {
+	hp, ok := hyper.(HealthProber)
+	return hp, ok
+}
+
+// Checkpointer is implemented by Hypervisor backends whose domains run as
+// containerd tasks and so can be checkpointed (via CRIU) for a later
+// restore, e.g. across an EVE reboot or a migration; ctrdContext
+// implements it, like TaskWatcher and HealthProber. Callers should
+// type-assert for it (see AsCheckpointer) rather than assuming every
+// Hypervisor supports it.
+type Checkpointer interface {
+	Checkpoint(domainName string) error
+}
+
+// AsCheckpointer returns hyper as a Checkpointer if its backend supports
+// checkpointing, and whether it does.
+func AsCheckpointer(hyper Hypervisor) (Checkpointer, bool) {
+	cp, ok := hyper.(Checkpointer)
+	return cp, ok
+}
+
+// QuiesceHookRunner is implemented by Hypervisor backends that can exec a
+// command inside a container's task; ctrdContext implements it via
+// containerd exec, so maybeCheckpointContainer can run a
+// types.QuiesceHookConfig's pre/post commands around a checkpoint to let a
+// database or other stateful process quiesce beforehand and resume after.
+// Callers should type-assert for it (see AsQuiesceHookRunner) rather than
+// assuming every Hypervisor supports it.
+type QuiesceHookRunner interface {
+	RunQuiesceCommand(domainName string, args []string, timeout time.Duration) (string, string, error)
+}
+
+// AsQuiesceHookRunner returns hyper as a QuiesceHookRunner if its backend
+// supports exec'ing quiesce hook commands, and whether it does.
+func AsQuiesceHookRunner(hyper Hypervisor) (QuiesceHookRunner, bool) {
+	r, ok := hyper.(QuiesceHookRunner)
+	return r, ok
+}
+
+// GuestNetworkStatsProvider is implemented by Hypervisor backends that can
+// reach into the guest over a guest agent channel (e.g. QEMU's QGA socket)
+// and pull in-guest network interface counters; kvmContext implements it.
+// Callers should type-assert for it (see AsGuestNetworkStatsProvider) rather
+// than assuming every Hypervisor supports it.
+type GuestNetworkStatsProvider interface {
+	GetGuestNetworkStats(domainName string) ([]types.NetworkMetric, error)
+}
+
+// AsGuestNetworkStatsProvider returns hyper as a GuestNetworkStatsProvider if
+// its backend supports querying in-guest network stats, and whether it does.
+func AsGuestNetworkStatsProvider(hyper Hypervisor) (GuestNetworkStatsProvider, bool) {
+	gs, ok := hyper.(GuestNetworkStatsProvider)
+	return gs, ok
+}
+
+// PCIResetter is implemented by Hypervisor backends that can apply a
+// function-level reset to a PCI device, independent of binding it to
+// vfio-pci; kvmContext implements it via the device's sysfs reset file,
+// the same mechanism PCIReserve already uses for devices hwquirks flags
+// as needing a reset-before-assign. Callers should type-assert for it
+// (see AsPCIResetter) rather than assuming every Hypervisor supports it -
+// the assignments package uses it for its explicit Reset operation.
+type PCIResetter interface {
+	PCIReset(long string) error
+}
+
+// AsPCIResetter returns hyper as a PCIResetter if its backend supports
+// resetting a PCI device's function, and whether it does.
+func AsPCIResetter(hyper Hypervisor) (PCIResetter, bool) {
+	r, ok := hyper.(PCIResetter)
+	return r, ok
+}
+
 type hypervisorDesc struct {
 	constructor func() Hypervisor
 	dom0handle  string
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	zconfig "github.com/lf-edge/eve/api/go/config"
 	"github.com/lf-edge/eve/pkg/pillar/agentlog"
+	"github.com/lf-edge/eve/pkg/pillar/hwquirks"
 	"github.com/lf-edge/eve/pkg/pillar/types"
+	"github.com/lf-edge/eve/pkg/pillar/virtiofsd"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
 	"os"
@@ -17,9 +19,37 @@ import (
 	"time"
 )
 
-//TBD: Have a better way to calculate this number.
-//For now it is based on some trial-and-error experiments
-const qemuOverHead = int64(600 * 1024 * 1024)
+// Fixed and per-resource contributions to qemuOverhead, in bytes. These are
+// based on the same trial-and-error experiments that produced the old flat
+// 600MB qemuOverHead constant, just broken out per resource so a small
+// single-vCPU, single-disk, single-VIF domain isn't charged for headroom
+// it doesn't need.
+const (
+	qemuBaseOverHead    = int64(160 * 1024 * 1024)
+	qemuPerVCpuOverHead = int64(32 * 1024 * 1024)
+	qemuPerDiskOverHead = int64(16 * 1024 * 1024)
+	qemuPerVifOverHead  = int64(16 * 1024 * 1024)
+	qemuVncOverHead     = int64(32 * 1024 * 1024)
+)
+
+// qemuOverhead estimates the amount of memory, beyond config.Memory, that
+// qemu itself needs for a domain with this shape: one virtio-serial console
+// is always present (see the PCIe topology notes below), and VCpus, disks
+// and VIFs each add their own device model and vhost state on top of that.
+func qemuOverhead(config types.DomainConfig) int64 {
+	vCPUs := int64(config.VCpus)
+	if vCPUs <= 0 {
+		vCPUs = 1
+	}
+	overhead := qemuBaseOverHead +
+		vCPUs*qemuPerVCpuOverHead +
+		int64(len(config.DiskConfigList))*qemuPerDiskOverHead +
+		int64(len(config.VifList))*qemuPerVifOverHead
+	if config.EnableVnc {
+		overhead += qemuVncOverHead
+	}
+	return overhead
+}
 
 // We build device model around PCIe topology according to best practices
 //    https://github.com/qemu/qemu/blob/master/docs/pcie.txt
@@ -88,7 +118,11 @@ const qemuConfTemplate = `# This file is automatically generated by domainmgr
   value = "1"
 
 [rtc]
+{{- if .RTCUTC }}
+  base = "utc"
+{{- else }}
   base = "localtime"
+{{- end }}
   driftfix = "slew"
 
 [device]
@@ -145,6 +179,17 @@ const qemuConfTemplate = `# This file is automatically generated by domainmgr
   chardev = "charserial0"
   name = "org.lfedge.eve.console.0"
 
+[chardev "charqga0"]
+  backend = "socket"
+  path = "` + kvmStateDir + `{{.DisplayName}}/qga"
+  server = "on"
+  wait = "off"
+
+[device]
+  driver = "virtserialport"
+  chardev = "charqga0"
+  name = "org.qemu.guest_agent.0"
+
 {{if .EnableVnc}}
 [vnc "default"]
   vnc = "0.0.0.0:{{if .VncDisplay}}{{.VncDisplay}}{{else}}0{{end}}"
@@ -202,7 +247,7 @@ const qemuConfTemplate = `# This file is automatically generated by domainmgr
   port = "2"
 {{end}}`
 
-//   multidevs = "remap"
+// multidevs = "remap"
 const qemuDiskTemplate = `
 {{if eq .Devtype "cdrom"}}
 [drive "drive-sata0-{{.DiskID}}"]
@@ -231,6 +276,16 @@ const qemuDiskTemplate = `
   fsdev = "fsdev{{.DiskID}}"
   mount_tag = "hostshare"
   addr = "{{.PCIId}}"
+{{else if eq .Devtype "virtiofs"}}
+[chardev "char{{.DiskID}}"]
+  backend = "socket"
+  path = "{{.SocketPath}}"
+
+[device "fs{{.DiskID}}"]
+  driver = "vhost-user-fs-pci"
+  chardev = "char{{.DiskID}}"
+  tag = "hostshare{{.DiskID}}"
+  addr = "{{.PCIId}}"
 {{else}}
 [device "pci.{{.PCIId}}"]
   driver = "pcie-root-port"
@@ -278,6 +333,26 @@ const qemuNetTemplate = `
   addr = "0x0"
 `
 
+const qemuIvshmemTemplate = `
+[object "ivshmem-{{.Name}}"]
+  qom-type = "memory-backend-file"
+  mem-path = "{{.Path}}"
+  size = "{{.SizeMB}}M"
+  share = "on"
+
+[device "pci.{{.PCIId}}"]
+  driver = "pcie-root-port"
+  port = "1{{.PCIId}}"
+  chassis = "{{.PCIId}}"
+  bus = "pcie.0"
+  addr = "{{.PCIId}}"
+
+[device "ivshmem-{{.ID}}"]
+  driver = "ivshmem-plain"
+  memdev = "ivshmem-{{.Name}}"
+  bus = "pci.{{.PCIId}}"
+`
+
 const qemuPciPassthruTemplate = `
 [device]
   driver = "vfio-pci"
@@ -312,9 +387,11 @@ const vfioDriverPath = "/sys/bus/pci/drivers/vfio-pci"
 // KVM domains map 1-1 to anchor device model UNIX processes (qemu or firecracker)
 // For every anchor process we maintain the following entry points in the
 // /var/run/hypervisor/kvm/DOMAIN_NAME:
-//    pid - contains PID of the anchor process
-//    qmp - UNIX domain socket that allows us to talk to anchor process
-//   cons - symlink to /dev/pts/X that allows us to talk to the serial console of the domain
+//
+//	 pid - contains PID of the anchor process
+//	 qmp - UNIX domain socket that allows us to talk to anchor process
+//	cons - symlink to /dev/pts/X that allows us to talk to the serial console of the domain
+//
 // In addition to that, we also maintain DOMAIN_NAME -> PID mapping in kvmContext, so we don't
 // have to look things up in the filesystem all the time (this also allows us to filter domains
 // that may be created by others)
@@ -326,6 +403,19 @@ type kvmContext struct {
 	dmArgs       []string
 	dmCPUArgs    []string
 	dmFmlCPUArgs []string
+	// virtiofsDaemons holds the running virtiofsd processes backing a
+	// domain's "virtiofs" disks (see types.DiskStatus.HostDirSharePath),
+	// keyed by domain name, for Delete to stop alongside the domain
+	// itself. A map field, like ctrdContext.PCI, so it stays shared
+	// across copies of this value-typed context.
+	virtiofsDaemons map[string][]*virtiofsd.Daemon
+	// ivshmemSizeMB and ivshmemUsers track, per ivshmem channel Name, the
+	// SizeMB agreed on by whichever domain attached first and the set of
+	// domain names currently attached. Delete removes a domain from
+	// every channel it was in, and once a channel's last user is gone
+	// its backing file is removed.
+	ivshmemSizeMB map[string]int
+	ivshmemUsers  map[string]map[string]bool
 }
 
 func newKvm() Hypervisor {
@@ -341,26 +431,68 @@ func newKvm() Hypervisor {
 	switch runtime.GOARCH {
 	case "arm64":
 		return kvmContext{
-			ctrdContext:  *ctrdCtx,
-			devicemodel:  "virt",
-			dmExec:       "/usr/lib/xen/bin/qemu-system-aarch64",
-			dmArgs:       []string{"-display", "none", "-S", "-no-user-config", "-nodefaults", "-no-shutdown", "-overcommit", "mem-lock=on", "-overcommit", "cpu-pm=on", "-serial", "chardev:charserial0"},
-			dmCPUArgs:    []string{"-cpu", "host"},
-			dmFmlCPUArgs: []string{},
+			ctrdContext:     *ctrdCtx,
+			devicemodel:     "virt",
+			dmExec:          "/usr/lib/xen/bin/qemu-system-aarch64",
+			dmArgs:          []string{"-display", "none", "-S", "-no-user-config", "-nodefaults", "-no-shutdown", "-overcommit", "mem-lock=on", "-overcommit", "cpu-pm=on", "-serial", "chardev:charserial0"},
+			dmCPUArgs:       []string{"-cpu", "host"},
+			dmFmlCPUArgs:    []string{},
+			virtiofsDaemons: make(map[string][]*virtiofsd.Daemon),
+			ivshmemSizeMB:   make(map[string]int),
+			ivshmemUsers:    make(map[string]map[string]bool),
 		}
 	case "amd64":
 		return kvmContext{
-			ctrdContext:  *ctrdCtx,
-			devicemodel:  "pc-q35-3.1",
-			dmExec:       "/usr/lib/xen/bin/qemu-system-x86_64",
-			dmArgs:       []string{"-display", "none", "-S", "-no-user-config", "-nodefaults", "-no-shutdown", "-overcommit", "mem-lock=on", "-overcommit", "cpu-pm=on", "-serial", "chardev:charserial0", "-no-hpet"},
-			dmCPUArgs:    []string{},
-			dmFmlCPUArgs: []string{"-cpu", "host,hv_time,hv_relaxed,hv_vendor_id=eveitis,hypervisor=off,kvm=off"},
+			ctrdContext:     *ctrdCtx,
+			devicemodel:     "pc-q35-3.1",
+			dmExec:          "/usr/lib/xen/bin/qemu-system-x86_64",
+			dmArgs:          []string{"-display", "none", "-S", "-no-user-config", "-nodefaults", "-no-shutdown", "-overcommit", "mem-lock=on", "-overcommit", "cpu-pm=on", "-serial", "chardev:charserial0", "-no-hpet"},
+			dmCPUArgs:       []string{},
+			dmFmlCPUArgs:    []string{"-cpu", "host,hv_time,hv_relaxed,hv_vendor_id=eveitis,hypervisor=off,kvm=off"},
+			virtiofsDaemons: make(map[string][]*virtiofsd.Daemon),
+			ivshmemSizeMB:   make(map[string]int),
+			ivshmemUsers:    make(map[string]map[string]bool),
 		}
 	}
 	return nil
 }
 
+// confidentialComputeArgs returns the extra qemu arguments needed to
+// launch the guest as an AMD SEV or Intel TDX confidential VM, after
+// checking that the host actually supports the requested technology.
+// The launch measurement that these options produce is reported to the
+// controller by the attest subsystem via the existing quote/event-log
+// flow, which gates key release on a successful attestation.
+func confidentialComputeArgs(cvmType types.CVMType) ([]string, error) {
+	if cvmType == types.CVMTypeNone {
+		return nil, nil
+	}
+	caps, err := GetCapabilities()
+	if err != nil {
+		return nil, err
+	}
+	switch cvmType {
+	case types.CVMTypeSEV:
+		if !caps.SEV {
+			return nil, logError("AMD SEV requested but not supported by this host")
+		}
+		return []string{
+			"-object", "sev-guest,id=sev0,cbitpos=47,reduced-phys-bits=1",
+			"-machine", "confidential-guest-support=sev0",
+		}, nil
+	case types.CVMTypeTDX:
+		if !caps.TDX {
+			return nil, logError("Intel TDX requested but not supported by this host")
+		}
+		return []string{
+			"-object", "tdx-guest,id=tdx0",
+			"-machine", "confidential-guest-support=tdx0",
+		}, nil
+	default:
+		return nil, logError("unknown confidential VM type %q", cvmType)
+	}
+}
+
 func (ctx kvmContext) Name() string {
 	return "kvm"
 }
@@ -373,6 +505,30 @@ func (ctx kvmContext) Task(status *types.DomainStatus) types.Task {
 	}
 }
 
+// guestCPUArgs builds the qemu -cpu argument for config. If the app
+// doesn't request a specific CPU model or flag tweaks, the hypervisor's
+// built-in default (baseCPUArgs) is used unchanged. Otherwise it starts
+// from config.CPUModel (defaulting to "host" passthrough) and layers the
+// requested flags on top, e.g. to disable AVX for guest software that
+// keys its license off CPU identification.
+func guestCPUArgs(config types.DomainConfig, baseCPUArgs []string) []string {
+	if config.CPUModel == "" && len(config.CPUFlagsAdd) == 0 && len(config.CPUFlagsRemove) == 0 {
+		return baseCPUArgs
+	}
+	model := config.CPUModel
+	if model == "" {
+		model = "host"
+	}
+	parts := []string{model}
+	for _, flag := range config.CPUFlagsAdd {
+		parts = append(parts, flag+"=on")
+	}
+	for _, flag := range config.CPUFlagsRemove {
+		parts = append(parts, flag+"=off")
+	}
+	return []string{"-cpu", strings.Join(parts, ",")}
+}
+
 func (ctx kvmContext) Setup(status types.DomainStatus, config types.DomainConfig, aa *types.AssignableAdapters, file *os.File) error {
 
 	diskStatusList := status.DiskStatusList
@@ -384,10 +540,16 @@ func (ctx kvmContext) Setup(status types.DomainStatus, config types.DomainConfig
 
 	dmArgs := ctx.dmArgs
 	if config.VirtualizationMode == types.FML {
-		dmArgs = append(dmArgs, ctx.dmFmlCPUArgs...)
+		dmArgs = append(dmArgs, guestCPUArgs(config, ctx.dmFmlCPUArgs)...)
 	} else {
-		dmArgs = append(dmArgs, ctx.dmCPUArgs...)
+		dmArgs = append(dmArgs, guestCPUArgs(config, ctx.dmCPUArgs)...)
+	}
+
+	cvmArgs, err := confidentialComputeArgs(config.CVMType)
+	if err != nil {
+		return logError("failed to configure confidential compute: %v", err)
 	}
+	dmArgs = append(dmArgs, cvmArgs...)
 
 	os.MkdirAll(kvmStateDir+domainName, 0777)
 
@@ -397,13 +559,113 @@ func (ctx kvmContext) Setup(status types.DomainStatus, config types.DomainConfig
 		"-readconfig", file.Name(),
 		"-pidfile", kvmStateDir+domainName+"/pid")
 
-	if err := ctx.ctrdClient.LKTaskPrepare(domainName, "xen-tools", &config, &status, qemuOverHead, args); err != nil {
+	if err := ctx.ctrdClient.LKTaskPrepare(domainName, "xen-tools", &config, &status, qemuOverhead(config), args); err != nil {
 		return logError("LKTaskPrepare failed for %s, (%v)", domainName, err)
 	}
 
 	return nil
 }
 
+// startVirtiofsDaemon launches a virtiofsd process sharing ds.HostDirSharePath
+// into the domain and returns the unix socket path qemu's vhost-user-fs-pci
+// device should connect to. The daemon is tracked under domainName so
+// Delete can stop it once the domain goes away; nothing currently restarts
+// it if it exits early (see virtiofsd.Daemon.Restart for when that's
+// needed).
+func (ctx kvmContext) startVirtiofsDaemon(domainName string, diskID int, ds types.DiskStatus) (string, error) {
+	if err := os.MkdirAll(kvmStateDir+domainName, 0777); err != nil {
+		return "", logError("can't create state dir for %s (%v)", domainName, err)
+	}
+	socketPath := fmt.Sprintf("%s%s/virtiofs-%d.sock", kvmStateDir, domainName, diskID)
+	daemon, err := virtiofsd.New(ctrdLog, virtiofsd.Config{
+		SocketPath: socketPath,
+		SharedDir:  ds.HostDirSharePath,
+		ReadOnly:   ds.ReadOnly,
+		Cache:      virtiofsd.CacheMode(ds.HostDirShareCacheMode),
+	})
+	if err != nil {
+		return "", err
+	}
+	ctx.virtiofsDaemons[domainName] = append(ctx.virtiofsDaemons[domainName], daemon)
+	return socketPath, nil
+}
+
+// stopVirtiofsDaemons stops and forgets all virtiofsd processes started for
+// domainName by startVirtiofsDaemon.
+func (ctx kvmContext) stopVirtiofsDaemons(domainName string) {
+	for _, daemon := range ctx.virtiofsDaemons[domainName] {
+		daemon.Stop()
+	}
+	delete(ctx.virtiofsDaemons, domainName)
+}
+
+// ivshmemStateDir holds the memory-backend-file backing files for
+// ivshmem-plain channels. Unlike kvmStateDir+domainName, it is not
+// per-domain: a channel's backing file outlives any one participant's
+// domain state directory for as long as another participant is still
+// attached.
+const ivshmemStateDir = kvmStateDir + "ivshmem/"
+
+func ivshmemBackingFile(name string) string {
+	return ivshmemStateDir + name
+}
+
+// attachIvshmemDevices prepares the backing files for domainName's
+// ivshmem channels and records domainName as a user of each, for
+// releaseIvshmemDevices to undo once the domain is gone. It rejects a
+// channel whose SizeMB disagrees with the size already in use by an
+// earlier participant, since qemu requires every attachment to a given
+// memory-backend-file to agree on its size.
+func (ctx kvmContext) attachIvshmemDevices(domainName string, devs []types.IvshmemConfig) error {
+	if len(devs) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(ivshmemStateDir, 0777); err != nil {
+		return logError("can't create ivshmem state dir: %v", err)
+	}
+	for _, dev := range devs {
+		if sizeMB, used := ctx.ivshmemSizeMB[dev.Name]; used && sizeMB != dev.SizeMB {
+			return logError("ivshmem channel %s already in use with SizeMB %d, %s wants %d",
+				dev.Name, sizeMB, domainName, dev.SizeMB)
+		}
+		path := ivshmemBackingFile(dev.Name)
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+		if err != nil {
+			return logError("can't create ivshmem backing file %s: %v", path, err)
+		}
+		err = f.Truncate(int64(dev.SizeMB) * 1024 * 1024)
+		f.Close()
+		if err != nil {
+			return logError("can't size ivshmem backing file %s: %v", path, err)
+		}
+		ctx.ivshmemSizeMB[dev.Name] = dev.SizeMB
+		if ctx.ivshmemUsers[dev.Name] == nil {
+			ctx.ivshmemUsers[dev.Name] = make(map[string]bool)
+		}
+		ctx.ivshmemUsers[dev.Name][domainName] = true
+	}
+	return nil
+}
+
+// releaseIvshmemDevices removes domainName from every ivshmem channel it
+// was attached to, and once a channel has no users left, removes its
+// backing file and forgets its SizeMB.
+func (ctx kvmContext) releaseIvshmemDevices(domainName string) {
+	for name, users := range ctx.ivshmemUsers {
+		if !users[domainName] {
+			continue
+		}
+		delete(users, domainName)
+		if len(users) == 0 {
+			delete(ctx.ivshmemUsers, name)
+			delete(ctx.ivshmemSizeMB, name)
+			if err := os.Remove(ivshmemBackingFile(name)); err != nil && !os.IsNotExist(err) {
+				log.Errorf("failed to remove ivshmem backing file for %s: %v", name, err)
+			}
+		}
+	}
+}
+
 func (ctx kvmContext) CreateDomConfig(domainName string, config types.DomainConfig, diskStatusList []types.DiskStatus,
 	aa *types.AssignableAdapters, file *os.File) error {
 	tmplCtx := struct {
@@ -438,6 +700,7 @@ func (ctx kvmContext) CreateDomConfig(domainName string, config types.DomainConf
 	diskContext := struct {
 		Machine               string
 		PCIId, DiskID, SATAId int
+		SocketPath            string
 		types.DiskStatus
 	}{Machine: ctx.devicemodel, PCIId: 4, DiskID: 0, SATAId: 0}
 	t, _ = template.New("qemuDisk").
@@ -448,6 +711,14 @@ func (ctx kvmContext) CreateDomConfig(domainName string, config types.DomainConf
 			continue
 		}
 		diskContext.DiskStatus = ds
+		diskContext.SocketPath = ""
+		if ds.Devtype == "virtiofs" {
+			socketPath, err := ctx.startVirtiofsDaemon(domainName, diskContext.DiskID, ds)
+			if err != nil {
+				return logError("failed to start virtiofsd for %s: %v", ds.HostDirSharePath, err)
+			}
+			diskContext.SocketPath = socketPath
+		}
 		if err := t.Execute(file, diskContext); err != nil {
 			return logError("can't write to config file %s (%v)", file.Name(), err)
 		}
@@ -476,6 +747,27 @@ func (ctx kvmContext) CreateDomConfig(domainName string, config types.DomainConf
 		netContext.NetID = netContext.NetID + 1
 	}
 
+	// render ivshmem device model settings
+	if err := ctx.attachIvshmemDevices(domainName, config.IvshmemDevices); err != nil {
+		return err
+	}
+	ivshmemContext := struct {
+		PCIId, ID  int
+		Name, Path string
+		SizeMB     int
+	}{PCIId: netContext.PCIId}
+	t, _ = template.New("qemuIvshmem").Parse(qemuIvshmemTemplate)
+	for _, dev := range config.IvshmemDevices {
+		ivshmemContext.Name = dev.Name
+		ivshmemContext.SizeMB = dev.SizeMB
+		ivshmemContext.Path = ivshmemBackingFile(dev.Name)
+		if err := t.Execute(file, ivshmemContext); err != nil {
+			return logError("can't write to config file %s (%v)", file.Name(), err)
+		}
+		ivshmemContext.PCIId = ivshmemContext.PCIId + 1
+		ivshmemContext.ID = ivshmemContext.ID + 1
+	}
+
 	// Gather all PCI assignments into a single line
 	var pciAssignments []typeAndPCI
 	// Gather all USB assignments into a single line
@@ -637,6 +929,8 @@ func (ctx kvmContext) Stop(domainName string, domainID int, force bool) error {
 }
 
 func (ctx kvmContext) Delete(domainName string, domainID int) error {
+	ctx.stopVirtiofsDaemons(domainName)
+	ctx.releaseIvshmemDevices(domainName)
 	//Sending a stop signal to then domain before quitting. This is done to freeze the domain before quitting it.
 	execStop(getQmpExecutorSocket(domainName))
 	if err := execQuit(getQmpExecutorSocket(domainName)); err != nil {
@@ -723,6 +1017,20 @@ func (ctx kvmContext) PCIReserve(long string) error {
 		}
 	}
 
+	//Some devices need a function-level reset before being handed to
+	//vfio-pci, or the guest's driver finds them in a state dom0's
+	//driver left behind. Apply that if this device is known to need it.
+	if quirk, found, err := hwquirks.LookupForPCI(sysfsPciDevices, long); err != nil {
+		log.Warnf("hwquirks lookup failed for PCI device %s: %v", long, err)
+	} else if found {
+		log.Infof("hwquirks: applying quirk for PCI device %s: %s", long, quirk.Description)
+		if quirk.ResetBeforeAssign {
+			if err := ctx.PCIReset(long); err != nil {
+				log.Warnf("reset failure for PCI device %s: %v", long, err)
+			}
+		}
+	}
+
 	if err := ioutil.WriteFile(sysfsPciDriversProbe, []byte(long), 0644); err != nil {
 		return logError("drivers_probe failure for PCI device %s: %v",
 			long, err)
@@ -731,6 +1039,19 @@ func (ctx kvmContext) PCIReserve(long string) error {
 	return nil
 }
 
+// PCIReset applies a function-level reset to a PCI device via its sysfs
+// reset file, without touching which driver it is bound to. It is safe to
+// call whether the device is currently bound to vfio-pci or its original
+// driver.
+func (ctx kvmContext) PCIReset(long string) error {
+	log.Infof("PCIReset long addr is %s", long)
+	resetFile := sysfsPciDevices + long + "/reset"
+	if err := ioutil.WriteFile(resetFile, []byte("1"), 0644); err != nil {
+		return logError("reset failure for PCI device %s: %v", long, err)
+	}
+	return nil
+}
+
 func (ctx kvmContext) PCIRelease(long string) error {
 	log.Infof("PCIRelease long addr is %s", long)
 
@@ -776,3 +1097,15 @@ func getQmpExecutorSocket(domainName string) string {
 func getQmpListenerSocket(domainName string) string {
 	return kvmStateDir + domainName + "/listener.qmp"
 }
+
+func getQgaSocket(domainName string) string {
+	return kvmStateDir + domainName + "/qga"
+}
+
+// GetGuestNetworkStats reaches into the domain over the QEMU guest agent
+// channel and returns per-interface counters as reported by the guest
+// kernel. It returns an error if the guest agent is not responding, e.g.
+// because the guest doesn't have qemu-guest-agent installed or running.
+func (ctx kvmContext) GetGuestNetworkStats(domainName string) ([]types.NetworkMetric, error) {
+	return qgaGetNetworkStats(getQgaSocket(domainName))
+}
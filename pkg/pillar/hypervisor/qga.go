@@ -0,0 +1,95 @@
+// Copyright (c) 2026 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package hypervisor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// this file implements a minimal client for the subset of the QEMU guest
+// agent protocol we need:
+//     https://qemu-project.gitlab.io/qemu/interop/qemu-ga-ref.html
+// Unlike QMP, the guest agent speaks newline-delimited JSON directly with no
+// capabilities handshake, so we talk to it over a plain unix socket rather
+// than reusing the digitalocean/go-qemu QMP monitor.
+
+func qgaExecRawCmd(socket, cmd string) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", socket, sockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(sockTimeout)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write([]byte(cmd)); err != nil {
+		return nil, err
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	return line, nil
+}
+
+type qgaNetworkInterfaceStat struct {
+	Name       string `json:"name"`
+	Statistics *struct {
+		RxBytes   uint64 `json:"rx-bytes"`
+		RxPackets uint64 `json:"rx-packets"`
+		RxErrs    uint64 `json:"rx-errs"`
+		RxDropped uint64 `json:"rx-dropped"`
+		TxBytes   uint64 `json:"tx-bytes"`
+		TxPackets uint64 `json:"tx-packets"`
+		TxErrs    uint64 `json:"tx-errs"`
+		TxDropped uint64 `json:"tx-dropped"`
+	} `json:"statistics,omitempty"`
+}
+
+// qgaGetNetworkStats queries "guest-network-get-interfaces" over the guest
+// agent socket and returns the in-guest per-interface counters. Interfaces
+// without a statistics block (older guest agents) are omitted.
+func qgaGetNetworkStats(socket string) ([]types.NetworkMetric, error) {
+	raw, err := qgaExecRawCmd(socket, `{ "execute": "guest-network-get-interfaces" }`+"\n")
+	if err != nil {
+		return nil, fmt.Errorf("qga guest-network-get-interfaces on %s: %v", socket, err)
+	}
+	var result struct {
+		Return []qgaNetworkInterfaceStat `json:"return"`
+		Error  *struct {
+			Desc string `json:"desc"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("qga guest-network-get-interfaces on %s: %v", socket, err)
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("qga guest-network-get-interfaces on %s: %s", socket, result.Error.Desc)
+	}
+	var stats []types.NetworkMetric
+	for _, ifc := range result.Return {
+		if ifc.Statistics == nil {
+			continue
+		}
+		stats = append(stats, types.NetworkMetric{
+			IfName:   ifc.Name,
+			TxBytes:  ifc.Statistics.TxBytes,
+			RxBytes:  ifc.Statistics.RxBytes,
+			TxPkts:   ifc.Statistics.TxPackets,
+			RxPkts:   ifc.Statistics.RxPackets,
+			TxErrors: ifc.Statistics.TxErrs,
+			RxErrors: ifc.Statistics.RxErrs,
+			TxDrops:  ifc.Statistics.TxDropped,
+			RxDrops:  ifc.Statistics.RxDropped,
+		})
+	}
+	return stats, nil
+}
@@ -88,6 +88,21 @@ func (ctx xenContext) Setup(status types.DomainStatus, config types.DomainConfig
 	return nil
 }
 
+// effectiveVirtualizationMode picks the actual mode to boot an app domain
+// in. PVH (our PV xen_type) is the lean default: no device model, no
+// emulated BIOS, so it is the better choice on low-memory ARM/x86 boxes.
+// But legacy images that ship their own kernel and expect a real device
+// model (rather than OVMF's direct PVH kernel entry) cannot boot under
+// PVH, so fall back to HVM for them rather than failing outright.
+func effectiveVirtualizationMode(config types.DomainConfig) types.VmMode {
+	if config.VirtualizationMode == types.PV && config.Kernel != "" {
+		log.Warnf("effectiveVirtualizationMode(%s): falling back to HVM for legacy image with custom kernel %s",
+			config.DisplayName, config.Kernel)
+		return types.HVM
+	}
+	return config.VirtualizationMode
+}
+
 func (ctx xenContext) CreateDomConfig(domainName string, config types.DomainConfig, diskStatusList []types.DiskStatus,
 	aa *types.AssignableAdapters, file *os.File) error {
 	xen_type := "pvh"
@@ -100,7 +115,8 @@ func (ctx xenContext) CreateDomConfig(domainName string, config types.DomainConf
 	xen_global := ""
 	uuidStr := fmt.Sprintf("appuuid=%s ", config.UUIDandVersion.UUID)
 
-	switch config.VirtualizationMode {
+	virtualizationMode := effectiveVirtualizationMode(config)
+	switch virtualizationMode {
 	case types.PV:
 		xen_type = "pvh"
 		extra = "console=hvc0 " + uuidStr + config.ExtraArgs
@@ -116,7 +132,7 @@ func (ctx xenContext) CreateDomConfig(domainName string, config types.DomainConf
 		xen_global = "hdtype = \"ahci\"\nspoof_xen = 1\npci_permissive = 1\n"
 	default:
 		log.Errorf("Internal error: Unknown virtualizationMode %d",
-			config.VirtualizationMode)
+			virtualizationMode)
 	}
 
 	if config.IsContainer {
@@ -145,7 +161,7 @@ func (ctx xenContext) CreateDomConfig(domainName string, config types.DomainConf
 			bootLoader))
 	}
 	if config.EnableVnc {
-		if config.VirtualizationMode == types.PV {
+		if virtualizationMode == types.PV {
 			vncParams := []string{"vnc=1", "vnclisten=0.0.0.0"}
 			if config.VncDisplay != 0 {
 				vncParams = append(vncParams, fmt.Sprintf("vncdisplay=%d",
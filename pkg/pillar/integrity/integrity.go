@@ -0,0 +1,102 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package integrity collects the runtime dm-verity and IMA measurement
+// status of the rootfs and EVE system containers, to extend the
+// attestation story beyond boot-time PCRs.
+package integrity
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+)
+
+const (
+	dmStatusDir           = "/sys/block"
+	imaMeasurementLogFile = "/sys/kernel/security/ima/ascii_runtime_measurements"
+)
+
+// VerityStatus is the dm-verity status of a single mapped device, as
+// reported by the kernel under /sys/block/<dev>/dm.
+type VerityStatus struct {
+	Device    string
+	Corrupted bool
+}
+
+// Status is the runtime integrity measurement status of the device.
+type Status struct {
+	VerityDevices   []VerityStatus
+	IMAEnabled      bool
+	IMAMeasurements int
+}
+
+// CollectStatus probes dm-verity corruption state for every dm-verity
+// backed block device, and the size of the IMA measurement log if IMA is
+// enabled in the running kernel. It never fails; unavailable probes
+// simply report as disabled/empty.
+func CollectStatus(log *base.LogObject) Status {
+	status := Status{
+		VerityDevices: collectVerityStatus(log),
+	}
+	status.IMAEnabled, status.IMAMeasurements = collectIMAStatus(log)
+	return status
+}
+
+func collectVerityStatus(log *base.LogObject) []VerityStatus {
+	var result []VerityStatus
+	entries, err := ioutil.ReadDir(dmStatusDir)
+	if err != nil {
+		log.Tracef("collectVerityStatus: %s", err)
+		return result
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "dm-") {
+			continue
+		}
+		corruptedFile := dmStatusDir + "/" + name + "/dm/corrupted"
+		corrupted := false
+		if contents, err := ioutil.ReadFile(corruptedFile); err == nil {
+			corrupted = strings.TrimSpace(string(contents)) == "1"
+		} else {
+			// Not a verity-backed device; skip it.
+			continue
+		}
+		result = append(result, VerityStatus{Device: name, Corrupted: corrupted})
+		if corrupted {
+			log.Errorf("dm-verity corruption detected on %s", name)
+		}
+	}
+	return result
+}
+
+func collectIMAStatus(log *base.LogObject) (bool, int) {
+	f, err := os.Open(imaMeasurementLogFile)
+	if err != nil {
+		return false, 0
+	}
+	defer f.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		count++
+	}
+	return true, count
+}
+
+// HasErrors returns true if any collected verity device reports
+// corruption, meaning the running rootfs or a system container failed
+// its integrity verification.
+func (s Status) HasErrors() bool {
+	for _, v := range s.VerityDevices {
+		if v.Corrupted {
+			return true
+		}
+	}
+	return false
+}
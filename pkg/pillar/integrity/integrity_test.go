@@ -0,0 +1,20 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package integrity
+
+import (
+	"testing"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCollectStatus(t *testing.T) {
+	log := base.NewSourceLogObject(logrus.StandardLogger(), "test", 1234)
+
+	status := CollectStatus(log)
+	if status.HasErrors() {
+		t.Errorf("unexpected integrity errors on test host: %+v", status.VerityDevices)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package nat64 implements the IPv4-embedded IPv6 address synthesis
+// described in RFC 6052, which is what lets a client on an IPv6-only,
+// NAT64/DNS64 carrier network reach an IPv4-literal destination (such as
+// a controller address pinned by IP rather than by name) by dialing a
+// synthesized AAAA address through the network's NAT64 gateway instead.
+//
+// Only the 96-bit prefix length is supported: it covers both the
+// Well-Known Prefix (64:ff9b::/96) and the common case of an
+// operator-assigned Network-Specific Prefix, and it is the only length
+// for which synthesis is a straight concatenation with no bits of the
+// IPv4 address interleaved around a "u" octet. The other RFC 6052 prefix
+// lengths (32, 40, 48, 56, 64) are not implemented.
+package nat64
+
+import (
+	"fmt"
+	"net"
+)
+
+// WellKnownPrefix is the Well-Known Prefix defined by RFC 6052 section 2.1,
+// used by networks that do not advertise an operator-specific NAT64 prefix.
+var WellKnownPrefix = net.ParseIP("64:ff9b::")
+
+// SynthesizeAAAA embeds ipv4 into prefix following RFC 6052 to produce the
+// IPv6 address that a NAT64 gateway advertising prefix will translate back
+// to ipv4. prefix must be a /96 IPv6 prefix (its last four bytes must be
+// zero); ipv4 must be a valid IPv4 address.
+func SynthesizeAAAA(prefix net.IP, ipv4 net.IP) (net.IP, error) {
+	prefix16 := prefix.To16()
+	if prefix16 == nil || prefix.To4() != nil {
+		return nil, fmt.Errorf("nat64: %s is not an IPv6 prefix", prefix)
+	}
+	for _, b := range prefix16[12:16] {
+		if b != 0 {
+			return nil, fmt.Errorf("nat64: %s is not a /96 prefix", prefix)
+		}
+	}
+	v4 := ipv4.To4()
+	if v4 == nil {
+		return nil, fmt.Errorf("nat64: %s is not an IPv4 address", ipv4)
+	}
+	synth := make(net.IP, net.IPv6len)
+	copy(synth, prefix16[:12])
+	copy(synth[12:], v4)
+	return synth, nil
+}
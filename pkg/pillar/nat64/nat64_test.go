@@ -0,0 +1,48 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package nat64
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSynthesizeAAAAWellKnownPrefix(t *testing.T) {
+	got, err := SynthesizeAAAA(WellKnownPrefix, net.ParseIP("192.0.2.1"))
+	if err != nil {
+		t.Fatalf("SynthesizeAAAA failed: %s", err)
+	}
+	want := net.ParseIP("64:ff9b::c000:201")
+	if !got.Equal(want) {
+		t.Errorf("SynthesizeAAAA: got %s, want %s", got, want)
+	}
+}
+
+func TestSynthesizeAAAACustomPrefix(t *testing.T) {
+	prefix := net.ParseIP("2001:db8:122::")
+	got, err := SynthesizeAAAA(prefix, net.ParseIP("192.0.2.33"))
+	if err != nil {
+		t.Fatalf("SynthesizeAAAA failed: %s", err)
+	}
+	want := net.ParseIP("2001:db8:122::c000:221")
+	if !got.Equal(want) {
+		t.Errorf("SynthesizeAAAA: got %s, want %s", got, want)
+	}
+}
+
+func TestSynthesizeAAAARejectsNon96Prefix(t *testing.T) {
+	prefix := net.ParseIP("2001:db8::1")
+	if _, err := SynthesizeAAAA(prefix, net.ParseIP("192.0.2.1")); err == nil {
+		t.Errorf("expected error for non-/96 prefix")
+	}
+}
+
+func TestSynthesizeAAAARejectsBadInputs(t *testing.T) {
+	if _, err := SynthesizeAAAA(net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.1")); err == nil {
+		t.Errorf("expected error for IPv4 prefix")
+	}
+	if _, err := SynthesizeAAAA(WellKnownPrefix, net.ParseIP("2001:db8::1")); err == nil {
+		t.Errorf("expected error for IPv6 ipv4 argument")
+	}
+}
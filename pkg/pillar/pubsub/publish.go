@@ -62,6 +62,11 @@ func (pub *PublicationImpl) IsRestarted() bool {
 	return pub.km.restarted
 }
 
+// TopicType returns the Go type of the items carried by this topic
+func (pub *PublicationImpl) TopicType() reflect.Type {
+	return pub.topicType
+}
+
 // Publish publish a key-value pair
 func (pub *PublicationImpl) Publish(key string, item interface{}) error {
 	topic := TypeToName(item)
@@ -6,6 +6,8 @@
 package pubsub
 
 import (
+	"reflect"
+
 	"github.com/lf-edge/eve/pkg/pillar/base"
 )
 
@@ -13,6 +15,10 @@ import (
 type Publication interface {
 	// Publish - Publish an object
 	Publish(key string, item interface{}) error
+	// TopicType returns the Go type of the items carried by this topic,
+	// for tooling that needs to introspect the shape of published data
+	// (see pubsub.DescribeTopicType).
+	TopicType() reflect.Type
 	// Unpublish - Delete / UnPublish an object
 	Unpublish(key string) error
 	// SignalRestarted - Signal the publisher has started.
@@ -49,4 +55,8 @@ type Subscription interface {
 	Activate() error
 	// Close stops the subscription and removes the state
 	Close() error
+	// TopicType returns the Go type of the items carried by this topic,
+	// for tooling that needs to introspect the shape of subscribed data
+	// (see pubsub.DescribeTopicType).
+	TopicType() reflect.Type
 }
@@ -0,0 +1,86 @@
+// Copyright (c) 2022 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package pubsub
+
+import "reflect"
+
+// maxSchemaDepth bounds the recursion when describing a type, so a struct
+// that (directly or indirectly) embeds itself can't spin DescribeTopicType
+// into an infinite loop.
+const maxSchemaDepth = 10
+
+// TypeDescriptor is a machine-readable, JSON-serializable description of a
+// topic's Go type, for local tooling that wants to know the shape of a
+// pubsub topic without having to vendor and compile the pillar Go types.
+//
+// This only describes structure (field names, JSON names, and kinds); it
+// does not attempt to be a full OpenAPI/proto descriptor. There is no local
+// admin socket in this tree yet to serve it over - this is the
+// introspection primitive such a server would call.
+type TypeDescriptor struct {
+	Name   string            `json:"name"`
+	Kind   string            `json:"kind"`
+	Fields []FieldDescriptor `json:"fields,omitempty"`
+	Elem   *TypeDescriptor   `json:"elem,omitempty"`
+}
+
+// FieldDescriptor describes a single struct field.
+type FieldDescriptor struct {
+	GoName   string         `json:"goName"`
+	JSONName string         `json:"jsonName"`
+	Type     TypeDescriptor `json:"type"`
+}
+
+// DescribeTopicType builds a TypeDescriptor for t, the Go type of a pubsub
+// topic as returned by Publication.TopicType/Subscription.TopicType.
+func DescribeTopicType(t reflect.Type) TypeDescriptor {
+	return describeType(t, maxSchemaDepth)
+}
+
+func describeType(t reflect.Type, depth int) TypeDescriptor {
+	if t == nil {
+		return TypeDescriptor{Name: "unknown", Kind: "invalid"}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	desc := TypeDescriptor{Name: t.Name(), Kind: t.Kind().String()}
+	if depth <= 0 {
+		return desc
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				// unexported field
+				continue
+			}
+			jsonName := field.Name
+			if tag, ok := field.Tag.Lookup("json"); ok {
+				if name := splitJSONTagName(tag); name != "" {
+					jsonName = name
+				}
+			}
+			desc.Fields = append(desc.Fields, FieldDescriptor{
+				GoName:   field.Name,
+				JSONName: jsonName,
+				Type:     describeType(field.Type, depth-1),
+			})
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		elem := describeType(t.Elem(), depth-1)
+		desc.Elem = &elem
+	}
+	return desc
+}
+
+func splitJSONTagName(tag string) string {
+	for i := 0; i < len(tag); i++ {
+		if tag[i] == ',' {
+			return tag[:i]
+		}
+	}
+	return tag
+}
@@ -156,6 +156,11 @@ func (sub *SubscriptionImpl) Restarted() bool {
 	return sub.km.restarted
 }
 
+// TopicType returns the Go type of the items carried by this topic
+func (sub *SubscriptionImpl) TopicType() reflect.Type {
+	return sub.topicType
+}
+
 // Synchronized -
 func (sub *SubscriptionImpl) Synchronized() bool {
 	return sub.synchronized
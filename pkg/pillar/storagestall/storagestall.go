@@ -0,0 +1,130 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package storagestall detects hung storage I/O: a /persist fsync that
+// takes unreasonably long, or an agent thread stuck in the kernel's
+// uninterruptible-sleep (D) state. Today such a stall just manifests as
+// an inexplicable watchdog reboot with no recorded cause; this package
+// gives callers (nodeagent) something concrete to log, and to decide a
+// policy on, before that happens.
+package storagestall
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+)
+
+const probeFileName = ".storage-stall-probe"
+
+// DProcess is an agent (or any other) process found in the kernel's
+// uninterruptible-sleep (D) state, a sign that it is blocked on I/O.
+type DProcess struct {
+	Pid  int
+	Comm string
+}
+
+// Status is the outcome of a single stall probe.
+type Status struct {
+	// FsyncDuration is how long it took to write and fsync a small
+	// probe file under the probed directory.
+	FsyncDuration time.Duration
+	// FsyncErr is set if the probe write/fsync itself failed, as
+	// opposed to merely being slow.
+	FsyncErr error
+	// DProcesses are the processes observed in D state at probe time.
+	DProcesses []DProcess
+	threshold  time.Duration
+}
+
+// HasStall reports whether this Status represents a storage stall: the
+// fsync probe exceeded the configured threshold, failed outright, or
+// some process was found blocked in D state.
+func (s Status) HasStall() bool {
+	return s.FsyncErr != nil || s.FsyncDuration > s.threshold || len(s.DProcesses) > 0
+}
+
+// CollectStatus probes fsync latency on persistDir and scans /proc for
+// D-state processes. threshold is the fsync duration above which the
+// probe is considered stalled.
+func CollectStatus(log *base.LogObject, persistDir string, threshold time.Duration) Status {
+	status := Status{threshold: threshold}
+	status.FsyncDuration, status.FsyncErr = probeFsync(persistDir)
+	status.DProcesses = collectDProcesses(log)
+	return status
+}
+
+// probeFsync writes a few bytes to a small file under dir and fsyncs it,
+// returning how long that took.
+func probeFsync(dir string) (time.Duration, error) {
+	path := dir + "/" + probeFileName
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	start := time.Now()
+	if _, err := f.Write([]byte(start.String())); err != nil {
+		return time.Since(start), err
+	}
+	if err := f.Sync(); err != nil {
+		return time.Since(start), err
+	}
+	return time.Since(start), nil
+}
+
+// collectDProcesses scans /proc/<pid>/stat for processes in the
+// uninterruptible-sleep (D) state. It never fails; processes that
+// disappear mid-scan, or a /proc without the expected layout, simply
+// yield fewer results.
+func collectDProcesses(log *base.LogObject) []DProcess {
+	var result []DProcess
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		log.Tracef("collectDProcesses: %s", err)
+		return result
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		contents, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			continue
+		}
+		comm, state, ok := parseProcStat(string(contents))
+		if !ok {
+			continue
+		}
+		if state == "D" {
+			result = append(result, DProcess{Pid: pid, Comm: comm})
+		}
+	}
+	return result
+}
+
+// parseProcStat extracts the comm and state fields from the contents of
+// a /proc/<pid>/stat file. comm is parenthesized and may itself contain
+// spaces, so it is located by the last ')' rather than by splitting on
+// spaces from the start.
+func parseProcStat(stat string) (comm string, state string, ok bool) {
+	open := strings.IndexByte(stat, '(')
+	closeParen := strings.LastIndexByte(stat, ')')
+	if open < 0 || closeParen < 0 || closeParen < open {
+		return "", "", false
+	}
+	comm = stat[open+1 : closeParen]
+	rest := strings.TrimSpace(stat[closeParen+1:])
+	fields := strings.Fields(rest)
+	if len(fields) < 1 {
+		return "", "", false
+	}
+	return comm, fields[0], true
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package storagestall
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseProcStat(t *testing.T) {
+	comm, state, ok := parseProcStat("1234 (some (weird) comm) S 1 1234 1234 0 -1 4194560 ...")
+	if !ok {
+		t.Fatalf("expected parseProcStat to succeed")
+	}
+	if comm != "some (weird) comm" {
+		t.Errorf("unexpected comm: %q", comm)
+	}
+	if state != "S" {
+		t.Errorf("unexpected state: %q", state)
+	}
+
+	if _, _, ok := parseProcStat("garbage with no parens"); ok {
+		t.Errorf("expected parseProcStat to fail on malformed input")
+	}
+}
+
+func TestHasStall(t *testing.T) {
+	notStalled := Status{FsyncDuration: time.Second, threshold: 20 * time.Second}
+	if notStalled.HasStall() {
+		t.Errorf("fsync well under threshold should not be a stall")
+	}
+
+	slowFsync := Status{FsyncDuration: 30 * time.Second, threshold: 20 * time.Second}
+	if !slowFsync.HasStall() {
+		t.Errorf("fsync over threshold should be a stall")
+	}
+
+	dState := Status{threshold: 20 * time.Second, DProcesses: []DProcess{{Pid: 1, Comm: "test"}}}
+	if !dState.HasStall() {
+		t.Errorf("a D-state process should be a stall")
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package swap implements configurable zram or swapfile-on-persist
+// support, so memory-constrained devices can survive transient memory
+// spikes instead of OOM-killing apps. Swap backing storage is never
+// placed under the vault, since vault-protected data must not be paged
+// out unencrypted.
+package swap
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// Backend selects how swap space is provided.
+type Backend string
+
+// Supported swap backends
+const (
+	BackendNone     Backend = ""
+	BackendZram     Backend = "zram"
+	BackendSwapfile Backend = "swapfile"
+)
+
+// Policy is the controller-configured swap policy for this device.
+type Policy struct {
+	Backend Backend
+	// SizeMB is the size of the zram device or swapfile in megabytes.
+	SizeMB uint64
+}
+
+const (
+	swapfileName  = "swapfile"
+	zramDevice    = "/dev/zram0"
+	zramSizeParam = "/sys/block/zram0/disksize"
+	zramResetFile = "/sys/block/zram0/reset"
+)
+
+// swapfilePath returns where the swapfile lives. It is deliberately
+// outside of vault.MountPoint + "/vault" so that enabling swap can never
+// page out vault-protected data in the clear.
+func swapfilePath() string {
+	return types.PersistDir + "/" + swapfileName
+}
+
+// Apply enables or disables swap according to policy, tearing down
+// whichever backend was previously active first.
+func Apply(log *base.LogObject, policy Policy) error {
+	if err := disableAll(log); err != nil {
+		log.Warnf("swap.Apply: failed to disable existing swap: %s", err)
+	}
+	switch policy.Backend {
+	case BackendNone:
+		return nil
+	case BackendZram:
+		return enableZram(log, policy.SizeMB)
+	case BackendSwapfile:
+		return enableSwapfile(log, policy.SizeMB)
+	default:
+		return fmt.Errorf("swap.Apply: unknown backend %q", policy.Backend)
+	}
+}
+
+func disableAll(log *base.LogObject) error {
+	// swapoff is harmless to call even when nothing is swapped on.
+	_, _ = base.Exec(log, "swapoff", swapfilePath()).CombinedOutput()
+	_, _ = base.Exec(log, "swapoff", zramDevice).CombinedOutput()
+	return nil
+}
+
+func enableZram(log *base.LogObject, sizeMB uint64) error {
+	sizeBytes := sizeMB * 1024 * 1024
+	if err := ioutil.WriteFile(zramSizeParam, []byte(fmt.Sprintf("%d", sizeBytes)), 0644); err != nil {
+		return fmt.Errorf("enableZram: setting disksize: %w", err)
+	}
+	if out, err := base.Exec(log, "mkswap", zramDevice).CombinedOutput(); err != nil {
+		return fmt.Errorf("enableZram: mkswap failed: %w, %s", err, out)
+	}
+	if out, err := base.Exec(log, "swapon", "-p", "100", zramDevice).CombinedOutput(); err != nil {
+		return fmt.Errorf("enableZram: swapon failed: %w, %s", err, out)
+	}
+	return nil
+}
+
+func enableSwapfile(log *base.LogObject, sizeMB uint64) error {
+	path := swapfilePath()
+	if out, err := base.Exec(log, "fallocate", "-l", fmt.Sprintf("%dM", sizeMB), path).CombinedOutput(); err != nil {
+		return fmt.Errorf("enableSwapfile: fallocate failed: %w, %s", err, out)
+	}
+	if out, err := base.Exec(log, "chmod", "600", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("enableSwapfile: chmod failed: %w, %s", err, out)
+	}
+	if out, err := base.Exec(log, "mkswap", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("enableSwapfile: mkswap failed: %w, %s", err, out)
+	}
+	if out, err := base.Exec(log, "swapon", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("enableSwapfile: swapon failed: %w, %s", err, out)
+	}
+	return nil
+}
+
+// Usage is the device's current swap accounting, as reported in
+// /proc/meminfo.
+type Usage struct {
+	TotalKB uint64
+	FreeKB  uint64
+}
+
+// GetUsage parses SwapTotal/SwapFree out of /proc/meminfo for inclusion
+// in device metrics.
+func GetUsage(log *base.LogObject) (Usage, error) {
+	var usage Usage
+	contents, err := ioutil.ReadFile("/proc/meminfo")
+	if err != nil {
+		return usage, err
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "SwapTotal:":
+			fmt.Sscanf(fields[1], "%d", &usage.TotalKB)
+		case "SwapFree:":
+			fmt.Sscanf(fields[1], "%d", &usage.FreeKB)
+		}
+	}
+	return usage, nil
+}
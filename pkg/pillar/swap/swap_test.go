@@ -0,0 +1,17 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package swap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lf-edge/eve/pkg/pillar/vault"
+)
+
+func TestSwapfileNotUnderVault(t *testing.T) {
+	if strings.HasPrefix(swapfilePath(), vault.MountPoint+"/vault") {
+		t.Errorf("swapfile path %s must not live under the vault", swapfilePath())
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package taskmonitor implements the restart-backoff policy for
+// containerd tasks: given a Policy and the outcome of the most recent run,
+// it decides whether a task should be restarted and, if so, how long to
+// wait before doing so. It is deliberately independent of containerd and
+// pubsub - containerd.Client owns watching a task's actual exit and
+// restarting it, and cmd/domainmgr owns publishing restart state - so the
+// policy itself can be tested without either.
+package taskmonitor
+
+import "time"
+
+// Policy mirrors the restart policies common to container runtimes
+// (Docker, Kubernetes): never restart, restart only on a non-zero exit,
+// or always restart.
+type Policy uint8
+
+const (
+	// PolicyNever never restarts an exited task.
+	PolicyNever Policy = iota
+	// PolicyOnFailure restarts only when the task exited with a non-zero
+	// status.
+	PolicyOnFailure
+	// PolicyAlways restarts regardless of exit status.
+	PolicyAlways
+)
+
+const (
+	// initialBackoff is the delay before the first automatic restart.
+	initialBackoff = time.Second
+	// maxBackoff caps the exponential backoff between restarts, so a
+	// task stuck in a crash loop settles at retrying once every 5
+	// minutes rather than spinning the host or backing off forever.
+	maxBackoff = 5 * time.Minute
+)
+
+// State tracks the restart history for a single task, so repeated
+// failures back off instead of being retried in a tight loop.
+type State struct {
+	RestartCount int
+	LastExitCode int
+	LastExitAt   time.Time
+}
+
+// ShouldRestart reports whether policy calls for restarting a task that
+// just exited with exitCode, given s's exit history (already updated via
+// RecordExit).
+func (s *State) ShouldRestart(policy Policy) bool {
+	switch policy {
+	case PolicyAlways:
+		return true
+	case PolicyOnFailure:
+		return s.LastExitCode != 0
+	default:
+		return false
+	}
+}
+
+// Backoff returns how long to wait before the next restart, given the
+// restarts already recorded in s. It doubles with each consecutive
+// restart, up to maxBackoff.
+func (s *State) Backoff() time.Duration {
+	if s.RestartCount <= 0 {
+		return initialBackoff
+	}
+	if s.RestartCount >= 32 { // guard against overflow from a long crash loop
+		return maxBackoff
+	}
+	if backoff := initialBackoff << uint(s.RestartCount); backoff > 0 && backoff < maxBackoff {
+		return backoff
+	}
+	return maxBackoff
+}
+
+// RecordExit updates s after a task exit with exitCode at now, so the
+// next ShouldRestart/Backoff call reflects it.
+func (s *State) RecordExit(exitCode int, now time.Time) {
+	s.LastExitCode = exitCode
+	s.LastExitAt = now
+	s.RestartCount++
+}
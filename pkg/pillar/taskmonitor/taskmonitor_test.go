@@ -0,0 +1,56 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package taskmonitor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShouldRestart(t *testing.T) {
+	testCases := []struct {
+		policy   Policy
+		exitCode int
+		want     bool
+	}{
+		{PolicyNever, 0, false},
+		{PolicyNever, 1, false},
+		{PolicyOnFailure, 0, false},
+		{PolicyOnFailure, 1, true},
+		{PolicyAlways, 0, true},
+		{PolicyAlways, 1, true},
+	}
+	for _, tc := range testCases {
+		var s State
+		s.RecordExit(tc.exitCode, time.Now())
+		if got := s.ShouldRestart(tc.policy); got != tc.want {
+			t.Errorf("policy %v exitCode %d: ShouldRestart() = %v, want %v",
+				tc.policy, tc.exitCode, got, tc.want)
+		}
+	}
+}
+
+func TestBackoffGrowsAndCaps(t *testing.T) {
+	var s State
+	now := time.Now()
+	prev := time.Duration(0)
+	for i := 0; i < 10; i++ {
+		s.RecordExit(1, now)
+		backoff := s.Backoff()
+		if backoff < prev {
+			t.Errorf("restart %d: backoff %v is less than previous %v", i, backoff, prev)
+		}
+		if backoff > maxBackoff {
+			t.Errorf("restart %d: backoff %v exceeds maxBackoff %v", i, backoff, maxBackoff)
+		}
+		prev = backoff
+	}
+}
+
+func TestBackoffCapsOnLongCrashLoop(t *testing.T) {
+	s := State{RestartCount: 1000}
+	if backoff := s.Backoff(); backoff != maxBackoff {
+		t.Errorf("Backoff() after 1000 restarts = %v, want %v", backoff, maxBackoff)
+	}
+}
@@ -13,6 +13,7 @@ package types
 
 import (
 	"strings"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	zcommon "github.com/lf-edge/eve/api/go/evecommon"
@@ -20,6 +21,32 @@ import (
 	"github.com/satori/go.uuid"
 )
 
+// IoBundleAssignmentState is a PCI adapter's position in the bind/unbind
+// state machine, as recorded by the assignments package.
+type IoBundleAssignmentState string
+
+const (
+	// IoBundleAssignmentFree - not bound to the passthrough driver.
+	IoBundleAssignmentFree IoBundleAssignmentState = "free"
+	// IoBundleAssignmentBound - bound to the passthrough driver and
+	// available to be handed to a domain.
+	IoBundleAssignmentBound IoBundleAssignmentState = "bound"
+	// IoBundleAssignmentError - the last bind/unbind/reset attempt
+	// failed; IoBundleAssignmentStatus.Error carries the reason.
+	IoBundleAssignmentError IoBundleAssignmentState = "error"
+)
+
+// IoBundleAssignmentStatus records the outcome of the most recent
+// bind/unbind/reset attempt made by the assignments package against this
+// bundle's PciLong, so a failure shows up against this specific adapter
+// instead of a generic domain error.
+type IoBundleAssignmentStatus struct {
+	State     IoBundleAssignmentState
+	Operation string // "bind", "unbind", "reset" or "dry-run"
+	Error     string // empty on success
+	At        time.Time
+}
+
 type AssignableAdapters struct {
 	Initialized  bool
 	IoBundleList []IoBundle
@@ -80,6 +107,11 @@ type IoBundle struct {
 	//  If the device is ( or to be ) managed by DomU, this is True
 	IsPCIBack bool // Assigned to pciback
 	IsPort    bool // Whole or part of the bundle is a zedrouter port
+
+	// AssignmentStatus is the outcome of the most recent bind/unbind/reset
+	// attempt the assignments package made against PciLong on behalf of
+	// this bundle. Zero value means no attempt has been made yet.
+	AssignmentStatus IoBundleAssignmentStatus
 }
 
 // Really a constant
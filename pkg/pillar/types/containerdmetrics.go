@@ -0,0 +1,28 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import "time"
+
+// ContainerdCallMetrics holds counters and latency accumulation for one
+// containerd.Client API call (e.g. "WriteBlob", "PrepareSnapshot", "Exec"),
+// as observed by the client instrumenting its own calls.
+type ContainerdCallMetrics struct {
+	CallCount  uint64
+	ErrorCount uint64
+	// TotalLatencyNsec and LastLatencyNsec are in nanoseconds;
+	// TotalLatencyNsec / CallCount gives the average.
+	TotalLatencyNsec uint64
+	LastLatencyNsec  uint64
+	LastError        string
+	LastErrorTime    time.Time
+	// ErrorsByCode counts errors by gRPC status code string (e.g.
+	// "Unavailable", "NotFound"), so a caller can tell a containerd
+	// daemon that is down apart from one call that legitimately failed.
+	ErrorsByCode map[string]uint64
+}
+
+// ContainerdMetrics is a snapshot of ContainerdCallMetrics for every
+// instrumented containerd.Client API call, keyed by call name.
+type ContainerdMetrics map[string]ContainerdCallMetrics
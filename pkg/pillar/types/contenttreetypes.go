@@ -5,6 +5,7 @@ package types
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	zconfig "github.com/lf-edge/eve/api/go/config"
@@ -110,9 +111,15 @@ type ContentTreeStatus struct {
 	DisplayName       string
 	HasResolverRef    bool
 	State             SwState
-	TotalSize         int64  // expected size as reported by the downloader, if any
-	CurrentSize       int64  // current total downloaded size as reported by the downloader
-	Progress          uint   // In percent i.e., 0-100
+	TotalSize         int64 // expected size as reported by the downloader, if any
+	CurrentSize       int64 // current total downloaded size as reported by the downloader
+	Progress          uint  // In percent i.e., 0-100
+	// ProgressDetail is the same progress, in the shared Progress shape,
+	// for controllers that understand it (phase, ETA, cancellable).
+	ProgressDetail Progress
+	// ProgressStartTime anchors ProgressDetail's ETA estimate; it is set
+	// once when the content tree first starts making progress.
+	ProgressStartTime time.Time
 	FileLocation      string // Location of filestystem
 	ObjType           string
 	NameIsURL         bool
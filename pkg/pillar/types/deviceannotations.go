@@ -0,0 +1,17 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+// DeviceAnnotations is a set of arbitrary key/value tags the controller has
+// attached to this device, e.g. site, rack or owner labels. They carry no
+// meaning to EVE itself; they are published so other agents (and, through
+// zedmanager's cloud-init template substitution, apps) can pick them up.
+//
+// Annotations arrive as ConfigItems in EdgeDevConfig whose Key has the
+// reserved "annotation." prefix -- see parseAnnotations in zedagent's
+// parseconfig.go -- since the device config API has no dedicated field for
+// them.
+type DeviceAnnotations struct {
+	Annotations map[string]string
+}
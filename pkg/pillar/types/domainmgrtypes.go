@@ -34,8 +34,211 @@ type DomainConfig struct {
 	// Container related info
 	IsContainer bool // Is this Domain for a Container?
 
+	// ResourceAlarms are copied from AppInstanceConfig.ResourceAlarms; see
+	// there for details.
+	ResourceAlarms []ResourceAlarmConfig
+
+	// RestartPolicy controls whether domainmgr automatically restarts
+	// this app's container task when it exits; see RestartPolicy. Only
+	// meaningful when IsContainer, and only enforced on hypervisor
+	// backends implementing hypervisor.TaskWatcher.
+	RestartPolicy RestartPolicy
+
+	// HealthProbe is copied from AppInstanceConfig.ContainerHealthProbe;
+	// see there for details. Only meaningful when IsContainer, and only
+	// enforced on hypervisor backends implementing
+	// hypervisor.HealthProber.
+	HealthProbe HealthProbeConfig
+
+	// EnableCheckpoint is copied from AppInstanceConfig.EnableCheckpoint;
+	// see there for details. Only meaningful when IsContainer, and only
+	// enforced on hypervisor backends implementing
+	// hypervisor.Checkpointer.
+	EnableCheckpoint bool
+
+	// QuiesceHook is copied from AppInstanceConfig.QuiesceHook; see there
+	// for details. Only meaningful when IsContainer and EnableCheckpoint
+	// is set, and only enforced on hypervisor backends implementing
+	// hypervisor.QuiesceHookRunner.
+	QuiesceHook QuiesceHookConfig
+
 	// CipherBlockStatus, for encrypted cloud-init data
 	CipherBlockStatus
+
+	// Critical is copied from AppInstanceConfig.Critical; see there for
+	// details.
+	Critical bool
+
+	// ApparmorProfile is copied from AppInstanceConfig.ApparmorProfile;
+	// see there for details. Only meaningful when IsContainer.
+	ApparmorProfile string
+
+	// SelinuxLabel is copied from AppInstanceConfig.SelinuxLabel; see
+	// there for details. Only meaningful when IsContainer.
+	SelinuxLabel string
+}
+
+// QuiesceHookConfig configures commands domainmgr execs inside a
+// container's task immediately before and after checkpointing it (see
+// maybeCheckpointContainer in cmd/domainmgr/checkpointwatch.go), so a
+// database or other stateful process can flush and pause writes before
+// the checkpoint is taken and resume normal operation once it completes.
+type QuiesceHookConfig struct {
+	// PreCommand runs before the checkpoint; the checkpoint is skipped
+	// if it returns a non-zero exit status.
+	PreCommand []string
+	// PostCommand runs after the checkpoint, whether or not it
+	// succeeded, as long as PreCommand ran and succeeded.
+	PostCommand []string
+	// Timeout bounds each command; defaults to quiesceHookDefaultTimeout
+	// (see cmd/domainmgr/checkpointwatch.go) if unset.
+	Timeout time.Duration
+}
+
+// HasHooks reports whether either hook command is configured.
+func (q QuiesceHookConfig) HasHooks() bool {
+	return len(q.PreCommand) > 0 || len(q.PostCommand) > 0
+}
+
+// QuiesceHookResult records the outcome of running one of
+// QuiesceHookConfig's commands.
+type QuiesceHookResult struct {
+	Ran      bool
+	Success  bool
+	Output   string
+	Error    string
+	At       time.Time
+	Duration time.Duration
+}
+
+// QuiesceHookStatus is domainmgr's locally-observed result of the most
+// recent pre/post quiesce hook run bracketing a container checkpoint,
+// published so zedagent/zedmanager and ultimately the controller can see
+// whether the app's data was quiesced before the snapshot was taken.
+type QuiesceHookStatus struct {
+	UUIDandVersion UUIDandVersion
+	Pre            QuiesceHookResult
+	Post           QuiesceHookResult
+}
+
+// Key uniquely identifies this status for pubsub.
+func (status QuiesceHookStatus) Key() string {
+	return status.UUIDandVersion.UUID.String()
+}
+
+// RestartPolicy controls whether domainmgr automatically restarts a
+// container task when it exits, mirroring the restart policies common to
+// container runtimes (Docker, Kubernetes), so critical app workloads
+// recover locally without a round trip through zedmanager/controller.
+type RestartPolicy uint8
+
+const (
+	// RestartPolicyNever never restarts an exited task; this is the
+	// default, matching pre-existing behavior for apps that don't set it.
+	RestartPolicyNever RestartPolicy = iota
+	// RestartPolicyOnFailure restarts only when the task exited with a
+	// non-zero status.
+	RestartPolicyOnFailure
+	// RestartPolicyAlways restarts regardless of exit status.
+	RestartPolicyAlways
+)
+
+// ContainerRestartStatus is domainmgr's locally-observed automatic-restart
+// history for an app's container task, published so zedagent/zedmanager
+// and ultimately the controller can see restart counts even though the
+// restarts themselves happen locally, without either one involved.
+type ContainerRestartStatus struct {
+	UUIDandVersion UUIDandVersion
+	RestartCount   int
+	LastExitCode   int
+	LastExitAt     time.Time
+	// LastRestartError is set if the most recent restart attempt itself
+	// failed, e.g. because the task could not be recreated.
+	LastRestartError string
+}
+
+// Key returns the pubsub key for ContainerRestartStatus.
+func (status ContainerRestartStatus) Key() string {
+	return status.UUIDandVersion.UUID.String()
+}
+
+// HealthProbeType selects how domainmgr checks a container's health,
+// mirroring healthprobe.Type (kept separate since types can't import
+// domainmgr's hypervisor/containerd dependencies).
+type HealthProbeType uint8
+
+const (
+	// HealthProbeTypeNone disables health probing; this is the default.
+	HealthProbeTypeNone HealthProbeType = iota
+	// HealthProbeTypeExec runs HealthProbeConfig.Exec inside the
+	// container; a zero exit code is a success.
+	HealthProbeTypeExec
+	// HealthProbeTypeTCP succeeds if a TCP connection to Port, from
+	// inside the container's own network namespace, can be established.
+	HealthProbeTypeTCP
+	// HealthProbeTypeHTTP succeeds if an HTTP GET of HTTPPath on Port,
+	// from inside the container's own network namespace, returns 2xx.
+	HealthProbeTypeHTTP
+)
+
+// HealthProbeConfig describes a scheduled health probe for a container,
+// run by domainmgr independently of AppInstanceConfig.HealthProbePort's
+// one-shot blue/green upgrade check. See healthprobe.Config, which this
+// is converted to.
+type HealthProbeConfig struct {
+	Type HealthProbeType
+	Exec []string
+	Port int
+	// HTTPPath is the path requested for HealthProbeTypeHTTP, e.g.
+	// "/healthz". Defaults to "/" if unset.
+	HTTPPath string
+	// Period is the time between probes; defaults to 10s if unset.
+	Period time.Duration
+	// Timeout bounds a single probe attempt; defaults to domainmgr's
+	// usual exec timeout if unset.
+	Timeout time.Duration
+	// SuccessThreshold/FailureThreshold default to 1 if unset.
+	SuccessThreshold int
+	FailureThreshold int
+	// RestartOnFailure asks domainmgr to restart the container's task
+	// once it becomes unhealthy, by stopping it so any RestartPolicy
+	// watch in effect restarts it; has no effect if RestartPolicy is
+	// RestartPolicyNever.
+	RestartOnFailure bool
+}
+
+// ContainerHealthStatus is domainmgr's locally-observed container health,
+// published so zedagent/zedmanager and ultimately the controller can
+// distinguish "task running" from "application healthy".
+type ContainerHealthStatus struct {
+	UUIDandVersion      UUIDandVersion
+	Healthy             bool
+	ConsecutiveFailures int
+	LastCheckAt         time.Time
+	LastError           string
+}
+
+// Key returns the pubsub key for ContainerHealthStatus.
+func (status ContainerHealthStatus) Key() string {
+	return status.UUIDandVersion.UUID.String()
+}
+
+// MeasurementLogEntry records one extension of evetpm.MeasurementPCRHdl
+// made by domainmgr for an app's launch, so zedagent/zedmanager and
+// ultimately the controller can see which workload digests contributed
+// to the current PCR value, not just its final quoted value.
+type MeasurementLogEntry struct {
+	UUIDandVersion UUIDandVersion
+	// ImageDigest is the "sha256:<hex>" digest of the app image config
+	// that was measured, e.g. from containerd.GetImageConfigDigest.
+	ImageDigest string
+	PCRIndex    uint8
+	MeasuredAt  time.Time
+}
+
+// Key returns the pubsub key for MeasurementLogEntry.
+func (entry MeasurementLogEntry) Key() string {
+	return entry.UUIDandVersion.UUID.String() + "#" + entry.ImageDigest
 }
 
 func (config DomainConfig) Key() string {
@@ -132,7 +335,84 @@ type VmConfig struct {
 	EnableVnc          bool
 	VncDisplay         uint32
 	VncPasswd          string
-}
+	// CVMType selects confidential VM support for this app, when the
+	// underlying host supports it (see hypervisor.Capabilities). Empty
+	// means no confidential compute is used.
+	CVMType CVMType
+	// CPUModel selects the qemu -cpu model exposed to the guest, e.g.
+	// "host" (passthrough) or a named model such as "IvyBridge".
+	// Default "" means the hypervisor's built-in default is used.
+	CPUModel string
+	// CPUFlagsAdd and CPUFlagsRemove add or remove individual CPU flags
+	// on top of CPUModel, e.g. CPUFlagsRemove=["avx"] to keep a licensed
+	// guest OS from keying its license check off a feature it doesn't
+	// expect, or CPUFlagsAdd=["avx512f"] to turn one on explicitly.
+	CPUFlagsAdd    []string
+	CPUFlagsRemove []string
+	// RTCUTC selects whether the emulated guest RTC runs in UTC (the
+	// default for Linux and most modern Windows installs) or localtime
+	// (legacy Windows default, which otherwise drifts by the host's UTC
+	// offset). kvmclock/PTP time is exposed to KVM guests unconditionally
+	// via the paravirtual clock device, independent of this setting.
+	RTCUTC bool
+	// ConsolePatterns are regexes evaluated against the app's console
+	// log; a match (e.g. a kernel panic string) is reported so basic
+	// in-guest failure detection works without an agent in the app.
+	ConsolePatterns []ConsolePatternRule
+	// OCIRuntime overrides the containerd shim runtime used for this
+	// app's container (see containerd.KataRuntime), e.g. to run an
+	// untrusted workload under kata-containers VM isolation while
+	// system containers stay on the default runc-backed shim. Empty
+	// means use the namespace's default runtime. Ignored if
+	// EnableGVisor is set.
+	OCIRuntime string
+	// EnableGVisor runs this eve-user-apps container's syscalls through
+	// the runsc sandboxed user-space kernel (see containerd.GvisorRuntime)
+	// instead of a bare runc container, for workloads that need stronger
+	// isolation at the syscall boundary than cgroups/namespaces alone but
+	// don't warrant Kata's heavier full-VM isolation.
+	EnableGVisor bool
+	// IvshmemDevices are the ivshmem shared memory channels this app
+	// attaches to, for low-latency data exchange with co-located apps
+	// (or host agents) without going through networking. See
+	// IvshmemConfig.
+	IvshmemDevices []IvshmemConfig
+}
+
+// IvshmemConfig configures one ivshmem-plain shared memory channel between
+// this app and every other participant (another app, or a host agent)
+// whose own IvshmemConfig names the same Name. Channel membership is a
+// controller policy decision, the same way ResourceAlarms or Critical are:
+// the device only exists because the controller configured matching Names
+// on both ends, not because of any on-device ACL.
+type IvshmemConfig struct {
+	// Name identifies the shared memory channel. All participants must
+	// use the same Name to attach to the same backing memory, and the
+	// same SizeMB -- domainmgr rejects a participant that disagrees with
+	// the SizeMB already in use for a Name.
+	Name string
+	// SizeMB is the size of the shared memory region, in megabytes. Must
+	// be a power of two, per qemu's ivshmem-plain memory-backend-file
+	// requirements.
+	SizeMB int
+}
+
+// ConsolePatternRule names a single regex to watch for in an app's
+// console output.
+type ConsolePatternRule struct {
+	Name    string
+	Pattern string
+}
+
+// CVMType identifies a confidential VM technology.
+type CVMType string
+
+// Supported confidential VM technologies
+const (
+	CVMTypeNone CVMType = ""
+	CVMTypeSEV  CVMType = "sev"
+	CVMTypeTDX  CVMType = "tdx"
+)
 
 type VmMode uint8
 
@@ -180,6 +460,10 @@ type DomainStatus struct {
 	AdaptersFailed bool
 	IsContainer    bool              // Is this Domain for a Container?
 	EnvVariables   map[string]string // List of environment variables to be set in container
+	// ConsolePatterns mirrors DomainConfig.ConsolePatterns so the
+	// console watcher can be (re)started across a boot retry, which
+	// only has access to DomainStatus.
+	ConsolePatterns []ConsolePatternRule
 }
 
 func (status DomainStatus) Key() string {
@@ -267,6 +551,20 @@ type VifInfo struct {
 	Vif     string
 	VifUsed string // Has -emu in name in Status if appropriate
 	Mac     string
+
+	// MacvlanParent, if non-empty, means Vif is not an existing host
+	// interface to move into the task's namespace but the name of a new
+	// macvlan sub-interface to create on top of the named parent.
+	MacvlanParent string
+	// NsVifName, if non-empty, renames Vif to this name once it is inside
+	// the task's network namespace.
+	NsVifName string
+	// Mtu, if non-zero, overrides the interface MTU inside the task's
+	// network namespace.
+	Mtu uint16
+	// IPAddrs are CIDRs (e.g. "10.1.1.5/24") assigned to the interface
+	// inside the task's network namespace.
+	IPAddrs []string
 }
 
 // DomainManager will pass these to the xen xl config file
@@ -280,6 +578,13 @@ type DiskConfig struct {
 	Format       zconfig.Format
 	MountDir     string
 	DisplayName  string
+	// HostDirSharePath mirrors VolumeRefStatus.HostDirSharePath: when
+	// non-empty, this disk is a host directory shared into the domain
+	// over virtiofs (via a virtiofsd.Daemon) rather than FileLocation
+	// being attached as a block device.
+	HostDirSharePath string
+	// HostDirShareCacheMode mirrors VolumeRefStatus.HostDirShareCacheMode.
+	HostDirShareCacheMode string
 }
 
 type DiskStatus struct {
@@ -290,6 +595,11 @@ type DiskStatus struct {
 	DisplayName  string
 	Devtype      string // XXX used internally by hypervisor; deprecate?
 	Vdev         string // Allocated
+	// HostDirSharePath mirrors DiskConfig.HostDirSharePath; see there for
+	// details. When set, Devtype is "virtiofs".
+	HostDirSharePath string
+	// HostDirShareCacheMode mirrors DiskConfig.HostDirShareCacheMode.
+	HostDirShareCacheMode string
 }
 
 // DomainMetric carries CPU and memory usage. UUID=devUUID for the dom0/host metrics overhead
@@ -299,6 +609,11 @@ type DomainMetric struct {
 	UsedMemory        uint32
 	AvailableMemory   uint32
 	UsedMemoryPercent float64
+	// GuestNetworkStats are per-interface counters collected inside the
+	// guest via the guest agent channel, when available. They are reported
+	// alongside the host-side VIF counters so operators can tell whether
+	// packet loss happens inside the guest or in the host datapath.
+	GuestNetworkStats []NetworkMetric
 }
 
 // Key returns the key for pubsub
@@ -27,6 +27,9 @@ const (
 	SenderStatusHashSizeError                          // senderCertHash length error
 	SenderStatusCertUnknownAuthority                   // device may miss proxy certificate for MiTM
 	SenderStatusCertUnknownAuthorityProxy              // device configed proxy, may miss proxy certificate for MiTM
+	SenderStatusCertExpired                            // server cert chain has an expired element; check device/cert clock skew
+	SenderStatusCertHostnameMismatch                   // server cert is not valid for the requested hostname
+	SenderStatusCertUnsupportedCiphers                 // TLS handshake failed negotiating a cipher suite
 )
 
 const (
@@ -59,7 +62,8 @@ func NewGlobalStatus() *GlobalStatus {
 }
 
 // setItemValue - Sets value for the key. Expects a valid key. asserts if
-//  the key is not found.
+//
+//	the key is not found.
 func (gs *GlobalStatus) setItemValue(key, value string) {
 	item := gs.ConfigItems[key]
 	item.Value = value
@@ -152,6 +156,26 @@ const (
 	Dom0DiskUsageMaxBytes GlobalSettingKey = "storage.dom0.disk.maxusagebytes"
 	// AppContainerStatsInterval - App Container Stats Collection
 	AppContainerStatsInterval GlobalSettingKey = "timer.appcontainer.stats.interval"
+	// VolumeCreateConcurrency global setting key: how many volumes
+	// volumemgr's background worker will create/destroy at once. Raising
+	// this shortens time-to-all-apps-running at boot on devices with many
+	// app volumes to bring up, at the cost of more concurrent disk/network
+	// I/O. Read once at startup; changing it takes effect on next restart.
+	VolumeCreateConcurrency GlobalSettingKey = "storage.create.concurrency"
+	// NetworkDiagBeaconInterval global setting key
+	NetworkDiagBeaconInterval GlobalSettingKey = "timer.network.diag.beacon.interval"
+	// CoreDumpQuotaBytes caps the total size, across all retained core
+	// files, of the coredump helper's capture directory
+	// (types.CoreDumpDirname). Oldest cores are deleted first to make
+	// room for new ones.
+	CoreDumpQuotaBytes GlobalSettingKey = "debug.coredump.quota.bytes"
+	// FlowlogSamplingFactor - only 1 in this many collected flow
+	// records is actually uploaded to the controller, to bound upload
+	// volume from high-cardinality flow logs. 1 means no sampling
+	// (every record is uploaded). The factor in effect is echoed back
+	// in each published types.IPFlow so downstream analytics can
+	// compensate for the records that were dropped.
+	FlowlogSamplingFactor GlobalSettingKey = "timer.flowlog.sampling.factor"
 
 	// Bool Items
 	// UsbAccess global setting key
@@ -160,6 +184,35 @@ const (
 	AllowAppVnc GlobalSettingKey = "app.allow.vnc"
 	// IgnoreDiskCheckForApps global setting key
 	IgnoreDiskCheckForApps GlobalSettingKey = "storage.apps.ignore.disk.check"
+	// LazyPullImages global setting key: when true, volumemgr tries the
+	// stargz/eStargz lazy-pulling snapshotter for eligible container
+	// images before falling back to a regular full pull.
+	LazyPullImages GlobalSettingKey = "storage.apps.lazypull"
+	// CoreDumpAgentsEnable global setting key: capture core dumps of
+	// crashing EVE agent processes.
+	CoreDumpAgentsEnable GlobalSettingKey = "debug.coredump.agents.enable"
+	// CoreDumpAppsEnable global setting key: capture core dumps of
+	// crashing native container app processes.
+	CoreDumpAppsEnable GlobalSettingKey = "debug.coredump.apps.enable"
+	// CoreDumpCompress global setting key: gzip-compress captured core
+	// files to save space in the quota set by CoreDumpQuotaBytes.
+	CoreDumpCompress GlobalSettingKey = "debug.coredump.compress"
+	// RecordConfigSequence global setting key: have zedagent save every
+	// controller config change it accepts, sanitized, as a numbered
+	// sequence under the checkpoint directory, so it can be replayed
+	// later to reproduce a controller-triggered device bug without the
+	// original controller.
+	RecordConfigSequence GlobalSettingKey = "debug.config.record.enable"
+	// RequireSignedImages global setting key: refuse to create an image
+	// in CAS (see imgverify) unless its detached signature verifies
+	// against one of the trust anchors in types.ImageSignTrustAnchorDirname.
+	RequireSignedImages GlobalSettingKey = "storage.require.signed.images"
+	// AppInstanceLocalOverrides global setting key: a JSON-encoded
+	// localoverride.overrides blob, applied on top of the per-app and
+	// per-volume config the controller otherwise sends, for fields the
+	// controller has no proto support for yet. See
+	// cmd/zedagent/localoverride.go.
+	AppInstanceLocalOverrides GlobalSettingKey = "app.instance.local.overrides"
 
 	// TriState Items
 	// NetworkFallbackAnyEth global setting key
@@ -176,6 +229,39 @@ const (
 	DefaultLogLevel GlobalSettingKey = "debug.default.loglevel"
 	// DefaultRemoteLogLevel global setting key
 	DefaultRemoteLogLevel GlobalSettingKey = "debug.default.remote.loglevel"
+	// WebhookNotificationURL is the HTTPS endpoint that device-local
+	// webhook notifications (e.g. app instance down) are POSTed to. Empty
+	// disables webhook notifications.
+	WebhookNotificationURL GlobalSettingKey = "webhook.notification.url"
+	// WebhookNotificationSecret is used to HMAC-sign the body of webhook
+	// notifications so the receiving endpoint can authenticate the device.
+	WebhookNotificationSecret GlobalSettingKey = "webhook.notification.secret"
+	// StorageStallPolicy controls how nodeagent reacts when the storage
+	// stall detector finds a hung /persist fsync or a D-state agent
+	// thread: "event" only logs it, "reboot" additionally reboots the
+	// device (recording the stall as the reboot reason). There is no
+	// failover/redundant-node concept in this tree to react with, so
+	// that policy value is not accepted.
+	StorageStallPolicy GlobalSettingKey = "storage.stall.policy"
+	// StorageStallThresholdSeconds is how long a /persist fsync may take
+	// before it is considered a stall.
+	StorageStallThresholdSeconds GlobalSettingKey = "storage.stall.threshold.seconds"
+	// NetworkDiagBeaconEndpoint is the host:port UDP rendezvous endpoint
+	// that the network diagnostics beacon sends its datagrams to once the
+	// controller has been unreachable for NetworkDiagBeaconInterval.
+	// Empty disables the beacon.
+	NetworkDiagBeaconEndpoint GlobalSettingKey = "network.diag.beacon.endpoint"
+	// NetworkDiagBeaconSecret is used to HMAC-sign the network
+	// diagnostics beacon datagram so the receiving endpoint can
+	// authenticate the device.
+	NetworkDiagBeaconSecret GlobalSettingKey = "network.diag.beacon.secret"
+	// StorageSnapshotterBackend overrides the containerd snapshotter
+	// used for app container volumes. Empty means auto-pick
+	// (overlayfs, or zfs if the persist filesystem is zfs);
+	// "devmapper" and "erofs" are supported alternatives for devices
+	// where overlayfs on persist performs poorly or lacks features
+	// those backends provide.
+	StorageSnapshotterBackend GlobalSettingKey = "storage.snapshotter.backend"
 )
 
 // AgentSettingKey - keys for per-agent settings
@@ -325,7 +411,8 @@ func (specMap *ConfigItemSpecMap) AddAgentSettingStringItem(key AgentSettingKey,
 }
 
 // parseAgentSettingKey
-//  Returns AgentName, AgentSettingKey, error ( nil if success )
+//
+//	Returns AgentName, AgentSettingKey, error ( nil if success )
 func parseAgentSettingKey(key string) (string, AgentSettingKey, error) {
 	// Check new Agent Key Setting
 	re := regexp.MustCompile(agentSettingKeyPattern)
@@ -378,8 +465,9 @@ func (specMap *ConfigItemSpecMap) parseAgentItem(
 }
 
 // ParseItem - Parses the Key/Value pair into a ConfigItem and updates
-//  newConfigMap. If there is a Parse error, it copies the corresponding value
-//  from oldConfigMap
+//
+//	newConfigMap. If there is a Parse error, it copies the corresponding value
+//	from oldConfigMap
 func (specMap *ConfigItemSpecMap) ParseItem(newConfigMap *ConfigItemValueMap,
 	oldConfigMap *ConfigItemValueMap,
 	key string, value string) (ConfigItemValue, error) {
@@ -704,18 +792,32 @@ func NewConfigItemSpecMap() ConfigItemSpecMap {
 	configItemSpecMap.AddIntItem(NetworkTestDuration, 30, 10, 0xFFFFFFFF)
 	configItemSpecMap.AddIntItem(NetworkTestInterval, 300, 300, 0xFFFFFFFF)
 	configItemSpecMap.AddIntItem(NetworkTestBetterInterval, 600, 0, 0xFFFFFFFF)
+	configItemSpecMap.AddIntItem(StorageStallThresholdSeconds, 20, 1, HourInSec)
 	configItemSpecMap.AddIntItem(NetworkTestTimeout, 15, 0, 0xFFFFFFFF)
 	configItemSpecMap.AddIntItem(NetworkSendTimeout, 120, 0, 0xFFFFFFFF)
 	configItemSpecMap.AddIntItem(Dom0MinDiskUsagePercent, 20, 20, 80)
 	configItemSpecMap.AddIntItem(AppContainerStatsInterval, 300, 1, 0xFFFFFFFF)
+	configItemSpecMap.AddIntItem(NetworkDiagBeaconInterval, 4*HourInSec, 0, 0xFFFFFFFF)
+	// CoreDumpQuotaBytes - default is 200MB
+	configItemSpecMap.AddIntItem(CoreDumpQuotaBytes, 200*1024*1024, 0, 0xFFFFFFFF)
+	// FlowlogSamplingFactor - default is 1, i.e. no sampling
+	configItemSpecMap.AddIntItem(FlowlogSamplingFactor, 1, 1, 1000000)
 	// Dom0DiskUsageMaxBytes - Default is 2GB, min is 100MB
 	configItemSpecMap.AddIntItem(Dom0DiskUsageMaxBytes, 2*1024*1024*1024,
 		100*1024*1024, 0xFFFFFFFF)
+	// VolumeCreateConcurrency - default is 4, min 1 (serial), max 32
+	configItemSpecMap.AddIntItem(VolumeCreateConcurrency, 4, 1, 32)
 
 	// Add Bool Items
 	configItemSpecMap.AddBoolItem(UsbAccess, true) // Controller likely default to false
 	configItemSpecMap.AddBoolItem(AllowAppVnc, false)
 	configItemSpecMap.AddBoolItem(IgnoreDiskCheckForApps, false)
+	configItemSpecMap.AddBoolItem(LazyPullImages, false)
+	configItemSpecMap.AddBoolItem(CoreDumpAgentsEnable, false)
+	configItemSpecMap.AddBoolItem(CoreDumpAppsEnable, false)
+	configItemSpecMap.AddBoolItem(CoreDumpCompress, true)
+	configItemSpecMap.AddBoolItem(RequireSignedImages, false)
+	configItemSpecMap.AddBoolItem(RecordConfigSequence, false)
 
 	// Add TriState Items
 	configItemSpecMap.AddTriStateItem(NetworkFallbackAnyEth, TS_ENABLED)
@@ -726,6 +828,13 @@ func NewConfigItemSpecMap() ConfigItemSpecMap {
 	configItemSpecMap.AddStringItem(SSHAuthorizedKeys, "", blankValidator)
 	configItemSpecMap.AddStringItem(DefaultLogLevel, "info", parseLevel)
 	configItemSpecMap.AddStringItem(DefaultRemoteLogLevel, "info", parseLevel)
+	configItemSpecMap.AddStringItem(WebhookNotificationURL, "", blankValidator)
+	configItemSpecMap.AddStringItem(WebhookNotificationSecret, "", blankValidator)
+	configItemSpecMap.AddStringItem(StorageStallPolicy, "event", parseStorageStallPolicy)
+	configItemSpecMap.AddStringItem(NetworkDiagBeaconEndpoint, "", blankValidator)
+	configItemSpecMap.AddStringItem(NetworkDiagBeaconSecret, "", blankValidator)
+	configItemSpecMap.AddStringItem(StorageSnapshotterBackend, "", parseSnapshotterBackend)
+	configItemSpecMap.AddStringItem(AppInstanceLocalOverrides, "", blankValidator)
 
 	// Add Agent Settings
 	configItemSpecMap.AddAgentSettingStringItem(LogLevel, "info", parseLevel)
@@ -745,6 +854,27 @@ func blankValidator(s string) error {
 	return nil
 }
 
+func parseStorageStallPolicy(policy string) error {
+	switch policy {
+	case "event", "reboot":
+		return nil
+	default:
+		return fmt.Errorf("unknown storage stall policy %s", policy)
+	}
+}
+
+// parseSnapshotterBackend validates StorageSnapshotterBackend. "" is
+// valid and means "let the device auto-pick based on persist storage
+// type", matching today's behavior.
+func parseSnapshotterBackend(backend string) error {
+	switch backend {
+	case "", "overlayfs", "zfs", "devmapper", "erofs":
+		return nil
+	default:
+		return fmt.Errorf("unknown storage snapshotter backend %s", backend)
+	}
+}
+
 // NewConfigItemValueMap - Create new instance of ConfigItemValueMap
 func NewConfigItemValueMap() *ConfigItemValueMap {
 	var valueMap ConfigItemValueMap
@@ -163,13 +163,24 @@ func TestNewConfigItemSpecMap(t *testing.T) {
 		NetworkTestBetterInterval,
 		NetworkTestTimeout,
 		NetworkSendTimeout,
+		StorageStallThresholdSeconds,
 		Dom0MinDiskUsagePercent,
 		AppContainerStatsInterval,
 		Dom0DiskUsageMaxBytes,
+		NetworkDiagBeaconInterval,
+		CoreDumpQuotaBytes,
+		FlowlogSamplingFactor,
+		VolumeCreateConcurrency,
 		// Bool Items
 		UsbAccess,
 		AllowAppVnc,
 		IgnoreDiskCheckForApps,
+		LazyPullImages,
+		CoreDumpAgentsEnable,
+		CoreDumpAppsEnable,
+		CoreDumpCompress,
+		RequireSignedImages,
+		RecordConfigSequence,
 		// TriState Items
 		NetworkFallbackAnyEth,
 		AllowNonFreeAppImages,
@@ -178,6 +189,13 @@ func TestNewConfigItemSpecMap(t *testing.T) {
 		SSHAuthorizedKeys,
 		DefaultLogLevel,
 		DefaultRemoteLogLevel,
+		WebhookNotificationURL,
+		WebhookNotificationSecret,
+		StorageStallPolicy,
+		NetworkDiagBeaconEndpoint,
+		NetworkDiagBeaconSecret,
+		StorageSnapshotterBackend,
+		AppInstanceLocalOverrides,
 	}
 	if len(specMap.GlobalSettings) != len(gsKeys) {
 		t.Errorf("GlobalSettings has more (%d) than expected keys (%d)",
@@ -262,7 +280,8 @@ func (testPtr *parseItemTestEntry) configItemValue(
 }
 
 // Verify Expected value is same as Actual value - both returned
-//  value as well as one in newGlobalConfig
+//
+//	value as well as one in newGlobalConfig
 func (testPtr *parseItemTestEntry) verifyEntry(t *testing.T, testname string,
 	newGlobalConfig *ConfigItemValueMap, val ConfigItemValue) {
 	// Verify Expected value is same as Actual value - both returned
@@ -367,9 +386,10 @@ func TestParseGlobalItem(t *testing.T) {
 }
 
 // Test ParseItem for Agent Settings
-//  Verify both new and Legacy settings are parsed correctly
-//  Verify Unknown settings ( New and Legacy ) are rejected
-//  Verify Invalid Values for known settings are rejected and old value retained
+//
+//	Verify both new and Legacy settings are parsed correctly
+//	Verify Unknown settings ( New and Legacy ) are rejected
+//	Verify Invalid Values for known settings are rejected and old value retained
 func TestParseAgentItem(t *testing.T) {
 	// log.SetLevel(log.TraceLevel)
 	specMap := NewConfigItemSpecMap()
@@ -0,0 +1,29 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import "time"
+
+// HeartBeat is published periodically by a pillar agent to show that its
+// main loop is still making progress. It complements the local watchdog
+// touch file written by pubsub.PubSub.StillRunning with the build and
+// timing detail needed to tell a wedged-but-not-crashed agent apart from
+// a merely quiet one -- see agentbase.Context.PublishHeartBeat, which is
+// the only publisher, and handleHeartBeatModify in zedagent, which is the
+// only consumer today.
+type HeartBeat struct {
+	AgentName string
+	Version   string
+	BootTime  time.Time
+	// LastBeat is when this HeartBeat was published.
+	LastBeat time.Time
+	// LoopDuration is how long it took the agent to get back around to
+	// publishing this HeartBeat since the previous one.
+	LoopDuration time.Duration
+}
+
+// Key for pubsub
+func (hb HeartBeat) Key() string {
+	return hb.AgentName
+}
@@ -63,6 +63,12 @@ func pciLongExists(long string) bool {
 
 }
 
+// PciLongExists reports whether a PCI device with the given long address
+// (Domain:Bus:Device.Function) is currently present under /sys/bus/pci.
+func PciLongExists(long string) bool {
+	return pciLongExists(long)
+}
+
 // Return a string likely to be unique for the device.
 // Used to make sure devices don't move around
 // Returns exist bool, string
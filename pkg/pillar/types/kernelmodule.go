@@ -0,0 +1,91 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"github.com/lf-edge/eve/pkg/pillar/base"
+)
+
+// KernelModuleAction - whether a kernel module is explicitly allowed,
+// explicitly denied, or left to the default (load on demand, e.g. when
+// an IoAdapter using it gets assigned to an app instance).
+type KernelModuleAction uint8
+
+// Kernel module policy actions
+const (
+	KernelModuleActionDefault KernelModuleAction = iota
+	KernelModuleActionAllow
+	KernelModuleActionDeny
+)
+
+// KernelModulePolicy - controller-configured policy for a single kernel
+// module, e.g. i915, gasket or apex (tpu).
+type KernelModulePolicy struct {
+	ModuleName string
+	Action     KernelModuleAction
+}
+
+// KernelModulePolicyList - the full set of kernel module policies
+// received from the controller, plus EVE's view of which of those
+// modules are currently loaded.
+type KernelModulePolicyList struct {
+	Policies []KernelModulePolicy
+	// Loaded - modules EVE has loaded as a result of this policy and an
+	// IoAdapter assignment; reported back so the controller can confirm
+	// the running state matches the requested policy.
+	Loaded []string
+}
+
+// Key returns the key for pubsub
+func (kmp KernelModulePolicyList) Key() string {
+	return "global"
+}
+
+// LogCreate :
+func (kmp KernelModulePolicyList) LogCreate(logBase *base.LogObject) {
+	logObject := base.NewLogObject(logBase, base.KernelModulePolicyLogType, "",
+		nilUUID, kmp.LogKey())
+	if logObject == nil {
+		return
+	}
+	logObject.Metricf("Kernel module policy create")
+}
+
+// LogModify :
+func (kmp KernelModulePolicyList) LogModify(logBase *base.LogObject, old interface{}) {
+	logObject := base.EnsureLogObject(logBase, base.KernelModulePolicyLogType, "",
+		nilUUID, kmp.LogKey())
+
+	oldKmp, ok := old.(KernelModulePolicyList)
+	if !ok {
+		logObject.Clone().Fatalf("LogModify: Old object interface passed is not of KernelModulePolicyList type")
+	}
+	_ = oldKmp
+	logObject.Metricf("Kernel module policy modify")
+}
+
+// LogDelete :
+func (kmp KernelModulePolicyList) LogDelete(logBase *base.LogObject) {
+	logObject := base.EnsureLogObject(logBase, base.KernelModulePolicyLogType, "",
+		nilUUID, kmp.LogKey())
+	logObject.Metricf("Kernel module policy delete")
+	base.DeleteLogObject(logBase, kmp.LogKey())
+}
+
+// LogKey :
+func (kmp KernelModulePolicyList) LogKey() string {
+	return string(base.KernelModulePolicyLogType) + "-" + kmp.Key()
+}
+
+// Allowed returns whether moduleName may be loaded under this policy.
+// Modules with no explicit policy entry default to allowed, matching
+// EVE's existing on-demand modprobe behavior.
+func (kmp KernelModulePolicyList) Allowed(moduleName string) bool {
+	for _, p := range kmp.Policies {
+		if p.ModuleName == moduleName {
+			return p.Action != KernelModuleActionDeny
+		}
+	}
+	return true
+}
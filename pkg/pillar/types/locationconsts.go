@@ -25,6 +25,26 @@ const (
 	VolumeClearDirName = ClearDirName + "/volumes"
 	// PersistDebugDir - Location for service specific debug/traces
 	PersistDebugDir = PersistDir + "/agentdebug"
+	// CoreDumpDirname - Location where the coredump helper stores
+	// captured core files (and their symbolization metadata sidecars)
+	// for EVE agents and app containers, for later retrieval via a
+	// support bundle.
+	CoreDumpDirname = PersistDir + "/coredumps"
+	// CoreDumpConfigFile - where zedagent mirrors the current
+	// core-dump-related GlobalConfig settings, for the coredump helper
+	// (invoked synchronously by the kernel on every crash) to read
+	// without paying the cost of a pubsub subscription handshake.
+	CoreDumpConfigFile = "/run/coredump.json"
+	// ContainerCheckpointDirname - Location for CRIU checkpoints of
+	// stateful app containers, so they can be restored across an EVE
+	// reboot or migrated to another device
+	ContainerCheckpointDirname = PersistDir + "/checkpoints"
+	// DnsmasqLeaseDirname - Location of dnsmasq's per-bridge DHCP lease
+	// databases for network instances, one file per bridge. Kept under
+	// /persist, rather than zedrouter's /var/run scratch dir, so app
+	// instances get back the same IP address across an EVE reboot
+	// instead of racing the controller's static IP assignment.
+	DnsmasqLeaseDirname = PersistDir + "/dnsmasq.leases"
 
 	// IdentityDirname - Config dir
 	IdentityDirname = "/config"
@@ -56,6 +76,11 @@ const (
 	// ShareCertDirname - directory to place private proxy server certificates
 	ShareCertDirname = "/usr/local/share/ca-certificates"
 
+	// ImageSignTrustAnchorDirname - directory of PEM-encoded public keys
+	// (one per file) the controller has provisioned as trust anchors for
+	// verifying detached image signatures. See imgverify.LoadTrustAnchors.
+	ImageSignTrustAnchorDirname = IdentityDirname + "/image-sign-trust-anchors"
+
 	// AppImgObj - name of app image type
 	AppImgObj = "appImg.obj"
 	// BaseOsObj - name of base image type
@@ -161,6 +161,53 @@ const (
 	NoHash = "sha"
 )
 
+// Progress is a shared progress-reporting shape for long-running
+// operations (download, verification, unpack, volume creation,
+// snapshot, baseos update, ...) so status objects can surface progress
+// to the controller in a uniform way instead of each flow inventing its
+// own percent/size fields.
+//
+// Adoption is intentionally incremental: today only ContentTreeStatus
+// populates this (see doUpdateContentTree in volumemgr), alongside its
+// pre-existing Progress/TotalSize/CurrentSize fields which are kept for
+// backward compatibility. Other flows can adopt it the same way.
+type Progress struct {
+	// Phase is a short human-readable label for the current step, e.g.
+	// "DOWNLOADING" or "VERIFYING".
+	Phase string
+	// Percent is 0-100; zero if TotalBytes is unknown.
+	Percent      uint
+	CurrentBytes int64
+	TotalBytes   int64
+	// ETA is the estimated time remaining, extrapolated from the
+	// average rate since StartTime. Zero if it cannot be estimated yet.
+	ETA time.Duration
+	// Cancellable reports whether the operation can still be aborted by
+	// the caller in its current phase.
+	Cancellable bool
+}
+
+// NewProgress computes a Progress snapshot for an operation that started
+// at startTime and has moved currentBytes of totalBytes so far.
+func NewProgress(phase string, currentBytes, totalBytes int64, startTime time.Time, cancellable bool) Progress {
+	p := Progress{
+		Phase:        phase,
+		CurrentBytes: currentBytes,
+		TotalBytes:   totalBytes,
+		Cancellable:  cancellable,
+	}
+	if totalBytes > 0 {
+		p.Percent = uint(100 * currentBytes / totalBytes)
+	}
+	if elapsed := time.Since(startTime); elapsed > 0 && currentBytes > 0 && totalBytes > currentBytes {
+		rate := float64(currentBytes) / elapsed.Seconds()
+		if rate > 0 {
+			p.ETA = time.Duration(float64(totalBytes-currentBytes)/rate) * time.Second
+		}
+	}
+	return p
+}
+
 // Used to retain UUID to integer maps across reboots.
 // Used for appNum and bridgeNum
 type UuidToNum struct {
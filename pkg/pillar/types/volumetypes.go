@@ -25,6 +25,28 @@ type VolumeConfig struct {
 	GenerationCounter       int64
 	VolumeDir               string
 	DisplayName             string
+	// ClonedFromKey, when non-empty, is the Key() of another VolumeStatus
+	// whose on-disk contents should be copied into this volume instead of
+	// extracting ContentID, so a new app instance can be instantiated
+	// from an existing app's volumes without re-downloading images.
+	ClonedFromKey string
+	// PhysicalDevicePath, when non-empty, names a host block device (a
+	// whole disk, e.g. /dev/sdb, or a partition, e.g. /dev/nvme0n1p3) to
+	// hand to the app as-is instead of creating a backing file from
+	// ContentID. volumemgr claims the device exclusively (see
+	// pkg/pillar/blockvol) and refuses to assign one already backing
+	// /persist. Mutually exclusive with ContentID/ClonedFromKey.
+	PhysicalDevicePath string
+	// HostDirSharePath, when non-empty, names a host directory to share
+	// into the app over virtiofs (see pkg/pillar/virtiofsd) instead of
+	// creating a backing file from ContentID, for POSIX-correct,
+	// higher-performance data exchange than a 9p mount. Mutually
+	// exclusive with ContentID/ClonedFromKey/PhysicalDevicePath.
+	HostDirSharePath string
+	// HostDirShareCacheMode is the virtiofsd cache mode (see
+	// virtiofsd.CacheMode) to use for HostDirSharePath. Defaults to
+	// virtiofsd.CacheAuto if empty.
+	HostDirShareCacheMode string
 }
 
 // Key is volume UUID which will be unique
@@ -115,10 +137,55 @@ type VolumeStatus struct {
 	LastUse                 time.Time
 	PreReboot               bool // Was volume last use prior to device reboot?
 	ReferenceName           string
+	ClonedFromKey           string
+	// RepairCount is incremented each time volumemgr detects that this
+	// volume's backing file was corrupted on disk and transparently
+	// recreated it from the still-verified content tree; see
+	// checkAndRepairVolumes in cmd/volumemgr.
+	RepairCount int
+	// LastRepairTime is when RepairCount was last incremented.
+	LastRepairTime time.Time
+	// PhysicalDevicePath mirrors VolumeConfig.PhysicalDevicePath; see
+	// there for details. When set, FileLocation is this same path once
+	// VolumeCreated, rather than a path under VolumeDir.
+	PhysicalDevicePath string
+	// HostDirSharePath mirrors VolumeConfig.HostDirSharePath; see there
+	// for details. When set, FileLocation is this same path once
+	// VolumeCreated, rather than a path under VolumeDir.
+	HostDirSharePath string
+	// HostDirShareCacheMode mirrors VolumeConfig.HostDirShareCacheMode.
+	HostDirShareCacheMode string
+
+	// BackingChainDepth is how many qcow2 images deep this volume's
+	// backing chain is (see diskmetrics.BackingChainLength), 0 if
+	// FileLocation isn't a qcow2 overlay of anything. Maintained by
+	// checkAndFlattenBackingChains, which flattens chains that grow past
+	// diskmetrics.MaxBackingChainDepth.
+	BackingChainDepth int
+	// UniqueBytes and SharedBytes break FileLocation's on-disk footprint
+	// down into bytes allocated in this volume's own image versus bytes
+	// allocated in the backing images it shares with other volumes (see
+	// diskmetrics.ChainUsage); both are 0 until BackingChainDepth > 0.
+	UniqueBytes uint64
+	SharedBytes uint64
 
 	ErrorAndTimeWithSource
 }
 
+// IsPhysicalDevice reports whether this volume is a raw passthrough of a
+// host block device (see PhysicalDevicePath) rather than a backing file
+// created from ContentID.
+func (status VolumeStatus) IsPhysicalDevice() bool {
+	return status.PhysicalDevicePath != ""
+}
+
+// IsHostDirShare reports whether this volume shares a host directory into
+// the app over virtiofs (see HostDirSharePath) rather than a backing file
+// created from ContentID.
+func (status VolumeStatus) IsHostDirShare() bool {
+	return status.HostDirSharePath != ""
+}
+
 // Key is volume UUID which will be unique
 func (status VolumeStatus) Key() string {
 	return fmt.Sprintf("%s#%d", status.VolumeID.String(), status.GenerationCounter)
@@ -294,9 +361,23 @@ type VolumeRefStatus struct {
 	MountDir           string
 	PendingAdd         bool // Flag to identify whether volume ref config published or not
 
+	// HostDirSharePath mirrors VolumeStatus.HostDirSharePath; see there
+	// for details. Carried through to VolumeRefStatus so zedmanager can
+	// attach the disk to a domain as a virtiofs share.
+	HostDirSharePath string
+	// HostDirShareCacheMode mirrors VolumeStatus.HostDirShareCacheMode.
+	HostDirShareCacheMode string
+
 	ErrorAndTimeWithSource
 }
 
+// IsHostDirShare returns true if this volume ref is for a host directory
+// shared into the app over virtiofs (see HostDirSharePath) rather than a
+// backing file or block device.
+func (status VolumeRefStatus) IsHostDirShare() bool {
+	return status.HostDirSharePath != ""
+}
+
 // Key : VolumeRefStatus unique key
 func (status VolumeRefStatus) Key() string {
 	return fmt.Sprintf("%s#%d", status.VolumeID.String(), status.GenerationCounter)
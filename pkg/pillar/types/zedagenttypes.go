@@ -255,6 +255,11 @@ type NodeAgentStatus struct {
 	RebootTime        time.Time // From last reboot
 	RestartCounter    uint32
 	RebootImage       string
+	// SafeMode reports that this boot only started networking, onboarding
+	// and logging agents, with every app-related agent (and hence every
+	// app) left down, e.g. to recover a device stuck in a crash loop
+	// caused by an app workload. See scripts/device-steps.sh.
+	SafeMode bool
 }
 
 // Key :
@@ -68,8 +68,121 @@ type AppInstanceConfig struct {
 	// Collect Stats IP Address, assume port is the default docker API for http: 2375
 	CollectStatsIPAddr net.IP
 
+	// BlueGreenUpgrade requests that, on purge, the new instance be
+	// health-probed on HealthProbePort before the old instance is torn
+	// down, instead of switching over unconditionally.
+	BlueGreenUpgrade bool
+	HealthProbePort  uint16
+
+	// ResourceAlarms are per-app hard resource usage thresholds enforced
+	// locally by domainmgr, so runaway disk or CPU usage is handled even
+	// when the controller is unreachable. See ResourceAlarmConfig.
+	ResourceAlarms []ResourceAlarmConfig
+
+	// RestartPolicy controls whether domainmgr automatically restarts
+	// this app's container task when it exits. See
+	// types.RestartPolicy.
+	RestartPolicy RestartPolicy
+
+	// ContainerHealthProbe configures domainmgr's scheduled exec/TCP/HTTP
+	// health probe for this app's container, distinct from
+	// HealthProbePort's one-shot blue/green upgrade check. See
+	// HealthProbeConfig.
+	ContainerHealthProbe HealthProbeConfig
+
+	// EnableCheckpoint asks domainmgr to checkpoint this app's container
+	// task (via CRIU) before tearing it down, and restore from that
+	// checkpoint the next time it is activated, instead of always
+	// starting fresh. Only meaningful for containers, and only enforced
+	// on hypervisor backends implementing hypervisor.Checkpointer.
+	EnableCheckpoint bool
+
+	// QuiesceHook configures pre/post commands domainmgr execs inside
+	// the container around each checkpoint triggered by EnableCheckpoint,
+	// so a database or other stateful process can produce a consistent
+	// snapshot instead of being checkpointed mid-write. See
+	// QuiesceHookConfig. Only meaningful alongside EnableCheckpoint.
+	QuiesceHook QuiesceHookConfig
+
 	// CipherBlockStatus, for encrypted cloud-init data
 	CipherBlockStatus
+
+	// Critical marks this app as safety-relevant to the site, so it is
+	// the last to be throttled or stopped under resource pressure and the
+	// first to start at boot. See BootOrder and
+	// domainmgr's handling of Critical in enforceResourceAlarm.
+	Critical bool
+
+	// BootOrder, if non-zero, makes zedmanager hold off activating this
+	// app until every other app instance with a lower non-zero BootOrder
+	// has reached types.RUNNING. Apps with BootOrder 0 (the default) are
+	// activated as soon as they are otherwise ready, with no ordering
+	// constraint relative to each other or to apps with a BootOrder set.
+	BootOrder uint32
+
+	// ApparmorProfile, if non-empty, names the AppArmor profile to confine
+	// this app's container process with, overriding domainmgr's
+	// restrictive default. Only meaningful when the app is a container;
+	// ignored on a host that doesn't have AppArmor enabled.
+	ApparmorProfile string
+
+	// SelinuxLabel, if non-empty, is the SELinux context to run this
+	// app's container process as. Only meaningful when the app is a
+	// container; ignored on a host that doesn't have SELinux enabled.
+	SelinuxLabel string
+}
+
+// ResourceAlarmMetric identifies which resource a ResourceAlarmConfig
+// threshold is measured against.
+type ResourceAlarmMetric uint8
+
+const (
+	// ResourceAlarmMetricNone is the zero value; unused.
+	ResourceAlarmMetricNone ResourceAlarmMetric = iota
+	// ResourceAlarmMetricDiskPercent measures an app's disk usage as a
+	// percentage of its provisioned (virtual) disk size, across the
+	// largest of its volumes.
+	ResourceAlarmMetricDiskPercent
+	// ResourceAlarmMetricCPUPercent measures an app's CPU usage as a
+	// percentage of the vCPUs assigned to it (VmConfig.VCpus).
+	ResourceAlarmMetricCPUPercent
+)
+
+// ResourceAlarmAction is the local enforcement action domainmgr takes once
+// a ResourceAlarmConfig's threshold has been exceeded for its Duration.
+type ResourceAlarmAction uint8
+
+const (
+	// ResourceAlarmActionNone is the zero value; unused.
+	ResourceAlarmActionNone ResourceAlarmAction = iota
+	// ResourceAlarmActionEvent only logs/reports the alarm; the app is
+	// left running untouched.
+	ResourceAlarmActionEvent
+	// ResourceAlarmActionThrottle is intended to cap the offending
+	// resource (e.g. a CPU quota) without restarting the app. Not yet
+	// implemented for any ResourceAlarmMetric; domainmgr logs that it is
+	// falling back to ResourceAlarmActionEvent when configured.
+	ResourceAlarmActionThrottle
+	// ResourceAlarmActionRestart restarts the app's domain locally
+	// (without a round trip through zedmanager/controller) by
+	// inactivating and reactivating it.
+	ResourceAlarmActionRestart
+)
+
+// ResourceAlarmConfig is a per-app hard threshold on a resource usage
+// metric, with a local enforcement action taken once the threshold has
+// been exceeded continuously for Duration. Unlike the metrics zedagent
+// reports upstream, these are evaluated and enforced entirely within
+// domainmgr so critical misbehavior (e.g. a runaway disk leak) is handled
+// even when the device cannot reach the controller.
+type ResourceAlarmConfig struct {
+	Metric ResourceAlarmMetric
+	// Threshold the metric must exceed, as a percentage (0-100].
+	Threshold float64
+	// Duration the metric must stay above Threshold before Action is
+	// taken. Zero means act as soon as one sample exceeds Threshold.
+	Duration time.Duration
+	Action   ResourceAlarmAction
 }
 
 type AppInstanceOpsCmd struct {
@@ -166,6 +279,18 @@ type AppInstanceStatus struct {
 	// Error* set implies error.
 	State          SwState
 	MissingNetwork bool // If some Network UUID not found
+	// BlueGreenProbeFailed is set when a BlueGreenUpgrade health probe of
+	// the newly purged-up instance has not yet succeeded, holding off
+	// the switchover/teardown of the old instance.
+	BlueGreenProbeFailed bool
+	// Critical and BootOrder are copies of the AppInstanceConfig fields of
+	// the same name, carried into status for reporting.
+	Critical  bool
+	BootOrder uint32
+	// WaitingForBootOrder is set while activation of this app is held off
+	// because an app instance with a lower BootOrder has not yet reached
+	// types.RUNNING. See BootOrder.
+	WaitingForBootOrder bool
 	// All error strings across all steps and all StorageStatus
 	// ErrorAndTimeWithSource provides SetError, SetErrrorWithSource, etc
 	ErrorAndTimeWithSource
@@ -280,6 +405,67 @@ func RoundupToKB(b uint64) uint64 {
 	return (b + 1023) / 1024
 }
 
+// AppInstanceHistory is a persisted, per-app-UUID record of an app
+// instance's display name, version and lifetime timestamps. Unlike
+// AppInstanceStatus it is not removed when the app instance is deleted;
+// zedmanager keeps it around (as a Persistent pubsub publication, so it
+// survives reboots too) so that log analysis can later resolve the UUID
+// of an app that no longer exists.
+type AppInstanceHistory struct {
+	UUID        uuid.UUID
+	DisplayName string
+	Version     string
+	CreateTime  time.Time
+	// PurgeTime is the time of the most recent purge command, or the
+	// zero value if the app instance has never been purged.
+	PurgeTime time.Time
+	// DeleteTime is set once the app instance has been deleted; the
+	// record itself is kept.
+	DeleteTime time.Time
+}
+
+// Key is the key in pubsub
+func (hist AppInstanceHistory) Key() string {
+	return hist.UUID.String()
+}
+
+// LogCreate :
+func (hist AppInstanceHistory) LogCreate(logBase *base.LogObject) {
+	logObject := base.NewLogObject(logBase, base.AppInstanceHistoryLogType, hist.DisplayName,
+		hist.UUID, hist.LogKey())
+	if logObject == nil {
+		return
+	}
+	logObject.Noticef("AppInstanceHistory create")
+}
+
+// LogModify :
+func (hist AppInstanceHistory) LogModify(logBase *base.LogObject, old interface{}) {
+	logObject := base.EnsureLogObject(logBase, base.AppInstanceHistoryLogType, hist.DisplayName,
+		hist.UUID, hist.LogKey())
+
+	oldHist, ok := old.(AppInstanceHistory)
+	if !ok {
+		logObject.Clone().Fatalf("LogModify: Old object interface passed is not of AppInstanceHistory type")
+	}
+	logObject.CloneAndAddField("diff", cmp.Diff(oldHist, hist)).
+		Noticef("AppInstanceHistory modify")
+}
+
+// LogDelete :
+func (hist AppInstanceHistory) LogDelete(logBase *base.LogObject) {
+	logObject := base.EnsureLogObject(logBase, base.AppInstanceHistoryLogType, hist.DisplayName,
+		hist.UUID, hist.LogKey())
+	logObject.Noticef("AppInstanceHistory delete")
+
+	base.DeleteLogObject(logBase, hist.LogKey())
+}
+
+// LogKey :
+func (hist AppInstanceHistory) LogKey() string {
+	return string(base.AppInstanceHistoryLogType) + "-" + hist.Key()
+}
+
 // AppAndImageToHash is used to retain <app,image> to sha maps across reboots.
 // Key for OCI images which can be specified with a tag and we need to be
 // able to latch the sha and choose when to update/refresh from the tag.
@@ -391,6 +391,90 @@ func (config DevicePortConfigList) LogKey() string {
 	return string(base.DevicePortConfigListLogType) + "-" + config.PubKey()
 }
 
+// MaxDPCHistoryEntries bounds DevicePortConfigHistory.Entries, so the
+// history grows unboundedly neither in pubsub nor on persisted storage
+// as a device churns through network configs over its lifetime.
+const MaxDPCHistoryEntries = 20
+
+// DPCHistoryEntry records one DevicePortConfig that was superseded by a
+// higher-priority one, so a later "why did connectivity break" question
+// can be answered from what's on the device rather than needing the
+// controller's own logs (which the device might not have been able to
+// reach in the first place).
+type DPCHistoryEntry struct {
+	Key           string
+	TimePriority  time.Time // When this DPC was first tried
+	Ports         []string  // IfNames, for a compact summary
+	WasWorking    bool      // WasDPCWorking() at the time it was replaced
+	LastSucceeded time.Time
+	LastFailed    time.Time
+	LastError     string
+	ReplacedAt    time.Time // When a higher-priority DPC took over
+}
+
+// DevicePortConfigHistory is a bounded, most-recent-first log of
+// DevicePortConfigs that were once in use and then replaced, each
+// annotated with whether it ever achieved controller connectivity and
+// for how long, so a support session can see e.g. that a site "worked
+// until the proxy config arrived Tuesday" instead of just the current
+// DevicePortConfigList, which only retains what's still a candidate.
+// This is only published under the key "global".
+type DevicePortConfigHistory struct {
+	Key     string // Assume "global" if empty
+	Entries []DPCHistoryEntry
+}
+
+// PubKey is used for pubsub
+func (history DevicePortConfigHistory) PubKey() string {
+	if history.Key == "" {
+		return "global"
+	}
+	return history.Key
+}
+
+// LogCreate :
+func (history DevicePortConfigHistory) LogCreate(logBase *base.LogObject) {
+	logObject := base.NewLogObject(logBase, base.DevicePortConfigHistoryLogType, "",
+		nilUUID, history.LogKey())
+	if logObject == nil {
+		return
+	}
+	logObject.CloneAndAddField("num-entries-int64", len(history.Entries)).
+		Noticef("DevicePortConfigHistory create")
+}
+
+// LogModify :
+func (history DevicePortConfigHistory) LogModify(logBase *base.LogObject, old interface{}) {
+	logObject := base.EnsureLogObject(logBase, base.DevicePortConfigHistoryLogType, "",
+		nilUUID, history.LogKey())
+
+	oldHistory, ok := old.(DevicePortConfigHistory)
+	if !ok {
+		logObject.Clone().Errorf("LogModify: Old object interface passed is not of DevicePortConfigHistory type")
+		return
+	}
+	if len(oldHistory.Entries) != len(history.Entries) {
+		logObject.CloneAndAddField("num-entries-int64", len(history.Entries)).
+			AddField("old-num-entries-int64", len(oldHistory.Entries)).
+			Noticef("DevicePortConfigHistory modify")
+	}
+}
+
+// LogDelete :
+func (history DevicePortConfigHistory) LogDelete(logBase *base.LogObject) {
+	logObject := base.EnsureLogObject(logBase, base.DevicePortConfigHistoryLogType, "",
+		nilUUID, history.LogKey())
+	logObject.CloneAndAddField("num-entries-int64", len(history.Entries)).
+		Noticef("DevicePortConfigHistory delete")
+
+	base.DeleteLogObject(logBase, history.LogKey())
+}
+
+// LogKey :
+func (history DevicePortConfigHistory) LogKey() string {
+	return string(base.DevicePortConfigHistoryLogType) + "-" + history.PubKey()
+}
+
 // PendDPCStatus tracks the internal progression of a DPC
 type PendDPCStatus uint32
 
@@ -922,6 +1006,13 @@ type NetworkPortStatus struct {
 	ProxyConfig
 	// TestResults provides recording of failure and success
 	TestResults
+	// Nat64Prefix is the NAT64 translation prefix (a Pref64::/96, either
+	// the RFC 6052 Well-Known Prefix or an operator-assigned
+	// Network-Specific Prefix) in use on this port, if reaching the
+	// Internet from it requires IPv4-embedded IPv6 synthesis (see
+	// package nat64). Empty when this port has native or NAT44 IPv4
+	// connectivity and no such synthesis is needed.
+	Nat64Prefix net.IP
 }
 
 type AddrInfo struct {
@@ -1702,6 +1793,11 @@ type UnderlayNetworkConfig struct {
 	Error   string
 	Network uuid.UUID // Points to a NetworkInstance.
 	ACLs    []ACE
+
+	// TrafficMirror, if Enabled, mirrors (SPANs) just this VIF's traffic
+	// to another app adapter's VIF, instead of an entire network
+	// instance. See TrafficMirrorConfig.
+	TrafficMirror TrafficMirrorConfig
 }
 
 type UnderlayNetworkStatus struct {
@@ -1713,6 +1809,11 @@ type UnderlayNetworkStatus struct {
 	Assigned        bool   // Set to true once DHCP has assigned it to domU
 	IPAddrMisMatch  bool
 	HostName        string
+	// LeaseExpires is the expiry time of the current dnsmasq lease for
+	// AllocatedIPAddr, copied from the lease database in
+	// types.DnsmasqLeaseDirname by checkAndPublishDhcpLeases. Zero if
+	// Assigned is false.
+	LeaseExpires time.Time
 	ACLRules        IPTablesRuleList
 }
 
@@ -2090,7 +2191,57 @@ type NetworkInstanceConfig struct {
 	HasEncap bool // Vpn, for adjusting pMTU
 	// For other network services - Proxy / StrongSwan etc..
 	OpaqueConfig string
-}
+
+	// DNSQueryLogPrivacy controls how much of a client app's DNS query
+	// name is retained when DNS requests/replies are packed into
+	// IPFlow.DNSReqs for export. Default DNSQueryLogPrivacyNone retains
+	// the full qname, matching today's behavior.
+	DNSQueryLogPrivacy DNSQueryLogPrivacyMode
+
+	// TrafficMirror, if Enabled, mirrors (SPANs) the traffic received on
+	// this network instance's bridge interface to the VIF of the app
+	// adapter named by MirrorToAdapter, so a monitoring app (IDS, packet
+	// broker, etc.) attached to that adapter can observe it. Traffic
+	// transmitted on the bridge interface is not mirrored.
+	TrafficMirror TrafficMirrorConfig
+}
+
+// TrafficMirrorConfig requests that traffic be mirrored (SPANned) to a
+// designated monitoring app's adapter, optionally rate-capped so the
+// mirror can't starve the production traffic it is copying. It can be
+// set on a NetworkInstanceConfig, to mirror an entire network instance's
+// bridge, or on an UnderlayNetworkConfig, to mirror just that one app
+// VIF.
+type TrafficMirrorConfig struct {
+	// Enabled turns mirroring on or off; the rest of the fields are
+	// only meaningful when this is true.
+	Enabled bool
+	// MirrorToAdapter is the Logicallabel of the network adapter,
+	// belonging to the monitoring app instance, that mirrored traffic
+	// should be copied to. The adapter must be attached to a network
+	// instance reachable from zedrouter for the mirror to be wired up.
+	MirrorToAdapter string
+	// RateLimitPps caps mirrored traffic at this many packets per
+	// second. Zero means unlimited.
+	RateLimitPps uint32
+}
+
+// DNSQueryLogPrivacyMode controls redaction of the domain name recorded
+// for an app's DNS queries before they are exported off the device.
+type DNSQueryLogPrivacyMode uint8
+
+// Supported DNS query log privacy modes
+const (
+	// DNSQueryLogPrivacyNone exports the full query name, unmodified.
+	DNSQueryLogPrivacyNone DNSQueryLogPrivacyMode = iota
+	// DNSQueryLogPrivacyHash exports a truncated SHA-256 hash of the
+	// query name instead of the name itself.
+	DNSQueryLogPrivacyHash
+	// DNSQueryLogPrivacyTruncate exports only the registrable domain
+	// (last two labels), dropping subdomains that might identify a
+	// specific host or user.
+	DNSQueryLogPrivacyTruncate
+)
 
 func (config *NetworkInstanceConfig) Key() string {
 	return config.UUID.String()
@@ -2229,6 +2380,9 @@ type AppNetworkACLArgs struct {
 	NIType     NetworkInstanceType
 	// This is the same AppNum that comes from AppNetworkStatus
 	AppNum int32
+	// AppMacAddr is the MAC address assigned to this vif, if any. When set
+	// it is used to build the anti-spoofing filters in antiSpoofRules.
+	AppMacAddr net.HardwareAddr
 }
 
 // IPTablesRule : iptables rule detail
@@ -2616,6 +2770,11 @@ type IPFlow struct {
 	Scope   FlowScope
 	Flows   []FlowRec
 	DNSReqs []DNSReq
+	// SampleFactor is the FlowlogSamplingFactor in effect when this
+	// batch was collected: only 1 in SampleFactor observed flows was
+	// kept. 1 means every flow was kept. Downstream analytics can use
+	// this to scale counts back up to an estimate of the true total.
+	SampleFactor int32
 }
 
 // Key :
@@ -0,0 +1,97 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package vault
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+)
+
+// fsckTimeout bounds the time spent checking/repairing /persist so a
+// stuck fsck cannot indefinitely delay agents from starting.
+const fsckTimeout = 5 * time.Minute
+
+// PersistCheckResult is the outcome of a boot-time filesystem check/repair
+// pass over /persist.
+type PersistCheckResult struct {
+	FsType    string
+	RepairRan bool
+	Repaired  bool
+	Failed    bool
+	Output    string
+}
+
+// RunPersistFsck runs an appropriate check/repair pass over /persist
+// based on its underlying filesystem: e2fsck -p -f for ext4, or
+// zpool status for zfs. No scrub is started on zfs, so a zfs pool is
+// checked and reported but not repaired. It is meant to run once, before agents
+// subscribe to pubsub, so that a corrupted /persist is repaired (or at
+// least reported) before anything else depends on it.
+func RunPersistFsck(log *base.LogObject, persistDevice string) PersistCheckResult {
+	fsType := ReadPersistType()
+	result := PersistCheckResult{FsType: fsType}
+
+	switch fsType {
+	case "zfs":
+		result.RepairRan = true
+		output, err := execWithTimeout(log, fsckTimeout, "zpool", "status", DefaultZpool)
+		result.Output = output
+		if err != nil {
+			result.Failed = true
+			log.Errorf("RunPersistFsck: zpool status failed: %s", err)
+		} else if strings.Contains(output, "DEGRADED") || strings.Contains(output, "FAULTED") {
+			result.Failed = true
+			log.Errorf("RunPersistFsck: zpool %s is unhealthy: %s", DefaultZpool, output)
+		}
+	case "ext4":
+		if persistDevice == "" {
+			log.Warnf("RunPersistFsck: no persist device known, skipping fsck")
+			return result
+		}
+		result.RepairRan = true
+		output, err := execWithTimeout(log, fsckTimeout, "e2fsck", "-p", "-f", persistDevice)
+		result.Output = output
+		if err != nil {
+			// e2fsck exit code 1 means errors were corrected; anything
+			// higher means it could not fully repair the filesystem.
+			if exitCode(err) == 1 {
+				result.Repaired = true
+			} else {
+				result.Failed = true
+				log.Errorf("RunPersistFsck: e2fsck failed on %s: %s, %s",
+					persistDevice, err, output)
+			}
+		}
+	default:
+		log.Warnf("RunPersistFsck: unknown persist filesystem type %q, skipping", fsType)
+	}
+	return result
+}
+
+func execWithTimeout(log *base.LogObject, timeout time.Duration, command string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		log.Errorf("execWithTimeout: %s %v timed out after %s", command, args, timeout)
+	}
+	return out.String(), err
+}
+
+func exitCode(err error) int {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
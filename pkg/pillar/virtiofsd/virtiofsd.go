@@ -0,0 +1,171 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package virtiofsd manages the lifecycle of virtiofsd processes that
+// back a domain's virtiofs host-directory-share disks (see
+// types.DiskConfig.HostDirSharePath): one daemon per shared directory,
+// listening on a unix socket that qemu's vhost-user-fs-pci device
+// connects to (see the "virtiofs" case of qemuDiskTemplate in the
+// hypervisor package). It is the virtio-fs replacement for the
+// virtio-9p-pci "fsdev" stanza also found there - virtiofsd gets its own
+// package, rather than living in hypervisor/kvm.go alongside the 9p
+// case, because unlike 9p it needs an external process supervised across
+// the life of the domain.
+package virtiofsd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+)
+
+// binary is the virtiofsd executable, expected on PATH inside the
+// pillar container.
+const binary = "virtiofsd"
+
+// CacheMode controls virtiofsd's -o cache= setting, trading off POSIX
+// coherence (clients see each other's writes promptly) against
+// performance (fewer round trips to the host for metadata/data).
+type CacheMode string
+
+const (
+	// CacheAuto lets virtiofsd pick based on file attributes; the
+	// default, and the right choice for most data-exchange shares.
+	CacheAuto CacheMode = "auto"
+	// CacheAlways maximizes performance for a share only one domain
+	// ever touches at a time, at the cost of coherence with the host.
+	CacheAlways CacheMode = "always"
+	// CacheNever maximizes coherence for a share the host and domain -
+	// or several domains - actively write to concurrently.
+	CacheNever CacheMode = "never"
+)
+
+// Config describes one virtiofsd instance.
+type Config struct {
+	// SocketPath is the vhost-user unix socket virtiofsd listens on
+	// and qemu's vhost-user-fs-pci device connects to.
+	SocketPath string
+	// SharedDir is the host directory exposed to the guest.
+	SharedDir string
+	ReadOnly  bool
+	Cache     CacheMode
+}
+
+// Status is a snapshot of a Daemon's state, for folding into a disk's
+// status for metrics and diagnostics.
+type Status struct {
+	Config
+	PID          int
+	StartedAt    time.Time
+	RestartCount int
+	Running      bool
+	LastError    string
+}
+
+// Daemon supervises a single virtiofsd process.
+type Daemon struct {
+	log *base.LogObject
+
+	mu     sync.Mutex
+	cfg    Config
+	cmd    *exec.Cmd
+	status Status
+}
+
+// New starts a virtiofsd process for cfg and returns once it has been
+// launched (not once it is ready to accept connections - qemu retries
+// its connection to SocketPath, so callers don't need to wait for that).
+func New(log *base.LogObject, cfg Config) (*Daemon, error) {
+	d := &Daemon{log: log, cfg: cfg}
+	if err := d.start(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Daemon) start() error {
+	// virtiofsd refuses to bind over an existing socket file, which
+	// can be left behind by a prior pillar process that crashed.
+	os.Remove(d.cfg.SocketPath)
+	cache := d.cfg.Cache
+	if cache == "" {
+		cache = CacheAuto
+	}
+	args := []string{
+		"--socket-path", d.cfg.SocketPath,
+		"--shared-dir", d.cfg.SharedDir,
+		"--cache", string(cache),
+	}
+	if d.cfg.ReadOnly {
+		args = append(args, "--readonly")
+	}
+	cmd := exec.Command(binary, args...)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("virtiofsd: failed to start for %s: %v", d.cfg.SharedDir, err)
+	}
+	d.cmd = cmd
+	d.status = Status{
+		Config:       d.cfg,
+		PID:          cmd.Process.Pid,
+		StartedAt:    time.Now(),
+		Running:      true,
+		RestartCount: d.status.RestartCount,
+	}
+	go d.wait(cmd)
+	return nil
+}
+
+// wait reaps cmd and records its exit, unless Stop/Restart has already
+// moved the Daemon on to a different process in the meantime.
+func (d *Daemon) wait(cmd *exec.Cmd) {
+	err := cmd.Wait()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cmd != cmd {
+		return
+	}
+	d.status.Running = false
+	if err != nil {
+		d.status.LastError = err.Error()
+		d.log.Errorf("virtiofsd(%s) exited: %s", d.cfg.SharedDir, err)
+	}
+}
+
+// Restart stops the current process, if still running, and starts a new
+// one, e.g. after Status().Running is observed to have gone false
+// unexpectedly.
+func (d *Daemon) Restart() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopLocked()
+	d.status.RestartCount++
+	return d.start()
+}
+
+// Stop terminates the daemon, if running, and removes its socket.
+func (d *Daemon) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopLocked()
+}
+
+func (d *Daemon) stopLocked() {
+	if d.cmd != nil && d.cmd.Process != nil {
+		d.cmd.Process.Kill()
+		d.cmd.Wait()
+	}
+	d.cmd = nil
+	os.Remove(d.cfg.SocketPath)
+	d.status.Running = false
+}
+
+// Status returns a snapshot of the daemon's current state.
+func (d *Daemon) Status() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtiofsd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNewFailsWithoutBinary(t *testing.T) {
+	// The test environment has no virtiofsd binary on PATH, so New
+	// should fail fast with a descriptive error rather than hang.
+	log := base.NewSourceLogObject(logrus.StandardLogger(), "test", 1234)
+	cfg := Config{
+		SocketPath: filepath.Join(t.TempDir(), "virtiofs.sock"),
+		SharedDir:  t.TempDir(),
+		Cache:      CacheAuto,
+	}
+	if _, err := New(log, cfg); err == nil {
+		t.Errorf("New() succeeded unexpectedly with no virtiofsd binary present")
+	}
+}
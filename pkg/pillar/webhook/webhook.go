@@ -0,0 +1,92 @@
+// Copyright (c) 2022 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package webhook forwards selected device events to a customer-defined
+// HTTPS endpoint, for integrations that cannot wait for controller-side
+// alerting (e.g. the endpoint is only reachable over an app network and
+// not from the controller at all). It is intentionally small: one event
+// type, one POST, a signature, and a bounded retry - not a general pub/sub
+// bridge.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+)
+
+const (
+	sendAttempts  = 3
+	sendBaseDelay = time.Second
+	sendTimeout   = 15 * time.Second
+
+	// SignatureHeader carries the hex-encoded HMAC-SHA256 of the request
+	// body, keyed by the configured notification secret, so the receiving
+	// endpoint can authenticate that the payload came from this device.
+	SignatureHeader = "X-Eve-Signature"
+)
+
+// Event is the payload POSTed to the configured webhook URL.
+type Event struct {
+	Type       string      `json:"type"`
+	DeviceUUID string      `json:"deviceUUID"`
+	Timestamp  time.Time   `json:"timestamp"`
+	Detail     interface{} `json:"detail"`
+}
+
+// Send POSTs event as JSON to url, signing the body with secret if one is
+// configured, and retries a bounded number of times with a backoff on
+// failure. An empty url is treated as "webhook notifications disabled" and
+// returns nil without doing anything.
+func Send(log *base.LogObject, url string, secret string, event Event) error {
+	if url == "" {
+		return nil
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("webhook.Send: failed to marshal event: %s", err)
+	}
+
+	client := &http.Client{Timeout: sendTimeout}
+	var lastErr error
+	for attempt := 0; attempt < sendAttempts; attempt++ {
+		if attempt > 0 {
+			delay := sendBaseDelay * time.Duration(1<<uint(attempt-1))
+			time.Sleep(delay)
+		}
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("webhook.Send: failed to build request: %s", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if secret != "" {
+			req.Header.Set(SignatureHeader, sign(body, secret))
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			log.Warnf("webhook.Send: attempt %d to %s failed: %s", attempt+1, url, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+		log.Warnf("webhook.Send: attempt %d to %s: %s", attempt+1, url, lastErr)
+	}
+	return fmt.Errorf("webhook.Send: giving up after %d attempts: %s", sendAttempts, lastErr)
+}
+
+func sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -7,6 +7,7 @@
 package worker
 
 import (
+	"sync"
 	"time"
 
 	"github.com/lf-edge/eve/pkg/pillar/agentlog"
@@ -56,11 +57,34 @@ type WorkFunction func(ctx interface{}, work Work) WorkResult
 // NewWorker creates a new function for a specific function and context
 // function takes the context and the channels
 func NewWorker(log *base.LogObject, fn WorkFunction, ctx interface{}, length int) *Worker {
+	return NewWorkerPool(log, fn, ctx, 1, length)
+}
+
+// NewWorkerPool is NewWorker generalized to run up to concurrency work
+// items at once instead of just one, for callers where work items are
+// independent of each other and serializing them would waste wall-clock
+// time (e.g. bringing up many apps at boot). A concurrency of 1 behaves
+// exactly like NewWorker.
+func NewWorkerPool(log *base.LogObject, fn WorkFunction, ctx interface{}, concurrency int, length int) *Worker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
 	w := new(Worker)
 	requestChan := make(chan Work, length)
 	resultChan := make(chan privateResult, length)
 	log.Infof("Creating %s at %s", "w.processWork", agentlog.GetMyStack())
-	go w.processWork(log, ctx, fn, requestChan, resultChan)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			w.processWork(log, ctx, fn, requestChan, resultChan)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
 	w.requestChan = requestChan
 	w.resultChan = resultChan
 	return w
@@ -73,7 +97,9 @@ func (workerPtr Worker) NumPending() int {
 	return int(workerPtr.requestCount) - int(workerPtr.resultCount)
 }
 
-// processWork calls the fn for each work until the requestChan is closed
+// processWork calls the fn for each work until the requestChan is closed.
+// It does not close resultChan; the caller (NewWorkerPool) does that once
+// every concurrently-running processWork goroutine has returned.
 func (workerPtr *Worker) processWork(log *base.LogObject, ctx interface{}, fn WorkFunction, requestChan <-chan Work, resultChan chan<- privateResult) {
 
 	log.Infof("processWork starting for context %T", ctx)
@@ -88,9 +114,6 @@ func (workerPtr *Worker) processWork(log *base.LogObject, ctx interface{}, fn Wo
 		}
 		resultChan <- priv
 	}
-	// XXX if we ever want multiple goroutines for one Worker we
-	// can't close here; would need some wait for all to finish
-	close(resultChan)
 	log.Infof("processWork done for context %T", ctx)
 }
 
@@ -188,6 +188,31 @@ func TestLength(t *testing.T) {
 	assert.True(t, done)
 }
 
+// TestPoolConcurrency verifies that NewWorkerPool actually runs work items
+// in parallel instead of serially like NewWorker does.
+func TestPoolConcurrency(t *testing.T) {
+	ctx := dummyContext{contextName: "testContext"}
+	pool := NewWorkerPool(
+		base.NewSourceLogObject(logrus.StandardLogger(), "test", 1234),
+		dummyWorker, &ctx, 2, 2)
+
+	start := time.Now()
+	pool.Submit(Work{Key: "a", Description: sleep1})
+	pool.Submit(Work{Key: "b", Description: sleep1})
+	pool.Process(<-pool.MsgChan())
+	pool.Process(<-pool.MsgChan())
+	took := time.Since(start)
+
+	// Run serially this would take >= 2s; with concurrency 2 it should
+	// take roughly 1s.
+	assert.Less(t, int64(took), int64(time.Duration(sleep1.sleepTime)*time.Second)*2)
+
+	pool.Done()
+	_, ok := <-pool.MsgChan()
+	done := !ok
+	assert.True(t, done)
+}
+
 type dummyContext struct {
 	contextName string
 }
@@ -20,6 +20,7 @@ import (
 	"github.com/lf-edge/eve/pkg/pillar/cmd/client"
 	"github.com/lf-edge/eve/pkg/pillar/cmd/command"
 	"github.com/lf-edge/eve/pkg/pillar/cmd/conntrack"
+	"github.com/lf-edge/eve/pkg/pillar/cmd/coredump"
 	"github.com/lf-edge/eve/pkg/pillar/cmd/diag"
 	"github.com/lf-edge/eve/pkg/pillar/cmd/domainmgr"
 	"github.com/lf-edge/eve/pkg/pillar/cmd/downloader"
@@ -91,6 +92,7 @@ var (
 		"baseosmgr":        {f: baseosmgr.Run},
 		"wstunnelclient":   {f: wstunnelclient.Run},
 		"conntrack":        {f: conntrack.Run, inline: inlineAlways},
+		"coredump":         {f: coredump.Run, inline: inlineAlways},
 		"tpmmgr":           {f: tpmmgr.Run, inline: inlineUnlessService},
 		"vaultmgr":         {f: vaultmgr.Run, inline: inlineUnlessService},
 		"upgradeconverter": {f: upgradeconverter.Run, inline: inlineAlways},
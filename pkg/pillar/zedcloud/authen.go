@@ -253,7 +253,7 @@ func getMyDevCert(ctx *ZedCloudContext, isOnboard bool) (tls.Certificate, error)
 		}
 	} else {
 		if ctx.deviceCert == nil {
-			cert, err = GetClientCert()
+			cert, err = GetClientCert(ctx.log)
 			if err != nil {
 				ctx.log.Errorf("getMyDevCert: get client cert error %v\n", err)
 				return cert, err
@@ -0,0 +1,58 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package zedcloud
+
+import (
+	"context"
+	"net"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+	"github.com/lf-edge/eve/pkg/pillar/nat64"
+	"github.com/lf-edge/eve/pkg/pillar/types"
+)
+
+// nat64AwareDial wraps d.Dial with a fallback for IPv6-only, NAT64/DNS64
+// ports: if a plain dial of address fails and the port used for intf has a
+// Nat64Prefix configured (see types.NetworkPortStatus), it resolves
+// address's host to an IPv4 address using resolver, synthesizes a NAT64
+// AAAA address for it, and dials that instead. This is what lets reaching
+// an IPv4-literal controller address (or a name that only has A records)
+// keep working on a carrier network with no native IPv4.
+//
+// TLS certificate validation is unaffected by which literal address we end
+// up dialing: GetTlsConfig always sets tls.Config.ServerName explicitly
+// from the configured controller hostname rather than deriving it from the
+// dialed address, so SNI and certificate hostname checks keep using the
+// real server name whether we dialed natively or via a synthesized
+// address.
+func nat64AwareDial(log *base.LogObject, d *net.Dialer, resolver *net.Resolver,
+	dns *types.DeviceNetworkStatus, intf string) func(network, address string) (net.Conn, error) {
+
+	return func(network, address string) (net.Conn, error) {
+		conn, err := d.Dial(network, address)
+		if err == nil {
+			return conn, nil
+		}
+		port := dns.GetPortByIfName(intf)
+		if port == nil || port.Nat64Prefix == nil {
+			return nil, err
+		}
+		host, portNum, splitErr := net.SplitHostPort(address)
+		if splitErr != nil {
+			return nil, err
+		}
+		ips, lookupErr := resolver.LookupIP(context.Background(), "ip4", host)
+		if lookupErr != nil || len(ips) == 0 {
+			return nil, err
+		}
+		synth, synthErr := nat64.SynthesizeAAAA(port.Nat64Prefix, ips[0])
+		if synthErr != nil {
+			log.Errorf("nat64AwareDial: %s", synthErr)
+			return nil, err
+		}
+		log.Noticef("nat64AwareDial: retrying %s over NAT64 as %s",
+			address, synth)
+		return d.Dial(network, net.JoinHostPort(synth.String(), portNum))
+	}
+}
@@ -8,6 +8,7 @@ package zedcloud
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
@@ -370,7 +371,7 @@ func SendOnIntf(ctx *ZedCloudContext, destURL string, intf string, reqlen int64,
 		r := net.Resolver{Dial: resolverDial, PreferGo: true,
 			StrictErrors: false}
 		d := net.Dialer{Resolver: &r, LocalAddr: &localTCPAddr}
-		transport.Dial = d.Dial
+		transport.Dial = nat64AwareDial(log, &d, &r, ctx.DeviceNetworkStatus, intf)
 
 		client := &http.Client{Transport: transport}
 		if ctx.NetworkSendTimeout != 0 {
@@ -442,30 +443,10 @@ func SendOnIntf(ctx *ZedCloudContext, destURL string, intf string, reqlen int64,
 		apiCallStartTime := time.Now()
 		resp, err := client.Do(req)
 		if err != nil {
-			if cf, cert := isCertFailure(err); cf {
-				// XXX can we ever get this from a proxy?
-				// We assume we reached the controller here
-				log.Errorf("client.Do fail: certFailure")
-				senderStatus = types.SenderStatusCertInvalid
-				if cert != nil {
-					errStr := fmt.Sprintf("cert failure for Subject %s NotBefore %v NotAfter %v",
-						cert.Subject, cert.NotBefore,
-						cert.NotAfter)
-					log.Error(errStr)
-					cerr := errors.New(errStr)
-					errorList = append(errorList, cerr)
-				} else {
-					errorList = append(errorList, err)
-				}
-			} else if isCertUnknownAuthority(err) {
-				if usedProxy {
-					log.Errorf("client.Do fail: CertUnknownAuthority with proxy")
-					senderStatus = types.SenderStatusCertUnknownAuthorityProxy
-				} else {
-					log.Errorf("client.Do fail: CertUnknownAuthority") // could be transparent proxy
-					senderStatus = types.SenderStatusCertUnknownAuthority
-				}
-				errorList = append(errorList, err)
+			if tlsStatus, tlsErr := classifyTLSFailure(err, usedProxy); tlsStatus != types.SenderStatusNone {
+				log.Error(tlsErr)
+				senderStatus = tlsStatus
+				errorList = append(errorList, tlsErr)
 			} else if isECONNREFUSED(err) {
 				if usedProxy {
 					// Must try other interfaces and configs
@@ -611,28 +592,63 @@ func SendOnIntf(ctx *ZedCloudContext, destURL string, intf string, reqlen int64,
 	return nil, nil, senderStatus, errors.New(errStr)
 }
 
-func isCertFailure(err error) (bool, *x509.Certificate) {
-	e0, ok := err.(*url.Error)
-	if !ok {
-		return false, nil
+// certFingerprint returns the sha256 fingerprint of cert as a colon-
+// separated hex string, in the form operators commonly compare against
+// a known MITM proxy CA fingerprint.
+func certFingerprint(cert *x509.Certificate) string {
+	if cert == nil {
+		return ""
 	}
-	e1, ok := e0.Err.(x509.CertificateInvalidError)
-	if !ok {
-		return false, nil
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02x", b)
 	}
-	return true, e1.Cert
+	return strings.Join(parts, ":")
 }
 
-func isCertUnknownAuthority(err error) bool {
+// classifyTLSFailure inspects err, as returned by client.Do, for a TLS/
+// certificate chain failure and turns it into an actionable error plus
+// the corresponding types.SenderStatus* for the caller to report.
+// It returns types.SenderStatusNone, nil if err is not TLS-related, so
+// the caller can fall through to its other error classifiers.
+func classifyTLSFailure(err error, usedProxy bool) (types.SenderResult, error) {
 	e0, ok := err.(*url.Error)
 	if !ok {
-		return false
+		return types.SenderStatusNone, nil
 	}
-	_, ok = e0.Err.(x509.UnknownAuthorityError)
-	if !ok {
-		return false
+	switch e1 := e0.Err.(type) {
+	case x509.CertificateInvalidError:
+		if e1.Reason == x509.Expired {
+			return types.SenderStatusCertExpired, fmt.Errorf(
+				"server certificate for Subject %s expired (NotBefore %v NotAfter %v); "+
+					"check certificate validity or the device's clock", e1.Cert.Subject,
+				e1.Cert.NotBefore, e1.Cert.NotAfter)
+		}
+		return types.SenderStatusCertInvalid, fmt.Errorf(
+			"server certificate for Subject %s invalid: %s (NotBefore %v NotAfter %v)",
+			e1.Cert.Subject, e1.Error(), e1.Cert.NotBefore, e1.Cert.NotAfter)
+	case x509.HostnameError:
+		return types.SenderStatusCertHostnameMismatch, fmt.Errorf(
+			"server certificate hostname mismatch: %s", e1.Error())
+	case x509.UnknownAuthorityError:
+		fingerprint := certFingerprint(e1.Cert)
+		if usedProxy {
+			return types.SenderStatusCertUnknownAuthorityProxy, fmt.Errorf(
+				"certificate signed by unknown authority while using a proxy "+
+					"(sha256 fingerprint %s); device may be missing the proxy's "+
+					"MITM CA certificate", fingerprint)
+		}
+		return types.SenderStatusCertUnknownAuthority, fmt.Errorf(
+			"certificate signed by unknown authority (sha256 fingerprint %s); "+
+				"could also be a transparent MITM proxy", fingerprint)
+	}
+	if strings.Contains(err.Error(), "tls: handshake failure") {
+		return types.SenderStatusCertUnsupportedCiphers, fmt.Errorf(
+			"TLS handshake failure, likely no common cipher suite/TLS version "+
+				"with server: %s", err)
 	}
-	return true
+	return types.SenderStatusNone, nil
 }
 
 func isECONNREFUSED(err error) bool {
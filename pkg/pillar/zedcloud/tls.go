@@ -26,13 +26,19 @@ import (
 )
 
 //GetClientCert prepares tls.Certificate to connect to the cloud Controller
-func GetClientCert() (tls.Certificate, error) {
-	if !etpm.IsTpmEnabled() {
-		//Not a TPM capable device, return openssl certificate
+func GetClientCert(log *base.LogObject) (tls.Certificate, error) {
+	if etpm.SelectKeyBackend() == etpm.KeyBackendSoftkey {
+		//Not a TPM/PKCS#11 capable device, return openssl certificate
 		return tls.LoadX509KeyPair(types.DeviceCertName, types.DeviceKeyName)
 	}
 
-	// TPM capable device, return TPM bcased certificate
+	// TPM or PKCS#11 backed device; the key never leaves the backend,
+	// so build the tls.Certificate from the on-disk cert plus a
+	// crypto.Signer for the backend's key.
+	signer, err := etpm.GetDeviceSigner(log)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
 	deviceCertBytes, err := ioutil.ReadFile(types.DeviceCertName)
 	if err != nil {
 		return tls.Certificate{}, err
@@ -41,11 +47,7 @@ func GetClientCert() (tls.Certificate, error) {
 	deviceTLSCert := tls.Certificate{}
 	deviceTLSCert.Certificate = append(deviceTLSCert.Certificate,
 		deviceCertDERBytes.Bytes)
-
-	tpmPrivKey := etpm.TpmPrivateKey{}
-	tpmPrivKey.PublicKey = tpmPrivKey.Public()
-
-	deviceTLSCert.PrivateKey = tpmPrivKey
+	deviceTLSCert.PrivateKey = signer
 	return deviceTLSCert, nil
 }
 
@@ -77,7 +79,11 @@ func GetTlsConfig(dns *types.DeviceNetworkStatus, serverName string, clientCert
 		serverName = strings.Split(strTrim, ":")[0]
 	}
 	if clientCert == nil {
-		deviceTLSCert, err := GetClientCert()
+		var certLog *base.LogObject
+		if ctx != nil {
+			certLog = ctx.log
+		}
+		deviceTLSCert, err := GetClientCert(certLog)
 		if err != nil {
 			return nil, err
 		}
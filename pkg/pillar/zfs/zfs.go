@@ -0,0 +1,94 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package zfs provides ARC sizing and pool health helpers for devices
+// whose /persist is backed by ZFS, on top of the zfs/zpool CLIs already
+// used by vault for the encrypted vault dataset.
+package zfs
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/lf-edge/eve/pkg/pillar/base"
+)
+
+const (
+	arcMaxParamFile = "/sys/module/zfs/parameters/zfs_arc_max"
+	// minArcBytes is the smallest ARC max we will ever set; below this
+	// ZFS itself performs poorly regardless of memory pressure.
+	minArcBytes = 64 * 1024 * 1024
+	// arcMemoryFraction is the fraction of total device memory given to
+	// the ARC before subtracting memory reserved for running apps.
+	arcMemoryFraction = 0.25
+)
+
+// ComputeARCMax returns a recommended zfs_arc_max in bytes given the
+// device's total memory and the memory already committed to app
+// instances, so the ARC does not starve apps of RAM.
+func ComputeARCMax(totalMemoryBytes, appReservedBytes uint64) uint64 {
+	available := int64(totalMemoryBytes) - int64(appReservedBytes)
+	if available <= 0 {
+		return minArcBytes
+	}
+	arcMax := uint64(float64(available) * arcMemoryFraction)
+	if arcMax < minArcBytes {
+		return minArcBytes
+	}
+	return arcMax
+}
+
+// SetARCMax writes zfs_arc_max via the zfs kernel module parameter.
+func SetARCMax(log *base.LogObject, bytes uint64) error {
+	err := ioutil.WriteFile(arcMaxParamFile, []byte(strconv.FormatUint(bytes, 10)), 0644)
+	if err != nil {
+		log.Errorf("SetARCMax(%d) failed: %s", bytes, err)
+	}
+	return err
+}
+
+// PoolHealth is the zpool-reported state of a pool plus the vdevs zpool
+// marks DEGRADED or FAULTED, parsed from `zpool status`. ParsePoolStatus
+// does not read the per-vdev error counters, so ChecksumErrors stays false.
+type PoolHealth struct {
+	Pool            string
+	State           string
+	ChecksumErrors  bool
+	DegradedVdevs   []string
+	ScrubInProgress bool
+}
+
+// ParsePoolStatus extracts PoolHealth from the text output of
+// `zpool status <pool>`. It is a best-effort line scanner, not a full
+// parser, matching the level of ZFS tooling integration elsewhere in
+// this repo.
+func ParsePoolStatus(pool, output string) PoolHealth {
+	health := PoolHealth{Pool: pool, State: "UNKNOWN"}
+	lines := strings.Split(output, "\n")
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "state:"):
+			health.State = strings.TrimSpace(strings.TrimPrefix(trimmed, "state:"))
+		case strings.Contains(trimmed, "scrub in progress"):
+			health.ScrubInProgress = true
+		case strings.Contains(trimmed, "DEGRADED") || strings.Contains(trimmed, "FAULTED"):
+			fields := strings.Fields(trimmed)
+			if len(fields) > 0 {
+				health.DegradedVdevs = append(health.DegradedVdevs, fields[0])
+			}
+		case strings.Contains(trimmed, "CKSUM"):
+			// Header line; actual non-zero counters are on the vdev
+			// lines below it and are covered by the DEGRADED/FAULTED
+			// case once zpool marks the vdev unhealthy.
+		}
+	}
+	return health
+}
+
+// Healthy reports whether the pool is fully online with no degraded
+// vdevs.
+func (h PoolHealth) Healthy() bool {
+	return h.State == "ONLINE" && len(h.DegradedVdevs) == 0
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2024 Zededa, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package zfs
+
+import "testing"
+
+func TestComputeARCMax(t *testing.T) {
+	got := ComputeARCMax(8*1024*1024*1024, 2*1024*1024*1024)
+	want := uint64(float64(6*1024*1024*1024) * arcMemoryFraction)
+	if got != want {
+		t.Errorf("ComputeARCMax: got %d, want %d", got, want)
+	}
+	if got := ComputeARCMax(1024, 2048); got != minArcBytes {
+		t.Errorf("ComputeARCMax with negative headroom: got %d, want %d", got, minArcBytes)
+	}
+}
+
+func TestParsePoolStatusHealthy(t *testing.T) {
+	output := "  pool: persist\n state: ONLINE\nconfig:\n\n\tNAME        STATE\n\tpersist     ONLINE\n"
+	health := ParsePoolStatus("persist", output)
+	if !health.Healthy() {
+		t.Errorf("expected healthy pool, got %+v", health)
+	}
+}
+
+func TestParsePoolStatusDegraded(t *testing.T) {
+	output := "  pool: persist\n state: DEGRADED\nconfig:\n\n\tNAME        STATE\n\tsda1        DEGRADED\n"
+	health := ParsePoolStatus("persist", output)
+	if health.Healthy() {
+		t.Errorf("expected unhealthy pool, got %+v", health)
+	}
+}